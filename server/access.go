@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Connection access control: per-IP connection limit and ban list, checked
+// before the WebSocket upgrade
+//
+// Ban file format: one IP or CIDR per line, optionally followed by a reason;
+// blank lines and # comments are ignored. The file is rewritten on every
+// ban/unban.
+// ---------------------------------------------------------------------------
+
+var (
+	errBanned      = errors.New("address is banned")
+	errTooManyConn = errors.New("too many connections from this address")
+)
+
+type Ban struct {
+	Prefix string `json:"ip"` // single address or CIDR
+	Reason string `json:"reason,omitempty"`
+}
+
+// AccessControl is used from HTTP handler goroutines and is safe for
+// concurrent use.
+type AccessControl struct {
+	maxPerIP int     // 0 = unlimited
+	banFile  string  // "" = bans are not persisted
+	store    Storage // nil = bans are not stored (see storage.go)
+
+	mu    sync.Mutex
+	conns map[netip.Addr]int
+	bans  map[netip.Prefix]string // prefix → reason
+}
+
+func NewAccessControl(maxPerIP int, banFile string) (*AccessControl, error) {
+	ac := &AccessControl{
+		maxPerIP: maxPerIP,
+		banFile:  banFile,
+		conns:    make(map[netip.Addr]int),
+		bans:     make(map[netip.Prefix]string),
+	}
+	if banFile == "" {
+		return ac, nil
+	}
+	data, err := os.ReadFile(banFile)
+	if os.IsNotExist(err) {
+		return ac, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addr, reason, _ := strings.Cut(line, " ")
+		prefix, err := parsePrefix(addr)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", banFile, n+1, err)
+		}
+		ac.bans[prefix] = strings.TrimSpace(reason)
+	}
+	return ac, nil
+}
+
+// UseStorage keeps the bans in st as well: bans stored there are added to
+// those from the ban file, and every change is written to both.
+func (ac *AccessControl) UseStorage(st Storage) error {
+	stored, err := st.LoadBans()
+	if err != nil {
+		return err
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	for _, b := range stored {
+		prefix, err := parsePrefix(b.Prefix)
+		if err != nil {
+			return fmt.Errorf("stored ban %q: %w", b.Prefix, err)
+		}
+		ac.bans[prefix] = b.Reason
+	}
+	for _, b := range ac.banListLocked() {
+		if err := st.SaveBan(b); err != nil {
+			return err
+		}
+	}
+	ac.store = st
+	return nil
+}
+
+// parsePrefix accepts a single address or a CIDR.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(p.Addr().Unmap(), p.Bits()).Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// clientAddr returns the remote address of r (the TCP peer; forwarding
+// headers are not trusted).
+func clientAddr(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// Admit reserves a connection slot for addr. The caller must call the
+// returned release func when the connection ends.
+func (ac *AccessControl) Admit(addr netip.Addr) (release func(), err error) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if ac.bannedLocked(addr) {
+		return nil, errBanned
+	}
+	if ac.maxPerIP > 0 && ac.conns[addr] >= ac.maxPerIP {
+		return nil, errTooManyConn
+	}
+	ac.conns[addr]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ac.mu.Lock()
+			defer ac.mu.Unlock()
+			if ac.conns[addr]--; ac.conns[addr] <= 0 {
+				delete(ac.conns, addr)
+			}
+		})
+	}, nil
+}
+
+func (ac *AccessControl) bannedLocked(addr netip.Addr) bool {
+	for p := range ac.bans {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ac *AccessControl) Bans() []Ban {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return ac.banListLocked()
+}
+
+func (ac *AccessControl) banListLocked() []Ban {
+	list := make([]Ban, 0, len(ac.bans))
+	for p, reason := range ac.bans {
+		list = append(list, Ban{Prefix: banString(p), Reason: reason})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Prefix < list[j].Prefix })
+	return list
+}
+
+// banString prints single-address prefixes without the /32 or /128.
+func banString(p netip.Prefix) string {
+	if p.IsSingleIP() {
+		return p.Addr().String()
+	}
+	return p.String()
+}
+
+// Ban adds (or updates) a ban and persists the list. Nothing changes if
+// the list can't be saved.
+func (ac *AccessControl) Ban(prefix netip.Prefix, reason string) error {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	prev, had := ac.bans[prefix]
+	ac.bans[prefix] = reason
+	err := ac.saveLocked()
+	if err == nil && ac.store != nil {
+		err = ac.store.SaveBan(Ban{Prefix: banString(prefix), Reason: reason})
+	}
+	if err != nil {
+		if had {
+			ac.bans[prefix] = prev
+		} else {
+			delete(ac.bans, prefix)
+		}
+		return err
+	}
+	return nil
+}
+
+// Unban removes a ban. Returns false if there was none.
+func (ac *AccessControl) Unban(prefix netip.Prefix) (bool, error) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	reason, ok := ac.bans[prefix]
+	if !ok {
+		return false, nil
+	}
+	delete(ac.bans, prefix)
+	err := ac.saveLocked()
+	if err == nil && ac.store != nil {
+		err = ac.store.DeleteBan(banString(prefix))
+	}
+	if err != nil {
+		ac.bans[prefix] = reason
+		return false, err
+	}
+	return true, nil
+}
+
+// saveLocked atomically rewrites the ban file (temp file + rename).
+func (ac *AccessControl) saveLocked() error {
+	if ac.banFile == "" {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# snake server ban list, written %s\n", time.Now().UTC().Format(time.RFC3339))
+	for _, ban := range ac.banListLocked() {
+		b.WriteString(ban.Prefix)
+		if ban.Reason != "" {
+			b.WriteString(" " + ban.Reason)
+		}
+		b.WriteByte('\n')
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(ac.banFile), filepath.Base(ac.banFile)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), ac.banFile)
+}
+
+// HandleBans serves /admin/bans (admin token required):
+//
+//	GET                                list bans
+//	POST   {"ip": "...", "reason": ...} ban an address or CIDR, kicking
+//	                                   matching players
+//	DELETE ?ip=...                     lift a ban
+func HandleBans(game *Game, ac *AccessControl, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(ac.Bans())
+
+	case http.MethodPost:
+		var req Ban
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		prefix, err := parsePrefix(req.Prefix)
+		if err != nil {
+			http.Error(w, "invalid ip: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		reason := strings.Join(strings.Fields(req.Reason), " ") // one line in the ban file
+		if err := ac.Ban(prefix, reason); err != nil {
+			slog.Error("failed to save ban list", "path", ac.banFile, "err", err)
+			http.Error(w, "failed to save ban list", http.StatusInternalServerError)
+			return
+		}
+		kicked := 0
+		for _, p := range game.GetPlayers() {
+			if addr, err := netip.ParseAddr(p.IP); err == nil && prefix.Contains(addr) {
+				if game.KickPlayer(p.ID, "banned") {
+					kicked++
+				}
+			}
+		}
+		slog.Warn("address banned", "ip", banString(prefix), "reason", reason, "kicked", kicked)
+		json.NewEncoder(w).Encode(map[string]any{"ip": banString(prefix), "kicked": kicked})
+
+	case http.MethodDelete:
+		prefix, err := parsePrefix(r.URL.Query().Get("ip"))
+		if err != nil {
+			http.Error(w, "invalid ip: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		ok, err := ac.Unban(prefix)
+		if err != nil {
+			slog.Error("failed to save ban list", "path", ac.banFile, "err", err)
+			http.Error(w, "failed to save ban list", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "not banned", http.StatusNotFound)
+			return
+		}
+		slog.Info("address unbanned", "ip", banString(prefix))
+		json.NewEncoder(w).Encode(map[string]any{"ip": banString(prefix)})
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Optional accounts (OAuth login)
+//
+// Providers configured under "oauth" in the config file get a login flow at
+// /auth/<provider>/login. The callback exchanges the authorization code,
+// looks up the provider's user ID and display name, and signs the player in
+// by redirecting to /#identity=<token>: an identity token (see identity.go)
+// for the identity "<provider>:<user id>". Account profiles work like guest
+// profiles but also keep a display name and never expire. Guests are not
+// affected.
+// ---------------------------------------------------------------------------
+
+const (
+	authStateCookie = "snake_auth_state"
+	authStateMaxAge = 10 * time.Minute
+	authTimeout     = 10 * time.Second
+)
+
+// OAuthClient is the app registration with one provider.
+type OAuthClient struct {
+	ClientID       string `json:"clientId"`
+	ClientSecret   string `json:"clientSecret"`   // Google, Discord
+	TeamID         string `json:"teamId"`         // Apple
+	KeyID          string `json:"keyId"`          // Apple
+	PrivateKeyFile string `json:"privateKeyFile"` // Apple: .p8 key that signs the client secret
+}
+
+type oauthProvider struct {
+	name     string
+	authURL  string
+	tokenURL string
+	userURL  string // "" = the user is read from the ID token (Apple)
+	scope    string
+	formPost bool // the callback is a POST (Apple, when asking for the name)
+
+	client   OAuthClient
+	appleKey *ecdsa.PrivateKey
+}
+
+var oauthEndpoints = map[string]oauthProvider{
+	"google": {
+		authURL:  "https://accounts.google.com/o/oauth2/v2/auth",
+		tokenURL: "https://oauth2.googleapis.com/token",
+		userURL:  "https://openidconnect.googleapis.com/v1/userinfo",
+		scope:    "openid profile",
+	},
+	"discord": {
+		authURL:  "https://discord.com/oauth2/authorize",
+		tokenURL: "https://discord.com/api/oauth2/token",
+		userURL:  "https://discord.com/api/users/@me",
+		scope:    "identify",
+	},
+	"apple": {
+		authURL:  "https://appleid.apple.com/auth/authorize",
+		tokenURL: "https://appleid.apple.com/auth/token",
+		scope:    "name",
+		formPost: true,
+	},
+}
+
+// Accounts serves the /auth/ endpoints.
+type Accounts struct {
+	game      *Game
+	publicURL string // "" = derived from each request
+	providers map[string]*oauthProvider
+	client    *http.Client
+}
+
+// NewAccounts sets up the providers configured in cfg.OAuth.
+func NewAccounts(game *Game, cfg GameConfig) (*Accounts, error) {
+	a := &Accounts{
+		game:      game,
+		publicURL: strings.TrimSuffix(cfg.PublicURL, "/"),
+		providers: make(map[string]*oauthProvider),
+		client:    &http.Client{Timeout: authTimeout},
+	}
+	for name, c := range cfg.OAuth {
+		ep, ok := oauthEndpoints[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown OAuth provider %q", name)
+		}
+		p := ep
+		p.name, p.client = name, c
+		if c.ClientID == "" {
+			return nil, fmt.Errorf("%s: clientId is required", name)
+		}
+		if name == "apple" {
+			key, err := loadAppleKey(c.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("apple: %w", err)
+			}
+			if c.TeamID == "" || c.KeyID == "" {
+				return nil, errors.New("apple: teamId and keyId are required")
+			}
+			p.appleKey = key
+		} else if c.ClientSecret == "" {
+			return nil, fmt.Errorf("%s: clientSecret is required", name)
+		}
+		a.providers[name] = &p
+	}
+	return a, nil
+}
+
+// Names returns the enabled providers, sorted.
+func (a *Accounts) Names() []string {
+	names := make([]string, 0, len(a.providers))
+	for name := range a.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ServeHTTP handles /auth/providers, /auth/<provider>/login and
+// /auth/<provider>/callback.
+func (a *Accounts) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/auth/")
+	if rest == "providers" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string][]string{"providers": a.Names()})
+		return
+	}
+	name, action, _ := strings.Cut(rest, "/")
+	p, ok := a.providers[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch action {
+	case "login":
+		a.login(w, r, p)
+	case "callback":
+		a.callback(w, r, p)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *Accounts) baseURL(r *http.Request) string {
+	if a.publicURL != "" {
+		return a.publicURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// login redirects to the provider. The state parameter is also kept in a
+// short-lived cookie and checked on the way back.
+func (a *Accounts) login(w http.ResponseWriter, r *http.Request, p *oauthProvider) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	state := hex.EncodeToString(b)
+	base := a.baseURL(r)
+	cookie := &http.Cookie{
+		Name: authStateCookie, Value: state, Path: "/auth/",
+		MaxAge: int(authStateMaxAge.Seconds()), HttpOnly: true, SameSite: http.SameSiteLaxMode,
+	}
+	if strings.HasPrefix(base, "https://") {
+		// Apple posts the callback cross-site, which Lax cookies don't survive.
+		cookie.Secure, cookie.SameSite = true, http.SameSiteNoneMode
+	}
+	http.SetCookie(w, cookie)
+
+	q := url.Values{
+		"client_id":     {p.client.ClientID},
+		"redirect_uri":  {base + "/auth/" + p.name + "/callback"},
+		"response_type": {"code"},
+		"scope":         {p.scope},
+		"state":         {state},
+	}
+	if p.formPost {
+		q.Set("response_mode", "form_post")
+	}
+	http.Redirect(w, r, p.authURL+"?"+q.Encode(), http.StatusFound)
+}
+
+func (a *Accounts) callback(w http.ResponseWriter, r *http.Request, p *oauthProvider) {
+	http.SetCookie(w, &http.Cookie{Name: authStateCookie, Path: "/auth/", MaxAge: -1})
+	fail := func(reason string, err error) {
+		slog.Warn("OAuth login failed", "provider", p.name, "reason", reason, "err", err)
+		http.Redirect(w, r, a.baseURL(r)+"/#authError="+url.QueryEscape(reason), http.StatusFound)
+	}
+
+	c, err := r.Cookie(authStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.FormValue("state"))) != 1 {
+		fail("state", err)
+		return
+	}
+	if e := r.FormValue("error"); e != "" {
+		fail("denied", errors.New(e))
+		return
+	}
+	redirect := a.baseURL(r) + "/auth/" + p.name + "/callback"
+	sub, name, err := a.fetchUser(p, r.FormValue("code"), redirect)
+	if err != nil {
+		fail("provider", err)
+		return
+	}
+	if p.name == "apple" {
+		name = appleName(r.FormValue("user"))
+	}
+	if name, err = a.game.names.Resolve(name, ""); err != nil {
+		slog.Info("account name rejected", "provider", p.name, "err", err)
+	}
+
+	id := p.name + ":" + sub
+	a.game.LoginAccount(id, p.name, name)
+	token := a.game.identities.Issue(id, time.Now())
+	slog.Info("account login", "provider", p.name, "identity", id)
+	http.Redirect(w, r, a.baseURL(r)+"/#identity="+token, http.StatusFound)
+}
+
+// fetchUser exchanges code for tokens and returns the provider's user ID
+// and display name.
+func (a *Accounts) fetchUser(p *oauthProvider, code, redirect string) (sub, name string, err error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirect},
+		"client_id":    {p.client.ClientID},
+	}
+	secret := p.client.ClientSecret
+	if p.appleKey != nil {
+		if secret, err = p.appleSecret(time.Now()); err != nil {
+			return "", "", err
+		}
+	}
+	form.Set("client_secret", secret)
+	resp, err := a.client.PostForm(p.tokenURL, form)
+	if err != nil {
+		return "", "", err
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if err := readJSON(resp, &tok); err != nil {
+		return "", "", fmt.Errorf("token exchange: %w", err)
+	}
+
+	var user map[string]any
+	if p.userURL == "" {
+		// The ID token came straight from the provider over TLS, so its
+		// claims can be used without checking the signature.
+		if user, err = jwtClaims(tok.IDToken); err != nil {
+			return "", "", err
+		}
+	} else {
+		req, _ := http.NewRequest(http.MethodGet, p.userURL, nil)
+		req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		resp, err := a.client.Do(req)
+		if err != nil {
+			return "", "", err
+		}
+		if err := readJSON(resp, &user); err != nil {
+			return "", "", fmt.Errorf("user info: %w", err)
+		}
+	}
+
+	str := func(keys ...string) string {
+		for _, k := range keys {
+			if v, _ := user[k].(string); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	sub = str("sub", "id")
+	if sub == "" {
+		return "", "", errors.New("no user ID")
+	}
+	return sub, str("given_name", "name", "global_name", "username"), nil
+}
+
+func readJSON(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+func jwtClaims(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]any
+	return claims, json.Unmarshal(payload, &claims)
+}
+
+// appleName reads the first name Apple posts with the first login (only).
+func appleName(user string) string {
+	var u struct {
+		Name struct {
+			FirstName string `json:"firstName"`
+		} `json:"name"`
+	}
+	json.Unmarshal([]byte(user), &u)
+	return u.Name.FirstName
+}
+
+func loadAppleKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("privateKeyFile is not PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ec, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("privateKeyFile is not an EC key")
+	}
+	return ec, nil
+}
+
+// appleSecret signs the short-lived ES256 client secret Apple expects in
+// place of a static one.
+func (p *oauthProvider) appleSecret(now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": p.client.KeyID})
+	claims, _ := json.Marshal(map[string]any{
+		"iss": p.client.TeamID,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+		"aud": "https://appleid.apple.com",
+		"sub": p.client.ClientID,
+	})
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, p.appleKey, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// Game loop side
+
+type accountLogin struct {
+	id, provider, name string
+	reply              chan struct{}
+}
+
+// LoginAccount creates or refreshes the profile of an account identity
+// (thread-safe).
+func (g *Game) LoginAccount(id, provider, name string) {
+	reply := make(chan struct{})
+	ask(g, g.accountCh, accountLogin{id: id, provider: provider, name: name, reply: reply}, reply)
+}
+
+func (g *Game) handleAccountLogin(l accountLogin) {
+	prof := g.profiles[l.id]
+	if prof == nil {
+		prof = &Profile{Color: g.rng.Intn(NumColors)}
+		g.profiles[l.id] = prof
+	}
+	if prof.Name == "" && l.name != defaultName {
+		prof.Name = l.name
+	}
+	prof.Provider = l.provider
+	prof.LastSeen = time.Now()
+	g.persistProfile(l.id, prof)
+	close(l.reply)
+}
+
+// accountName gives a signed-in player who joined without a name the one
+// saved in their account, or saves the name they chose. Called from
+// handleJoin before the name is made unique.
+func (g *Game) accountName(p *Player) {
+	prof := g.profiles[p.identity]
+	if prof == nil || prof.Provider == "" {
+		return
+	}
+	if p.name == defaultName && prof.Name != "" {
+		p.name = prof.Name
+	} else {
+		prof.Name = p.name
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Achievements
+//
+// Achievements are unlocked from game events (kills, bounty claims) and a
+// once-per-second check of every player's snake (length, time alive, food
+// eaten). They are kept per identity in the profile, so they persist like
+// the rest of it, and each unlock is announced to the player with an
+// "achievement" event. GET /stats/achievements lists them with unlock
+// counts, or the ones an identity has with ?identity=.
+// ---------------------------------------------------------------------------
+
+type Achievement struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+const (
+	achFirstKill  = "first_kill"
+	achLength500  = "length_500"
+	achSurvive10  = "survive_10m"
+	achGoldenKill = "golden_kill"
+	achFood1000   = "food_1000"
+)
+
+var achievements = []Achievement{
+	{achFirstKill, "First Blood", "Kill another snake"},
+	{achLength500, "Titan", "Reach length 500"},
+	{achSurvive10, "Survivor", "Stay alive for 10 minutes"},
+	{achGoldenKill, "Bounty Hunter", "Kill the golden snake"},
+	{achFood1000, "Glutton", "Eat 1000 food"},
+}
+
+type achievementEvent struct {
+	Type string `json:"t"` // "achievement"
+	Achievement
+}
+
+// unlock awards achievement id to the player controlling s, if any and if
+// they don't have it yet.
+func (g *Game) unlock(s *Snake, id string) {
+	if s == nil || s.IsAI {
+		return
+	}
+	p, ok := g.players[s.PlayerID]
+	if !ok || p.snake != s {
+		return
+	}
+	prof := g.profiles[p.identity]
+	if prof == nil {
+		return
+	}
+	if _, done := prof.Achievements[id]; done {
+		return
+	}
+	if prof.Achievements == nil {
+		prof.Achievements = make(map[string]time.Time)
+	}
+	prof.Achievements[id] = time.Now()
+	g.persistProfile(p.identity, prof)
+	for _, a := range achievements {
+		if a.ID == id {
+			g.log.Info("achievement unlocked", "playerID", p.id, "name", p.name, "achievement", id)
+			g.sendEvent(p, achievementEvent{Type: "achievement", Achievement: a})
+		}
+	}
+}
+
+// checkAchievements unlocks the progress achievements. Called once per
+// second.
+func (g *Game) checkAchievements() {
+	for _, p := range g.players {
+		s := p.snake
+		if s == nil || !s.Alive {
+			continue
+		}
+		prof := g.profiles[p.identity]
+		if prof == nil {
+			continue
+		}
+		if len(s.Segments) >= 500 {
+			g.unlock(s, achLength500)
+		}
+		if g.frame-s.spawnFrame >= 10*60*TickRate {
+			g.unlock(s, achSurvive10)
+		}
+		if prof.Food+s.foodEaten >= 1000 {
+			g.unlock(s, achFood1000)
+		}
+	}
+}
+
+// Stats view (GET /stats/achievements)
+
+type AchievementStats struct {
+	Achievement
+	Unlocked int `json:"unlocked"` // identities that have it
+}
+
+type achievementsReq struct {
+	identity string // "" = all achievements with unlock counts
+	reply    chan any
+}
+
+func (g *Game) buildAchievements(identity string) any {
+	if identity != "" {
+		prof := g.profiles[identity]
+		if prof == nil {
+			return nil
+		}
+		unlocked := make(map[string]time.Time, len(prof.Achievements))
+		for id, at := range prof.Achievements {
+			unlocked[id] = at
+		}
+		return map[string]any{"identity": identity, "unlocked": unlocked}
+	}
+	stats := make([]AchievementStats, len(achievements))
+	for i, a := range achievements {
+		stats[i].Achievement = a
+	}
+	for _, prof := range g.profiles {
+		for i, a := range achievements {
+			if _, ok := prof.Achievements[a.ID]; ok {
+				stats[i].Unlocked++
+			}
+		}
+	}
+	return map[string]any{"achievements": stats}
+}
+
+// GetAchievements returns the achievement stats, or the achievements of one
+// identity (nil if unknown). Thread-safe.
+func (g *Game) GetAchievements(identity string) any {
+	reply := make(chan any, 1)
+	v, _ := ask(g, g.achievementsReqCh, achievementsReq{identity: identity, reply: reply}, reply)
+	return v
+}
+
+func HandleAchievements(game *Game, w http.ResponseWriter, r *http.Request) {
+	res := game.GetAchievements(r.URL.Query().Get("identity"))
+	if res == nil {
+		http.Error(w, "unknown identity", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Scripted AI personalities (embedded Lua)
+//
+// Every *.lua file in the script directory is a personality named after the
+// file. It must define a global function
+//
+//	function think(view) return angle, boost end
+//
+// which is called ScriptThinkRate times a second per bot with a fresh
+// snapshot of its surroundings. AI snakes are spread over the personalities and
+// the built-in behavior by ID. The directory is polled for changes and
+// modified scripts are swapped in without a restart; a script that fails to
+// load, errors or runs over its time budget is logged and its bots fall back
+// to the built-in AI until the file changes.
+//
+// Scripts run in a sandbox: only the base, table, string and math libraries,
+// without file access or code loading. print() writes to the debug log.
+// Besides the time budget, a script's call depth and value stack are
+// bounded, and string.rep refuses results over scriptMaxRep bytes, so a
+// script can't take the server's memory in a single call either.
+// ---------------------------------------------------------------------------
+
+const (
+	ScriptThinkRate    = 10    // think() calls per second per bot
+	ScriptViewRange    = 600.0 // radius of the world view passed to think()
+	ScriptMaxFood      = 32    // nearest food items in the view
+	ScriptMaxBodyPts   = 24    // body points per snake in the view
+	scriptCallBudget   = time.Millisecond
+	scriptLoadBudget   = 50 * time.Millisecond
+	scriptPollInterval = 2 * time.Second
+	scriptCallStack    = 64        // Lua call frames
+	scriptRegistry     = 1024      // initial value stack slots
+	scriptRegistryMax  = 64 * 1024 // value stack slots
+	scriptMaxRep       = 64 * 1024 // bytes from one string.rep
+)
+
+type aiScript struct {
+	name   string
+	proto  *lua.FunctionProto
+	L      *lua.LState
+	think  *lua.LFunction
+	broken bool // errored since it was loaded
+}
+
+// aiScripts is owned by the game loop.
+type aiScripts struct {
+	list []*aiScript // sorted by name
+}
+
+// EnableAIScripts loads the personalities in dir and starts watching it for
+// changes. Must be called before Run.
+func (g *Game) EnableAIScripts(dir string) error {
+	w := &scriptWatcher{dir: dir, files: make(map[string]scriptFile)}
+	protos, _, err := w.scan()
+	if err != nil {
+		return err
+	}
+	g.scripts = &aiScripts{}
+	g.scripts.install(protos)
+	go w.run(g.scriptCh)
+	return nil
+}
+
+// install replaces the loaded personalities with protos, keeping the Lua
+// state of scripts that didn't change.
+func (sc *aiScripts) install(protos map[string]*lua.FunctionProto) {
+	old := make(map[string]*aiScript, len(sc.list))
+	for _, s := range sc.list {
+		old[s.name] = s
+	}
+	var list []*aiScript
+	for name, proto := range protos {
+		if s := old[name]; s != nil && s.proto == proto {
+			list = append(list, s)
+			delete(old, name)
+			continue
+		}
+		s, err := newAIScript(name, proto)
+		if err != nil {
+			slog.Error("failed to load AI script", "script", name, "err", err)
+			continue
+		}
+		slog.Info("loaded AI script", "script", name)
+		list = append(list, s)
+	}
+	for _, s := range old {
+		s.L.Close()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	sc.list = list
+}
+
+func newAIScript(name string, proto *lua.FunctionProto) (*aiScript, error) {
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   scriptCallStack,
+		RegistrySize:    scriptRegistry,
+		RegistryMaxSize: scriptRegistryMax,
+	})
+	for _, lib := range []struct {
+		name string
+		fn   lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.fn))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, fn := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "collectgarbage"} {
+		L.SetGlobal(fn, lua.LNil)
+	}
+	L.GetGlobal("string").(*lua.LTable).RawSetString("rep", L.NewFunction(scriptRep))
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		parts := make([]string, L.GetTop())
+		for i := range parts {
+			parts[i] = L.ToStringMeta(L.Get(i + 1)).String()
+		}
+		slog.Debug("AI script", "script", name, "msg", strings.Join(parts, " "))
+		return 0
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), scriptLoadBudget)
+	defer cancel()
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+	L.Push(L.NewFunctionFromProto(proto))
+	if err := L.PCall(0, 0, nil); err != nil {
+		L.Close()
+		return nil, err
+	}
+	think, ok := L.GetGlobal("think").(*lua.LFunction)
+	if !ok {
+		L.Close()
+		return nil, fmt.Errorf("no think(view) function")
+	}
+	return &aiScript{name: name, proto: proto, L: L, think: think}, nil
+}
+
+// scriptRep is string.rep for scripts, with the result capped at
+// scriptMaxRep bytes.
+func scriptRep(L *lua.LState) int {
+	str, n := L.CheckString(1), L.CheckInt(2)
+	if n <= 0 || str == "" {
+		L.Push(lua.LString(""))
+		return 1
+	}
+	if n > scriptMaxRep/len(str) {
+		L.RaiseError("string.rep: result longer than %d bytes", scriptMaxRep)
+	}
+	L.Push(lua.LString(strings.Repeat(str, n)))
+	return 1
+}
+
+// forSnake returns the personality driving s, nil for the built-in AI.
+func (sc *aiScripts) forSnake(s *Snake) *aiScript {
+	if sc == nil || len(sc.list) == 0 {
+		return nil
+	}
+	idx := -s.PlayerID % (len(sc.list) + 1) // the last slot is the built-in AI
+	if idx >= len(sc.list) || sc.list[idx].broken {
+		return nil
+	}
+	return sc.list[idx]
+}
+
+// scriptedAI steers s with its personality. Returns false if s uses the
+// built-in AI.
+func (g *Game) scriptedAI(s *Snake) bool {
+	sc := g.scripts.forSnake(s)
+	if sc == nil {
+		return false
+	}
+	if (g.frame-s.PlayerID)%(TickRate/ScriptThinkRate) == 0 {
+		angle, boost, err := sc.call(g.scriptView(sc.L, s))
+		if err != nil {
+			sc.broken = true
+			g.log.Error("AI script failed, falling back to built-in AI", "script", sc.name, "err", err)
+			return false
+		}
+		s.AITargetAngle, s.scriptBoost = angle, boost
+	}
+	s.TargetAngle = s.AITargetAngle
+	s.IsBoosting = s.scriptBoost
+	return true
+}
+
+func (sc *aiScript) call(view *lua.LTable) (angle float64, boost bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), scriptCallBudget)
+	defer cancel()
+	L := sc.L
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+	if err := L.CallByParam(lua.P{Fn: sc.think, NRet: 2, Protect: true}, view); err != nil {
+		return 0, false, err
+	}
+	a, b := L.Get(-2), L.Get(-1)
+	L.Pop(2)
+	n, ok := a.(lua.LNumber)
+	if !ok || math.IsNaN(float64(n)) || math.IsInf(float64(n), 0) {
+		return 0, false, fmt.Errorf("think returned %s, want an angle", a.Type())
+	}
+	return float64(n), lua.LVAsBool(b), nil
+}
+
+// scriptView builds the think() argument:
+//
+//	view.frame, view.world               frame number, world size
+//	view.arena, view.edge                arena shape, distance of the own head
+//	                                     to the arena edge
+//	view.self                            {id, x, y, angle, length, score, boost, boosting}
+//	view.food[i]                         {x, y, value, dist}, nearest first
+//	view.snakes[i]                       {id, name, x, y, angle, length, score, boosting,
+//	                                      ai, golden, dist, body = {{x, y}, ...}}
+//
+// Angles are radians, 0 pointing along +x.
+func (g *Game) scriptView(L *lua.LState, s *Snake) *lua.LTable {
+	head := s.Segments[0]
+	view := L.NewTable()
+	view.RawSetString("frame", lua.LNumber(g.frame))
+	view.RawSetString("world", lua.LNumber(g.cfg.WorldSize))
+	view.RawSetString("arena", lua.LString(g.arena.Name()))
+	view.RawSetString("edge", lua.LNumber(g.arena.EdgeDist(head)))
+	self := scriptSnake(L, s, head)
+	self.RawSetString("boost", lua.LNumber(s.Boost))
+	view.RawSetString("self", self)
+
+	type near struct {
+		f *Food
+		d float64
+	}
+	var foods []near
+	for _, f := range g.foods {
+		if d := sim.Dist(head.X, head.Y, f.X, f.Y); d < ScriptViewRange {
+			foods = append(foods, near{f, d})
+		}
+	}
+	sort.Slice(foods, func(i, j int) bool { return foods[i].d < foods[j].d })
+	if len(foods) > ScriptMaxFood {
+		foods = foods[:ScriptMaxFood]
+	}
+	ft := L.CreateTable(len(foods), 0)
+	for _, n := range foods {
+		t := L.CreateTable(0, 4)
+		t.RawSetString("x", lua.LNumber(n.f.X))
+		t.RawSetString("y", lua.LNumber(n.f.Y))
+		t.RawSetString("value", lua.LNumber(n.f.Value))
+		t.RawSetString("dist", lua.LNumber(n.d))
+		ft.Append(t)
+	}
+	view.RawSetString("food", ft)
+
+	st := L.NewTable()
+	for _, o := range g.snakes {
+		if o == s || !o.Alive || len(o.Segments) == 0 {
+			continue
+		}
+		// Include snakes whose body is in range, not only their head
+		d := math.Inf(1)
+		for k := 0; k < len(o.Segments); k += 4 {
+			d = math.Min(d, sim.Dist(head.X, head.Y, o.Segments[k].X, o.Segments[k].Y))
+		}
+		if d >= ScriptViewRange {
+			continue
+		}
+		t := scriptSnake(L, o, head)
+		t.RawSetString("name", lua.LString(o.Name))
+		t.RawSetString("ai", lua.LBool(o.IsAI))
+		t.RawSetString("golden", lua.LBool(o.golden))
+		step := (len(o.Segments) + ScriptMaxBodyPts - 1) / ScriptMaxBodyPts
+		body := L.CreateTable(len(o.Segments)/step+1, 0)
+		for k := 0; k < len(o.Segments); k += step {
+			p := L.CreateTable(0, 2)
+			p.RawSetString("x", lua.LNumber(o.Segments[k].X))
+			p.RawSetString("y", lua.LNumber(o.Segments[k].Y))
+			body.Append(p)
+		}
+		t.RawSetString("body", body)
+		st.Append(t)
+	}
+	view.RawSetString("snakes", st)
+	return view
+}
+
+func scriptSnake(L *lua.LState, s *Snake, from Vec2) *lua.LTable {
+	h := s.Segments[0]
+	t := L.CreateTable(0, 12)
+	t.RawSetString("id", lua.LNumber(s.PlayerID))
+	t.RawSetString("x", lua.LNumber(h.X))
+	t.RawSetString("y", lua.LNumber(h.Y))
+	t.RawSetString("angle", lua.LNumber(s.Angle))
+	t.RawSetString("length", lua.LNumber(len(s.Segments)))
+	t.RawSetString("score", lua.LNumber(s.Score))
+	t.RawSetString("boosting", lua.LBool(s.IsBoosting))
+	t.RawSetString("dist", lua.LNumber(sim.Dist(from.X, from.Y, h.X, h.Y)))
+	return t
+}
+
+// ---------------------------------------------------------------------------
+// Script directory watcher (own goroutine)
+// ---------------------------------------------------------------------------
+
+type scriptFile struct {
+	mod   time.Time
+	size  int64
+	proto *lua.FunctionProto // nil if the file doesn't compile
+}
+
+type scriptWatcher struct {
+	dir   string
+	files map[string]scriptFile // by personality name
+}
+
+// scan compiles new and modified scripts and returns the current set.
+// changed is false if nothing in the directory changed since the last scan.
+func (w *scriptWatcher) scan() (protos map[string]*lua.FunctionProto, changed bool, err error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, false, err
+	}
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".lua" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), ".lua")
+		seen[name] = true
+		if f, ok := w.files[name]; ok && f.mod.Equal(info.ModTime()) && f.size == info.Size() {
+			continue
+		}
+		changed = true
+		path := filepath.Join(w.dir, e.Name())
+		proto, err := compileScript(path)
+		if err != nil {
+			slog.Error("failed to compile AI script", "path", path, "err", err)
+		}
+		w.files[name] = scriptFile{mod: info.ModTime(), size: info.Size(), proto: proto}
+	}
+	for name := range w.files {
+		if !seen[name] {
+			delete(w.files, name)
+			changed = true
+		}
+	}
+
+	protos = make(map[string]*lua.FunctionProto, len(w.files))
+	for name, f := range w.files {
+		if f.proto != nil {
+			protos[name] = f.proto
+		}
+	}
+	return protos, changed, nil
+}
+
+func compileScript(path string) (*lua.FunctionProto, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	chunk, err := parse.Parse(src, path)
+	if err != nil {
+		return nil, err
+	}
+	return lua.Compile(chunk, path)
+}
+
+func (w *scriptWatcher) run(ch chan<- map[string]*lua.FunctionProto) {
+	ticker := time.NewTicker(scriptPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		protos, changed, err := w.scan()
+		if err != nil {
+			slog.Error("failed to scan AI script directory", "dir", w.dir, "err", err)
+			continue
+		}
+		if changed {
+			ch <- protos
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Announcements
+//
+// The server sends its own messages to players as "announcement" events
+// instead of the client having them built in: a message of the day after
+// joining, scheduled messages every so many seconds, and the start and end
+// of tournament rounds. Every message is a set of templates, one per
+// language, and each player gets the one for the locale hint it sent with
+// its join message ("locale": "de-AT"). The exact locale is tried first,
+// then its language ("de"), then the config's fallback locale, which every
+// message must have.
+//
+// Templates may use {name} (the receiving player), {players} (players
+// online), {mode} (the rotation mode), {round} and, at the end of a round,
+// {winner}. Anything else in braces is left as it is.
+// ---------------------------------------------------------------------------
+
+const (
+	DefaultAnnounceLocale = "en"
+	MaxAnnouncementLen    = 500 // bytes per template
+	maxLocaleLen          = 35  // longest locale hint kept from a join message
+)
+
+// AnnouncementConfig holds the server's announcements. Messages left out
+// aren't sent.
+type AnnouncementConfig struct {
+	Locale     string                  `json:"locale"`     // fallback locale, "" = "en"
+	MOTD       Localized               `json:"motd"`       // after joining
+	RoundStart Localized               `json:"roundStart"` // when a tournament round starts
+	RoundEnd   Localized               `json:"roundEnd"`   // when it ends
+	Scheduled  []ScheduledAnnouncement `json:"scheduled"`
+}
+
+type ScheduledAnnouncement struct {
+	Every int       `json:"every"` // seconds
+	Text  Localized `json:"text"`
+}
+
+// Localized maps locales ("en", "de", "pt-br") to message templates.
+type Localized map[string]string
+
+// UnmarshalJSON replaces the map instead of adding to it, so decoding a
+// config change into a copy of the live config leaves the live one alone.
+func (l *Localized) UnmarshalJSON(data []byte) error {
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*l = m
+	return nil
+}
+
+type announcementEvent struct {
+	Type   string `json:"t"`    // "announcement"
+	Kind   string `json:"kind"` // "motd", "scheduled", "roundStart" or "roundEnd"
+	Locale string `json:"locale"`
+	Text   string `json:"text"`
+}
+
+func (c *AnnouncementConfig) fallback() string {
+	if c.Locale == "" {
+		return DefaultAnnounceLocale
+	}
+	return normalizeLocale(c.Locale)
+}
+
+// validate checks that every message has a template for the fallback
+// locale and that the schedule is sound.
+func (c *AnnouncementConfig) validate() error {
+	check := func(what string, l Localized) error {
+		if len(l) == 0 {
+			return nil
+		}
+		if _, ok := l[c.fallback()]; !ok {
+			return fmt.Errorf("%s has no %q template", what, c.fallback())
+		}
+		for loc, text := range l {
+			if loc != normalizeLocale(loc) {
+				return fmt.Errorf("%s: locale %q should be written %q", what, loc, normalizeLocale(loc))
+			}
+			if len(text) > MaxAnnouncementLen {
+				return fmt.Errorf("%s: %q template is longer than %d bytes", what, loc, MaxAnnouncementLen)
+			}
+		}
+		return nil
+	}
+	if err := check("motd", c.MOTD); err != nil {
+		return err
+	}
+	if err := check("roundStart", c.RoundStart); err != nil {
+		return err
+	}
+	if err := check("roundEnd", c.RoundEnd); err != nil {
+		return err
+	}
+	for i, s := range c.Scheduled {
+		if s.Every < 1 {
+			return fmt.Errorf("scheduled %d: every must be at least 1 second", i+1)
+		}
+		if len(s.Text) == 0 {
+			return fmt.Errorf("scheduled %d has no text", i+1)
+		}
+		if err := check(fmt.Sprintf("scheduled %d", i+1), s.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeLocale turns a locale hint like "de_AT" or "DE-at" into "de-at".
+func normalizeLocale(s string) string {
+	if len(s) > maxLocaleLen {
+		s = s[:maxLocaleLen]
+	}
+	return strings.ToLower(strings.ReplaceAll(s, "_", "-"))
+}
+
+// pick returns the template for locale and the locale it was written for.
+func (c *AnnouncementConfig) pick(l Localized, locale string) (string, string, bool) {
+	if text, ok := l[locale]; ok {
+		return text, locale, true
+	}
+	if lang, _, found := strings.Cut(locale, "-"); found {
+		if text, ok := l[lang]; ok {
+			return text, lang, true
+		}
+	}
+	fb := c.fallback()
+	text, ok := l[fb]
+	return text, fb, ok
+}
+
+// sendAnnouncement sends the message l of the given kind to p, with vars
+// filled in along with {name}, {players} and {mode}.
+func (g *Game) sendAnnouncement(p *Player, kind string, l Localized, vars ...string) {
+	text, locale, ok := g.cfg.Announcements.pick(l, p.locale)
+	if !ok {
+		return
+	}
+	vars = append(vars, "{name}", p.name, "{players}", strconv.Itoa(len(g.players)), "{mode}", g.modeName())
+	g.sendEvent(p, announcementEvent{
+		Type: "announcement", Kind: kind, Locale: locale,
+		Text: strings.NewReplacer(vars...).Replace(text),
+	})
+}
+
+// announceAll sends an announcement to every player and director.
+func (g *Game) announceAll(kind string, l Localized, vars ...string) {
+	if len(l) == 0 {
+		return
+	}
+	for _, p := range g.players {
+		g.sendAnnouncement(p, kind, l, vars...)
+	}
+	for _, p := range g.dir.viewers {
+		g.sendAnnouncement(p, kind, l, vars...)
+	}
+}
+
+// sendMOTD sends the message of the day to a player that just joined.
+func (g *Game) sendMOTD(p *Player) {
+	if len(g.cfg.Announcements.MOTD) > 0 {
+		g.sendAnnouncement(p, "motd", g.cfg.Announcements.MOTD)
+	}
+}
+
+// updateAnnouncements sends the scheduled announcements that are due.
+// Called once per tick.
+func (g *Game) updateAnnouncements() {
+	for _, s := range g.cfg.Announcements.Scheduled {
+		if g.every(s.Every * TickRate) {
+			g.announceAll("scheduled", s.Text)
+		}
+	}
+}
+
+func (g *Game) announceRoundStart() {
+	g.announceAll("roundStart", g.cfg.Announcements.RoundStart, "{round}", strconv.Itoa(g.round.round))
+}
+
+func (g *Game) announceRoundEnd(res RoundResults) {
+	winner := ""
+	if len(res.Podium) > 0 {
+		winner = res.Podium[0].Name
+	}
+	g.announceAll("roundEnd", g.cfg.Announcements.RoundEnd,
+		"{round}", strconv.Itoa(res.Round), "{winner}", winner)
+}
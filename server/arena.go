@@ -0,0 +1,40 @@
+package main
+
+import "math"
+
+// ---------------------------------------------------------------------------
+// Arena shapes
+//
+//	square   the whole WorldSize square (default)
+//	circle   the disc inscribed in the square, like slither.io
+//	hexagon  the regular hexagon inscribed in the square, with corners to the
+//	         left and right and flat edges at the top and bottom
+//
+// Everything that used to compare coordinates with the square's edges now
+// asks the shape for the distance to its edge: boundary deaths (a player's
+// head closer than BoundaryMargin), AI fleeing and wandering, steering, and
+// random positions for food and spawns, which are drawn from the square and
+// kept when they are at least randPosInset inside the shape. The centre of
+// the world stays the centre of every shape, so code steering "inward" still
+// aims at (WorldSize/2, WorldSize/2). The init event describes the shape.
+// The shapes themselves are in package sim.
+// ---------------------------------------------------------------------------
+
+const randPosInset = 200 // distance of random world positions from the edge
+
+// outOfBounds reports whether p is past the boundary margin.
+func (g *Game) outOfBounds(p Vec2) bool {
+	return g.arena.EdgeDist(p) < g.cfg.BoundaryMargin
+}
+
+// worldCenter returns the middle of the world, which every shape shares.
+func (g *Game) worldCenter() Vec2 {
+	c := float64(g.cfg.WorldSize) / 2
+	return Vec2{X: c, Y: c}
+}
+
+// angleToCenter returns the heading from p to the middle of the world.
+func (g *Game) angleToCenter(p Vec2) float64 {
+	c := g.worldCenter()
+	return math.Atan2(c.Y-p.Y, c.X-p.X)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Kill assists
+//
+// A snake rarely dies on its own: others cut it off and box it in until it
+// has nowhere left to turn. Every assistSampleEvery game ticks each living
+// snake records its head position in a short ring buffer covering the last
+// assistWindow. When a snake dies, every other living snake except the
+// killer whose body came within assistRange of one of those positions at
+// the time is credited with an assist, the closest MaxAssists of them.
+//
+// Bodies need no history of their own: a snake adds one body point per game
+// tick, so the points of o's body that already existed a ticks ago are
+// o.Segments[a:], and that is what a sample of age a is checked against.
+//
+// Assists count for every death cause, so boxing a snake into the boundary
+// or a predator earns one too. The assisting player gets an "assist" event,
+// the victim's death report lists the assisting snakes, and assists are
+// kept in the match history and the event log.
+// ---------------------------------------------------------------------------
+
+const (
+	MaxAssists = 3 // snakes credited per death at most
+
+	assistWindow      = 2 * TickRate // game ticks before a death in which boxing counts
+	assistSampleEvery = 6            // game ticks between head samples
+	assistSamples     = assistWindow / assistSampleEvery
+	assistRange       = 30.0 // gap between a body and the victim's head that counts as boxing it in
+)
+
+type assistEvent struct {
+	Type         string `json:"t"` // "assist"
+	Victim       string `json:"victim"`
+	VictimEntity uint32 `json:"victimEntity"`
+	Killer       string `json:"killer,omitempty"`
+	Cause        string `json:"cause"`
+}
+
+// headTrace is a ring buffer of a snake's recent head positions.
+type headTrace struct {
+	pos   [assistSamples]Vec2
+	clock [assistSamples]int // game clock of each sample
+	n     int                // samples recorded; the next goes to n % assistSamples
+}
+
+// recordTraces samples the heads of all living snakes. Called once per tick.
+func (g *Game) recordTraces() {
+	if !g.every(assistSampleEvery) {
+		return
+	}
+	for _, s := range g.snakes {
+		if !s.Alive || len(s.Segments) == 0 {
+			continue
+		}
+		tr := &s.trace
+		i := tr.n % assistSamples
+		tr.pos[i], tr.clock[i] = s.Segments[0], g.clock
+		tr.n++
+	}
+}
+
+// findAssists returns the snakes that boxed victim in before it died,
+// closest first. Called from killSnake.
+func (g *Game) findAssists(victim, killer *Snake) []*Snake {
+	type candidate struct {
+		s    *Snake
+		dist float64
+	}
+	var found []candidate
+	for _, o := range g.snakes {
+		if o == victim || o == killer || !o.Alive || len(o.Segments) == 0 {
+			continue
+		}
+		if d, ok := g.boxedBy(victim, o); ok {
+			found = append(found, candidate{o, d})
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].dist < found[j].dist })
+	assists := make([]*Snake, 0, min(len(found), MaxAssists))
+	for _, c := range found[:min(len(found), MaxAssists)] {
+		assists = append(assists, c.s)
+	}
+	return assists
+}
+
+// boxedBy returns how close o's body came to victim's head within the
+// assist window, and whether that is within assistRange.
+func (g *Game) boxedBy(victim, o *Snake) (float64, bool) {
+	reach := g.headRadius(victim) + g.bodyRadius(o) + assistRange
+	maxReach := g.bodySpan(o) + reach
+	best := math.Inf(1)
+	check := func(p Vec2, age int) {
+		if !nearBody(o, p, maxReach) {
+			return
+		}
+		for j := max(age, 0); j < len(o.Segments); j += 3 {
+			best = math.Min(best, sim.DistSq(p.X, p.Y, o.Segments[j].X, o.Segments[j].Y))
+		}
+	}
+	check(victim.Segments[0], 0)
+	tr := &victim.trace
+	for i := 0; i < min(tr.n, assistSamples); i++ {
+		if age := g.clock - tr.clock[i]; age <= assistWindow {
+			check(tr.pos[i], age)
+		}
+	}
+	if best > reach*reach {
+		return 0, false
+	}
+	return math.Sqrt(best), true
+}
+
+// creditAssists counts the assists killSnake found for victim and tells
+// the assisting players. Called from reportDeath.
+func (g *Game) creditAssists(victim *Snake) {
+	for _, o := range victim.assistedBy {
+		o.assists++
+		g.totalAssists++
+		if o.IsAI {
+			continue
+		}
+		if p, ok := g.players[o.PlayerID]; ok && p.snake == o {
+			ev := assistEvent{Type: "assist", Victim: victim.Name, VictimEntity: victim.id, Cause: victim.deathCause}
+			if victim.killedBy != nil {
+				ev.Killer = victim.killedBy.Name
+			}
+			g.sendEvent(p, ev)
+		}
+	}
+}
+
+// assistNames returns the names of the snakes that assisted in victim's
+// death.
+func assistNames(victim *Snake) []string {
+	var names []string
+	for _, o := range victim.assistedBy {
+		names = append(names, o.Name)
+	}
+	return names
+}
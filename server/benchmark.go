@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Headless benchmark (-benchmark N)
+//
+// Runs N ticks of the real game loop on a seeded world with no listeners.
+// Besides the configured AI snakes, Bots scripted players join like network
+// clients: they steer through the input channel, respawn when they die and
+// get protocol v6 state frames, which are encoded and then discarded. The
+// report adds up the tick's stages (see profile.go) and adds heap
+// allocations, so runs with the same flags can be compared across builds.
+// ---------------------------------------------------------------------------
+
+type benchmarkOptions struct {
+	Ticks int
+	Bots  int
+	Seed  int64
+}
+
+// benchBot is a scripted player: it wanders, turns back from the edges and
+// boosts in short bursts.
+type benchBot struct {
+	p          *Player
+	angle      float64
+	turnAt     int // frame of the next heading change
+	boostUntil int
+}
+
+func runBenchmark(g *Game, opts benchmarkOptions) {
+	if g.cfg.AIScriptDir != "" {
+		if err := g.EnableAIScripts(g.cfg.AIScriptDir); err != nil {
+			fatal("failed to load AI scripts", "dir", g.cfg.AIScriptDir, "err", err)
+		}
+	}
+	g.rngSrc.Seed(opts.Seed)
+	g.resetWorld()
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	bots := make([]*benchBot, opts.Bots)
+	for i := range bots {
+		p := &Player{
+			id:          nextPlayerID(),
+			name:        fmt.Sprintf("Bot %d", i+1),
+			sendCh:      make(chan []byte, SendBufferSize),
+			textCh:      make(chan []byte, 4),
+			done:        make(chan struct{}),
+			knownSnakes: make(map[uint32]bool),
+			sendEvery:   1,
+			serializer:  serializerV6,
+			names:       newNameTable(),
+		}
+		g.handleJoin(p)
+		bots[i] = &benchBot{p: p, angle: p.snake.Angle}
+	}
+
+	var stages stageTimes
+	ticks := make([]time.Duration, opts.Ticks)
+	sentBefore := g.totalBytesSent
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	for i := range ticks {
+		for _, b := range bots {
+			b.steer(g, rng)
+		}
+		t := time.Now()
+		g.tick()
+		ticks[i] = time.Since(t)
+		last := g.stageDurations[(g.tickDurIdx-1)%len(g.stageDurations)]
+		for k := range stages {
+			stages[k] += last[k]
+		}
+		for _, b := range bots {
+			drainBot(b.p)
+		}
+	}
+
+	wall := time.Since(start)
+	runtime.ReadMemStats(&after)
+	g.printBenchmark(opts, ticks, &stages, wall, &before, &after, g.totalBytesSent-sentBefore)
+}
+
+// steer queues this tick's input for b, or a respawn once its snake died.
+func (b *benchBot) steer(g *Game, rng *rand.Rand) {
+	s := b.p.snake
+	if !s.Alive {
+		select {
+		case g.respawnCh <- b.p.id:
+		default:
+		}
+		return
+	}
+	head := s.Segments[0]
+	switch {
+	case g.arena.EdgeDist(head) < 500:
+		b.angle = g.angleToCenter(head)
+	case g.frame >= b.turnAt:
+		b.angle += rng.Float64()*2 - 1
+		b.turnAt = g.frame + 20 + rng.Intn(60)
+		if rng.Intn(5) == 0 {
+			b.boostUntil = g.frame + 30 + rng.Intn(60)
+		}
+	}
+	select {
+	case g.inputCh <- InputMsg{PlayerID: b.p.id, Angle: b.angle, Boost: g.frame < b.boostUntil}:
+	default:
+	}
+}
+
+// drainBot throws away the frames queued for p, as a fast client would.
+func drainBot(p *Player) {
+	for {
+		select {
+		case <-p.sendCh:
+		case <-p.textCh:
+		default:
+			return
+		}
+	}
+}
+
+func (g *Game) printBenchmark(opts benchmarkOptions, ticks []time.Duration, stages *stageTimes, wall time.Duration, before, after *runtime.MemStats, sent int64) {
+	n := len(ticks)
+	var total time.Duration
+	for _, d := range ticks {
+		total += d
+	}
+	sorted := slices.Clone(ticks)
+	slices.Sort(sorted)
+	ms := func(d time.Duration) string { return fmt.Sprintf("%.3f ms", float64(d.Nanoseconds())/1e6) }
+	perTick := func(d time.Duration) time.Duration { return d / time.Duration(n) }
+	share := func(d time.Duration) string { return fmt.Sprintf("%.1f%%", 100*float64(d)/float64(total)) }
+
+	ai := 0
+	for _, s := range g.snakes {
+		if s.IsAI {
+			ai++
+		}
+	}
+	fmt.Printf("benchmark: %d ticks, %d bots, %d AI, %d food, world %d, seed %d\n",
+		n, opts.Bots, ai, g.cfg.FoodCount, g.cfg.WorldSize, opts.Seed)
+	fmt.Printf("wall time %s (%.0f ticks/s, %.1f× real time)\n\n",
+		wall.Round(time.Millisecond), float64(n)/wall.Seconds(), float64(n)/TickRate/wall.Seconds())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "stage\tper tick\tshare")
+	var staged time.Duration
+	for i, d := range stages {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", stageNames[i], ms(perTick(d)), share(d))
+		staged += d
+	}
+	fmt.Fprintf(w, "other\t%s\t%s\n", ms(perTick(total-staged)), share(total-staged))
+	fmt.Fprintf(w, "tick\t%s\n", ms(perTick(total)))
+	w.Flush()
+
+	fmt.Printf("\ntick p50 %s, p99 %s, max %s\n",
+		ms(sorted[n/2]), ms(sorted[min(n*99/100, n-1)]), ms(sorted[n-1]))
+	fmt.Printf("allocations %.0f/tick, %.1f KB/tick, %d GC cycles\n",
+		float64(after.Mallocs-before.Mallocs)/float64(n),
+		float64(after.TotalAlloc-before.TotalAlloc)/float64(n)/1024, after.NumGC-before.NumGC)
+	if opts.Bots > 0 {
+		fmt.Printf("state frames %.1f KB/s per bot\n", float64(sent)/float64(opts.Bots)/(float64(n)/TickRate)/1024)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Boost models
+//
+//	meter   the boost meter drains while boosting and regenerates otherwise
+//	        (default)
+//	charge  the meter only refills by eating charge pellets, and boosting is
+//	        blocked for BoostCooldown ticks after it runs dry
+//
+// Charge pellets are regular food with Charge set, sent with color index
+// ChargeFoodColor so clients can draw them apart.
+// ---------------------------------------------------------------------------
+
+const ChargeFoodColor = NumFoodColors // food color index reserved for charge pellets
+
+// BoostPolicy decides how a snake's boost meter fills and empties. Methods
+// run on the game loop goroutine.
+type BoostPolicy interface {
+	Name() string
+	// CanBoost reports whether s may boost this frame.
+	CanBoost(g *Game, s *Snake) bool
+	// Update advances s's meter by one tick, scaled by TimeScale.
+	Update(g *Game, s *Snake, boosting bool)
+	// OnEat is called after s ate f.
+	OnEat(g *Game, s *Snake, f *Food)
+	// PrepareFood may alter randomly spawned food before it is placed.
+	PrepareFood(g *Game, f *Food)
+}
+
+func boostPolicyFor(mode string) (BoostPolicy, error) {
+	switch mode {
+	case "", "meter":
+		return meterBoost{}, nil
+	case "charge":
+		return chargeBoost{}, nil
+	}
+	return nil, fmt.Errorf("unknown boost mode %q (want meter or charge)", mode)
+}
+
+type meterBoost struct{}
+
+func (meterBoost) Name() string { return "meter" }
+
+func (meterBoost) CanBoost(g *Game, s *Snake) bool { return s.Boost > 0 }
+
+func (meterBoost) Update(g *Game, s *Snake, boosting bool) {
+	if boosting {
+		s.Boost -= g.cfg.BoostDrain * g.cfg.TimeScale
+	} else if s.Boost < g.cfg.MaxBoost {
+		s.Boost += g.cfg.BoostRegen * g.cfg.TimeScale
+	}
+}
+
+func (meterBoost) OnEat(g *Game, s *Snake, f *Food) {}
+
+func (meterBoost) PrepareFood(g *Game, f *Food) {}
+
+type chargeBoost struct{}
+
+func (chargeBoost) Name() string { return "charge" }
+
+func (chargeBoost) CanBoost(g *Game, s *Snake) bool {
+	return s.boostCooldown == 0 && s.Boost > 0
+}
+
+func (chargeBoost) Update(g *Game, s *Snake, boosting bool) {
+	if !boosting {
+		g.countDown(&s.boostCooldown)
+		return
+	}
+	s.Boost -= g.cfg.BoostDrain * g.cfg.TimeScale
+	if s.Boost <= 0 {
+		s.Boost = 0
+		s.boostCooldown = g.cfg.BoostCooldown
+	}
+}
+
+func (chargeBoost) OnEat(g *Game, s *Snake, f *Food) {
+	if f.Charge {
+		s.Boost = sim.Clamp(s.Boost+g.cfg.ChargeValue, 0, g.cfg.MaxBoost)
+	}
+}
+
+func (chargeBoost) PrepareFood(g *Game, f *Food) {
+	if g.rng.Float64() < g.cfg.ChargeFoodRatio {
+		f.Charge = true
+		f.ColorIdx = ChargeFoodColor
+		f.Radius = FoodRadiusVal + 2
+	}
+}
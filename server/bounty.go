@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// ---------------------------------------------------------------------------
+// Golden-snake bounty: the top scorer is periodically crowned "golden" and
+// whoever kills it collects a bonus.
+// ---------------------------------------------------------------------------
+
+const BountyHuntRange = 1200.0 // AI hunters chase the golden snake within this range
+
+type bountyEvent struct {
+	Type   string `json:"t"`
+	ID     int    `json:"id,omitempty"`
+	Entity uint32 `json:"entity,omitempty"` // entity ID, for protocol v6 clients
+	Name   string `json:"name,omitempty"`
+	Killer string `json:"killer,omitempty"`
+	Bonus  int    `json:"bonus"`
+}
+
+func (g *Game) bountyEnabled() bool {
+	return g.cfg.BountyInterval > 0
+}
+
+// updateBounty drops a bounty whose target is gone and crowns a new golden
+// snake every BountyInterval seconds. Called once per tick.
+func (g *Game) updateBounty() {
+	if g.golden != nil && !g.goldenValid() {
+		g.log.Info("bounty lapsed", snakeAttrs(g.golden)...)
+		g.setGolden(nil)
+		g.announce(bountyEvent{Type: "bounty", Bonus: g.cfg.BountyBonus})
+	}
+	if !g.bountyEnabled() || g.roundFrozen() {
+		return
+	}
+	if !g.every(g.cfg.BountyInterval * TickRate) {
+		return
+	}
+
+	var top *Snake
+	for _, s := range g.snakes {
+		if s.Alive && (top == nil || s.Score > top.Score) {
+			top = s
+		}
+	}
+	if top == nil || top == g.golden {
+		return
+	}
+	g.setGolden(top)
+	g.log.Info("golden snake crowned", append(snakeAttrs(top), "bonus", g.cfg.BountyBonus)...)
+	g.announce(bountyEvent{Type: "bounty", ID: top.PlayerID, Entity: top.id, Name: top.Name, Bonus: g.cfg.BountyBonus})
+}
+
+// goldenValid reports whether the golden snake is still alive and in play.
+// respawnAI reuses the Snake value, which clears the golden flag.
+func (g *Game) goldenValid() bool {
+	if !g.golden.golden || !g.golden.Alive {
+		return false
+	}
+	for _, s := range g.snakes {
+		if s == g.golden {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Game) setGolden(s *Snake) {
+	if g.golden != nil {
+		g.golden.golden = false
+	}
+	g.golden = s
+	if s != nil {
+		s.golden = true
+	}
+}
+
+// claimBounty pays out the bonus if victim was the golden snake. Called at
+// every credited kill.
+func (g *Game) claimBounty(victim, killer *Snake) {
+	if victim != g.golden || g.golden == nil {
+		return
+	}
+	g.setGolden(nil)
+	g.unlock(killer, achGoldenKill)
+	if killer.Alive {
+		g.growSnake(killer, g.cfg.BountyBonus)
+	}
+	g.log.Info("bounty claimed", append(killAttrs(victim, killer), "bonus", g.cfg.BountyBonus)...)
+	g.announce(bountyEvent{Type: "bountyClaimed", ID: killer.PlayerID, Entity: killer.id, Name: victim.Name,
+		Killer: killer.Name, Bonus: g.cfg.BountyBonus})
+}
+
+// announce sends a JSON event to every connected player.
+func (g *Game) announce(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		g.log.Error("failed to encode event", "err", err)
+		return
+	}
+	g.broadcastText(data)
+}
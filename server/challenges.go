@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Daily and weekly challenges
+//
+// Every UTC day DailyChallenges objectives from dailyPool are active, and
+// every UTC week (starting Monday) one from weeklyPool. The selection only
+// depends on the date, so every server shows the same challenges without
+// coordination. Progress is kept per identity in the profile under the
+// challenge key ("<period start>/<id>") and dropped once the challenge has
+// rotated out. Challenges are listed in the welcome message and at
+// /challenges; players get a "challenge" event whenever they make progress
+// on one that counts events, and when they complete one.
+// ---------------------------------------------------------------------------
+
+const DailyChallenges = 2
+
+// Challenge kinds: what is counted.
+const (
+	chKills      = "kills"       // kills
+	chBoostKills = "boost_kills" // kills while boosting
+	chFood       = "food"        // food items eaten
+	chLength     = "length"      // best length in one life
+	chSurvive    = "survive"     // best seconds alive in one life
+	chTop3       = "top3"        // 1 once in the top 3 of the leaderboard
+)
+
+type Challenge struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Goal        int    `json:"goal"`
+	kind        string
+}
+
+// best reports whether progress is the best single value rather than a sum.
+func (c Challenge) best() bool {
+	return c.kind == chLength || c.kind == chSurvive || c.kind == chTop3
+}
+
+var dailyPool = []Challenge{
+	{"boost_kills_5", "Get 5 kills while boosting", 5, chBoostKills},
+	{"top3", "Reach the top 3 on the leaderboard", 1, chTop3},
+	{"kills_10", "Get 10 kills", 10, chKills},
+	{"food_300", "Eat 300 food", 300, chFood},
+	{"length_300", "Reach length 300", 300, chLength},
+	{"survive_5m", "Stay alive for 5 minutes", 5 * 60, chSurvive},
+}
+
+var weeklyPool = []Challenge{
+	{"kills_50", "Get 50 kills", 50, chKills},
+	{"boost_kills_25", "Get 25 kills while boosting", 25, chBoostKills},
+	{"food_3000", "Eat 3000 food", 3000, chFood},
+	{"length_1000", "Reach length 1000", 1000, chLength},
+	{"survive_20m", "Stay alive for 20 minutes", 20 * 60, chSurvive},
+}
+
+// ActiveChallenge is a challenge in its current period.
+type ActiveChallenge struct {
+	Challenge
+	Key    string    `json:"key"`    // progress key, unique per period
+	Period string    `json:"period"` // "daily" or "weekly"
+	Ends   time.Time `json:"ends"`
+}
+
+// CurrentChallenges returns the challenges active at now.
+func CurrentChallenges(now time.Time) []ActiveChallenge {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	week := day.AddDate(0, 0, -(int(day.Weekday())+6)%7) // back to Monday
+
+	var active []ActiveChallenge
+	add := func(pool []Challenge, n int, start, end time.Time, period string) {
+		// Seeded by the period start, so the pick is the same everywhere.
+		rng := rand.New(rand.NewSource(start.Unix()))
+		for _, i := range rng.Perm(len(pool))[:n] {
+			c := pool[i]
+			active = append(active, ActiveChallenge{
+				Challenge: c, Key: start.Format("2006-01-02") + "/" + c.ID, Period: period, Ends: end,
+			})
+		}
+	}
+	add(dailyPool, DailyChallenges, day, day.AddDate(0, 0, 1), "daily")
+	add(weeklyPool, 1, week, week.AddDate(0, 0, 7), "weekly")
+	return active
+}
+
+type challengeEvent struct {
+	Type     string `json:"t"` // "challenge"
+	Key      string `json:"key"`
+	Progress int    `json:"progress"`
+	Goal     int    `json:"goal"`
+	Done     bool   `json:"done"`
+}
+
+type challengesEvent struct {
+	Type       string            `json:"t"` // "challenges", after a rotation
+	Challenges []ActiveChallenge `json:"challenges"`
+}
+
+// advanceChallenges adds n (or, for best-value kinds, offers n) to the
+// progress of s's player on the active challenges of the given kind.
+func (g *Game) advanceChallenges(s *Snake, kind string, n int) {
+	if s == nil || s.IsAI || n <= 0 {
+		return
+	}
+	p, ok := g.players[s.PlayerID]
+	if !ok || p.snake != s {
+		return
+	}
+	prof := g.profiles[p.identity]
+	if prof == nil {
+		return
+	}
+	for _, c := range g.challenges {
+		if c.kind != kind {
+			continue
+		}
+		old := prof.Challenges[c.Key]
+		if old >= c.Goal {
+			continue
+		}
+		progress := old + n
+		if c.best() {
+			progress = max(old, n)
+		}
+		progress = min(progress, c.Goal)
+		if progress == old {
+			continue
+		}
+		if prof.Challenges == nil {
+			prof.Challenges = make(map[string]int)
+		}
+		prof.Challenges[c.Key] = progress
+		done := progress >= c.Goal
+		if done {
+			g.log.Info("challenge completed", "playerID", p.id, "name", p.name, "challenge", c.Key)
+			g.persistProfile(p.identity, prof)
+		}
+		// Best-value kinds change every second; only report completing them.
+		if done || !c.best() {
+			g.sendEvent(p, challengeEvent{Type: "challenge", Key: c.Key, Progress: progress, Goal: c.Goal, Done: done})
+		}
+	}
+}
+
+// updateChallenges rotates the challenges at period boundaries and feeds
+// the per-life kinds. Called once per second.
+func (g *Game) updateChallenges() {
+	// Weeks start at a day boundary, so the first daily key tells.
+	if cur := CurrentChallenges(time.Now()); len(g.challenges) == 0 || cur[0].Key != g.challenges[0].Key {
+		rotated := len(g.challenges) > 0
+		g.challenges = cur
+		g.pruneChallenges()
+		if rotated {
+			g.log.Info("challenges rotated", "active", len(cur))
+			g.announce(challengesEvent{Type: "challenges", Challenges: cur})
+		}
+	}
+
+	ranked := make([]*Snake, 0, len(g.snakes))
+	for _, s := range g.snakes {
+		if s.Alive {
+			ranked = append(ranked, s)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+	for i, s := range ranked {
+		if s.IsAI {
+			continue
+		}
+		if i < PodiumSize {
+			g.advanceChallenges(s, chTop3, 1)
+		}
+		g.advanceChallenges(s, chLength, len(s.Segments))
+		g.advanceChallenges(s, chSurvive, (g.frame-s.spawnFrame)/TickRate)
+		g.advanceChallenges(s, chFood, s.foodEaten-s.foodCounted)
+		s.foodCounted = s.foodEaten
+	}
+}
+
+// pruneChallenges drops progress on challenges that are no longer active.
+func (g *Game) pruneChallenges() {
+	active := make(map[string]bool, len(g.challenges))
+	for _, c := range g.challenges {
+		active[c.Key] = true
+	}
+	for _, prof := range g.profiles {
+		for key := range prof.Challenges {
+			if !active[key] {
+				delete(prof.Challenges, key)
+			}
+		}
+	}
+}
+
+// HandleChallenges serves /challenges: the active challenges.
+func HandleChallenges(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"challenges": CurrentChallenges(time.Now())})
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Chat and chat commands
+//
+// Players send {"t":"chat","text":"..."}. readPump sanitizes the text and
+// rate-limits it; the game loop does the rest. Plain messages go to every
+// player as a "chat" event. Messages starting with "/" are commands: they
+// are looked up in the command registry, and the reply goes only to the
+// player who sent them, as a "reply" event. Modes and plugins add their own
+// commands with AddCommand.
+//
+// A message sent with "ch":"near" is proximity chat: it only reaches players
+// whose camera is within ChatRadius of the sender's, found through the shard
+// grid when sharding is on. Spectators hear what is said around the snake
+// they watch. With ProximityChatOnly the room has no all-chat and every
+// message is proximity chat.
+// ---------------------------------------------------------------------------
+
+const (
+	MaxChatRunes = 120         // visible characters per chat message
+	ChatInterval = time.Second // minimum time between chat messages per connection
+
+	DefaultChatRadius = 1500.0 // about the area a client sees around its snake
+
+	chatNear = "near" // proximity channel, "ch" in chat messages and events
+)
+
+type ChatMsg struct {
+	PlayerID int
+	Text     string
+	Near     bool // proximity chat
+}
+
+type chatEvent struct {
+	Type    string `json:"t"` // "chat"
+	ID      int    `json:"id"`
+	Entity  uint32 `json:"entity,omitempty"` // sender's snake, for protocol v6 clients
+	Name    string `json:"name"`
+	Text    string `json:"text"`
+	Channel string `json:"ch,omitempty"` // "near" for proximity chat, "" for all-chat
+}
+
+type replyEvent struct {
+	Type    string `json:"t"` // "reply"
+	Command string `json:"command"`
+	Text    string `json:"text"`
+}
+
+// Command is a chat command. Run gets the words after the command name and
+// returns the reply; an empty reply sends nothing. Like hooks, commands run
+// on the game loop goroutine and may read and modify the world.
+type Command struct {
+	Name  string // without the slash, matched case-insensitively
+	Usage string // arguments shown by /help, e.g. "<1-3>"
+	Help  string // one line shown by /help
+	Run   func(g *Game, p *Player, args []string) string
+}
+
+// AddCommand registers c, replacing a command of the same name (built-ins
+// included). Must be called before Run.
+func (g *Game) AddCommand(c Command) {
+	if g.commands == nil {
+		g.commands = make(map[string]*Command)
+	}
+	g.commands[strings.ToLower(c.Name)] = &c
+}
+
+// handleChat broadcasts a chat message, sends it to the players nearby or
+// runs the command it holds.
+func (g *Game) handleChat(msg ChatMsg) {
+	p, ok := g.players[msg.PlayerID]
+	if !ok {
+		return
+	}
+	if strings.HasPrefix(msg.Text, "/") {
+		g.runCommand(p, msg.Text[1:])
+		return
+	}
+	near := msg.Near || g.cfg.ProximityChatOnly
+	g.log.Info("chat", "playerID", p.id, "name", p.name, "near", near, "text", msg.Text)
+	ev := chatEvent{Type: "chat", ID: p.id, Name: p.name, Text: msg.Text}
+	if p.snake != nil {
+		ev.Entity = p.snake.id
+	}
+	if !near {
+		g.announce(ev)
+		return
+	}
+	ev.Channel = chatNear
+	for _, o := range g.playersNear(p, g.cfg.ChatRadius) {
+		g.sendEvent(o, ev)
+	}
+}
+
+// playersNear returns p and the players whose camera target is a living
+// snake within r of p's. Without a camera target p only reaches itself.
+func (g *Game) playersNear(p *Player, r float64) []*Player {
+	out := []*Player{p}
+	c := p.cameraTarget()
+	if c == nil || !c.Alive || len(c.Segments) == 0 {
+		return out
+	}
+	h := c.Segments[0]
+	var candidates []*Snake
+	if g.shards != nil {
+		candidates = g.shards.visibleSnakes(h.X, h.Y, r)
+	} else {
+		candidates = g.snakes
+	}
+	near := make(map[*Snake]bool)
+	for _, s := range candidates {
+		if s.Alive && len(s.Segments) > 0 && sim.DistSq(s.Segments[0].X, s.Segments[0].Y, h.X, h.Y) <= r*r {
+			near[s] = true
+		}
+	}
+	for _, o := range g.players {
+		if o != p && near[o.cameraTarget()] {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// runCommand parses "name args..." and replies to p with the command's
+// result. A command that panics is logged and answered with an error.
+func (g *Game) runCommand(p *Player, line string) {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return
+	}
+	name := strings.ToLower(words[0])
+	c, ok := g.commands[name]
+	if !ok {
+		g.sendEvent(p, replyEvent{Type: "reply", Command: name, Text: fmt.Sprintf("Unknown command /%s, try /help", name)})
+		return
+	}
+	g.log.Debug("chat command", "playerID", p.id, "command", name, "args", words[1:])
+	reply := func() (reply string) {
+		defer func() {
+			if r := recover(); r != nil {
+				g.log.Error("chat command panicked", "command", name, "playerID", p.id, "panic", r)
+				reply = "Command failed"
+			}
+		}()
+		return c.Run(g, p, words[1:])
+	}()
+	if reply != "" {
+		g.sendEvent(p, replyEvent{Type: "reply", Command: name, Text: reply})
+	}
+}
+
+// registerBuiltinCommands adds /help, /stats, /players and /vote.
+func (g *Game) registerBuiltinCommands() {
+	g.AddCommand(Command{Name: "help", Help: "List the chat commands", Run: cmdHelp})
+	g.AddCommand(Command{Name: "stats", Help: "Show your score, kills and rank", Run: cmdStats})
+	g.AddCommand(Command{Name: "players", Help: "List the connected players", Run: cmdPlayers})
+	g.AddCommand(Command{Name: "vote", Usage: "<choice>", Help: "Vote in the running vote", Run: cmdVote})
+}
+
+func cmdHelp(g *Game, p *Player, args []string) string {
+	names := make([]string, 0, len(g.commands))
+	for name := range g.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		c := g.commands[name]
+		usage := "/" + name
+		if c.Usage != "" {
+			usage += " " + c.Usage
+		}
+		lines = append(lines, usage+" - "+c.Help)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func cmdStats(g *Game, p *Player, args []string) string {
+	s := p.snake
+	if s == nil || !s.Alive {
+		return "You are not alive right now"
+	}
+	rank, alive := 1, 0
+	for _, o := range g.snakes {
+		if o.Alive {
+			alive++
+			if o.Score > s.Score {
+				rank++
+			}
+		}
+	}
+	out := fmt.Sprintf("Score %d, length %d, %d kills, alive %s, rank %d of %d, level %d",
+		s.Score, len(s.Segments), s.kills, formatDuration(time.Duration(g.frame-s.spawnFrame)*time.Second/TickRate),
+		rank, alive, g.playerLevel(p))
+	if prof := g.profiles[p.identity]; prof != nil && g.cfg.RatingK > 0 {
+		out += fmt.Sprintf(", rating %d", prof.rating())
+	}
+	return out
+}
+
+func cmdPlayers(g *Game, p *Player, args []string) string {
+	list := make([]*Player, 0, len(g.players))
+	for _, o := range g.players {
+		if o.snake != nil {
+			list = append(list, o)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].snake.Score > list[j].snake.Score })
+	parts := make([]string, 0, len(list))
+	for _, o := range list {
+		entry := fmt.Sprintf("%s (%d)", o.name, o.snake.Score)
+		if !o.snake.Alive {
+			entry = fmt.Sprintf("%s (%d, dead)", o.name, o.snake.Score)
+		}
+		parts = append(parts, entry)
+	}
+	return fmt.Sprintf("%d players: %s", len(list), strings.Join(parts, ", "))
+}
+
+// cmdVote is the fallback for /vote while nothing is put to a vote. Modes
+// that run votes replace it.
+func cmdVote(g *Game, p *Player, args []string) string {
+	return "There is no vote running"
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Cluster mode
+//
+// With -cluster-redis, several server processes form a fleet. Every process
+// registers its room (public URL and current load) in a RoomDirectory and
+// refreshes it every clusterHeartbeat; entries expire after clusterTTL, so
+// a process that dies drops out on its own. The built-in directory is Redis
+// (see cluster_redis.go); other backends only need to implement
+// RoomDirectory.
+//
+// Any process can then act as the front door: /play redirects a player to
+// the room with the fewest players, and /cluster/rooms and /cluster/stats
+// show the rooms and the totals across the fleet. Worlds are not shared;
+// a player plays in the room they were sent to.
+// ---------------------------------------------------------------------------
+
+const (
+	clusterHeartbeat = 5 * time.Second
+	clusterTTL       = 3 * clusterHeartbeat
+)
+
+// RoomDirectory is a registry of the rooms in a fleet. Implementations must
+// be safe for concurrent use.
+type RoomDirectory interface {
+	Name() string
+	// Register adds or refreshes a room; it is dropped after ttl unless
+	// registered again.
+	Register(room RoomInfo, ttl time.Duration) error
+	Rooms() ([]RoomInfo, error)
+	Close() error
+}
+
+// RoomInfo is one server process as seen by the directory.
+type RoomInfo struct {
+	ID            string    `json:"id"`
+	URL           string    `json:"url"` // public base URL
+	Version       string    `json:"version"`
+	Players       int       `json:"players"`
+	AI            int       `json:"ai"`
+	TotalJoins    int64     `json:"totalJoins"`
+	TotalKills    int64     `json:"totalKills"`
+	AvgTickMs     float64   `json:"avgTickMs"`
+	BandwidthKBps float64   `json:"bandwidthKBps"`
+	Updated       time.Time `json:"updated"`
+}
+
+// ClusterStats is the fleet-wide summary served by /cluster/stats.
+type ClusterStats struct {
+	Rooms         int        `json:"rooms"`
+	Players       int        `json:"players"`
+	AI            int        `json:"ai"`
+	TotalJoins    int64      `json:"totalJoins"`
+	TotalKills    int64      `json:"totalKills"`
+	BandwidthKBps float64    `json:"bandwidthKBps"`
+	RoomList      []RoomInfo `json:"roomList"`
+}
+
+type Cluster struct {
+	dir  RoomDirectory
+	game *Game
+	id   string
+	url  string
+}
+
+// NewCluster registers game as room id, reachable at url, and keeps the
+// registration fresh until the process exits.
+func NewCluster(game *Game, dir RoomDirectory, id, url string) *Cluster {
+	c := &Cluster{dir: dir, game: game, id: id, url: strings.TrimSuffix(url, "/")}
+	go c.heartbeat()
+	return c
+}
+
+func (c *Cluster) heartbeat() {
+	failing := false
+	for {
+		err := c.dir.Register(c.roomInfo(), clusterTTL)
+		if err != nil && !failing {
+			slog.Warn("cluster registration failed", "directory", c.dir.Name(), "err", err)
+		} else if err == nil && failing {
+			slog.Info("cluster registration restored", "directory", c.dir.Name())
+		}
+		failing = err != nil
+		time.Sleep(clusterHeartbeat)
+	}
+}
+
+func (c *Cluster) roomInfo() RoomInfo {
+	snap := c.game.GetStats()
+	return RoomInfo{
+		ID:            c.id,
+		URL:           c.url,
+		Version:       snap.Version,
+		Players:       snap.CurrentPlayers,
+		AI:            snap.AICount,
+		TotalJoins:    snap.TotalJoins,
+		TotalKills:    snap.TotalKills,
+		AvgTickMs:     snap.AvgTickMs,
+		BandwidthKBps: snap.BandwidthKBps,
+		Updated:       time.Now().UTC(),
+	}
+}
+
+// rooms returns the live rooms, least loaded first.
+func (c *Cluster) rooms() ([]RoomInfo, error) {
+	all, err := c.dir.Rooms()
+	if err != nil {
+		return nil, err
+	}
+	rooms := make([]RoomInfo, 0, len(all))
+	for _, r := range all {
+		if time.Since(r.Updated) < clusterTTL {
+			rooms = append(rooms, r)
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].Players != rooms[j].Players {
+			return rooms[i].Players < rooms[j].Players
+		}
+		if rooms[i].AvgTickMs != rooms[j].AvgTickMs {
+			return rooms[i].AvgTickMs < rooms[j].AvgTickMs
+		}
+		return rooms[i].ID < rooms[j].ID
+	})
+	return rooms, nil
+}
+
+// HandlePlay is the front door: it redirects to the least-loaded room, or
+// to this server's own page when the directory can't be read.
+func (c *Cluster) HandlePlay(w http.ResponseWriter, r *http.Request) {
+	target := "/"
+	rooms, err := c.rooms()
+	if err != nil {
+		slog.Warn("cluster directory unavailable", "directory", c.dir.Name(), "err", err)
+	} else if len(rooms) > 0 && rooms[0].ID != c.id {
+		target = rooms[0].URL + "/"
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (c *Cluster) HandleRooms(w http.ResponseWriter, r *http.Request) {
+	rooms, err := c.rooms()
+	if err != nil {
+		http.Error(w, "cluster directory unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"rooms": rooms})
+}
+
+func (c *Cluster) HandleStats(w http.ResponseWriter, r *http.Request) {
+	rooms, err := c.rooms()
+	if err != nil {
+		http.Error(w, "cluster directory unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	st := ClusterStats{Rooms: len(rooms), RoomList: rooms}
+	for _, room := range rooms {
+		st.Players += room.Players
+		st.AI += room.AI
+		st.TotalJoins += room.TotalJoins
+		st.TotalKills += room.TotalKills
+		st.BandwidthKBps += room.BandwidthKBps
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Redis room directory
+//
+// Each room is a JSON value under redisRoomPrefix+<id> with an expiry, so
+// the directory needs nothing but SET, SCAN and MGET. The client speaks just
+// enough RESP for these over a single connection, which is redialed after
+// any error.
+// ---------------------------------------------------------------------------
+
+const (
+	redisRoomPrefix = "schlangen:room:"
+	redisTimeout    = 3 * time.Second
+)
+
+type redisDirectory struct {
+	addr     string
+	user     string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// OpenRedisDirectory connects to the Redis server at addr: "host:port" or
+// "redis://[[user]:password@]host:port[/db]".
+func OpenRedisDirectory(addr string) (RoomDirectory, error) {
+	d := &redisDirectory{addr: addr}
+	if strings.HasPrefix(addr, "redis://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		d.addr = u.Host
+		if u.Port() == "" {
+			d.addr = net.JoinHostPort(u.Hostname(), "6379")
+		}
+		d.user = u.User.Username()
+		d.password, _ = u.User.Password()
+		if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+			if d.db, err = strconv.Atoi(db); err != nil {
+				return nil, fmt.Errorf("invalid redis database %q", db)
+			}
+		}
+	}
+	if _, err := d.do("PING"); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *redisDirectory) Name() string { return "redis" }
+
+func (d *redisDirectory) Register(room RoomInfo, ttl time.Duration) error {
+	data, err := json.Marshal(room)
+	if err != nil {
+		return err
+	}
+	_, err = d.do("SET", redisRoomPrefix+room.ID, string(data), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+func (d *redisDirectory) Rooms() ([]RoomInfo, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := d.do("SCAN", cursor, "MATCH", redisRoomPrefix+"*", "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+		page, ok := reply.([]any)
+		if !ok || len(page) != 2 {
+			return nil, errors.New("redis: unexpected SCAN reply")
+		}
+		cursor, _ = page[0].(string)
+		batch, _ := page[1].([]any)
+		for _, k := range batch {
+			if s, ok := k.(string); ok {
+				keys = append(keys, s)
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			break
+		}
+	}
+	rooms := []RoomInfo{}
+	if len(keys) == 0 {
+		return rooms, nil
+	}
+	reply, err := d.do("MGET", keys...)
+	if err != nil {
+		return nil, err
+	}
+	values, _ := reply.([]any)
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue // expired between SCAN and MGET
+		}
+		var room RoomInfo
+		if json.Unmarshal([]byte(s), &room) == nil {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms, nil
+}
+
+func (d *redisDirectory) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn == nil {
+		return nil
+	}
+	err := d.conn.Close()
+	d.conn = nil
+	return err
+}
+
+// do sends one command and reads its reply, dialing first if needed.
+// Bulk strings come back as string, arrays as []any and nil values as nil.
+func (d *redisDirectory) do(cmd string, args ...string) (any, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn == nil {
+		if err := d.dial(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := d.roundTrip(cmd, args...)
+	if err != nil {
+		var re redisError
+		if !errors.As(err, &re) {
+			// The connection is in an unknown state
+			d.conn.Close()
+			d.conn = nil
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (d *redisDirectory) dial() error {
+	conn, err := net.DialTimeout("tcp", d.addr, redisTimeout)
+	if err != nil {
+		return err
+	}
+	d.conn, d.rd = conn, bufio.NewReader(conn)
+	if d.password != "" {
+		auth := []string{d.password}
+		if d.user != "" {
+			auth = []string{d.user, d.password}
+		}
+		if _, err := d.roundTrip("AUTH", auth...); err != nil {
+			d.conn.Close()
+			d.conn = nil
+			return err
+		}
+	}
+	if d.db != 0 {
+		if _, err := d.roundTrip("SELECT", strconv.Itoa(d.db)); err != nil {
+			d.conn.Close()
+			d.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *redisDirectory) roundTrip(cmd string, args ...string) (any, error) {
+	d.conn.SetDeadline(time.Now().Add(redisTimeout))
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n$%d\r\n%s\r\n", len(args)+1, len(cmd), cmd)
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := d.conn.Write([]byte(b.String())); err != nil {
+		return nil, err
+	}
+	return readRESP(d.rd)
+}
+
+// redisError is an error reply; the connection stays usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func readRESP(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, errors.New("redis: malformed reply")
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readRESP(rd); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
@@ -0,0 +1,26 @@
+package main
+
+import "snake-server/sim"
+
+// ---------------------------------------------------------------------------
+// Head-vs-body collision geometry
+//
+// With CollisionPrecision 0 the head is tested as a point against every
+// body point. Otherwise the head is a capsule swept from its previous
+// position to its current one, and bodies are polylines through every
+// CollisionPrecision-th point (1 = exact), so fast snakes can't tunnel
+// through thin bodies between samples. The geometry is in package sim,
+// which also runs the snake collisions (see physics.go); the trail checks
+// use touchesPoint.
+// ---------------------------------------------------------------------------
+
+// touchesPoint reports whether s's swept head comes within sqrt(thresholdSq)
+// of p (point test when CollisionPrecision is 0).
+func (g *Game) touchesPoint(s *Snake, p Vec2, thresholdSq float64) bool {
+	if g.cfg.CollisionPrecision <= 0 {
+		head := s.Segments[0]
+		return sim.DistSq(head.X, head.Y, p.X, p.Y) < thresholdSq
+	}
+	a, b := sim.HeadPath(s.Segments)
+	return sim.SegDistSq(a, b, p, p) < thresholdSq
+}
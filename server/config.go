@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Config validation
+//
+// GameConfig.Validate is the one place that decides whether a config makes
+// sense. The command line and config file (main.go), runtime changes from
+// the admin and control APIs (control.go, grpc.go), rotation modes
+// (rotation.go) and custom rooms (rooms.go) all go through it.
+//
+// When a whole config is loaded (at startup and for a new room), some
+// fields have an obvious fix and are clamped instead of rejected:
+//
+//	simRate 0, timeScale 0   zero tick-derived rates, reset to their
+//	                         defaults as if the field was left out
+//	boostSpeed < baseSpeed   boosting never slows a snake down
+//	foodCount > capacity     at most one pellet per foodCellSize² of
+//	                         world, so food can't fill the whole world
+//
+// Runtime changes and rotation modes change a live world field by field,
+// so they are rejected instead (see checkRuntimeConfig). Everything else
+// that is wrong is always rejected. Errors are ConfigErrors, one
+// ConfigError per field, so the APIs can report them field by field.
+// ---------------------------------------------------------------------------
+
+const (
+	MinWorldSize = 500
+	foodCellSize = 50 // world units per pellet side at the food capacity
+)
+
+// ConfigError is a problem with one config field, named by its JSON key.
+type ConfigError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (e ConfigError) Error() string { return e.Field + " " + e.Reason }
+
+// ConfigErrors lists every problem Validate found.
+type ConfigErrors []ConfigError
+
+func (es ConfigErrors) Error() string {
+	msgs := make([]string, len(es))
+	for i, e := range es {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// foodCapacity is the most food a world of the given size holds.
+func foodCapacity(worldSize int) int {
+	cells := worldSize / foodCellSize
+	return cells * cells
+}
+
+// Validate clamps the fields of c that have an obvious fix and checks the
+// rest. It returns the clamped fields and, if anything is invalid, a
+// ConfigErrors.
+func (c *GameConfig) Validate() (clamped []ConfigError, err error) {
+	clamped = c.normalize()
+	errs := c.check()
+	if rerr := validateRotation(*c); rerr != nil {
+		errs = append(errs, ConfigError{"rotation", rerr.Error()})
+	}
+	if aerr := c.Announcements.validate(); aerr != nil {
+		errs = append(errs, ConfigError{"announcements", aerr.Error()})
+	}
+	if cerr := c.ChatSnake.validate(); cerr != nil {
+		errs = append(errs, ConfigError{"chatSnake", cerr.Error()})
+	}
+	if len(errs) > 0 {
+		return clamped, errs
+	}
+	return clamped, nil
+}
+
+// normalize clamps the fields with an obvious fix and returns them.
+func (c *GameConfig) normalize() []ConfigError {
+	var clamped []ConfigError
+	clamp := func(field, reason string) { clamped = append(clamped, ConfigError{field, reason}) }
+	if c.SimRate == 0 {
+		c.SimRate = TickRate
+		clamp("simRate", fmt.Sprintf("was 0, set to %d", TickRate))
+	}
+	if c.TimeScale == 0 {
+		c.TimeScale = 1
+		clamp("timeScale", "was 0, set to 1")
+	}
+	if c.BaseSpeed > 0 && c.BoostSpeed > 0 && c.BoostSpeed < c.BaseSpeed {
+		clamp("boostSpeed", fmt.Sprintf("was %g, below baseSpeed, set to %g", c.BoostSpeed, c.BaseSpeed))
+		c.BoostSpeed = c.BaseSpeed
+	}
+	if capacity := foodCapacity(c.WorldSize); c.WorldSize >= MinWorldSize && c.FoodCount > capacity {
+		clamp("foodCount", fmt.Sprintf("was %d, above the world's capacity, set to %d", c.FoodCount, capacity))
+		c.FoodCount = capacity
+	}
+	return clamped
+}
+
+// check returns the invalid fields of c, leaving out the rotation.
+func (c *GameConfig) check() ConfigErrors {
+	var errs ConfigErrors
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, ConfigError{field, fmt.Sprintf(format, args...)})
+	}
+	atLeast := func(field string, v, min float64) {
+		if v < min {
+			fail(field, "must be at least %g", min)
+		}
+	}
+	positive := func(field string, v float64) {
+		if v <= 0 {
+			fail(field, "must be positive")
+		}
+	}
+	within := func(field string, v, min, max float64) {
+		if v < min || v > max {
+			fail(field, "must be in [%g, %g]", min, max)
+		}
+	}
+
+	atLeast("worldSize", float64(c.WorldSize), MinWorldSize)
+	atLeast("foodCount", float64(c.FoodCount), 0)
+	atLeast("aiCount", float64(c.AICount), 0)
+	atLeast("killFoodCount", float64(c.KillFoodCount), 1)
+	positive("baseSpeed", c.BaseSpeed)
+	positive("boostSpeed", c.BoostSpeed)
+	positive("turnSpeed", c.TurnSpeed)
+	if c.BoostSpeed < c.BaseSpeed {
+		fail("boostSpeed", "must be at least baseSpeed (%g)", c.BaseSpeed)
+	}
+	if capacity := foodCapacity(c.WorldSize); c.WorldSize >= MinWorldSize && c.FoodCount > capacity {
+		fail("foodCount", "must be at most %d in a world of size %d", capacity, c.WorldSize)
+	}
+	atLeast("boundaryMargin", c.BoundaryMargin, 0)
+	atLeast("warningBand", c.WarningBand, 0)
+	atLeast("baseSnakeLen", float64(c.BaseSnakeLen), 1)
+	within("segmentSpacing", c.SegmentSpacing, 1, MaxSegmentSpacing)
+	within("growthRate", c.GrowthRate, 0.01, MaxGrowthRate)
+	atLeast("trailLifetime", float64(c.TrailLifetime), 1)
+	atLeast("aiRespawnTicks", float64(c.AIRespawnTicks), 0)
+	within("fillBots", float64(c.FillBots), 0, MaxFillBots)
+	positive("fillRadius", c.FillRadius)
+	if c.SimRate < TickRate || c.SimRate%TickRate != 0 {
+		fail("simRate", "must be a multiple of %d", TickRate)
+	}
+	within("timeScale", c.TimeScale, MinTimeScale, MaxTimeScale)
+	atLeast("tickBudget", c.TickBudget, 0)
+	within("idleTickRate", float64(c.IdleTickRate), 0, TickRate)
+	atLeast("idlePause", float64(c.IdlePause), 0)
+	within("heatmapSize", float64(c.HeatmapSize), 0, MaxHeatmapSize)
+	within("shardCells", float64(c.ShardCells), 0, MaxShardCells)
+	atLeast("collisionPrecision", float64(c.CollisionPrecision), 0)
+	if c.FrameBudget != 0 && c.FrameBudget < MinFrameBudget {
+		fail("frameBudget", "must be 0 or at least %d", MinFrameBudget)
+	}
+	atLeast("spawnProtection", float64(c.SpawnProtection), 0)
+	atLeast("spawnClearance", c.SpawnClearance, 0)
+	within("respawnCooldown", float64(c.RespawnCooldown), 0, MaxRespawnCooldown)
+
+	if _, err := boostPolicyFor(c.BoostMode); err != nil {
+		fail("boostMode", "%v", err)
+	}
+	positive("maxBoost", c.MaxBoost)
+	positive("boostDrain", c.BoostDrain)
+	positive("boostRegen", c.BoostRegen)
+	if c.MaxBoost > 0 && c.BoostDrain > c.MaxBoost {
+		fail("boostDrain", "must be at most maxBoost (%g)", c.MaxBoost)
+	}
+	if c.MaxBoost > 0 && c.BoostRegen > c.MaxBoost {
+		fail("boostRegen", "must be at most maxBoost (%g)", c.MaxBoost)
+	}
+	atLeast("boostCooldown", float64(c.BoostCooldown), 0)
+	atLeast("chargeValue", c.ChargeValue, 0)
+	within("chargeFoodRatio", c.ChargeFoodRatio, 0, 1)
+	if _, err := sim.ArenaShapeFor(c.Arena, float64(c.WorldSize)); err != nil {
+		fail("arena", "%v", err)
+	}
+	if _, err := killRewardFor(c.KillReward); err != nil {
+		fail("killReward", "%v", err)
+	}
+	atLeast("killRewardPercent", c.KillRewardPercent, 0)
+	atLeast("killRewardFlat", float64(c.KillRewardFlat), 0)
+	positive("killRewardKnee", float64(c.KillRewardKnee))
+	if _, err := sim.HeadOnRuleFor(c.HeadOn); err != nil {
+		fail("headOn", "%v", err)
+	}
+	if _, err := foodSpawnerFor(c.FoodSpawn); err != nil {
+		fail("foodSpawn", "%v", err)
+	}
+	within("foodBlooms", float64(c.FoodBlooms), 0, MaxFoodBlooms)
+	within("foodTTL", float64(c.FoodTTL), 0, MaxFoodTTL)
+
+	atLeast("roundDuration", float64(c.RoundDuration), 0)
+	atLeast("roundCountdown", float64(c.RoundCountdown), 0)
+	atLeast("roundResultsTime", float64(c.RoundResultsTime), 0)
+	if err := validateMutators(c.Mutators); err != nil {
+		fail("mutators", "%v", err)
+	}
+	within("randomMutators", float64(c.RandomMutators), 0, float64(len(mutatorList)))
+	if c.RandomMutators > 0 && c.RoundDuration == 0 {
+		fail("randomMutators", "needs tournament rounds (roundDuration)")
+	}
+	within("predators", float64(c.Predators), 0, MaxPredators)
+	positive("predatorSpeed", c.PredatorSpeed)
+	within("portals", float64(c.Portals), 0, MaxPortals)
+	within("portalCooldown", float64(c.PortalCooldown), 0, MaxPortalCooldown)
+	atLeast("streakBonus", float64(c.StreakBonus), 0)
+	atLeast("bountyInterval", float64(c.BountyInterval), 0)
+	atLeast("bountyBonus", float64(c.BountyBonus), 0)
+	atLeast("decayThreshold", float64(c.DecayThreshold), 0)
+	within("decayRate", c.DecayRate, 0, 1)
+	if c.AIPackSize < 0 || c.AIPackSize == 1 {
+		fail("aiPackSize", "must be 0 or at least 2")
+	}
+
+	atLeast("xpPerKill", float64(c.XPPerKill), 0)
+	atLeast("xpLevelBase", float64(c.XPLevelBase), 1)
+	atLeast("xpLevelGrowth", c.XPLevelGrowth, 1)
+	within("maxLevel", float64(c.MaxLevel), 1, MaxLevelLimit)
+	atLeast("ratingK", c.RatingK, 0)
+
+	positive("chatRadius", c.ChatRadius)
+	within("nameWidth", float64(c.NameWidth), 4, MaxNameWidth)
+	atLeast("eventLogMaxMB", float64(c.EventLogMaxMB), 1)
+	atLeast("eventLogKeep", float64(c.EventLogKeep), 0)
+	atLeast("replays", float64(c.Replays), 0)
+	within("inputLogMinutes", float64(c.InputLogMinutes), 0, MaxInputLogMinutes)
+	atLeast("maxRooms", float64(c.MaxRooms), 0)
+	atLeast("minLength", float64(c.MinLength), 0)
+	atLeast("maxLength", float64(c.MaxLength), 0)
+	if c.MaxLength > 0 && c.MaxLength < c.MinLength {
+		fail("maxLength", "must be 0 or at least minLength")
+	}
+	return errs
+}
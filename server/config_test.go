@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// TestValidate changes the default config one way per case and checks
+// which fields Validate clamps, to what, and which it rejects.
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(c *GameConfig)
+		clamped []string // fields, in Validate's order
+		errs    []string
+		check   func(c *GameConfig) bool // the clamped values
+	}{
+		{name: "defaults", change: func(c *GameConfig) {}},
+		{
+			name:    "sim rate 0",
+			change:  func(c *GameConfig) { c.SimRate = 0 },
+			clamped: []string{"simRate"},
+			check:   func(c *GameConfig) bool { return c.SimRate == TickRate },
+		},
+		{
+			name:    "time scale 0",
+			change:  func(c *GameConfig) { c.TimeScale = 0 },
+			clamped: []string{"timeScale"},
+			check:   func(c *GameConfig) bool { return c.TimeScale == 1 },
+		},
+		{
+			name:    "boost slower than base",
+			change:  func(c *GameConfig) { c.BaseSpeed, c.BoostSpeed = 4, 3 },
+			clamped: []string{"boostSpeed"},
+			check:   func(c *GameConfig) bool { return c.BoostSpeed == 4 },
+		},
+		{
+			name:    "food over capacity",
+			change:  func(c *GameConfig) { c.WorldSize, c.FoodCount = 1000, 1000 },
+			clamped: []string{"foodCount"},
+			check:   func(c *GameConfig) bool { return c.FoodCount == foodCapacity(1000) },
+		},
+		{
+			name:    "clamped and invalid",
+			change:  func(c *GameConfig) { c.SimRate, c.AICount = 0, -1 },
+			clamped: []string{"simRate"},
+			errs:    []string{"aiCount"},
+		},
+		{
+			name:   "world too small",
+			change: func(c *GameConfig) { c.WorldSize, c.FoodCount = MinWorldSize-1, 1000 },
+			errs:   []string{"worldSize"}, // no capacity to clamp food to
+		},
+		{
+			name:   "negative speeds",
+			change: func(c *GameConfig) { c.BaseSpeed, c.BoostSpeed = -1, -2 },
+			errs:   []string{"baseSpeed", "boostSpeed", "boostSpeed"},
+		},
+		{
+			name:   "sim rate not a multiple",
+			change: func(c *GameConfig) { c.SimRate = TickRate + 1 },
+			errs:   []string{"simRate"},
+		},
+		{
+			name:   "time scale out of range",
+			change: func(c *GameConfig) { c.TimeScale = MaxTimeScale * 2 },
+			errs:   []string{"timeScale"},
+		},
+		{
+			name:   "frame budget",
+			change: func(c *GameConfig) { c.FrameBudget = MinFrameBudget - 1 },
+			errs:   []string{"frameBudget"},
+		},
+		{
+			name:   "boost drain above max",
+			change: func(c *GameConfig) { c.MaxBoost, c.BoostDrain = 10, 11 },
+			errs:   []string{"boostDrain"},
+		},
+		{
+			name:   "unknown names",
+			change: func(c *GameConfig) { c.BoostMode, c.KillReward, c.HeadOn = "x", "x", "x" },
+			errs:   []string{"boostMode", "killReward", "headOn"},
+		},
+		{
+			name:   "random mutators without rounds",
+			change: func(c *GameConfig) { c.RandomMutators, c.RoundDuration = 1, 0 },
+			errs:   []string{"randomMutators"},
+		},
+		{
+			name:   "pack of one",
+			change: func(c *GameConfig) { c.AIPackSize = 1 },
+			errs:   []string{"aiPackSize"},
+		},
+		{
+			name:   "max length below min",
+			change: func(c *GameConfig) { c.MinLength, c.MaxLength = 50, 20 },
+			errs:   []string{"maxLength"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := DefaultConfig()
+			tt.change(&c)
+			clamped, err := c.Validate()
+			if got := configFields(clamped); !slices.Equal(got, tt.clamped) {
+				t.Errorf("clamped %v, want %v", got, tt.clamped)
+			}
+			if tt.check != nil && !tt.check(&c) {
+				t.Errorf("clamped to %+v", clamped)
+			}
+			var errs ConfigErrors
+			if err != nil && !errors.As(err, &errs) {
+				t.Fatalf("error %v is not a ConfigErrors", err)
+			}
+			if got := configFields(errs); !slices.Equal(got, tt.errs) {
+				t.Errorf("errors %v, want %v", err, tt.errs)
+			}
+		})
+	}
+}
+
+func configFields(es []ConfigError) []string {
+	var fields []string
+	for _, e := range es {
+		fields = append(fields, e.Field)
+	}
+	return fields
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+)
+
+// ---------------------------------------------------------------------------
+// Live config view
+//
+// GET /admin/config returns the config a room runs with right now, after
+// defaults, config file, environment and flags were merged at startup and
+// with every change made since by the control API or a mode rotation.
+// GET /admin/config/diff lists only the fields that differ from
+// DefaultConfig, each with the default and where the value came from:
+//
+//	file      the config file (-config)
+//	env       a SCHLANGEN_* environment variable
+//	flag      a flag on the command line
+//	room      the ruleset of an extra room (see rooms.go)
+//	runtime   changed after startup by the control API or a rotation mode
+//	startup   set at startup some other way, e.g. clamped by Validate
+//
+// Both take ?room=<id> (default: the main room). Secrets (configSecrets)
+// are shown as "redacted".
+// ---------------------------------------------------------------------------
+
+// ConfigDiff is a config field that differs from its default.
+type ConfigDiff struct {
+	Field   string          `json:"field"`
+	Value   json.RawMessage `json:"value"`
+	Default json.RawMessage `json:"default"`
+	Source  string          `json:"source"`
+}
+
+// startupSources returns the layer that last set each config key at
+// startup, by JSON key. fileKeys are the keys of the config file and
+// cmdLine the flags given on the command line.
+func startupSources(fileKeys map[string]json.RawMessage, cmdLine []string) map[string]string {
+	flags := make(map[string]bool, len(cmdLine))
+	for _, name := range cmdLine {
+		flags[envName(name)] = true
+	}
+	sources := make(map[string]string)
+	for name, f := range configEnvKeys() {
+		key := jsonKey(f)
+		if _, ok := fileKeys[key]; ok {
+			sources[key] = "file"
+		}
+		if _, ok := os.LookupEnv(name); ok {
+			sources[key] = "env"
+		}
+		if flags[name] {
+			sources[key] = "flag"
+		}
+	}
+	return sources
+}
+
+// configDiff returns the fields of live that differ from DefaultConfig, by
+// name. startup is the config the room started with.
+func configDiff(live, startup, base GameConfig, sources map[string]string) []ConfigDiff {
+	var liveM, startM, baseM, defM map[string]json.RawMessage
+	for _, c := range []struct {
+		cfg GameConfig
+		m   *map[string]json.RawMessage
+	}{{live, &liveM}, {startup, &startM}, {base, &baseM}, {DefaultConfig(), &defM}} {
+		if err := unmarshalConfig(c.cfg, c.m); err != nil {
+			return nil
+		}
+	}
+	var diff []ConfigDiff
+	for k, v := range liveM {
+		if bytes.Equal(v, defM[k]) {
+			continue
+		}
+		d := ConfigDiff{Field: k, Value: v, Default: defM[k], Source: sources[k]}
+		switch {
+		case !bytes.Equal(v, startM[k]):
+			d.Source = "runtime"
+		case !bytes.Equal(v, baseM[k]):
+			d.Source = "room"
+		case d.Source == "":
+			d.Source = "startup"
+		}
+		if configSecrets[k] {
+			d.Value = json.RawMessage(`"redacted"`)
+		}
+		diff = append(diff, d)
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Field < diff[j].Field })
+	return diff
+}
+
+// redactedConfig returns cfg by JSON key with the secrets that are set
+// replaced by "redacted".
+func redactedConfig(cfg GameConfig) (map[string]json.RawMessage, error) {
+	var m, empty map[string]json.RawMessage
+	if err := unmarshalConfig(cfg, &m); err != nil {
+		return nil, err
+	}
+	if err := unmarshalConfig(GameConfig{}, &empty); err != nil {
+		return nil, err
+	}
+	for k := range configSecrets {
+		if !bytes.Equal(m[k], empty[k]) {
+			m[k] = json.RawMessage(`"redacted"`)
+		}
+	}
+	return m, nil
+}
+
+// HandleConfigView serves /admin/config and /admin/config/diff.
+func HandleConfigView(rs *Rooms, sources map[string]string, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rm, ok := rs.find(r.URL.Query().Get("room"))
+	if !ok {
+		http.Error(w, "room not found", http.StatusNotFound)
+		return
+	}
+	live := rm.game.GetConfig()
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/admin/config/diff" {
+		json.NewEncoder(w).Encode(map[string]any{"room": rm.id, "diff": configDiff(live, rm.cfg, rs.base, sources)})
+		return
+	}
+	m, err := redactedConfig(live)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"room": rm.id, "config": m})
+}
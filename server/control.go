@@ -0,0 +1,222 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Runtime control (roster, kick, config changes). Requests are queued to the
+// game loop and executed there, like stats snapshots.
+// ---------------------------------------------------------------------------
+
+// DefaultRoomID names the single world this server runs.
+const DefaultRoomID = "main"
+
+type PlayerInfo struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+	Alive bool   `json:"alive"`
+	RTTMs int    `json:"rttMs"`
+	IP    string `json:"ip"`
+	// Identity is the player's persistent identity (see identity.go).
+	Identity string `json:"identity,omitempty"`
+}
+
+type kickReq struct {
+	id     int
+	reason string
+	reply  chan bool
+}
+
+type configReq struct {
+	apply func(*GameConfig) error // nil = read only
+	reply chan configReply
+}
+
+type configReply struct {
+	cfg GameConfig
+	err error
+}
+
+// validAdminToken reports whether got matches the configured admin token.
+// An empty configured token never matches.
+func validAdminToken(want, got string) bool {
+	if want == "" || got == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// adminOnly wraps h so it requires the admin token, either as an
+// "Authorization: Bearer <token>" header or a "token" query parameter
+// (for tools like `go tool pprof` that can't set headers).
+func adminOnly(adminToken string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if !validAdminToken(adminToken, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// GetPlayers returns the connected players (thread-safe).
+func (g *Game) GetPlayers() []PlayerInfo {
+	reply := make(chan []PlayerInfo, 1)
+	players, _ := ask(g, g.playersReqCh, reply, reply)
+	return players
+}
+
+// KickPlayer disconnects a player. Returns false if no such player exists.
+func (g *Game) KickPlayer(id int, reason string) bool {
+	reply := make(chan bool, 1)
+	ok, _ := ask(g, g.kickCh, kickReq{id: id, reason: reason, reply: reply}, reply)
+	return ok
+}
+
+// GetConfig returns the current runtime config (thread-safe).
+func (g *Game) GetConfig() GameConfig {
+	cfg, _ := g.UpdateConfig(nil)
+	return cfg
+}
+
+// UpdateConfig applies a change to the runtime config on the game loop and
+// returns the resulting config. The change is rejected as a whole if the
+// result is invalid.
+func (g *Game) UpdateConfig(apply func(*GameConfig) error) (GameConfig, error) {
+	reply := make(chan configReply, 1)
+	r, ok := ask(g, g.configReqCh, configReq{apply: apply, reply: reply}, reply)
+	if !ok {
+		return GameConfig{}, errRoomStopped
+	}
+	return r.cfg, r.err
+}
+
+func (g *Game) buildPlayerList() []PlayerInfo {
+	list := make([]PlayerInfo, 0, len(g.players))
+	for _, p := range g.players {
+		info := PlayerInfo{ID: p.id, Name: p.name, RTTMs: int(p.rttMs.Load()), IP: p.addr.String(), Identity: p.identity}
+		if p.snake != nil {
+			info.Score = p.snake.Score
+			info.Alive = p.snake.Alive
+		}
+		list = append(list, info)
+	}
+	return list
+}
+
+func (g *Game) handleKick(r kickReq) {
+	p, ok := g.players[r.id]
+	if !ok {
+		r.reply <- false
+		return
+	}
+	g.log.Warn("player kicked", "playerID", p.id, "name", p.name, "reason", r.reason)
+	// Closing the connection ends readPump, which queues the normal leave.
+	p.conn.Close()
+	r.reply <- true
+}
+
+func (g *Game) handleConfig(r configReq) {
+	if r.apply == nil {
+		r.reply <- configReply{cfg: g.cfg}
+		return
+	}
+	next := g.cfg
+	if err := r.apply(&next); err != nil {
+		r.reply <- configReply{cfg: g.cfg, err: err}
+		return
+	}
+	if err := checkRuntimeConfig(g.cfg, next); err != nil {
+		r.reply <- configReply{cfg: g.cfg, err: err}
+		return
+	}
+	prev := g.cfg
+	// Apply to the live config through the same function so untouched
+	// fields (e.g. WorldSize, read by connection goroutines) aren't written.
+	r.apply(&g.cfg)
+	g.syncAICount(prev.AICount)
+	g.syncFoodSpawner(prev.FoodSpawn)
+	g.applyMutators()
+	g.announceInit()
+	g.log.Info("runtime config updated")
+	g.logConfig(prev)
+	r.reply <- configReply{cfg: g.cfg}
+}
+
+// checkRuntimeConfig rejects changes that can't be applied to a running
+// world, and invalid configs (see GameConfig.Validate).
+func checkRuntimeConfig(cur, next GameConfig) error {
+	var errs ConfigErrors
+	fixed := func(field string, changed bool) {
+		if changed {
+			errs = append(errs, ConfigError{field, "can't be changed at runtime"})
+		}
+	}
+	fixed("worldSize", next.WorldSize != cur.WorldSize)
+	fixed("arena", next.Arena != cur.Arena)
+	fixed("shardCells", next.ShardCells != cur.ShardCells)
+	fixed("deterministic", next.Deterministic != cur.Deterministic)
+	fixed("boostMode", next.BoostMode != cur.BoostMode)
+	fixed("nameWidth", next.NameWidth != cur.NameWidth)
+	fixed("clusterRedis", next.ClusterRedis != cur.ClusterRedis)
+	fixed("instanceId", next.InstanceID != cur.InstanceID)
+	fixed("identityKey", next.IdentityKey != cur.IdentityKey)
+	fixed("identityExpiryDays", next.IdentityExpiryDays != cur.IdentityExpiryDays)
+	fixed("eventLog", next.EventLog != cur.EventLog)
+	fixed("eventLogMaxMB", next.EventLogMaxMB != cur.EventLogMaxMB)
+	fixed("eventLogKeep", next.EventLogKeep != cur.EventLogKeep)
+	fixed("replays", next.Replays != cur.Replays)
+	fixed("chatSnake", next.ChatSnake.chat() != cur.ChatSnake.chat())
+	fixed("inputLogMinutes", next.InputLogMinutes != cur.InputLogMinutes)
+	fixed("maxRooms", next.MaxRooms != cur.MaxRooms)
+	fixed("minLength", next.MinLength != cur.MinLength)
+	fixed("maxLength", next.MaxLength != cur.MaxLength)
+	errs = append(errs, next.check()...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// syncAICount adds or removes AI snakes after AICount changed.
+func (g *Game) syncAICount(prev int) {
+	if delta := g.cfg.AICount - prev; delta > 0 {
+		g.addAI(delta)
+	} else {
+		g.removeAI(-delta)
+	}
+}
+
+// addAI spawns n AI snakes.
+func (g *Game) addAI(n int) {
+	for ; n > 0; n-- {
+		pos := g.spawnPos(nil)
+		name := g.uniqueName(aiNames[g.rng.Intn(len(aiNames))])
+		ai := g.createSnake(name, pos.X, pos.Y, g.rng.Intn(NumColors), true, nextAIID())
+		extra := g.aiExtraLen()
+		ai.TargetLen += extra
+		ai.Score += extra
+		g.snakes = append(g.snakes, ai)
+	}
+}
+
+// removeAI removes up to n AI snakes, newest first, and returns how many
+// it removed.
+func (g *Game) removeAI(n int) int {
+	removed := 0
+	for i := len(g.snakes) - 1; i >= 0 && removed < n; i-- {
+		if s := g.snakes[i]; s.IsAI && !s.crowd && !s.filler {
+			g.snakes = append(g.snakes[:i], g.snakes[i+1:]...)
+			removed++
+		}
+	}
+	return removed
+}
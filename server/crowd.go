@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Chat snake (crowd play)
+//
+// With ChatSnake.Channel set, the server joins that IRC channel (Twitch chat
+// by default) and the viewers of a stream steer one snake together, the
+// chat snake. A chat message whose first word is left, right or boost
+// (also l, r, b, links, rechts, with or without a "!") is a vote. Votes are
+// counted in windows of ChatSnake.Window ticks that close on tick
+// boundaries, so every window is as long as the next however busy the chat
+// is. Each viewer has one vote per window; the last one counts.
+//
+// When a window closes, the choice with the most votes wins: left and right
+// turn the snake by ChatSnake.Turn degrees, boost boosts it until the next
+// window closes. Ties and windows without votes keep it going straight. The
+// tally goes to every director as a "chatSnake" event, for a stream overlay:
+//
+//	{"t":"chatSnake","choice":"left","votes":{"left":7,"right":3,"boost":1},"voters":11}
+//
+// The chat snake is an AI snake that doesn't think for itself: it respawns
+// like one and turns away from the wall, but only the chat steers it. The
+// connector reads the chat on its own goroutine, reconnecting with backoff,
+// and hands votes to the game loop through a queue; without Nick and Token
+// it logs in anonymously, which is enough to read a Twitch channel.
+// ---------------------------------------------------------------------------
+
+const (
+	DefaultChatSnakeServer = "ircs://irc.chat.twitch.tv:6697"
+	DefaultChatSnakeName   = "Chat"
+	DefaultChatSnakeWindow = 30 // ticks (half a second)
+	DefaultChatSnakeTurn   = 45 // degrees
+	crowdQueueSize         = 256
+	crowdMaxBackoff        = time.Minute
+)
+
+// ChatSnakeConfig sets up the chat snake. It is a secret as a whole (see
+// configSecrets), as it holds the IRC password.
+type ChatSnakeConfig struct {
+	Server  string  `json:"server"`  // irc://host:port or ircs://host:port, "" = Twitch
+	Channel string  `json:"channel"` // channel to read votes from, "" = off
+	Nick    string  `json:"nick"`    // "" = anonymous (read-only on Twitch)
+	Token   string  `json:"token"`   // IRC password, e.g. "oauth:..." on Twitch
+	Name    string  `json:"name"`    // the snake's name, "" = "Chat"
+	Window  int     `json:"window"`  // ticks per vote window, 0 = 30
+	Turn    float64 `json:"turn"`    // degrees per left or right, 0 = 45
+}
+
+func (c *ChatSnakeConfig) enabled() bool { return c.Channel != "" }
+
+// chat returns the fields the connector uses, which are fixed once it runs.
+func (c *ChatSnakeConfig) chat() [4]string {
+	return [4]string{c.Server, c.Channel, c.Nick, c.Token}
+}
+
+func (c *ChatSnakeConfig) window() int {
+	if c.Window <= 0 {
+		return DefaultChatSnakeWindow
+	}
+	return c.Window
+}
+
+func (c *ChatSnakeConfig) turn() float64 {
+	if c.Turn <= 0 {
+		return DefaultChatSnakeTurn * math.Pi / 180
+	}
+	return c.Turn * math.Pi / 180
+}
+
+func (c *ChatSnakeConfig) name() string {
+	if c.Name == "" {
+		return DefaultChatSnakeName
+	}
+	return c.Name
+}
+
+// validate checks the server address and the vote rules.
+func (c *ChatSnakeConfig) validate() error {
+	if c.Server != "" {
+		if _, _, err := chatServerAddr(c.Server); err != nil {
+			return err
+		}
+	}
+	if strings.ContainsAny(c.Channel+c.Nick+c.Token, " \r\n") {
+		return fmt.Errorf("channel, nick and token can't contain spaces or line breaks")
+	}
+	if c.Window < 0 || c.Window > 10*TickRate {
+		return fmt.Errorf("window must be between 0 and %d ticks", 10*TickRate)
+	}
+	if c.Turn < 0 || c.Turn > 180 {
+		return fmt.Errorf("turn must be between 0 and 180 degrees")
+	}
+	return nil
+}
+
+// chatServerAddr splits an irc:// or ircs:// URL into host:port and
+// whether to use TLS.
+func chatServerAddr(s string) (string, bool, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || (u.Scheme != "irc" && u.Scheme != "ircs") {
+		return "", false, fmt.Errorf("server %q should be irc://host:port or ircs://host:port", s)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "6667"
+		if u.Scheme == "ircs" {
+			port = "6697"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return host, u.Scheme == "ircs", nil
+}
+
+type crowdChoice uint8
+
+const (
+	crowdNone crowdChoice = iota
+	crowdLeft
+	crowdRight
+	crowdBoost
+)
+
+var crowdChoiceNames = [...]string{"", "left", "right", "boost"}
+
+// crowdVote is one chat message that was a vote.
+type crowdVote struct {
+	user   string
+	choice crowdChoice
+}
+
+// parseCrowdVote returns the vote in a chat message, crowdNone if there is
+// none.
+func parseCrowdVote(text string) crowdChoice {
+	word, _, _ := strings.Cut(strings.TrimSpace(text), " ")
+	switch strings.ToLower(strings.TrimPrefix(word, "!")) {
+	case "l", "left", "links":
+		return crowdLeft
+	case "r", "right", "rechts":
+		return crowdRight
+	case "b", "boost":
+		return crowdBoost
+	}
+	return crowdNone
+}
+
+// crowdState is the vote window in progress. Game loop only.
+type crowdState struct {
+	votes      map[string]crowdChoice // by chat user
+	totalVotes int64
+}
+
+type chatSnakeEvent struct {
+	Type   string         `json:"t"`      // "chatSnake"
+	Choice string         `json:"choice"` // "left", "right", "boost" or "" (straight)
+	Votes  map[string]int `json:"votes"`
+	Voters int            `json:"voters"`
+}
+
+// handleCrowdVote counts a vote in the current window.
+func (g *Game) handleCrowdVote(v crowdVote) {
+	if g.crowd.votes == nil {
+		g.crowd.votes = make(map[string]crowdChoice)
+	}
+	g.crowd.votes[v.user] = v.choice
+	g.crowd.totalVotes++
+}
+
+// updateChatSnake spawns the chat snake if it is missing and steers it when
+// a vote window closes. Called once per tick.
+func (g *Game) updateChatSnake() {
+	c := &g.cfg.ChatSnake
+	if !c.enabled() {
+		return
+	}
+	s := g.chatSnake()
+	if s == nil {
+		pos := g.spawnPos(nil)
+		s = g.createSnake(g.uniqueName(c.name()), pos.X, pos.Y, g.rng.Intn(NumColors), true, nextAIID())
+		s.crowd = true
+		g.snakes = append(g.snakes, s)
+		g.log.Info("chat snake spawned", "name", s.Name, "channel", c.Channel)
+	}
+	if g.frame%c.window() != 0 {
+		return
+	}
+
+	var counts [len(crowdChoiceNames)]int
+	for _, choice := range g.crowd.votes {
+		counts[choice]++
+	}
+	winner, best := crowdNone, 0
+	for choice := crowdLeft; choice <= crowdBoost; choice++ {
+		switch {
+		case counts[choice] > best:
+			winner, best = choice, counts[choice]
+		case counts[choice] == best:
+			winner = crowdNone // a tie keeps it going straight
+		}
+	}
+	if s.Alive {
+		switch winner {
+		case crowdLeft:
+			s.TargetAngle = s.Angle - c.turn()
+		case crowdRight:
+			s.TargetAngle = s.Angle + c.turn()
+		}
+		s.IsBoosting = winner == crowdBoost
+	}
+	if len(g.crowd.votes) > 0 {
+		ev := chatSnakeEvent{
+			Type: "chatSnake", Choice: crowdChoiceNames[winner], Voters: len(g.crowd.votes),
+			Votes: map[string]int{"left": counts[crowdLeft], "right": counts[crowdRight], "boost": counts[crowdBoost]},
+		}
+		for _, p := range g.dir.viewers {
+			g.sendEvent(p, ev)
+		}
+	}
+	clear(g.crowd.votes)
+}
+
+// chatSnake returns the chat snake, nil if there is none.
+func (g *Game) chatSnake() *Snake {
+	for _, s := range g.snakes {
+		if s.crowd {
+			return s
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// IRC connector
+// ---------------------------------------------------------------------------
+
+// RunChatConnector reads votes from the chat in cfg and queues them for the
+// game, reconnecting whenever the connection drops. It never returns.
+func (g *Game) RunChatConnector(cfg ChatSnakeConfig) {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := g.readChat(cfg)
+		if time.Since(start) > crowdMaxBackoff {
+			backoff = time.Second // it ran for a while, so try again soon
+		}
+		g.log.Warn("chat connection lost, reconnecting", "channel", cfg.Channel, "err", err, "in", backoff)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, crowdMaxBackoff)
+	}
+}
+
+// readChat connects to the chat and reads votes until the connection fails.
+func (g *Game) readChat(cfg ChatSnakeConfig) error {
+	server := cfg.Server
+	if server == "" {
+		server = DefaultChatSnakeServer
+	}
+	addr, useTLS, err := chatServerAddr(server)
+	if err != nil {
+		return err
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if useTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, nil)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	nick := cfg.Nick
+	if nick == "" {
+		nick = fmt.Sprintf("justinfan%d", 10000+rand.Intn(90000)) // Twitch's anonymous login
+	}
+	channel := "#" + strings.ToLower(strings.TrimPrefix(cfg.Channel, "#"))
+	if cfg.Token != "" {
+		fmt.Fprintf(conn, "PASS %s\r\n", cfg.Token)
+	}
+	fmt.Fprintf(conn, "NICK %s\r\nJOIN %s\r\n", nick, channel)
+	g.log.Info("chat connected", "server", addr, "channel", channel, "nick", nick)
+
+	r := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(6 * time.Minute)) // Twitch pings every 5
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "@") { // IRCv3 tags
+			_, line, _ = strings.Cut(line, " ")
+		}
+		if rest, ok := strings.CutPrefix(line, "PING "); ok {
+			fmt.Fprintf(conn, "PONG %s\r\n", rest)
+			continue
+		}
+		user, text, ok := parsePrivmsg(line)
+		if !ok {
+			continue
+		}
+		if choice := parseCrowdVote(text); choice != crowdNone {
+			select {
+			case g.crowdCh <- crowdVote{user: user, choice: choice}:
+			default: // the game is behind; a vote more or less doesn't matter
+			}
+		}
+	}
+}
+
+// parsePrivmsg returns the sender and text of a line like
+// ":nick!user@host PRIVMSG #channel :text".
+func parsePrivmsg(line string) (string, string, bool) {
+	prefix, rest, ok := strings.Cut(line, " ")
+	if !ok || !strings.HasPrefix(prefix, ":") {
+		return "", "", false
+	}
+	cmd, rest, ok := strings.Cut(rest, " ")
+	if !ok || cmd != "PRIVMSG" {
+		return "", "", false
+	}
+	_, text, ok := strings.Cut(rest, " :")
+	if !ok {
+		return "", "", false
+	}
+	nick, _, _ := strings.Cut(prefix[1:], "!")
+	return strings.ToLower(nick), text, true
+}
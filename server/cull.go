@@ -0,0 +1,52 @@
+package main
+
+import "math"
+
+// ---------------------------------------------------------------------------
+// Segment culling (protocol v8)
+//
+// Frames carry every segmentStride()-th body segment of each visible snake, so a very long
+// snake, most of all the player's own, sends its whole body every frame even
+// while most of it is far off-screen. v8 frames leave out the sampled points
+// more than SegmentViewDist from the camera on either axis. The head is always
+// sent, and so is the first point outside the box on each side of a visible
+// run, so the body still reaches the screen edge. The box grows as the
+// camera zooms out (see zoom.go). Each culled run is replaced
+// by a skip marker with its length, which keeps the segment count and the
+// index of every point, so clients interpolate frames point by point as
+// before.
+// ---------------------------------------------------------------------------
+
+// SegmentViewDist is the half size of the box around the camera whose body
+// points v8 frames send; the client's food view (FoodViewDist) plus a margin
+// for zooming out.
+const SegmentViewDist = FoodViewDist + 300
+
+// segmentCuller decides which sampled points (every stride-th segment) of
+// a snake a v8 frame sends.
+type segmentCuller struct {
+	segs   []Vec2
+	stride int
+	cx, cy float64
+	dist   float64 // half size of the box, SegmentViewDist at zoom 1
+	off    bool    // send everything (protocols before v8)
+	drop   int     // point left out anyway, 0 = none (a portal jump, see portal.go)
+}
+
+// inside reports whether sampled point k lies in the box around the camera.
+func (c segmentCuller) inside(k int) bool {
+	j := k * c.stride
+	if j < 0 || j >= len(c.segs) {
+		return false
+	}
+	return math.Abs(c.segs[j].X-c.cx) < c.dist && math.Abs(c.segs[j].Y-c.cy) < c.dist
+}
+
+// keep reports whether sampled point k is sent: the head, points in the box
+// and their neighbours along the body, but not the drop point.
+func (c segmentCuller) keep(k int) bool {
+	if k > 0 && k == c.drop {
+		return false
+	}
+	return c.off || k == 0 || c.inside(k) || c.inside(k-1) || c.inside(k+1)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+)
+
+// ---------------------------------------------------------------------------
+// Profiling endpoints (/debug/pprof/*), admin token required
+//
+// /debug/pprof/trace?seconds=N captures an execution trace for N seconds.
+// Registered on our own mux: importing net/http/pprof also registers the
+// handlers on http.DefaultServeMux, which is never served.
+// ---------------------------------------------------------------------------
+
+func registerDebugHandlers(mux *http.ServeMux, adminToken string) {
+	if adminToken == "" {
+		slog.Warn("profiling endpoints disabled: -pprof requires an admin token")
+		return
+	}
+	mux.Handle("/debug/pprof/", adminOnly(adminToken, http.HandlerFunc(pprof.Index)))
+	mux.Handle("/debug/pprof/cmdline", adminOnly(adminToken, http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle("/debug/pprof/profile", adminOnly(adminToken, http.HandlerFunc(pprof.Profile)))
+	mux.Handle("/debug/pprof/symbol", adminOnly(adminToken, http.HandlerFunc(pprof.Symbol)))
+	mux.Handle("/debug/pprof/trace", adminOnly(adminToken, http.HandlerFunc(pprof.Trace)))
+	slog.Info("profiling endpoints enabled", "path", "/debug/pprof/")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+)
+
+// ---------------------------------------------------------------------------
+// Length decay: a soft cap for runaway leaders
+//
+// Once a second, snakes longer than DecayThreshold lose DecayRate of the
+// length above the threshold (at least 1), together with the same amount
+// of score. With DecayDropFood the lost length is dropped behind the tail
+// as food instead of vanishing.
+// ---------------------------------------------------------------------------
+
+func (g *Game) decayEnabled() bool {
+	return g.cfg.DecayThreshold > 0 && g.cfg.DecayRate > 0
+}
+
+// decaying reports whether s is currently above the decay threshold.
+func (g *Game) decaying(s *Snake) bool {
+	return g.decayEnabled() && s.Alive && s.TargetLen > g.cfg.DecayThreshold
+}
+
+// updateDecay shrinks oversized snakes. Called from simulate.
+func (g *Game) updateDecay() {
+	if !g.decayEnabled() || !g.every(TickRate) {
+		return
+	}
+	for _, s := range g.snakes {
+		if !g.decaying(s) {
+			continue
+		}
+		excess := s.TargetLen - g.cfg.DecayThreshold
+		loss := int(math.Ceil(float64(excess) * g.cfg.DecayRate))
+		if loss > excess {
+			loss = excess
+		}
+		s.TargetLen -= loss
+		s.Score -= loss
+		if s.Score < 0 {
+			s.Score = 0
+		}
+		if g.cfg.DecayDropFood {
+			g.dropDecayFood(s, loss)
+		}
+		g.log.Debug("snake decayed", append(snakeAttrs(s), "loss", loss)...)
+	}
+}
+
+// dropDecayFood scatters food worth amount along the end of s's body.
+func (g *Game) dropDecayFood(s *Snake, amount int) {
+	n := (amount + 2) / 3 // pellets worth up to 3 each
+	value := float64(amount) / float64(n)
+	for i := 0; i < n; i++ {
+		k := len(s.Segments) - 1 - i*3
+		if k < 0 {
+			k = 0
+		}
+		seg := s.Segments[k]
+		g.addFood(&Food{
+			X: seg.X + g.rng.Float64()*20 - 10, Y: seg.Y + g.rng.Float64()*20 - 10,
+			ColorIdx: g.rng.Intn(NumFoodColors),
+			Radius:   FoodRadiusVal + value,
+			Value:    value,
+		})
+	}
+}
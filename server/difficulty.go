@@ -0,0 +1,128 @@
+package main
+
+import "math"
+
+// ---------------------------------------------------------------------------
+// Dynamic difficulty
+//
+// With one player in the world, the bots are all the opposition there is,
+// and the same bots that make a busy server lively can be too much for one
+// beginner, or too little for a good player. So while exactly one player is
+// connected, the server tunes the bots to how that player is doing, once a
+// second:
+//
+//   - difficultyEasier or more deaths within difficultyWindow: a step easier
+//   - no death for a whole window: a step harder
+//   - anything in between: no change
+//
+// The difficulty is a scalar from MinDifficulty to MaxDifficulty, 1 being
+// the bots as configured, and moves by difficultyStep a second, so a
+// change takes effect over a minute or so. It scales:
+//
+//   - aggression: how often a built-in bot goes hunting (scripted bots
+//     decide for themselves)
+//   - count: up to difficultyAIShare of AICount bots more or fewer, added
+//     or removed one a second
+//   - size: the random extra length new bots spawn with
+//
+// When a second player joins, or the player leaves, it drifts back to 1 the
+// same way and the player's record is forgotten. FixedDifficulty opts out.
+// /stats reports the current value as "difficulty".
+// ---------------------------------------------------------------------------
+
+const (
+	MinDifficulty     = 0.5
+	MaxDifficulty     = 1.5
+	difficultyStep    = 0.01              // per second
+	difficultyWindow  = 3 * 60 * TickRate // game ticks
+	difficultyEasier  = 3                 // deaths within the window
+	difficultyAIShare = 0.5               // of AICount at Min/MaxDifficulty
+	aiHuntShare       = 0.2               // of state changes, at difficulty 1
+	aiMaxExtraLen     = 40                // random extra length at difficulty 1
+)
+
+// difficultyState tracks the solo player's record. Game loop only.
+type difficultyState struct {
+	offset   float64 // difficulty - 1, so the zero value is neutral
+	playerID int     // the solo player, 0 = none
+	since    int     // game clock when they became the solo player
+	deaths   []int   // game clock of their deaths within the window
+	lastDead *Snake  // their last dead snake, counted once
+	aiDelta  int     // AI snakes added (or removed, if negative) for the difficulty
+}
+
+// difficulty returns the current difficulty scalar.
+func (g *Game) difficulty() float64 {
+	return 1 + g.diff.offset
+}
+
+// updateDifficulty tunes the difficulty to the solo player's deaths and the
+// bot count to the difficulty. Called once a second.
+func (g *Game) updateDifficulty() {
+	d := &g.diff
+	var solo *Player
+	if len(g.players) == 1 && !g.cfg.FixedDifficulty {
+		for _, p := range g.players {
+			solo = p
+		}
+	}
+
+	switch {
+	case solo == nil:
+		d.playerID, d.deaths, d.lastDead = 0, d.deaths[:0], nil
+		d.offset = towardZero(d.offset, difficultyStep)
+	case solo.id != d.playerID:
+		d.playerID, d.since, d.deaths, d.lastDead = solo.id, g.clock, d.deaths[:0], nil
+	default:
+		if s := solo.snake; s != nil && !s.Alive && s != d.lastDead {
+			d.lastDead = s
+			d.deaths = append(d.deaths, g.clock)
+		}
+		kept := d.deaths[:0]
+		for _, at := range d.deaths {
+			if g.clock-at < difficultyWindow {
+				kept = append(kept, at)
+			}
+		}
+		d.deaths = kept
+		switch {
+		case len(d.deaths) >= difficultyEasier:
+			d.offset = max(d.offset-difficultyStep, MinDifficulty-1)
+		case len(d.deaths) == 0 && g.clock-d.since >= difficultyWindow:
+			d.offset = min(d.offset+difficultyStep, MaxDifficulty-1)
+		}
+	}
+
+	// One bot a second toward the share of AICount for this difficulty,
+	// unless load shedding has taken bots out.
+	if g.wd.level >= degradeAI {
+		return
+	}
+	want := int(math.Round(float64(g.cfg.AICount) * difficultyAIShare * d.offset / (MaxDifficulty - 1)))
+	switch {
+	case want > d.aiDelta:
+		g.addAI(1)
+		d.aiDelta++
+	case want < d.aiDelta:
+		d.aiDelta -= g.removeAI(1)
+	}
+}
+
+// towardZero moves v toward 0 by step without passing it.
+func towardZero(v, step float64) float64 {
+	if v > 0 {
+		return max(v-step, 0)
+	}
+	return min(v+step, 0)
+}
+
+// aiHunts reports whether a built-in bot picking its next state with the
+// random number r goes hunting.
+func (g *Game) aiHunts(r float64) bool {
+	return r >= 1-aiHuntShare*g.difficulty()
+}
+
+// aiExtraLen returns the random extra length of a new AI snake.
+func (g *Game) aiExtraLen() int {
+	return int(float64(g.rng.Intn(aiMaxExtraLen)) * g.difficulty())
+}
@@ -0,0 +1,147 @@
+package main
+
+// ---------------------------------------------------------------------------
+// Director (observer connections for casting)
+//
+// A connection that sends {"t":"director","token":"<admin token>"} instead
+// of a join gets no snake. It watches the whole world: its frames skip the
+// viewport filter and segment culling, so a stream or TV display can show
+// any part of the map, and it gets them every DirectorSendEvery net ticks
+// to keep those large frames affordable. It also gets the usual events.
+//
+// The server suggests what to watch. By default the camera follows the
+// leader, switching at most every directorMinHold. A kill of a snake of at
+// least directorKillLen segments cuts to the killer for directorKillHold,
+// and the death of the followed snake moves on at once. Each change goes to
+// every director as a "director" event, and the frames are centred on the
+// suggested snake like a spectator's.
+// ---------------------------------------------------------------------------
+
+const (
+	DirectorSendEvery = 3 // net ticks per director frame (10 Hz)
+
+	directorMinHold  = 5 * TickRate // ticks on a leader before switching to a new one
+	directorKillHold = 8 * TickRate // ticks on a killer after a notable kill
+	directorKillLen  = 60           // victim length that makes a kill notable
+)
+
+type directorEvent struct {
+	Type         string `json:"t"` // "director"
+	Camera       int    `json:"camera"`
+	CameraEntity uint32 `json:"cameraEntity"`
+	Name         string `json:"name"`
+	Reason       string `json:"reason"` // "leader" or "kill"
+}
+
+// director is the shared camera of all director connections.
+type director struct {
+	viewers map[int]*Player
+	target  *Snake
+	id      uint32 // target's entity ID; respawnAI reuses Snake values
+	reason  string
+	since   int // frame the target was picked
+	hold    int // frame until which a kill shot is kept
+}
+
+// handleDirector adds an observer connection.
+func (g *Game) handleDirector(p *Player) {
+	p.director = true
+	g.dir.viewers[p.id] = p
+	g.log.Info("director joined", "playerID", p.id, "format", p.serializer.Name(), "directors", len(g.dir.viewers))
+	g.sendInit(p)
+	if g.directorTargetGone() {
+		g.directorCut(g.leader(), "leader")
+	} else {
+		p.setCamera(g.dir.target)
+		g.sendEvent(p, g.directorEvent())
+	}
+
+	data, sections := g.initialStateFor(p)
+	select {
+	case p.sendCh <- data:
+		g.queueSections(p, sections)
+	default:
+	}
+}
+
+// leaveDirector removes an observer connection, reporting whether id was one.
+func (g *Game) leaveDirector(id int) bool {
+	if _, ok := g.dir.viewers[id]; !ok {
+		return false
+	}
+	delete(g.dir.viewers, id)
+	g.log.Info("director left", "playerID", id, "directors", len(g.dir.viewers))
+	return true
+}
+
+// updateDirector follows the leader when nothing else is going on. Called
+// once a second.
+func (g *Game) updateDirector() {
+	if len(g.dir.viewers) == 0 {
+		return
+	}
+	if g.directorTargetGone() {
+		g.directorCut(g.leader(), "leader")
+		return
+	}
+	if g.frame < g.dir.hold || g.frame-g.dir.since < directorMinHold {
+		return
+	}
+	if l := g.leader(); l != nil && l != g.dir.target {
+		g.directorCut(l, "leader")
+	}
+}
+
+// directorKill cuts to the killer after a notable kill, or away from a
+// followed snake that died. Called from reportDeath.
+func (g *Game) directorKill(victim, killer *Snake) {
+	if len(g.dir.viewers) == 0 {
+		return
+	}
+	followed := victim == g.dir.target && victim.id == g.dir.id
+	if killer != nil && killer.Alive && (len(victim.Segments) >= directorKillLen || followed) {
+		g.directorCut(killer, "kill")
+		g.dir.hold = g.frame + directorKillHold
+		return
+	}
+	if followed {
+		g.directorCut(g.leader(), "leader")
+	}
+}
+
+func (g *Game) directorTargetGone() bool {
+	t := g.dir.target
+	return t == nil || !t.Alive || t.id != g.dir.id
+}
+
+// directorCut points every director at s and tells them.
+func (g *Game) directorCut(s *Snake, reason string) {
+	if s == nil || (s == g.dir.target && s.id == g.dir.id) {
+		return
+	}
+	g.dir.target, g.dir.id, g.dir.reason, g.dir.since = s, s.id, reason, g.frame
+	ev := g.directorEvent()
+	for _, p := range g.dir.viewers {
+		p.setCamera(s)
+		g.sendEvent(p, ev)
+	}
+}
+
+func (g *Game) directorEvent() directorEvent {
+	s := g.dir.target
+	if s == nil {
+		return directorEvent{Type: "director"}
+	}
+	return directorEvent{Type: "director", Camera: s.PlayerID, CameraEntity: s.id, Name: s.Name, Reason: g.dir.reason}
+}
+
+// leader returns the living snake with the highest score.
+func (g *Game) leader() *Snake {
+	var best *Snake
+	for _, s := range g.snakes {
+		if s.Alive && len(s.Segments) > 0 && (best == nil || s.Score > best.Score) {
+			best = s
+		}
+	}
+	return best
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ---------------------------------------------------------------------------
+// Environment configuration
+//
+// For container deployments every config key and every flag can be set with
+// a SCHLANGEN_* environment variable, so the server can be configured
+// without templating a config file. The name is the key or flag in upper
+// snake case: aiCount and -ai-count are both SCHLANGEN_AI_COUNT.
+//
+// Every variable sets exactly one thing. When a flag and a config key share
+// a name, they are the same setting and the variable goes to the config
+// key. A flag that means something else than the key of its name gets its
+// own name in flagEnvNames: -name-blocklist, a file, is
+// SCHLANGEN_NAME_BLOCKLIST_FILE, as SCHLANGEN_NAME_BLOCKLIST is the
+// nameBlocklist word list. checkEnvNames stops the server at startup if
+// two keys, two flags, or a flag and a key of different types share a name.
+//
+// Settings are layered: defaults < config file < environment < flags.
+// Strings are taken as they are, numbers and booleans as in JSON, and list,
+// map and object keys (allowedOrigins, oauth, rotation, ...) as JSON; lists
+// of strings may also be comma-separated.
+// ---------------------------------------------------------------------------
+
+const envPrefix = "SCHLANGEN_"
+
+// envName returns the environment variable of a flag or config key.
+func envName(name string) string {
+	var b strings.Builder
+	b.WriteString(envPrefix)
+	rs := []rune(name)
+	for i, r := range rs {
+		switch {
+		case r == '-':
+			b.WriteByte('_')
+			continue
+		case i > 0 && unicode.IsUpper(r) &&
+			(unicode.IsLower(rs[i-1]) || unicode.IsDigit(rs[i-1]) || i+1 < len(rs) && unicode.IsLower(rs[i+1])):
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// flagEnvNames are the environment variables of the flags whose envName
+// is a config key with a different meaning.
+var flagEnvNames = map[string]string{
+	"name-blocklist": envPrefix + "NAME_BLOCKLIST_FILE",
+}
+
+// flagEnvName returns the environment variable of a flag.
+func flagEnvName(name string) string {
+	if env, ok := flagEnvNames[name]; ok {
+		return env
+	}
+	return envName(name)
+}
+
+// jsonKey returns the JSON key of a GameConfig field.
+func jsonKey(f reflect.StructField) string {
+	key, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return key
+}
+
+// configEnvKeys maps environment variables to the GameConfig fields they
+// set.
+func configEnvKeys() map[string]reflect.StructField {
+	keys := make(map[string]reflect.StructField)
+	t := reflect.TypeOf(GameConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if key := jsonKey(f); key != "" && key != "-" {
+			keys[envName(key)] = f
+		}
+	}
+	return keys
+}
+
+// configFromEnv applies the config keys set in the environment to cfg and
+// returns the variables it used.
+func configFromEnv(cfg *GameConfig) ([]string, error) {
+	var used []string
+	for name, f := range configEnvKeys() {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		key := jsonKey(f)
+		raw, err := envJSON(f.Type, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if err := json.Unmarshal([]byte(`{"`+key+`":`+raw+`}`), cfg); err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s: %v", v, name, err)
+		}
+		used = append(used, name)
+	}
+	sort.Strings(used)
+	return used, nil
+}
+
+// envJSON returns the JSON for the value v of a field of type t.
+func envJSON(t reflect.Type, v string) (string, error) {
+	switch {
+	case t.Kind() == reflect.String:
+		data, err := json.Marshal(v)
+		return string(data), err
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(v), "["):
+		list := []string{}
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		data, err := json.Marshal(list)
+		return string(data), err
+	}
+	return v, nil
+}
+
+// flagsFromEnv sets every flag that isn't on the command line from its
+// environment variable, if that is set and isn't a config key's. Flags set
+// this way count as set for flagSet.
+func flagsFromEnv() error {
+	onCmdLine := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { onCmdLine[f.Name] = true })
+	keys := configEnvKeys()
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		name := flagEnvName(f.Name)
+		if _, isKey := keys[name]; isKey || onCmdLine[f.Name] || err != nil {
+			return
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			if serr := flag.Set(f.Name, v); serr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %v", v, name, serr)
+			}
+		}
+	})
+	return err
+}
+
+// checkEnvNames returns an error if an environment variable would set two
+// config keys or two flags, or a config key and a flag that take different
+// types of values.
+func checkEnvNames() error {
+	keys := make(map[string]string)
+	t := reflect.TypeOf(GameConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		key := jsonKey(t.Field(i))
+		if key == "" || key == "-" {
+			continue
+		}
+		if other, ok := keys[envName(key)]; ok {
+			return fmt.Errorf("config keys %s and %s are both %s", other, key, envName(key))
+		}
+		keys[envName(key)] = key
+	}
+	fields := configEnvKeys()
+	flags := make(map[string]string)
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		name := flagEnvName(f.Name)
+		if other, ok := flags[name]; ok && err == nil {
+			err = fmt.Errorf("flags -%s and -%s are both %s", other, f.Name, name)
+		}
+		flags[name] = f.Name
+		if field, ok := fields[name]; ok && err == nil && !sameEnvType(f, field.Type) {
+			err = fmt.Errorf("flag -%s and config key %s are both %s but take different values", f.Name, keys[name], name)
+		}
+	})
+	return err
+}
+
+// sameEnvType reports whether the flag f takes the same values from the
+// environment as a config field of type t.
+func sameEnvType(f *flag.Flag, t reflect.Type) bool {
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	ft := reflect.TypeOf(getter.Get())
+	switch {
+	case ft.Kind() == t.Kind():
+		return true
+	case ft.Kind() == reflect.String:
+		return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String // comma list
+	}
+	return false
+}
+
+// unknownEnv returns the SCHLANGEN_* variables that match neither a config
+// key nor a flag, which are most likely typos.
+func unknownEnv() []string {
+	known := configEnvKeys()
+	flags := make(map[string]bool)
+	flag.VisitAll(func(f *flag.Flag) { flags[flagEnvName(f.Name)] = true })
+	var unknown []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, ok := known[name]; strings.HasPrefix(name, envPrefix) && !ok && !flags[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
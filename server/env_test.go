@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestEnvKeys sets the variable of every scalar config key on its own and
+// checks that it changes that GameConfig field and no other.
+func TestEnvKeys(t *testing.T) {
+	if err := checkEnvNames(); err != nil {
+		t.Fatal(err)
+	}
+	def := DefaultConfig()
+	dv := reflect.ValueOf(def)
+	for name, f := range configEnvKeys() {
+		var v string
+		switch old := dv.FieldByIndex(f.Index); old.Kind() {
+		case reflect.Int, reflect.Int64:
+			v = strconv.FormatInt(old.Int()+1, 10)
+		case reflect.Float64:
+			v = strconv.FormatFloat(old.Float()+0.5, 'g', -1, 64)
+		case reflect.Bool:
+			v = strconv.FormatBool(!old.Bool())
+		case reflect.String:
+			v = old.String() + "x"
+		default:
+			continue // lists and objects are JSON
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, v)
+			cfg := DefaultConfig()
+			used, err := configFromEnv(&cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(used, []string{name}) {
+				t.Errorf("used %v", used)
+			}
+			cv := reflect.ValueOf(cfg)
+			for i := 0; i < cv.NumField(); i++ {
+				changed := !reflect.DeepEqual(cv.Field(i).Interface(), dv.Field(i).Interface())
+				if field := cv.Type().Field(i); changed != (field.Name == f.Name) {
+					t.Errorf("%s=%s: %s changed %v, want %v", name, v, field.Name, changed, !changed)
+				}
+			}
+		})
+	}
+}
+
+// TestEnvPrecedence layers a config the way main does and checks that a
+// flag beats the environment, which beats the config file, which beats
+// the defaults, for a config key (aiCount) and a flag without one
+// (-drain-timeout).
+func TestEnvPrecedence(t *testing.T) {
+	def := DefaultConfig().AICount
+	tests := []struct {
+		file  string
+		env   bool
+		args  []string
+		ai    int
+		drain time.Duration
+	}{
+		{ai: def, drain: DefaultDrainTimeout},
+		{file: `{"aiCount": 11}`, ai: 11, drain: DefaultDrainTimeout},
+		{file: `{"aiCount": 11}`, env: true, ai: 22, drain: 22 * time.Second},
+		{env: true, ai: 22, drain: 22 * time.Second},
+		{file: `{"aiCount": 11}`, env: true, args: []string{"-ai-count", "33", "-drain-timeout", "33s"}, ai: 33, drain: 33 * time.Second},
+		{file: `{"aiCount": 11}`, args: []string{"-ai-count", "0"}, ai: 0, drain: DefaultDrainTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("file=%q,env=%v,args=%v", tt.file, tt.env, tt.args), func(t *testing.T) {
+			if tt.env {
+				t.Setenv("SCHLANGEN_AI_COUNT", "22")
+				t.Setenv("SCHLANGEN_DRAIN_TIMEOUT", "22s")
+			}
+			saved := flag.CommandLine
+			t.Cleanup(func() { flag.CommandLine = saved })
+			flag.CommandLine = flag.NewFlagSet("snake-server", flag.ContinueOnError)
+			aiCount := flag.Int("ai-count", 0, "")
+			drainTimeout := flag.Duration("drain-timeout", DefaultDrainTimeout, "")
+			if err := flag.CommandLine.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := flagsFromEnv(); err != nil {
+				t.Fatal(err)
+			}
+			cfg := DefaultConfig()
+			if tt.file != "" {
+				if err := json.Unmarshal([]byte(tt.file), &cfg); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := configFromEnv(&cfg); err != nil {
+				t.Fatal(err)
+			}
+			if flagSet("ai-count") {
+				cfg.AICount = *aiCount
+			}
+			if cfg.AICount != tt.ai || *drainTimeout != tt.drain {
+				t.Errorf("aiCount %d, drain timeout %v; want %d, %v", cfg.AICount, *drainTimeout, tt.ai, tt.drain)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// ---------------------------------------------------------------------------
+// Game event log (NDJSON)
+//
+// With EventLog set, the server appends one JSON object per line to that
+// file for every join, leave, death, finished round, config change and mode
+// switch. It is meant for offline analytics (jq, DuckDB, a notebook) without
+// running the storage layer. Every line has "ts" and "type"; the other
+// fields use the names of the server log (see logging.go):
+//
+//   join    playerID, name, identity, beginner, format
+//   leave   playerID, name, score, kills
+//   death   snakeID, name, score, length, kills, ai, cause and, when another
+//           snake was involved, killerID, killer, killerAI; assists lists
+//           the snakes credited with an assist (see assist.go)
+//   round   round, startedAt, podium (name, score, isAI...), snakes
+//   config  changes: the changed config fields with their new values
+//   mode    mode, from, votes, voters
+//
+// The game loop only formats the line; a writer goroutine appends it, so a
+// slow disk never stalls a tick. When the writer falls behind by
+// eventLogQueueSize lines, further lines are dropped and counted in the log.
+// Once the file reaches EventLogMaxMB it is renamed to <path>.1 (the older
+// ones to .2 and so on, up to EventLogKeep) and a new file is started.
+// ---------------------------------------------------------------------------
+
+const (
+	DefaultEventLogMaxMB = 100
+	DefaultEventLogKeep  = 5
+	eventLogQueueSize    = 4096
+)
+
+// Config fields never written to the event log or shown by /admin/config
+// (see configview.go). Only their names are logged.
+var configSecrets = map[string]bool{"adminToken": true, "identityKey": true, "oauth": true, "chatSnake": true}
+
+type eventLog struct {
+	log     *slog.Logger
+	ch      chan []byte
+	dropped int
+}
+
+// Write queues one encoded line. Called by the slog handler on the game loop.
+func (l *eventLog) Write(b []byte) (int, error) {
+	select {
+	case l.ch <- append([]byte(nil), b...):
+	default:
+		l.dropped++
+		if l.dropped&(l.dropped-1) == 0 {
+			slog.Warn("event log queue full, dropping events", "dropped", l.dropped)
+		}
+	}
+	return len(b), nil
+}
+
+// EnableEventLog opens path for appending and starts the writer. Must be
+// called before Run.
+func (g *Game) EnableEventLog(path string, maxMB, keep int) error {
+	f, err := openRotatingFile(path, int64(maxMB)<<20, keep)
+	if err != nil {
+		return err
+	}
+	l := &eventLog{ch: make(chan []byte, eventLogQueueSize)}
+	l.log = slog.New(slog.NewJSONHandler(l, &slog.HandlerOptions{ReplaceAttr: eventLogAttr}))
+	g.evlog = l
+	g.writers.Add(1)
+	go func() {
+		defer g.writers.Done()
+		for b := range l.ch {
+			if err := f.write(b, len(l.ch) == 0); err != nil {
+				g.log.Error("event log write failed", "path", path, "err", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// eventLogAttr turns slog's time/level/msg into "ts" and "type".
+func eventLogAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey:
+		a.Key = "ts"
+	case slog.LevelKey:
+		return slog.Attr{}
+	case slog.MessageKey:
+		a.Key = "type"
+	}
+	return a
+}
+
+// logEvent appends a line of type typ with the given key-value pairs.
+func (g *Game) logEvent(typ string, args ...any) {
+	if g.evlog == nil {
+		return
+	}
+	g.evlog.log.Log(context.Background(), slog.LevelInfo, typ, args...)
+}
+
+func (g *Game) logJoin(p *Player) {
+	g.logEvent("join", "playerID", p.id, "name", p.name, "identity", p.identity,
+		"beginner", p.beginner, "format", p.serializer.Name())
+}
+
+func (g *Game) logLeave(p *Player) {
+	args := []any{"playerID", p.id, "name", p.name}
+	if p.snake != nil {
+		args = append(args, "score", p.snake.Score, "kills", p.snake.kills)
+	}
+	g.logEvent("leave", args...)
+}
+
+// logDeath records a death; killer is nil for boundary deaths.
+func (g *Game) logDeath(victim, killer *Snake, cause string) {
+	if g.evlog == nil {
+		return
+	}
+	args := append(snakeAttrs(victim), "length", len(victim.Segments), "kills", victim.kills,
+		"ai", victim.IsAI, "cause", cause)
+	if killer != nil {
+		args = append(args, "killerID", killer.PlayerID, "killer", killer.Name, "killerAI", killer.IsAI)
+	}
+	if len(victim.assistedBy) > 0 {
+		args = append(args, "assists", assistNames(victim))
+	}
+	g.logEvent("death", args...)
+}
+
+func (g *Game) logRound(res RoundResults) {
+	g.logEvent("round", "round", res.Round, "startedAt", g.round.startedAt,
+		"podium", res.Podium, "snakes", len(res.Standings))
+}
+
+func (g *Game) logMode(from string, votes, voters int) {
+	g.logEvent("mode", "mode", g.modeName(), "from", from, "votes", votes, "voters", voters)
+}
+
+// logConfig records the fields that differ between prev and the live config.
+func (g *Game) logConfig(prev GameConfig) {
+	if g.evlog == nil {
+		return
+	}
+	if changes := configChanges(prev, g.cfg); len(changes) > 0 {
+		g.logEvent("config", "changes", changes)
+	}
+}
+
+// configChanges returns the changed fields of a config by JSON name, with
+// secrets replaced by "redacted".
+func configChanges(prev, next GameConfig) map[string]any {
+	var a, b map[string]json.RawMessage
+	if err := unmarshalConfig(prev, &a); err != nil {
+		return nil
+	}
+	if err := unmarshalConfig(next, &b); err != nil {
+		return nil
+	}
+	changes := make(map[string]any)
+	for k, v := range b {
+		if bytes.Equal(a[k], v) {
+			continue
+		}
+		if configSecrets[k] {
+			changes[k] = "redacted"
+		} else {
+			changes[k] = v
+		}
+	}
+	return changes
+}
+
+func unmarshalConfig(cfg GameConfig, m *map[string]json.RawMessage) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, m)
+}
+
+// rotatingFile is an append-only file that moves itself aside at maxBytes.
+// Only the writer goroutine uses it.
+type rotatingFile struct {
+	path     string
+	maxBytes int64
+	keep     int
+	f        *os.File
+	w        *bufio.Writer
+	size     int64
+}
+
+func openRotatingFile(path string, maxBytes int64, keep int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxBytes: maxBytes, keep: keep}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.w, r.size = f, bufio.NewWriter(f), st.Size()
+	return nil
+}
+
+// write appends b, rotating first if it would cross maxBytes, and flushes
+// when flush is set (the queue is empty).
+func (r *rotatingFile) write(b []byte, flush bool) error {
+	if r.size > 0 && r.size+int64(len(b)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.w.Write(b)
+	r.size += int64(n)
+	if err == nil && flush {
+		err = r.w.Flush()
+	}
+	return err
+}
+
+// rotate closes the file, shifts <path>.N up by one (dropping the oldest)
+// and starts a new file.
+func (r *rotatingFile) rotate() error {
+	r.w.Flush()
+	r.f.Close()
+	os.Remove(fmt.Sprintf("%s.%d", r.path, r.keep))
+	for i := r.keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if r.keep > 0 {
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			slog.Warn("event log rotation failed", "path", r.path, "err", err)
+		}
+	} else {
+		os.Remove(r.path)
+	}
+	return r.open()
+}
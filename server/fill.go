@@ -0,0 +1,152 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Filler bots
+//
+// AICount spreads bots over the whole world, so a lone player in a big
+// world can go minutes without meeting anyone. With FillBots, the server
+// checks once a second that every living player has at least FillBots
+// other living snakes with their heads within FillRadius, and if not,
+// spawns filler bots into the emptiest of a few spots just outside that
+// player's view. Nearby snakes are looked up in the shard grid when the
+// world is sharded.
+//
+// Filler bots are ordinary AI snakes, except that they don't respawn: a
+// dead filler bot is removed, and so is one with no player within twice
+// FillRadius, so the world only holds them where someone is playing. At
+// most MaxFillBots exist at a time.
+// ---------------------------------------------------------------------------
+
+const (
+	DefaultFillRadius = 1500
+	MaxFillBots       = 64
+	fillSamples       = 8   // candidate spots per filler bot
+	fillSpawnMin      = 0.7 // of FillRadius: spawn outside the player's view
+)
+
+// updateFill removes filler bots that aren't needed any more and spawns
+// new ones near players with too few snakes around. Called once a second.
+func (g *Game) updateFill() {
+	if g.cfg.FillBots <= 0 {
+		g.removeFillers(func(*Snake) bool { return true })
+		return
+	}
+	var heads []Vec2
+	for _, p := range g.sortedPlayers() {
+		if p.snake != nil && p.snake.Alive {
+			heads = append(heads, p.snake.Segments[0])
+		}
+	}
+	far := 2 * g.cfg.FillRadius
+	g.removeFillers(func(s *Snake) bool {
+		if !s.Alive {
+			return true
+		}
+		for _, h := range heads {
+			if sim.DistSq(h.X, h.Y, s.Segments[0].X, s.Segments[0].Y) < far*far {
+				return false
+			}
+		}
+		return true
+	})
+
+	count := g.fillerCount()
+	for _, h := range heads {
+		missing := g.cfg.FillBots - (len(g.snakesNear(h, g.cfg.FillRadius)) - 1) // not counting the player
+		for ; missing > 0 && count < MaxFillBots; missing-- {
+			pos := g.fillSpawnPos(h)
+			name := g.uniqueName(aiNames[g.rng.Intn(len(aiNames))])
+			s := g.createSnake(name, pos.X, pos.Y, g.rng.Intn(NumColors), true, nextAIID())
+			s.filler = true
+			g.snakes = append(g.snakes, s)
+			g.bodyDensity().Add(s.Segments, 1) // spread the next ones out
+			count++
+			g.log.Debug("filler bot spawned", "name", name, "x", int(pos.X), "y", int(pos.Y))
+		}
+	}
+}
+
+// removeFillers removes the filler bots for which drop is true. They are
+// marked dead, so a bounty or pack still pointing at one lets go.
+func (g *Game) removeFillers(drop func(*Snake) bool) {
+	kept := g.snakes[:0]
+	for _, s := range g.snakes {
+		if s.filler && drop(s) {
+			s.Alive = false
+			continue
+		}
+		kept = append(kept, s)
+	}
+	clear(g.snakes[len(kept):])
+	g.snakes = kept
+}
+
+// fillerCount returns the number of filler bots, for the cap and /stats.
+func (g *Game) fillerCount() int {
+	n := 0
+	for _, s := range g.snakes {
+		if s.filler {
+			n++
+		}
+	}
+	return n
+}
+
+// sortedPlayers returns the players in ID order, so filling doesn't depend
+// on map iteration.
+func (g *Game) sortedPlayers() []*Player {
+	players := make([]*Player, 0, len(g.players))
+	for _, p := range g.players {
+		players = append(players, p)
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].id < players[j].id })
+	return players
+}
+
+// snakesNear returns the living snakes with their heads within r of p.
+func (g *Game) snakesNear(p Vec2, r float64) []*Snake {
+	candidates := g.snakes
+	if g.shards != nil {
+		candidates = g.shards.visibleSnakes(p.X, p.Y, r)
+	}
+	var near []*Snake
+	for _, s := range candidates {
+		if !s.Alive || len(s.Segments) == 0 {
+			continue
+		}
+		if h := s.Segments[0]; sim.DistSq(p.X, p.Y, h.X, h.Y) < r*r {
+			near = append(near, s)
+		}
+	}
+	return near
+}
+
+// fillSpawnPos returns the spot with the fewest body points around it
+// among fillSamples random ones between fillSpawnMin and 1 FillRadius from
+// head, inside the arena.
+func (g *Game) fillSpawnPos(head Vec2) Vec2 {
+	d := g.bodyDensity()
+	best, bestN := Vec2{}, math.Inf(1)
+	for i := 0; i < fillSamples; i++ {
+		a := g.rng.Float64() * 2 * math.Pi
+		dist := g.cfg.FillRadius * (fillSpawnMin + (1-fillSpawnMin)*g.rng.Float64())
+		p := Vec2{X: head.X + math.Cos(a)*dist, Y: head.Y + math.Sin(a)*dist}
+		if g.arena.EdgeDist(p) < randPosInset {
+			continue
+		}
+		if n := d.Within(p, SteerCellSize); n < bestN {
+			best, bestN = p, n
+		}
+	}
+	if math.IsInf(bestN, 1) {
+		return g.spawnPos(nil) // the player is in a corner; anywhere will do
+	}
+	return best
+}
@@ -0,0 +1,75 @@
+package main
+
+import "math"
+
+// ---------------------------------------------------------------------------
+// Food aging
+//
+// Without FoodTTL, food lies where it fell until someone eats it, so the
+// piles dropped by dead snakes in corners nobody visits only grow. With
+// FoodTTL, every pellet despawns FoodTTL seconds of game time after it
+// appeared, give or take foodTTLJitter so the pellets of a world reset
+// don't all vanish together. The refill after the simulation tops the
+// world up to FoodCount again at the food spawner's positions, so food
+// above FoodCount (kill drops, boost trails) drains back to the target and
+// the rest keeps moving to where the spawner wants it.
+//
+// Clients learn how long a pellet has left when they receive it: protocol
+// v12 food entries and protobuf Food messages carry the remaining time once
+// it is below foodLifeMax, and clients fade the pellet out over its last
+// two seconds. Keyframes resend the visible food every few seconds, which
+// is well within foodLifeMax, so every pellet is announced before it fades.
+//
+// A pellet's expiry is not saved with snapshots; restored food and food
+// that was there before FoodTTL was turned on get one from now.
+// ---------------------------------------------------------------------------
+
+const (
+	MaxFoodTTL    = 3600 // seconds
+	foodTTLJitter = 0.25 // expiry spread, as a fraction of FoodTTL
+	foodLifeMax   = 255  // tenths of a second: longer lives aren't sent
+)
+
+// foodExpiry returns the game clock at which a pellet placed now despawns,
+// 0 without FoodTTL.
+func (g *Game) foodExpiry() int {
+	if g.cfg.FoodTTL <= 0 {
+		return 0
+	}
+	ttl := float64(g.cfg.FoodTTL*TickRate) * (1 + foodTTLJitter*(2*g.rng.Float64()-1))
+	return g.clock + max(int(ttl), 1)
+}
+
+// expireFood removes the food whose time is up. Called once per tick before
+// the refill.
+func (g *Game) expireFood() {
+	if g.cfg.FoodTTL <= 0 {
+		return
+	}
+	kept := g.foods[:0]
+	for _, f := range g.foods {
+		if f.Expires == 0 {
+			f.Expires = g.foodExpiry()
+		}
+		if f.Expires <= g.clock {
+			g.expiredFood++
+			continue
+		}
+		kept = append(kept, f)
+	}
+	clear(g.foods[len(kept):])
+	g.foods = kept
+}
+
+// foodLife returns the real time f has left in tenths of a second, or -1 if
+// it has foodLifeMax or more (or no expiry at all).
+func (g *Game) foodLife(f *Food) int {
+	if g.cfg.FoodTTL <= 0 || f.Expires == 0 {
+		return -1
+	}
+	ds := int(math.Ceil(float64(f.Expires-g.clock) / g.cfg.TimeScale * 10 / TickRate))
+	if ds >= foodLifeMax {
+		return -1
+	}
+	return max(ds, 0)
+}
@@ -0,0 +1,45 @@
+package main
+
+// ---------------------------------------------------------------------------
+// Food delta sync (v2 and protobuf clients)
+//
+// Every food has a stable ID. Delta clients get their full visible food
+// list only every FoodKeyframeRate net ticks; every frame in between
+// carries the IDs of food that left their view (eaten or out of range) and
+// the food that entered it. v1 clients keep receiving full lists every
+// FoodSyncRate net ticks.
+// ---------------------------------------------------------------------------
+
+const FoodKeyframeRate = 150 // net ticks between full food lists (5 s)
+
+type foodDelta struct {
+	added   []*Food
+	removed []uint32
+}
+
+func (d foodDelta) empty() bool {
+	return len(d.added) == 0 && len(d.removed) == 0
+}
+
+// syncFood records visible as the food p's client holds and returns what
+// changed since the last frame. Keyframes replace the client's list, so
+// they return an empty delta.
+func (p *Player) syncFood(visible []*Food, keyframe bool) foodDelta {
+	var d foodDelta
+	known := make(map[uint32]bool, len(visible))
+	for _, f := range visible {
+		known[f.ID] = true
+		if !keyframe && !p.knownFood[f.ID] {
+			d.added = append(d.added, f)
+		}
+	}
+	if !keyframe {
+		for id := range p.knownFood {
+			if !known[id] {
+				d.removed = append(d.removed, id)
+			}
+		}
+	}
+	p.knownFood = known
+	return d
+}
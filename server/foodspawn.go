@@ -0,0 +1,149 @@
+package main
+
+import "fmt"
+
+// ---------------------------------------------------------------------------
+// Food distribution
+//
+//	uniform   every point of the arena is equally likely (default)
+//	biome     rich near the centre, sparse at the edges: a point is kept with
+//	          a probability that falls from 1 at the centre to
+//	          biomeEdgeWeight at the edge
+//	clusters  FoodBlooms blooms hold bloomShare of the refills, scattered
+//	          around their centre; the rest is spread as in biome. A bloom
+//	          runs dry after bloomFood pellets and a new one opens elsewhere,
+//	          so the rich spots move around the map as they are eaten
+//
+// The spawner only picks positions for randomly spawned food (refills and
+// world resets); food dropped by dying snakes stays where they died. Maps and
+// modes can install their own rules with SetFoodSpawner, or pick one of the
+// above with the foodSpawn config field, which a mode may change.
+// ---------------------------------------------------------------------------
+
+const (
+	DefaultFoodBlooms = 5
+	MaxFoodBlooms     = 32
+
+	biomeEdgeWeight = 0.25  // keep probability of a point at the edge
+	bloomShare      = 0.5   // fraction of clusters refills that go to blooms
+	bloomFood       = 150   // pellets a bloom spawns before it runs dry
+	bloomSpread     = 120.0 // standard deviation of pellets around a bloom
+)
+
+// FoodSpawner decides where randomly spawned food appears. Methods run on
+// the game loop goroutine.
+type FoodSpawner interface {
+	Name() string
+	// FoodPos returns the position of the next pellet.
+	FoodPos(g *Game) Vec2
+	// Reset is called when the world is reset and when the spawner is
+	// installed.
+	Reset(g *Game)
+}
+
+func foodSpawnerFor(name string) (FoodSpawner, error) {
+	switch name {
+	case "", "uniform":
+		return uniformFood{}, nil
+	case "biome":
+		return biomeFood{}, nil
+	case "clusters":
+		return &clusterFood{}, nil
+	}
+	return nil, fmt.Errorf("unknown food spawn %q (want uniform, biome or clusters)", name)
+}
+
+// SetFoodSpawner replaces the food distribution. Must be called before Run.
+func (g *Game) SetFoodSpawner(fs FoodSpawner) {
+	g.food = fs
+	fs.Reset(g)
+}
+
+// syncFoodSpawner installs the configured spawner after a config change
+// from prev.
+func (g *Game) syncFoodSpawner(prev string) {
+	if g.cfg.FoodSpawn == prev {
+		return
+	}
+	fs, err := foodSpawnerFor(g.cfg.FoodSpawn)
+	if err != nil {
+		return // rejected by checkRuntimeConfig and validateRotation
+	}
+	g.SetFoodSpawner(fs)
+}
+
+type uniformFood struct{}
+
+func (uniformFood) Name() string { return "uniform" }
+
+func (uniformFood) FoodPos(g *Game) Vec2 { return g.randWorldPos() }
+
+func (uniformFood) Reset(*Game) {}
+
+type biomeFood struct{}
+
+func (biomeFood) Name() string { return "biome" }
+
+func (biomeFood) FoodPos(g *Game) Vec2 { return g.biomePos() }
+
+func (biomeFood) Reset(*Game) {}
+
+// biomePos returns a random point weighted towards the centre.
+func (g *Game) biomePos() Vec2 {
+	half := float64(g.cfg.WorldSize) / 2
+	p := g.randWorldPos()
+	for attempts := 0; attempts < 32; attempts++ {
+		t := g.arena.EdgeDist(p) / half
+		if g.rng.Float64() < biomeEdgeWeight+(1-biomeEdgeWeight)*t {
+			return p
+		}
+		p = g.randWorldPos()
+	}
+	return p
+}
+
+type foodBloom struct {
+	center Vec2
+	left   int // pellets until the bloom runs dry
+}
+
+type clusterFood struct {
+	blooms []foodBloom
+	next   int // bloom that gets the next pellet
+}
+
+func (*clusterFood) Name() string { return "clusters" }
+
+func (c *clusterFood) Reset(g *Game) {
+	c.blooms = c.blooms[:0]
+	for i := 0; i < g.cfg.FoodBlooms; i++ {
+		c.blooms = append(c.blooms, foodBloom{center: g.biomePos(), left: bloomFood})
+	}
+	c.next = 0
+}
+
+func (c *clusterFood) FoodPos(g *Game) Vec2 {
+	if len(c.blooms) != g.cfg.FoodBlooms {
+		c.Reset(g)
+	}
+	if len(c.blooms) == 0 || g.rng.Float64() >= bloomShare {
+		return g.biomePos()
+	}
+	c.next = (c.next + 1) % len(c.blooms)
+	b := &c.blooms[c.next]
+	p := b.center
+	for attempts := 0; attempts < 8; attempts++ {
+		q := Vec2{
+			X: b.center.X + g.rng.NormFloat64()*bloomSpread,
+			Y: b.center.Y + g.rng.NormFloat64()*bloomSpread,
+		}
+		if g.arena.EdgeDist(q) >= randPosInset {
+			p = q
+			break
+		}
+	}
+	if b.left--; b.left <= 0 {
+		*b = foodBloom{center: g.biomePos(), left: bloomFood}
+	}
+	return p
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/binary"
+)
+
+// ---------------------------------------------------------------------------
+// Frame size budget (protocol v5)
+//
+// A frame with a full food keyframe, the summary and many long snakes can
+// reach tens of kilobytes, which a slow connection can't take within its 8
+// queue slots and the 5 s write deadline. For v5 clients, a state frame
+// larger than FrameBudget bytes is split: the type 5 frame keeps the
+// snakes, trails and round (and all new names), and food and summary follow
+// as section frames of at most about FrameBudget bytes each:
+//
+//   type(1)=7, flags(1: bit0=hasFood, bit1=hasSummary, bit6=hasFoodDelta),
+//   tick(uvarint), serverTimeMs(uint32 BE), then the flagged sections as
+//   in the type 5 frame of the same tick.
+//
+// A food keyframe is cut into a keyframe with the first entries and deltas
+// that add the rest. Section frames are queued while the player's send queue
+// is less than half full (more would throttle the player, see adaptRate).
+// Dropped sections are taken back out of the player's food cache, so the
+// next delta resends what they carried; if the first part of a keyframe is
+// dropped, the next frame carries a keyframe again.
+// ---------------------------------------------------------------------------
+
+const MinFrameBudget = 1024
+
+// splitEncoder is implemented by serializers that can split oversized
+// frames into a state frame and section frames.
+type splitEncoder interface {
+	EncodeSplit(g *Game, p *Player, includeFood, includeSummary bool, sh *frameShared, budget int) ([]byte, []sectionFrame)
+}
+
+// sectionFrame is an encoded section frame and the food changes it carries.
+type sectionFrame struct {
+	data     []byte
+	keyframe bool // replaces the client's food list
+	added    []*Food
+	removed  []uint32
+}
+
+// encodeState encodes p's state frame and, if it was split, the section
+// frames that follow it.
+func (g *Game) encodeState(p *Player, includeFood, includeSummary bool, sh *frameShared) ([]byte, []sectionFrame) {
+	if se, ok := p.serializer.(splitEncoder); ok && g.cfg.FrameBudget > 0 {
+		return se.EncodeSplit(g, p, includeFood, includeSummary, sh, g.cfg.FrameBudget)
+	}
+	return p.serializer.Encode(g, p, includeFood, includeSummary, sh), nil
+}
+
+// queueSections queues the section frames of a split frame whose state
+// frame was just queued, as far as p's send queue has room for them.
+func (g *Game) queueSections(p *Player, sections []sectionFrame) {
+	if len(sections) == 0 {
+		return
+	}
+	g.splitFrames++
+	room := SendBufferSize/2 - len(p.sendCh)
+	for i, sec := range sections {
+		if i < room {
+			select {
+			case p.sendCh <- sec.data:
+				n := int64(len(sec.data))
+				g.totalBytesSent += n
+				g.bwAccum += n
+				continue
+			default:
+			}
+		}
+		g.droppedSections++
+		if sec.keyframe {
+			p.foodResync = true
+		}
+		for _, f := range sec.added {
+			delete(p.knownFood, f.ID)
+		}
+		for _, id := range sec.removed {
+			p.knownFood[id] = true
+		}
+	}
+	if room < len(sections) {
+		g.log.Debug("dropped section frames", "playerID", p.id, "sections", len(sections)-max(room, 0),
+			"backlog", len(p.sendCh))
+	}
+}
+
+// splitSections builds the section frames for the summary section and the
+// food of a frame: a keyframe list (keyframe set in flags) or a delta.
+// life gives the remaining food life from protocol v12 on, nil before.
+func splitSections(summary []byte, keyframe []*Food, delta foodDelta, flags byte, sh *frameShared, budget int, life func(*Food) int) []sectionFrame {
+	var sections []sectionFrame
+	newSection := func(flags byte) *v2Frame {
+		f := &v2Frame{buf: make([]byte, 2, budget+64)}
+		f.buf[0], f.buf[1] = 7, flags
+		f.uvarint(sh.tick)
+		f.buf = binary.BigEndian.AppendUint32(f.buf, sh.sentMs)
+		return f
+	}
+
+	if flags&(1|64) != 0 {
+		// Encode entries ahead, then cut them into frames
+		var removed []uint32
+		entries := &v2Frame{life: life}
+		ends := []int{}
+		added := delta.added
+		first := byte(64)
+		if flags&1 != 0 {
+			added, first = keyframe, 1
+		} else {
+			removed = delta.removed
+		}
+		for _, fd := range added {
+			entries.food(fd)
+			ends = append(ends, len(entries.buf))
+		}
+		start, i := 0, 0
+		for {
+			f := newSection(first)
+			if first == 64 {
+				f.uvarint(len(removed))
+				for _, id := range removed {
+					f.buf = binary.AppendUvarint(f.buf, uint64(id))
+				}
+			}
+			n := 0
+			for j := i; j < len(ends) && (n == 0 || ends[j]-start <= budget-len(f.buf)-8); j++ {
+				n++
+			}
+			f.uvarint(n)
+			end := start
+			if n > 0 {
+				end = ends[i+n-1]
+			}
+			f.buf = append(f.buf, entries.buf[start:end]...)
+			sections = append(sections, sectionFrame{
+				data: f.buf, keyframe: first == 1, added: added[i : i+n], removed: removed,
+			})
+			start, i = end, i+n
+			if i >= len(ends) {
+				break
+			}
+			first, removed = 64, nil
+		}
+	}
+
+	if flags&2 != 0 {
+		f := newSection(2)
+		f.buf = append(f.buf, summary...)
+		sections = append(sections, sectionFrame{data: f.buf})
+	}
+	return sections
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"math/rand"
 	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"snake-server/sim"
 )
 
 // ---------------------------------------------------------------------------
@@ -25,9 +30,166 @@ type GameConfig struct {
 	BoostDrain     float64 `json:"boostDrain"`
 	BoostRegen     float64 `json:"boostRegen"`
 	BaseSnakeLen   int     `json:"baseSnakeLen"`
+	SegmentSpacing float64 `json:"segmentSpacing"` // distance between drawn body points (see growth.go)
+	GrowthRate     float64 `json:"growthRate"`     // body points grown per game tick at most
 	KillFoodCount  int     `json:"killFoodCount"`
 	BoundaryMargin float64 `json:"boundaryMargin"`
+	WarningBand    float64 `json:"warningBand"` // width of the red haze inside the kill line, 0 = none (see sim/border.go)
+	WallBrake      bool    `json:"wallBrake"`   // players slow down and stop at the wall instead of dying
+	Arena          string  `json:"arena"`       // "square" (default), "circle" or "hexagon" (see arena.go)
+	HeadOn         string  `json:"headOn"`      // "longer" (default), "faster" or "both" (see sim/headon.go)
 	AIRespawnTicks int     `json:"aiRespawnTicks"`
+	FillBots       int     `json:"fillBots"`      // living snakes to keep near every player, 0 = off (see fill.go)
+	FillRadius     float64 `json:"fillRadius"`    // how near they must be
+	LaserTail      bool    `json:"laserTail"`     // boosting snakes leave a deadly trail
+	TrailLifetime  int     `json:"trailLifetime"` // frames a trail point stays hazardous
+
+	CollisionPrecision int  `json:"collisionPrecision"` // 0 = point test, N = swept head vs every Nth body point (see collision.go)
+	SimRate            int  `json:"simRate"`            // movement/collision steps per second, a multiple of TickRate
+	Deterministic      bool `json:"deterministic"`      // fixed-point movement, bit-identical across architectures (see sim/fixed.go)
+	HeatmapSize        int  `json:"heatmapSize"`        // minimap heatmap cells per side, 0 = off (see heatmap.go)
+	ShardCells         int  `json:"shardCells"`         // world shard cells per side, 0 or 1 = unsharded (see shard.go)
+	FrameBudget        int  `json:"frameBudget"`        // bytes per state message before v5 frames are split, 0 = never (see framebudget.go)
+
+	TimeScale float64 `json:"timeScale"` // game time per real time, 0.25 to 2 (see timescale.go)
+
+	FixedDifficulty bool `json:"fixedDifficulty"` // don't tune the bots to a solo player (see difficulty.go)
+
+	// Idle power saving (see idle.go)
+	IdleTickRate int `json:"idleTickRate"` // ticks per second while no players are connected, 0 = full rate
+	IdlePause    int `json:"idlePause"`    // seconds without players before the simulation pauses, 0 = never
+
+	TickBudget float64 `json:"tickBudget"` // ms of average tick time before the watchdog sheds load, 0 = off (see watchdog.go)
+
+	// Spawn placement and protection (see spawn.go)
+	SpawnProtection int     `json:"spawnProtection"` // ticks a new snake is invulnerable, 0 = none
+	SpawnClearance  float64 `json:"spawnClearance"`  // preferred distance from other snakes at spawn, 0 = uniform random
+
+	// Respawn cooldown (see respawn.go)
+	RespawnCooldown int  `json:"respawnCooldown"` // ticks after a death before the player may respawn, 0 = none
+	SpectateKiller  bool `json:"spectateKiller"`  // watch a living killer for 5 s before respawning
+
+	// Boost model (see boost.go)
+	BoostMode       string  `json:"boostMode"`       // "meter" (default) or "charge"
+	BoostCooldown   int     `json:"boostCooldown"`   // charge mode: ticks boosting is blocked after the meter runs dry
+	ChargeValue     float64 `json:"chargeValue"`     // charge mode: boost restored per charge pellet
+	ChargeFoodRatio float64 `json:"chargeFoodRatio"` // charge mode: fraction of spawned food that are charge pellets
+
+	// Kill rewards (see reward.go)
+	KillReward        string  `json:"killReward"`        // "percent" (default), "flat" or "diminishing"
+	KillRewardPercent float64 `json:"killRewardPercent"` // percent, diminishing: fraction of the victim's length
+	KillRewardFlat    int     `json:"killRewardFlat"`    // flat: length per kill
+	KillRewardKnee    int     `json:"killRewardKnee"`    // diminishing: victim length that yields half the percent reward
+
+	// Food distribution (see foodspawn.go)
+	FoodSpawn  string `json:"foodSpawn"`  // "uniform" (default), "biome" or "clusters"
+	FoodBlooms int    `json:"foodBlooms"` // clusters: blooms open at a time
+	FoodTTL    int    `json:"foodTTL"`    // seconds a pellet lies before it despawns, 0 = forever (see foodage.go)
+
+	// Tournament mode (see tournament.go)
+	RoundDuration    int    `json:"roundDuration"`    // seconds per round, 0 = endless play
+	RoundCountdown   int    `json:"roundCountdown"`   // seconds of countdown before a round
+	RoundResultsTime int    `json:"roundResultsTime"` // seconds the podium is shown
+	WebhookURL       string `json:"webhookUrl"`       // receives round results as JSON
+
+	// Mutators (see mutators.go)
+	Mutators       []string `json:"mutators"`       // always on: lowGravity, doubleFood, tinySnakes, infiniteBoost, fog
+	RandomMutators int      `json:"randomMutators"` // tournament mode: more drawn at random for each round
+
+	// Roaming predators (see predator.go)
+	Predators     int     `json:"predators"`     // giant eels in the world, 0 = none
+	PredatorSpeed float64 `json:"predatorSpeed"` // units per tick, lunges go 1.6 times as fast
+
+	// Portals (see portal.go)
+	Portals        int `json:"portals"`        // linked portal pairs in the world, 0 = none
+	PortalCooldown int `json:"portalCooldown"` // ticks after a jump before a snake may take a portal again
+
+	// Kill streaks (see streak.go)
+	StreakBonus int `json:"streakBonus"` // score per streak kill before multipliers, 0 = no bonus
+
+	// Golden-snake bounty (see bounty.go)
+	BountyInterval int `json:"bountyInterval"` // seconds between crownings, 0 = off
+	BountyBonus    int `json:"bountyBonus"`    // score awarded for killing the golden snake
+
+	// Length decay for oversized snakes (see decay.go)
+	DecayThreshold int     `json:"decayThreshold"` // length above which snakes decay, 0 = off
+	DecayRate      float64 `json:"decayRate"`      // fraction of the excess length lost per second
+	DecayDropFood  bool    `json:"decayDropFood"`  // drop the lost length as food
+
+	// Connection access control (see access.go)
+	MaxConnsPerIP int    `json:"maxConnsPerIp"` // concurrent WebSocket connections per IP, 0 = unlimited
+	BanFile       string `json:"banFile"`       // persistent ban list
+
+	// Browser origins allowed for WebSocket and stats CORS (see origins.go)
+	AllowedOrigins []string `json:"allowedOrigins"` // empty = any origin
+
+	// Lua AI personalities (see aiscript.go)
+	AIScriptDir string `json:"aiScriptDir"` // directory of *.lua bot scripts, "" = built-in AI only
+
+	// AI pack hunting (see pack.go)
+	AIPackSize int `json:"aiPackSize"` // max bots per hunting pack, 0 = no packs
+
+	// Persistent player identity (see identity.go)
+	IdentityKey        string `json:"identityKey"`        // HMAC key for identity tokens, "" = random per run
+	IdentityExpiryDays int    `json:"identityExpiryDays"` // days without play before an identity expires, 0 = never
+
+	// XP and levels (see xp.go)
+	XPPerKill     int     `json:"xpPerKill"`     // XP per kill, on top of the final score
+	XPLevelBase   int     `json:"xpLevelBase"`   // XP from level 1 to 2
+	XPLevelGrowth float64 `json:"xpLevelGrowth"` // factor by which each further level takes more XP
+	MaxLevel      int     `json:"maxLevel"`      // highest level, at most 255
+
+	// Skill rating (see rating.go)
+	RatingK     float64 `json:"ratingK"`     // Elo K-factor: most a kill can move a rating, 0 = ratings off
+	RatingSpawn bool    `json:"ratingSpawn"` // spawn players away from much stronger players
+
+	// Persistent storage (see storage.go)
+	DataDir string `json:"dataDir"` // SQLite database directory, "" = no storage
+
+	// NDJSON event log (see eventlog.go)
+	EventLog      string `json:"eventLog"`      // file the events are appended to, "" = off
+	EventLogMaxMB int    `json:"eventLogMaxMB"` // size at which the file is rotated
+	EventLogKeep  int    `json:"eventLogKeep"`  // rotated files kept next to it
+
+	// Round replays (see replay.go)
+	Replays int `json:"replays"` // recorded rounds kept in <dataDir>/replays, 0 = off
+
+	// Cluster mode (see cluster.go)
+	ClusterRedis string `json:"clusterRedis"` // Redis room directory, "" = standalone
+	InstanceID   string `json:"instanceId"`   // room ID in the directory, "" = host name and process ID
+
+	// Extra rooms (see rooms.go)
+	MaxRooms int `json:"maxRooms"` // extra rooms with custom rulesets, 0 = main room only
+
+	// Size classes (see sizeclass.go)
+	MinLength int `json:"minLength"` // snakes spawning shorter go to another room, 0 = no lower bound
+	MaxLength int `json:"maxLength"` // snakes growing longer go to another room, 0 = no upper bound
+
+	// Optional accounts (see accounts.go)
+	PublicURL string                 `json:"publicUrl"` // base URL for OAuth redirects and the cluster room, "" = from the request
+	OAuth     map[string]OAuthClient `json:"oauth"`     // by provider: google, discord, apple
+
+	// Mode rotation (see rotation.go)
+	Rotation RotationConfig `json:"rotation"`
+
+	// Announcements (see announce.go)
+	Announcements AnnouncementConfig `json:"announcements"`
+
+	// Input recording for cheat review (see inputlog.go)
+	InputLogMinutes int `json:"inputLogMinutes"` // minutes of raw inputs kept per player, 0 = off
+
+	// Chat snake steered by stream viewers (see crowd.go)
+	ChatSnake ChatSnakeConfig `json:"chatSnake"`
+
+	// Chat (see chat.go)
+	ChatRadius        float64 `json:"chatRadius"`        // reach of proximity chat
+	ProximityChatOnly bool    `json:"proximityChatOnly"` // no all-chat, every message is proximity chat
+
+	// Name policy
+	NameBlocklist []string `json:"nameBlocklist"`
+	ReservedNames []string `json:"reservedNames"`
+	NameWidth     int      `json:"nameWidth"` // display cells per name, wide characters count 2 (see names.go)
+	AdminToken    string   `json:"adminToken"`
 }
 
 func DefaultConfig() GameConfig {
@@ -42,9 +204,56 @@ func DefaultConfig() GameConfig {
 		BoostDrain:     0.6,
 		BoostRegen:     0.15,
 		BaseSnakeLen:   10,
+		SegmentSpacing: DefaultSegmentSpacing,
+		GrowthRate:     DefaultGrowthRate,
 		KillFoodCount:  8,
 		BoundaryMargin: 50,
+		WarningBand:    150,
 		AIRespawnTicks: 180,
+		FillRadius:     DefaultFillRadius,
+		TrailLifetime:  90,
+		ReservedNames:  []string{"Admin", "Server", "Moderator"},
+		NameWidth:      DefaultNameWidth,
+		ChatRadius:     DefaultChatRadius,
+		EventLogMaxMB:  DefaultEventLogMaxMB,
+		PredatorSpeed:  DefaultPredatorSpeed,
+		PortalCooldown: DefaultPortalCooldown,
+		FoodBlooms:     DefaultFoodBlooms,
+		StreakBonus:    DefaultStreakBonus,
+		MaxRooms:       DefaultMaxRooms,
+		EventLogKeep:   DefaultEventLogKeep,
+
+		KillRewardPercent: DefaultKillRewardPercent,
+		KillRewardFlat:    DefaultKillRewardFlat,
+		KillRewardKnee:    DefaultKillRewardKnee,
+
+		CollisionPrecision: 1,
+		SimRate:            TickRate,
+		HeatmapSize:        32,
+		FrameBudget:        16384,
+		TimeScale:          1,
+
+		SpawnProtection: 120,
+		SpawnClearance:  400,
+
+		RoundCountdown:   5,
+		RoundResultsTime: 10,
+		BountyBonus:      100,
+		DecayRate:        0.01,
+		AIPackSize:       3,
+
+		IdentityExpiryDays: 90,
+
+		XPPerKill:     50,
+		XPLevelBase:   500,
+		XPLevelGrowth: 1.2,
+		MaxLevel:      50,
+
+		RatingK: 24,
+
+		BoostCooldown:   90,
+		ChargeValue:     25,
+		ChargeFoodRatio: 0.05,
 	}
 }
 
@@ -52,8 +261,8 @@ func DefaultConfig() GameConfig {
 // Fixed constants (technical/network, not configurable)
 // ---------------------------------------------------------------------------
 const (
-	HeadRadius    = 12.0
-	BodyRadius    = 10.0
+	HeadRadius    = sim.HeadRadius
+	BodyRadius    = sim.BodyRadius
 	FoodRadiusVal = 6.0
 	FoodValueVal  = 1.0
 	TickRate      = 60
@@ -83,7 +292,8 @@ func nextAIID() int {
 // Types
 // ---------------------------------------------------------------------------
 
-type Vec2 struct{ X, Y float64 }
+// Vec2 is a point or direction in world units (see package sim).
+type Vec2 = sim.Vec2
 
 type Snake struct {
 	Name        string
@@ -93,9 +303,10 @@ type Snake struct {
 	Speed       float64
 	ColorIdx    int
 	IsAI        bool
-	PlayerID    int // -1 for AI
+	PlayerID    int // player's ID; a new negative ID per life for AI
 	Score       int
 	TargetLen   int
+	bodyLen     float64 // follows TargetLen at GrowthRate (see growth.go)
 	Boost       float64
 	IsBoosting  bool
 	Alive       bool
@@ -105,19 +316,65 @@ type Snake struct {
 	AIState       string
 	AIStateTimer  int
 	AITargetAngle float64
+
+	id          uint32  // entity ID, new for every life (see newEntityID)
+	turnRate    float64 // heading change during the last tick (rad)
+	spawnFrame  int
+	kills       int
+	foodEaten   int     // food items eaten this life (see achievements.go)
+	foodCounted int     // foodEaten already counted for challenges (see challenges.go)
+	level       int     // player's level, 0 for AI (see xp.go)
+	skin        int     // skin ID (see xp.go)
+	cell        int     // owning shard cell, -1 before the first assignment (see shard.go)
+	order       int     // index in g.snakes at the last shard assignment
+	killedBy    *Snake  // snake run into on the last death, nil for boundary deaths
+	deathCause  string  // collision, trail, boundary or predator (see killSnake)
+	headPlaced  bool    // this tick's head point exists; later substeps move it
+	spawnInput  bool    // spawnAngle holds the first input since spawning
+	spawnAngle  float64 // see checkSpawnInput
+
+	golden        bool // current bounty target
+	boostCooldown int  // frames until boosting is allowed again (charge mode)
+	scriptBoost   bool // last boost decision of a scripted AI (see aiscript.go)
+	pack          *aiPack
+	filler        bool // spawned near a lonely player, never respawns (see fill.go)
+	crowd         bool // the chat snake, steered by chat votes (see crowd.go)
+
+	streak     streakState // kill streak of this life (see streak.go)
+	trace      headTrace   // recent head positions (see assist.go)
+	glow       glowState   // boost glow and afterimages (see glow.go)
+	assists    int
+	assistedBy []*Snake // snakes credited with an assist on the last death
+
+	portalGap      int // body points past the last portal jump, 0 = none (see portal.go)
+	portalCooldown int // ticks until the snake may take a portal again
 }
 
 type Food struct {
+	ID       uint32 `json:"-"` // stable identity for delta sync, assigned by placeFood
 	X, Y     float64
 	ColorIdx int
 	Radius   float64
 	Value    float64
+	Charge   bool `json:",omitempty"` // refills boost in charge mode
+	Expires  int  `json:"-"`          // game clock at which it despawns, 0 = never (see foodage.go)
 }
 
 type InputMsg struct {
 	PlayerID int
 	Angle    float64
 	Boost    bool
+	Seq      uint16 // client's input sequence number, if HasSeq (see prediction.go)
+	HasSeq   bool
+}
+
+// CustomizeMsg changes a player's appearance. Empty Name / negative
+// ColorIdx or Skin leave that attribute unchanged.
+type CustomizeMsg struct {
+	PlayerID int
+	Name     string
+	ColorIdx int
+	Skin     int
 }
 
 type StatsSnapshot struct {
@@ -127,16 +384,51 @@ type StatsSnapshot struct {
 	TotalJoins     int64              `json:"totalJoins"`
 	TotalLeaves    int64              `json:"totalLeaves"`
 	TotalKills     int64              `json:"totalKills"`
+	AIKills        int64              `json:"aiKills"`     // kills by AI snakes
+	PlayerKills    int64              `json:"playerKills"` // kills by player snakes
+	BoundaryDeaths int64              `json:"boundaryDeaths"`
+	PredatorKills  int64              `json:"predatorKills"` // snakes killed by predators (see predator.go)
+	Predators      int                `json:"predators"`
+	PredatorsDown  int64              `json:"predatorsDown"` // predators that hit the boundary
+	BestStreak     int                `json:"bestStreak"`    // longest kill streak since start (see streak.go)
+	Assists        int64              `json:"assists"`       // kill assists credited (see assist.go)
+	HeadOns        int64              `json:"headOns"`       // head-on collisions resolved (see sim/headon.go)
+	PortalJumps    int64              `json:"portalJumps"`   // snakes that went through a portal (see portal.go)
+	SizeClassMoves int64              `json:"classMoves"`    // players sent to another room by length (see sizeclass.go)
+	FillerBots     int                `json:"fillerBots"`    // bots spawned near lonely players (see fill.go)
+	ChatVotes      int64              `json:"chatVotes"`     // chat snake votes counted (see crowd.go)
+	FoodExpired    int64              `json:"foodExpired"`   // pellets that despawned uneaten (see foodage.go)
+	Difficulty     float64            `json:"difficulty"`    // bot difficulty for a solo player, 1 = as configured (see difficulty.go)
+	Mutators       []string           `json:"mutators"`      // active mutators (see mutators.go)
 	PeakPlayers    int                `json:"peakPlayers"`
 	CurrentPlayers int                `json:"currentPlayers"`
+	Directors      int                `json:"directors,omitempty"` // observer connections (see director.go)
 	AICount        int                `json:"aiCount"`
 	FoodCount      int                `json:"foodCount"`
 	AvgTickMs      float64            `json:"avgTickMs"`
 	MaxTickMs      float64            `json:"maxTickMs"`
+	Stages         []StageTiming      `json:"stages"` // tick time by stage over the last 60 ticks
 	BandwidthKBps  float64            `json:"bandwidthKBps"`
 	TotalBytesSent int64              `json:"totalBytesSent"`
 	TotalBytesRecv int64              `json:"totalBytesRecv"`
+	AvgRTTMs       float64            `json:"avgRttMs"`
+	MaxRTTMs       int64              `json:"maxRttMs"`
+	Throttled      int                `json:"throttledPlayers"` // players on a reduced update rate
+	SplitFrames    int64              `json:"splitFrames"`      // frames over the frame budget
+	SectionDrops   int64              `json:"droppedSections"`  // section frames dropped for lack of queue room
 	Frame          int                `json:"frame"`
+	TimeScale      float64            `json:"timeScale"`
+	Idle           string             `json:"idle"`              // "awake", "slow" (IdleTickRate) or "paused"
+	TickRate       int                `json:"tickRate"`          // ticks per second right now, 0 while paused
+	Degrade        *DegradeStatus     `json:"degrade,omitempty"` // nil while the watchdog is off and no level is pinned
+	Round          int                `json:"round,omitempty"`
+	RoundPhase     string             `json:"roundPhase,omitempty"`
+	RoundRemaining int                `json:"roundRemainingSec,omitempty"`
+	Mode           string             `json:"mode,omitempty"` // current rotation mode
+	Golden         string             `json:"golden,omitempty"`
+	DecayThreshold int                `json:"decayThreshold,omitempty"` // 0 when decay is off
+	Decaying       int                `json:"decayingSnakes"`           // snakes currently above the threshold
+	Shards         *ShardStats        `json:"shards,omitempty"`         // nil when unsharded
 	Leaderboard    []LeaderboardEntry `json:"leaderboard"`
 }
 
@@ -145,94 +437,216 @@ type LeaderboardEntry struct {
 	Score   int    `json:"score"`
 	IsAI    bool   `json:"isAI"`
 	IsAlive bool   `json:"alive"`
+	Decay   bool   `json:"decaying,omitempty"`
 }
 
 type Game struct {
 	cfg     GameConfig
+	log     *slog.Logger // records carry the room ID (see rooms.go)
+	roomID  string
+	rooms   *Rooms // the server's rooms, for size classes (see sizeclass.go)
+	names   *NamePolicy
+	boost   BoostPolicy
+	spawn   SpawnPolicy
+	arena   sim.ArenaShape
+	food    FoodSpawner
 	snakes  []*Snake
 	foods   []*Food
+	trails  []*Trail
+	golden  *Snake
 	players map[int]*Player
 
+	seenNames map[string]bool // lowercased names that have joined (see firstSession)
+
+	// Player identities and profiles (see identity.go)
+	identities       *IdentitySigner
+	profiles         map[string]*Profile
+	lastProfilePrune time.Time
+	accountCh        chan accountLogin // OAuth logins (see accounts.go)
+
+	// Persistent storage, nil without -data-dir (see storage.go)
+	store   Storage
+	storeCh chan func(Storage) error
+
+	nextFoodID   uint32
+	nextEntityID uint32 // last entity ID handed out (see newEntityID)
+
 	frame   int
 	netTick int
-
-	inputCh   chan InputMsg
-	joinCh    chan *Player
-	leaveCh   chan int
-	respawnCh chan int
+	round   roundState
+
+	// Game clock: ticks of game time at the current TimeScale (see timescale.go)
+	clock      int
+	clockSteps int     // game ticks covered by the current tick
+	clockAcc   float64 // fraction of a game tick carried over
+
+	// Game RNG (single goroutine; state is saved with snapshots)
+	rng    *rand.Rand
+	rngSrc *splitMix64
+
+	inputCh     chan InputMsg
+	joinCh      chan *Player
+	directorCh  chan *Player
+	leaveCh     chan int
+	respawnCh   chan int
+	customizeCh chan CustomizeMsg
+	chatCh      chan ChatMsg
+	voteCh      chan VoteMsg
+	crowdCh     chan crowdVote // chat snake votes from the connector (see crowd.go)
 
 	// Stats tracking
 	startTime   time.Time
 	totalJoins  int64
 	totalLeaves int64
-	totalKills  int64
+	totalKills  int64 // deaths with a killer, split into aiKills and playerKills
 	peakPlayers int
 
+	aiKills        int64
+	playerKills    int64
+	boundaryDeaths int64
+	expiredFood    int64
+	predatorKills  int64
+	predatorsDown  int64
+	bestStreak     int
+	totalAssists   int64
+	headOns        int64
+	portalJumps    int64
+	sizeClassMoves int64
+
 	// Tick performance
-	tickDurations [60]time.Duration
-	tickDurIdx    int
-	maxTickMs     float64
+	tickDurations  [60]time.Duration
+	stageDurations [60]stageTimes // per-stage split of tickDurations (see profile.go)
+	stages         stageTimes     // stage times of the running tick
+	tickDurIdx     int
+	maxTickMs      float64
 
 	// Bandwidth tracking
 	totalBytesSent int64
-	totalBytesRecv int64 // atomic — written from readPump goroutines
+	totalBytesRecv int64     // atomic — written from readPump goroutines
 	bwPerSec       [30]int64 // bytes-per-second ring buffer (last 30s)
 	bwSecIdx       int
 	bwAccum        int64 // bytes accumulated in the current second
 	bwLastSec      int   // frame number of the last second boundary
 
+	splitFrames     int64 // frames over the frame budget (see framebudget.go)
+	droppedSections int64 // section frames dropped for lack of queue room
+
+	// Rolling metrics history (see history.go)
+	history      metricsHistory
+	historyReqCh chan chan HistorySnapshot
+
 	// Stats request channel (channel-of-channels for thread-safe reads)
 	statsReqCh chan chan StatsSnapshot
+
+	// Stats subscribers, pushed a snapshot once per second (owned by game loop)
+	statsSubCh   chan chan StatsSnapshot
+	statsUnsubCh chan chan StatsSnapshot
+	statsSubs    map[chan StatsSnapshot]bool
+
+	// Snapshots (see snapshot.go)
+	snapshotReqCh chan chan *gameSnapshot
+	autosavePath  string
+	autosaveEvery int // frames between autosaves, 0 = off
+	autosaveBusy  atomic.Bool
+
+	// Runtime control requests (see control.go)
+	playersReqCh chan chan []PlayerInfo
+	kickCh       chan kickReq
+	configReqCh  chan configReq
+
+	// Tick budget watchdog (see watchdog.go)
+	wd           watchdog
+	degradeReqCh chan degradeReq
+
+	// Idle power saving (see idle.go)
+	wakeCh     chan struct{}
+	idle       idleState
+	lastActive time.Time // last time players were connected or a connection came in
+
+	// Health and shutdown (see shutdown.go)
+	lastLoop   atomic.Int64 // UnixNano of the last loop iteration
+	shutdownCh chan time.Duration
+	stopCh     chan chan struct{}
+	stopped    chan struct{}  // closed when Run returns
+	writers    sync.WaitGroup // storage and event log writers
+
+	// Observer connections (see director.go)
+	dir director
+
+	crowd  crowdState  // chat snake vote window (see crowd.go)
+	review inputReview // input recordings and captures (see inputlog.go)
+
+	diff difficultyState // solo player's record and the bots added for it (see difficulty.go)
+
+	// Mutators (see mutators.go)
+	rules         Rules    // what the simulation reads, with the active mutators applied
+	roundMutators []string // drawn for the current round
+
+	evlog   *eventLog       // NDJSON event log, nil = off (see eventlog.go)
+	replays *replayRecorder // round recordings, nil = off (see replay.go)
+
+	// Roaming predators (see predator.go)
+	predators   []*Predator
+	predatorDue []int // game clock at which fallen eels are replaced
+
+	portals []PortalPair // see portal.go
+
+	hooks    []*hook             // see hooks.go
+	commands map[string]*Command // chat commands by name (see chat.go)
+
+	density densityGrid // body points per cell (see steering.go)
+
+	// Physics step scratch space, reused between substeps (see physics.go)
+	physics sim.State
+	inputs  []sim.Input
+	avoid   []bool
+	eaten   []*Food
+
+	// AI pack hunting (see pack.go)
+	packs        []*aiPack
+	nextPackID   int
+	packsFormed  int64
+	packKills    int64
+	aiDebugReqCh chan chan AIDebugSnapshot
+
+	achievementsReqCh chan achievementsReq // see achievements.go
+	worldReqCh        chan chan WorldView  // see worldview.go
+	worldView         worldViewCache       // last /world response
+	challenges        []ActiveChallenge    // see challenges.go
+	shards            *shardGrid           // nil = unsharded (see shard.go)
+	rotation          *rotation            // nil = no mode rotation (see rotation.go)
+
+	// Scripted AI personalities (see aiscript.go)
+	scripts  *aiScripts // nil = built-in AI only
+	scriptCh chan map[string]*lua.FunctionProto
 }
 
 // ---------------------------------------------------------------------------
 // Helpers
 // ---------------------------------------------------------------------------
 
-func distSq(x1, y1, x2, y2 float64) float64 {
-	dx, dy := x2-x1, y2-y1
-	return dx*dx + dy*dy
-}
-
-func dist(x1, y1, x2, y2 float64) float64 {
-	return math.Sqrt(distSq(x1, y1, x2, y2))
-}
-
-func angleDiff(a, b float64) float64 {
-	d := b - a
-	for d > math.Pi {
-		d -= 2 * math.Pi
-	}
-	for d < -math.Pi {
-		d += 2 * math.Pi
-	}
-	return d
-}
-
-func clampF(v, lo, hi float64) float64 {
-	if v < lo {
-		return lo
-	}
-	if v > hi {
-		return hi
-	}
-	return v
-}
-
+// randWorldPos returns a random point at least randPosInset inside the
+// arena, drawn from the world square until one fits the shape.
 func (g *Game) randWorldPos() Vec2 {
 	ws := float64(g.cfg.WorldSize)
-	return Vec2{
-		X: 200 + rand.Float64()*(ws-400),
-		Y: 200 + rand.Float64()*(ws-400),
+	for attempts := 0; attempts < 32; attempts++ {
+		p := Vec2{
+			X: randPosInset + g.rng.Float64()*(ws-2*randPosInset),
+			Y: randPosInset + g.rng.Float64()*(ws-2*randPosInset),
+		}
+		if g.arena.EdgeDist(p) >= randPosInset {
+			return p
+		}
 	}
+	return g.worldCenter()
 }
 
-func headRadius(s *Snake) float64 {
-	return HeadRadius + math.Min(float64(len(s.Segments))*0.03, 6)
+func (g *Game) headRadius(s *Snake) float64 {
+	return sim.HeadRadiusFor(len(s.Segments), g.rules.SizeScale)
 }
 
-func bodyRadius(s *Snake) float64 {
-	return BodyRadius + math.Min(float64(len(s.Segments))*0.025, 5)
+func (g *Game) bodyRadius(s *Snake) float64 {
+	return sim.BodyRadiusFor(len(s.Segments), g.rules.SizeScale)
 }
 
 // ---------------------------------------------------------------------------
@@ -240,34 +654,97 @@ func bodyRadius(s *Snake) float64 {
 // ---------------------------------------------------------------------------
 
 func NewGame(cfg GameConfig) *Game {
+	src := &splitMix64{state: uint64(time.Now().UnixNano())}
+	boost, err := boostPolicyFor(cfg.BoostMode)
+	if err != nil {
+		slog.Warn("falling back to the default boost mode", "err", err)
+		boost = meterBoost{}
+	}
+	arena, err := sim.ArenaShapeFor(cfg.Arena, float64(cfg.WorldSize))
+	if err != nil {
+		slog.Warn("falling back to the square arena", "err", err)
+		arena, _ = sim.ArenaShapeFor("square", float64(cfg.WorldSize))
+	}
 	g := &Game{
-		cfg:        cfg,
-		players:    make(map[int]*Player),
-		inputCh:    make(chan InputMsg, 2048),
-		joinCh:     make(chan *Player, 32),
-		leaveCh:    make(chan int, 32),
-		respawnCh:  make(chan int, 32),
-		startTime:  time.Now(),
-		statsReqCh: make(chan chan StatsSnapshot, 4),
+		cfg:         cfg,
+		log:         slog.With("room", DefaultRoomID),
+		roomID:      DefaultRoomID,
+		rng:         rand.New(src),
+		rngSrc:      src,
+		names:       NewNamePolicy(cfg),
+		identities:  NewIdentitySigner(cfg),
+		profiles:    make(map[string]*Profile),
+		boost:       boost,
+		spawn:       balancedSpawn{},
+		arena:       arena,
+		players:     make(map[int]*Player),
+		dir:         director{viewers: make(map[int]*Player)},
+		seenNames:   make(map[string]bool),
+		inputCh:     make(chan InputMsg, 2048),
+		joinCh:      make(chan *Player, 32),
+		directorCh:  make(chan *Player, 4),
+		leaveCh:     make(chan int, 32),
+		respawnCh:   make(chan int, 32),
+		customizeCh: make(chan CustomizeMsg, 32),
+		chatCh:      make(chan ChatMsg, 32),
+		voteCh:      make(chan VoteMsg, 32),
+		crowdCh:     make(chan crowdVote, crowdQueueSize),
+		startTime:   time.Now(),
+		statsReqCh:  make(chan chan StatsSnapshot, 4),
+
+		historyReqCh:  make(chan chan HistorySnapshot, 4),
+		snapshotReqCh: make(chan chan *gameSnapshot, 2),
+		statsSubCh:    make(chan chan StatsSnapshot, 4),
+		statsUnsubCh:  make(chan chan StatsSnapshot, 4),
+		statsSubs:     make(map[chan StatsSnapshot]bool),
+
+		playersReqCh: make(chan chan []PlayerInfo, 4),
+		kickCh:       make(chan kickReq, 4),
+		configReqCh:  make(chan configReq, 4),
+		wakeCh:       make(chan struct{}, 1),
+		shutdownCh:   make(chan time.Duration, 1),
+		stopCh:       make(chan chan struct{}),
+		stopped:      make(chan struct{}),
+		degradeReqCh: make(chan degradeReq, 4),
+		scriptCh:     make(chan map[string]*lua.FunctionProto, 1),
+		aiDebugReqCh: make(chan chan AIDebugSnapshot, 4),
+		accountCh:    make(chan accountLogin, 4),
+
+		achievementsReqCh: make(chan achievementsReq, 4),
+		worldReqCh:        make(chan chan WorldView, 4),
+	}
+
+	g.registerBuiltinCommands()
+	g.enableRotation()
+	cfg = g.cfg // with the first mode applied
+	g.applyMutators()
+	food, err := foodSpawnerFor(cfg.FoodSpawn)
+	if err != nil {
+		g.log.Warn("falling back to uniform food", "err", err)
+		food = uniformFood{}
+	}
+	g.SetFoodSpawner(food)
+	if cfg.ShardCells > 1 {
+		g.shards = newShardGrid(cfg.ShardCells, float64(cfg.WorldSize))
 	}
 
 	used := make(map[string]bool)
 	for i := 0; i < cfg.AICount; i++ {
 		name := aiNames[i%len(aiNames)]
 		if used[name] {
-			name = fmt.Sprintf("%s %d", aiNames[rand.Intn(len(aiNames))], i)
+			name = fmt.Sprintf("%s %d", aiNames[g.rng.Intn(len(aiNames))], i)
 		}
 		used[name] = true
-		pos := g.randWorldPos()
+		pos := g.spawnPos(nil)
 		s := g.createSnake(name, pos.X, pos.Y, i%NumColors, true, nextAIID())
-		extra := rand.Intn(40)
+		extra := g.rng.Intn(40)
 		s.TargetLen += extra
 		s.Score += extra
 		g.snakes = append(g.snakes, s)
 	}
 
 	for i := 0; i < cfg.FoodCount; i++ {
-		g.foods = append(g.foods, g.newFood())
+		g.addFood(g.newFood())
 	}
 	return g
 }
@@ -277,89 +754,88 @@ func NewGame(cfg GameConfig) *Game {
 // ---------------------------------------------------------------------------
 
 func (g *Game) createSnake(name string, x, y float64, colorIdx int, isAI bool, pid int) *Snake {
-	angle := rand.Float64() * 2 * math.Pi
-	segs := make([]Vec2, g.cfg.BaseSnakeLen)
-	for i := range segs {
-		segs[i] = Vec2{
-			X: x - math.Cos(angle)*8*float64(i),
-			Y: y - math.Sin(angle)*8*float64(i),
-		}
-	}
+	segs, angle := sim.LineBody(Vec2{X: x, Y: y}, g.rng.Float64()*2*math.Pi, g.cfg.BaseSnakeLen,
+		g.cfg.SegmentSpacing, g.cfg.Deterministic)
 	return &Snake{
 		Name: name, Segments: segs, Angle: angle, TargetAngle: angle,
 		Speed: g.cfg.BaseSpeed, ColorIdx: colorIdx, IsAI: isAI, PlayerID: pid,
-		TargetLen: g.cfg.BaseSnakeLen, Boost: g.cfg.MaxBoost, Alive: true, InvTimer: 120,
-		AIState: "wander", AITargetAngle: angle,
+		TargetLen: g.cfg.BaseSnakeLen, bodyLen: float64(g.cfg.BaseSnakeLen), Boost: g.cfg.MaxBoost, Alive: true, InvTimer: g.cfg.SpawnProtection,
+		AIState: "wander", AITargetAngle: angle, spawnFrame: g.frame, cell: -1, id: g.newEntityID(),
 	}
 }
 
+// newEntityID returns the next entity ID. Entity IDs identify snakes on the
+// wire (protocol v6) and in the per-player metadata cache. Unlike PlayerID
+// they are never reused: every snake life gets a fresh one, so a respawn is
+// a new entity to clients. Later entity types draw from the same sequence.
+func (g *Game) newEntityID() uint32 {
+	g.nextEntityID++
+	return g.nextEntityID
+}
+
 func (g *Game) growSnake(s *Snake, amt int) {
 	s.TargetLen += amt
 	s.Score += amt
 }
 
-func (g *Game) updateSnake(s *Snake) {
-	if !s.Alive {
-		return
-	}
-	if s.InvTimer > 0 {
-		s.InvTimer--
-	}
-
-	diff := angleDiff(s.Angle, s.TargetAngle)
-	s.Angle += clampF(diff, -g.cfg.TurnSpeed, g.cfg.TurnSpeed) * 1.8
-
-	if s.IsBoosting && s.Boost > 0 && len(s.Segments) > 12 {
-		s.Speed = g.cfg.BoostSpeed
-		s.Boost -= g.cfg.BoostDrain
-		if g.frame%8 == 0 && s.TargetLen > g.cfg.BaseSnakeLen {
-			s.TargetLen--
-			tail := s.Segments[len(s.Segments)-1]
-			g.foods = append(g.foods, &Food{
-				X: tail.X + rand.Float64()*20 - 10,
-				Y: tail.Y + rand.Float64()*20 - 10,
-				ColorIdx: rand.Intn(NumFoodColors),
-				Radius:   FoodRadiusVal,
-				Value:    FoodValueVal,
-			})
-		}
-	} else {
-		s.Speed = g.cfg.BaseSpeed
-		s.IsBoosting = false
-		if s.Boost < g.cfg.MaxBoost {
-			s.Boost += g.cfg.BoostRegen
-		}
-	}
-
-	head := s.Segments[0]
-	newX := head.X + math.Cos(s.Angle)*s.Speed
-	newY := head.Y + math.Sin(s.Angle)*s.Speed
+// substeps returns how many movement steps make up one tick (SimRate /
+// TickRate, at least 1).
+func (g *Game) substeps() int {
+	return max(g.cfg.SimRate/TickRate, 1)
+}
 
-	ws := float64(g.cfg.WorldSize)
-	bm := g.cfg.BoundaryMargin
-	if newX < bm || newX > ws-bm ||
-		newY < bm || newY > ws-bm {
-		if !s.IsAI {
-			log.Printf("[DEATH] '%s' hit boundary (score: %d)", s.Name, s.Score)
-			g.killSnake(s)
-			return
-		}
-		s.TargetAngle = math.Atan2(ws/2-head.Y, ws/2-head.X)
+// updateBoost runs once per tick, after the tick's first substep moved s:
+// boost meter, trail and the food shed while boosting.
+func (g *Game) updateBoost(s *Snake) {
+	g.rules.Boost.Update(g, s, s.IsBoosting)
+	if !s.IsBoosting {
 		return
 	}
-
-	// Prepend new head
-	s.Segments = append([]Vec2{{newX, newY}}, s.Segments...)
-	for len(s.Segments) > s.TargetLen {
-		s.Segments = s.Segments[:len(s.Segments)-1]
+	if g.cfg.LaserTail {
+		g.emitTrail(s)
+	}
+	if g.every(8) && s.TargetLen > g.cfg.BaseSnakeLen {
+		s.TargetLen--
+		tail := s.Segments[len(s.Segments)-1]
+		g.addFood(&Food{
+			X:        tail.X + g.rng.Float64()*20 - 10,
+			Y:        tail.Y + g.rng.Float64()*20 - 10,
+			ColorIdx: g.rng.Intn(NumFoodColors),
+			Radius:   FoodRadiusVal,
+			Value:    FoodValueVal,
+		})
 	}
 }
 
-func (g *Game) killSnake(s *Snake) {
+// killSnake kills s, drops its body as food and records the death for the
+// death report and the kill counters. killer is the snake s ran into, or
+// nil for boundary and predator deaths and for trails whose owner has left
+// the world; cause is collision, trail, boundary or predator.
+func (g *Game) killSnake(s, killer *Snake, cause string) {
 	if !s.Alive {
 		return
 	}
 	s.Alive = false
+	s.killedBy, s.deathCause = killer, cause
+	s.assistedBy = g.findAssists(s, killer)
+	if killer == nil {
+		switch cause {
+		case "predator":
+			g.predatorKills++
+		case "boundary":
+			g.boundaryDeaths++
+		}
+		g.log.Info("snake died", append(snakeAttrs(s), "cause", cause)...)
+	} else {
+		g.totalKills++
+		if killer.IsAI {
+			g.aiKills++
+		} else {
+			g.playerKills++
+		}
+		g.log.Info("snake killed", append(killAttrs(s, killer), "cause", cause)...)
+	}
+	g.logDeath(s, killer, cause)
 
 	step := len(s.Segments) / g.cfg.KillFoodCount
 	if step < 1 {
@@ -367,11 +843,11 @@ func (g *Game) killSnake(s *Snake) {
 	}
 	for i := 0; i < len(s.Segments); i += step {
 		seg := s.Segments[i]
-		g.foods = append(g.foods, &Food{
-			X: seg.X + rand.Float64()*30 - 15, Y: seg.Y + rand.Float64()*30 - 15,
-			ColorIdx: rand.Intn(NumFoodColors),
-			Radius:   7 + rand.Float64()*4,
-			Value:    2 + rand.Float64()*3,
+		g.addFood(&Food{
+			X: seg.X + g.rng.Float64()*30 - 15, Y: seg.Y + g.rng.Float64()*30 - 15,
+			ColorIdx: g.rng.Intn(NumFoodColors),
+			Radius:   7 + g.rng.Float64()*4,
+			Value:    2 + g.rng.Float64()*3,
 		})
 	}
 
@@ -381,9 +857,11 @@ func (g *Game) killSnake(s *Snake) {
 }
 
 func (g *Game) respawnAI(s *Snake) {
-	pos := g.randWorldPos()
-	*s = *g.createSnake(s.Name, pos.X, pos.Y, rand.Intn(NumColors), true, nextAIID())
-	extra := rand.Intn(40)
+	pos := g.spawnPos(nil)
+	crowd := s.crowd
+	*s = *g.createSnake(s.Name, pos.X, pos.Y, g.rng.Intn(NumColors), true, nextAIID())
+	s.crowd = crowd
+	extra := g.aiExtraLen()
 	s.TargetLen += extra
 	s.Score += extra
 }
@@ -392,13 +870,30 @@ func (g *Game) respawnAI(s *Snake) {
 // AI
 // ---------------------------------------------------------------------------
 
-func (g *Game) updateAI(s *Snake) {
-	if !s.Alive || !s.IsAI {
-		return
+// updateAI picks the heading and boost of bot s for this tick. It reports
+// whether the bot should steer around crowds on the way (see
+// sim/steer.go), which it doesn't while fleeing a trail or an eel.
+func (g *Game) updateAI(s *Snake) bool {
+	if !s.Alive || !s.IsAI || s.crowd {
+		return false
 	}
-	s.AIStateTimer--
 	head := s.Segments[0]
-	ws := float64(g.cfg.WorldSize)
+	if !g.scriptedAI(s) {
+		g.builtinAI(s, head)
+	}
+
+	if g.cfg.LaserTail && g.avoidTrails(s, head) {
+		return false
+	}
+	if len(g.predators) > 0 && g.avoidPredators(s, head) {
+		return false
+	}
+	return true
+}
+
+// builtinAI is the state machine driving bots without a script.
+func (g *Game) builtinAI(s *Snake, head Vec2) {
+	s.AIStateTimer -= g.clockSteps
 
 	// Check for encirclement every 30 frames
 	if g.frame%30 == 0 {
@@ -410,10 +905,7 @@ func (g *Game) updateAI(s *Snake) {
 	}
 
 	// Near boundary → flee (proportional duration based on proximity)
-	edgeDist := math.Min(
-		math.Min(head.X, ws-head.X),
-		math.Min(head.Y, ws-head.Y),
-	)
+	edgeDist := g.arena.EdgeDist(head)
 	if edgeDist < 300 && s.AIState != "escape" {
 		s.AIState = "flee"
 		if edgeDist < 150 {
@@ -423,6 +915,10 @@ func (g *Game) updateAI(s *Snake) {
 		}
 	}
 
+	if s.AIState != "flee" && s.AIState != "escape" && g.packSteer(s, head) {
+		return
+	}
+
 	// State transition (boundary-aware)
 	if s.AIStateTimer <= 0 {
 		// If still near boundary after flee, force food-seeking (steers inward)
@@ -430,18 +926,18 @@ func (g *Game) updateAI(s *Snake) {
 			s.AIState = "food"
 			s.AIStateTimer = 90
 		} else {
-			r := rand.Float64()
+			r := g.rng.Float64()
 			switch {
 			case r < 0.5:
 				s.AIState = "food"
-				s.AIStateTimer = 60 + rand.Intn(120)
-			case r < 0.8:
+				s.AIStateTimer = 60 + g.rng.Intn(120)
+			case !g.aiHunts(r):
 				s.AIState = "wander"
-				s.AIStateTimer = 60 + rand.Intn(90)
-				s.AITargetAngle = g.safeWanderAngle(head, ws)
+				s.AIStateTimer = 60 + g.rng.Intn(90)
+				s.AITargetAngle = g.safeWanderAngle(head)
 			default:
 				s.AIState = "hunt"
-				s.AIStateTimer = 90 + rand.Intn(110)
+				s.AIStateTimer = 90 + g.rng.Intn(110)
 			}
 		}
 	}
@@ -449,7 +945,7 @@ func (g *Game) updateAI(s *Snake) {
 	switch s.AIState {
 	case "flee":
 		// Steer toward center, no random jitter near corners
-		s.TargetAngle = math.Atan2(ws/2-head.Y, ws/2-head.X)
+		s.TargetAngle = g.angleToCenter(head)
 		s.IsBoosting = edgeDist < 200
 
 	case "escape":
@@ -461,7 +957,7 @@ func (g *Game) updateAI(s *Snake) {
 		var closest *Food
 		closestD := 400.0
 		for _, f := range g.foods {
-			d := dist(head.X, head.Y, f.X, f.Y)
+			d := sim.Dist(head.X, head.Y, f.X, f.Y)
 			if d < closestD {
 				closestD = d
 				closest = f
@@ -471,24 +967,38 @@ func (g *Game) updateAI(s *Snake) {
 			s.TargetAngle = math.Atan2(closest.Y-head.Y, closest.X-head.X)
 		} else {
 			s.AIState = "wander"
-			s.AIStateTimer = 60 + rand.Intn(60)
+			s.AIStateTimer = 60 + g.rng.Intn(60)
 		}
 		s.IsBoosting = false
 
 	case "hunt":
 		var target *Snake
-		targetD := 500.0
+		targetD, targetVia := 500.0, false
+		gate, exit, hasGate := g.portalGate(s, head)
+		// The golden snake is worth chasing regardless of size
+		if gs := g.golden; gs != nil && gs != s && gs.Alive {
+			if d, via := huntDist(head, gs.Segments[0], gate, exit, hasGate); d < BountyHuntRange {
+				target, targetD, targetVia = gs, d, via
+			}
+		}
 		for _, o := range g.snakes {
+			if target != nil && target == g.golden {
+				break // already chasing the bounty
+			}
 			if o == s || !o.Alive || len(o.Segments) > int(float64(len(s.Segments))*1.5) {
 				continue
 			}
-			d := dist(head.X, head.Y, o.Segments[0].X, o.Segments[0].Y)
+			d, via := huntDist(head, o.Segments[0], gate, exit, hasGate)
 			if d < targetD {
-				targetD = d
+				targetD, targetVia = d, via
 				target = o
 			}
 		}
-		if target != nil {
+		if target != nil && targetVia {
+			// The short way is through the portal (see portal.go)
+			s.TargetAngle = math.Atan2(gate.Y-head.Y, gate.X-head.X)
+			s.IsBoosting = false
+		} else if target != nil {
 			th := target.Segments[0]
 			px := th.X + math.Cos(target.Angle)*100
 			py := th.Y + math.Sin(target.Angle)*100
@@ -500,32 +1010,14 @@ func (g *Game) updateAI(s *Snake) {
 
 	default: // wander
 		if g.frame%60 == 0 {
-			s.AITargetAngle += rand.Float64()*1.6 - 0.8
+			s.AITargetAngle += g.rng.Float64()*1.6 - 0.8
+			if gate, _, ok := g.portalGate(s, head); ok && g.rng.Float64() < portalLureChance {
+				s.AITargetAngle = math.Atan2(gate.Y-head.Y, gate.X-head.X)
+			}
 		}
 		s.TargetAngle = s.AITargetAngle
 		s.IsBoosting = false
 	}
-
-	// Collision avoidance (increased range and scan depth)
-	for _, o := range g.snakes {
-		if o == s || !o.Alive {
-			continue
-		}
-		lim := len(o.Segments)
-		if lim > 60 {
-			lim = 60
-		}
-		for k := 0; k < lim; k += 2 {
-			seg := o.Segments[k]
-			d := dist(head.X, head.Y, seg.X, seg.Y)
-			ad := bodyRadius(o) + headRadius(s) + 60
-			if d < ad {
-				s.TargetAngle = math.Atan2(head.Y-seg.Y, head.X-seg.X)
-				s.IsBoosting = d < ad*0.6 && s.Boost > 20
-				return
-			}
-		}
-	}
 }
 
 // checkEncircled casts 8 rays outward from the snake's head to detect
@@ -551,7 +1043,7 @@ func (g *Game) checkEncircled(s *Snake) (bool, float64) {
 				}
 				step := 4
 				for k := 0; k < len(o.Segments); k += step {
-					if distSq(rx, ry, o.Segments[k].X, o.Segments[k].Y) < 900 {
+					if sim.DistSq(rx, ry, o.Segments[k].X, o.Segments[k].Y) < 900 {
 						rayBlocked = true
 						break
 					}
@@ -580,17 +1072,16 @@ func (g *Game) checkEncircled(s *Snake) (bool, float64) {
 
 // safeWanderAngle picks a random wander angle that doesn't point toward
 // a nearby wall (within 500 units).
-func (g *Game) safeWanderAngle(head Vec2, ws float64) float64 {
+func (g *Game) safeWanderAngle(head Vec2) float64 {
 	for attempts := 0; attempts < 8; attempts++ {
-		angle := rand.Float64() * math.Pi * 2
-		testX := head.X + math.Cos(angle)*400
-		testY := head.Y + math.Sin(angle)*400
-		if testX > 200 && testX < ws-200 && testY > 200 && testY < ws-200 {
+		angle := g.rng.Float64() * math.Pi * 2
+		test := Vec2{X: head.X + math.Cos(angle)*400, Y: head.Y + math.Sin(angle)*400}
+		if g.arena.EdgeDist(test) > randPosInset {
 			return angle
 		}
 	}
 	// Fallback: steer toward center
-	return math.Atan2(ws/2-head.Y, ws/2-head.X)
+	return g.angleToCenter(head)
 }
 
 // ---------------------------------------------------------------------------
@@ -598,76 +1089,42 @@ func (g *Game) safeWanderAngle(head Vec2, ws float64) float64 {
 // ---------------------------------------------------------------------------
 
 func (g *Game) newFood() *Food {
-	pos := g.randWorldPos()
-	return &Food{
+	pos := g.food.FoodPos(g)
+	f := &Food{
 		X: pos.X, Y: pos.Y,
-		ColorIdx: rand.Intn(NumFoodColors),
+		ColorIdx: g.rng.Intn(NumFoodColors),
 		Radius:   FoodRadiusVal,
 		Value:    FoodValueVal,
 	}
+	g.rules.Boost.PrepareFood(g, f)
+	return f
 }
 
-func (g *Game) checkFoodCollision(s *Snake) {
-	if !s.Alive {
-		return
-	}
-	head := s.Segments[0]
-	hr := headRadius(s)
+// addFood puts newly spawned food in the world.
+func (g *Game) addFood(f *Food) {
+	g.placeFood(f)
+	g.hookFoodSpawn(f)
+}
 
-	n := len(g.foods)
-	for i := n - 1; i >= 0; i-- {
-		f := g.foods[i]
-		if distSq(head.X, head.Y, f.X, f.Y) < (hr+f.Radius)*(hr+f.Radius) {
-			g.growSnake(s, int(math.Round(f.Value)))
-			// Remove food (swap with last)
-			g.foods[i] = g.foods[len(g.foods)-1]
-			g.foods = g.foods[:len(g.foods)-1]
-		}
-	}
+// placeFood assigns f the next food ID and puts it in the world.
+func (g *Game) placeFood(f *Food) {
+	g.nextFoodID++
+	f.ID = g.nextFoodID
+	f.Expires = g.foodExpiry()
+	g.foods = append(g.foods, f)
 }
 
 // ---------------------------------------------------------------------------
-// Snake-snake collision
+// Snake-snake collision (see physics.go)
 // ---------------------------------------------------------------------------
 
-func (g *Game) checkSnakeCollisions() {
-	for _, s := range g.snakes {
-		if !s.Alive || s.InvTimer > 0 {
-			continue
-		}
-		head := s.Segments[0]
-		hr := headRadius(s)
-
-		for _, o := range g.snakes {
-			if o == s || !o.Alive {
-				continue
-			}
-			// Early-out: rough distance check against other snake's head
-			oh := o.Segments[0]
-			maxReach := float64(len(o.Segments)) * 8
-			if distSq(head.X, head.Y, oh.X, oh.Y) > (maxReach+hr+50)*(maxReach+hr+50) {
-				continue
-			}
-
-			br := bodyRadius(o)
-			threshold := hr + br - 4
-			thresholdSq := threshold * threshold
-
-			for k := 5; k < len(o.Segments); k++ {
-				seg := o.Segments[k]
-				if distSq(head.X, head.Y, seg.X, seg.Y) < thresholdSq {
-					g.totalKills++
-					log.Printf("[KILL] '%s' killed by '%s' (score: %d)", s.Name, o.Name, s.Score)
-					g.killSnake(s)
-					g.growSnake(o, int(float64(len(s.Segments))*0.3))
-					break
-				}
-			}
-			if !s.Alive {
-				break
-			}
-		}
-	}
+// collisionKill kills s for running into o.
+func (g *Game) collisionKill(s, o *Snake) {
+	g.killSnake(s, o, "collision")
+	g.rewardKill(s, o)
+	g.claimBounty(s, o)
+	g.hookKill(s, o)
+	g.reportDeath(s)
 }
 
 // ---------------------------------------------------------------------------
@@ -678,18 +1135,61 @@ func (g *Game) drainMessages() {
 	for {
 		select {
 		case msg := <-g.inputCh:
-			if p, ok := g.players[msg.PlayerID]; ok && p.snake != nil && p.snake.Alive {
+			p, ok := g.players[msg.PlayerID]
+			if ok && msg.HasSeq {
+				p.inputSeq, p.hasInputSeq = msg.Seq, true
+			}
+			if ok && p.snake != nil && p.snake.Alive {
+				checkSpawnInput(p.snake, msg)
 				p.snake.TargetAngle = msg.Angle
 				p.snake.IsBoosting = msg.Boost
 			}
 		case p := <-g.joinCh:
 			g.handleJoin(p)
+		case p := <-g.directorCh:
+			g.handleDirector(p)
 		case id := <-g.leaveCh:
 			g.handleLeave(id)
 		case id := <-g.respawnCh:
 			g.handleRespawn(id)
+		case msg := <-g.customizeCh:
+			g.handleCustomize(msg)
+		case msg := <-g.chatCh:
+			g.handleChat(msg)
+		case msg := <-g.voteCh:
+			g.handleVote(msg)
+		case v := <-g.crowdCh:
+			g.handleCrowdVote(v)
+		case req := <-g.degradeReqCh:
+			g.handleDegrade(req)
 		case replyCh := <-g.statsReqCh:
 			replyCh <- g.buildSnapshot()
+		case replyCh := <-g.snapshotReqCh:
+			replyCh <- g.captureSnapshot()
+		case replyCh := <-g.historyReqCh:
+			replyCh <- g.history.snapshot()
+		case ch := <-g.statsSubCh:
+			g.statsSubs[ch] = true
+		case ch := <-g.statsUnsubCh:
+			delete(g.statsSubs, ch)
+		case replyCh := <-g.playersReqCh:
+			replyCh <- g.buildPlayerList()
+		case r := <-g.kickCh:
+			g.handleKick(r)
+		case d := <-g.shutdownCh:
+			g.announceShutdown(d)
+		case r := <-g.configReqCh:
+			g.handleConfig(r)
+		case protos := <-g.scriptCh:
+			g.scripts.install(protos)
+		case replyCh := <-g.aiDebugReqCh:
+			replyCh <- g.buildAIDebug()
+		case l := <-g.accountCh:
+			g.handleAccountLogin(l)
+		case r := <-g.achievementsReqCh:
+			r.reply <- g.buildAchievements(r.identity)
+		case replyCh := <-g.worldReqCh:
+			replyCh <- g.buildWorldView()
 		default:
 			return
 		}
@@ -697,6 +1197,9 @@ func (g *Game) drainMessages() {
 }
 
 func (g *Game) handleJoin(p *Player) {
+	if _, ok := g.players[p.id]; ok {
+		return // joined already
+	}
 	// Remove one AI to make room
 	for i, s := range g.snakes {
 		if s.IsAI && s.Alive {
@@ -705,9 +1208,14 @@ func (g *Game) handleJoin(p *Player) {
 		}
 	}
 
-	pos := g.randWorldPos()
-	snake := g.createSnake(p.name, pos.X, pos.Y, rand.Intn(NumColors), false, p.id)
+	g.accountName(p)
+	p.beginner = g.firstSession(p.name)
+	p.name = g.uniqueName(p.name)
+	pos := g.spawnPos(p)
+	snake := g.createSnake(p.name, pos.X, pos.Y, g.rng.Intn(NumColors), false, p.id)
 	p.snake = snake
+	g.joinProfile(p)
+	g.applySizeTicket(p)
 	g.snakes = append(g.snakes, snake)
 	g.players[p.id] = p
 	g.totalJoins++
@@ -715,47 +1223,67 @@ func (g *Game) handleJoin(p *Player) {
 	if current > g.peakPlayers {
 		g.peakPlayers = current
 	}
-	log.Printf("[JOIN] Player %d '%s' joined (players: %d, peak: %d)", p.id, p.name, current, g.peakPlayers)
+	g.log.Info("player joined", "playerID", p.id, "snakeID", snake.PlayerID, "name", p.name,
+		"format", p.serializer.Name(), "beginner", p.beginner, "players", current, "peak", g.peakPlayers)
+	g.logJoin(p)
+	g.sendInit(p)
+	g.sendMOTD(p)
+	g.hookJoin(p)
+	g.rotationJoin(p)
 
 	// Send full initial state
-	data := g.serializeStateFor(p, true)
+	data, sections := g.initialStateFor(p)
 	select {
 	case p.sendCh <- data:
+		g.queueSections(p, sections)
 	default:
 	}
+	g.checkSpawnClass(p)
 }
 
 func (g *Game) handleLeave(id int) {
+	if g.leaveDirector(id) {
+		return
+	}
 	p, ok := g.players[id]
 	if !ok {
 		return
 	}
 	g.totalLeaves++
-	log.Printf("[LEAVE] Player %d '%s' left (players: %d)", id, p.name, len(g.players)-1)
+	g.log.Info("player left", "playerID", id, "name", p.name, "players", len(g.players)-1)
+	g.logLeave(p)
 
-	// Remove player's snake, replace with AI
-	if p.snake != nil {
-		for i, s := range g.snakes {
-			if s == p.snake {
-				g.snakes = append(g.snakes[:i], g.snakes[i+1:]...)
-				break
-			}
-		}
-		pos := g.randWorldPos()
-		name := aiNames[rand.Intn(len(aiNames))]
-		ai := g.createSnake(name, pos.X, pos.Y, rand.Intn(NumColors), true, nextAIID())
-		extra := rand.Intn(40)
-		ai.TargetLen += extra
-		ai.Score += extra
-		g.snakes = append(g.snakes, ai)
+	if p.snake != nil && p.snake.Alive {
+		g.recordProfile(p)
 	}
-
+	g.replaceWithAI(p)
 	delete(g.players, id)
 }
 
+// replaceWithAI removes p's snake, if any, and spawns an AI snake in its
+// place.
+func (g *Game) replaceWithAI(p *Player) {
+	if p.snake == nil {
+		return
+	}
+	for i, s := range g.snakes {
+		if s == p.snake {
+			g.snakes = append(g.snakes[:i], g.snakes[i+1:]...)
+			break
+		}
+	}
+	pos := g.spawnPos(nil)
+	name := g.uniqueName(aiNames[g.rng.Intn(len(aiNames))])
+	ai := g.createSnake(name, pos.X, pos.Y, g.rng.Intn(NumColors), true, nextAIID())
+	extra := g.aiExtraLen()
+	ai.TargetLen += extra
+	ai.Score += extra
+	g.snakes = append(g.snakes, ai)
+}
+
 func (g *Game) handleRespawn(id int) {
 	p, ok := g.players[id]
-	if !ok || p.snake == nil || p.snake.Alive {
+	if !ok || p.snake == nil || p.snake.Alive || !g.respawnAllowed(p) {
 		return
 	}
 
@@ -767,17 +1295,45 @@ func (g *Game) handleRespawn(id int) {
 		}
 	}
 
-	pos := g.randWorldPos()
-	snake := g.createSnake(p.name, pos.X, pos.Y, rand.Intn(NumColors), false, p.id)
+	pos := g.spawnPos(p)
+	snake := g.createSnake(p.name, pos.X, pos.Y, g.rng.Intn(NumColors), false, p.id)
 	p.snake = snake
+	g.dressSnake(p)
+	p.setCamera(nil)
 	g.snakes = append(g.snakes, snake)
-	// Invalidate metadata cache for this player's snake in all other players
-	for _, other := range g.players {
-		if other.knownSnakes != nil {
-			delete(other.knownSnakes, p.id)
+	g.log.Info("player respawned", "playerID", id, "snakeID", snake.PlayerID, "name", p.name)
+	g.checkSpawnClass(p)
+}
+
+// handleCustomize applies a name/color/skin change to a living player snake and
+// makes every client re-receive its metadata.
+func (g *Game) handleCustomize(msg CustomizeMsg) {
+	p, ok := g.players[msg.PlayerID]
+	if !ok || p.snake == nil || !p.snake.Alive {
+		return
+	}
+	s := p.snake
+	if msg.Name != "" && msg.Name != s.Name {
+		old := s.Name
+		s.Name = g.uniqueNameExcept(msg.Name, s)
+		p.name = s.Name
+		g.log.Info("player renamed", "playerID", p.id, "snakeID", s.PlayerID, "from", old, "name", s.Name)
+	}
+	if msg.ColorIdx >= 0 {
+		s.ColorIdx = msg.ColorIdx
+	}
+	if msg.Skin >= 0 {
+		if !g.skinUnlocked(p, msg.Skin) {
+			g.log.Debug("skin locked", "playerID", p.id, "skin", msg.Skin, "level", g.playerLevel(p))
+			msg.Skin = -1
+		} else {
+			s.skin = msg.Skin
 		}
 	}
-	log.Printf("[RESPAWN] Player %d '%s' respawned", id, p.name)
+	g.profileCustomize(p, msg.Name, msg.ColorIdx, msg.Skin)
+	for _, other := range g.players {
+		delete(other.knownSnakes, s.id)
+	}
 }
 
 // ---------------------------------------------------------------------------
@@ -821,7 +1377,26 @@ func (g *Game) buildSnapshot() StatsSnapshot {
 		bwKBps = float64(bwTotal) / float64(bwCount) / 1024.0
 	}
 
-	aiCount := 0
+	var rttSum, rttMax int64
+	rttCount, throttled := 0, 0
+	for _, p := range g.players {
+		if p.sendInterval() > 1 {
+			throttled++
+		}
+		if rtt := p.rttMs.Load(); rtt > 0 {
+			rttSum += rtt
+			rttCount++
+			if rtt > rttMax {
+				rttMax = rtt
+			}
+		}
+	}
+	avgRTT := 0.0
+	if rttCount > 0 {
+		avgRTT = float64(rttSum) / float64(rttCount)
+	}
+
+	aiCount, decaying := 0, 0
 	lb := make([]LeaderboardEntry, 0, len(g.snakes))
 	for _, s := range g.snakes {
 		if s.IsAI && s.Alive {
@@ -833,7 +1408,11 @@ func (g *Game) buildSnapshot() StatsSnapshot {
 				Score:   s.Score,
 				IsAI:    s.IsAI,
 				IsAlive: s.Alive,
+				Decay:   g.decaying(s),
 			})
+			if g.decaying(s) {
+				decaying++
+			}
 		}
 	}
 	sort.Slice(lb, func(i, j int) bool { return lb[i].Score > lb[j].Score })
@@ -841,75 +1420,175 @@ func (g *Game) buildSnapshot() StatsSnapshot {
 		lb = lb[:20]
 	}
 
-	return StatsSnapshot{
+	snap := StatsSnapshot{
 		Version:        Version,
 		Uptime:         formatDuration(uptime),
 		UptimeSec:      int64(uptime.Seconds()),
 		TotalJoins:     g.totalJoins,
 		TotalLeaves:    g.totalLeaves,
 		TotalKills:     g.totalKills,
+		AIKills:        g.aiKills,
+		PlayerKills:    g.playerKills,
+		BoundaryDeaths: g.boundaryDeaths,
+		FoodExpired:    g.expiredFood,
+		Difficulty:     math.Round(g.difficulty()*100) / 100,
+		Mutators:       g.activeMutators(),
+		PredatorKills:  g.predatorKills,
+		Predators:      len(g.predators),
+		PredatorsDown:  g.predatorsDown,
+		BestStreak:     g.bestStreak,
+		Assists:        g.totalAssists,
+		HeadOns:        g.headOns,
+		PortalJumps:    g.portalJumps,
+		SizeClassMoves: g.sizeClassMoves,
+		FillerBots:     g.fillerCount(),
+		ChatVotes:      g.crowd.totalVotes,
 		PeakPlayers:    g.peakPlayers,
 		CurrentPlayers: len(g.players),
+		Directors:      len(g.dir.viewers),
 		AICount:        aiCount,
 		FoodCount:      len(g.foods),
 		AvgTickMs:      math.Round(avgMs*100) / 100,
 		MaxTickMs:      math.Round(g.maxTickMs*100) / 100,
+		Stages:         g.stageStats(),
 		BandwidthKBps:  math.Round(bwKBps*100) / 100,
 		TotalBytesSent: g.totalBytesSent,
 		TotalBytesRecv: atomic.LoadInt64(&g.totalBytesRecv),
+		AvgRTTMs:       math.Round(avgRTT*10) / 10,
+		MaxRTTMs:       rttMax,
+		Throttled:      throttled,
+		SplitFrames:    g.splitFrames,
+		SectionDrops:   g.droppedSections,
+		Decaying:       decaying,
 		Frame:          g.frame,
+		TimeScale:      g.cfg.TimeScale,
+		Idle:           g.idle.String(),
+		TickRate:       g.tickRate(),
+		Mode:           g.modeName(),
 		Leaderboard:    lb,
 	}
+	if g.golden != nil {
+		snap.Golden = g.golden.Name
+	}
+	if g.decayEnabled() {
+		snap.DecayThreshold = g.cfg.DecayThreshold
+	}
+	if g.shards != nil {
+		snap.Shards = g.shards.stats()
+	}
+	if g.cfg.TickBudget > 0 || g.wd.manual {
+		d := g.degradeStatus()
+		snap.Degrade = &d
+	}
+	if g.roundsEnabled() && g.round.round > 0 {
+		snap.Round = g.round.round
+		snap.RoundPhase = g.round.phase.String()
+		snap.RoundRemaining = g.roundRemaining()
+	}
+	return snap
 }
 
 // ---------------------------------------------------------------------------
 // Tick + Run
 // ---------------------------------------------------------------------------
 
-func (g *Game) tick() {
-	start := time.Now()
-
-	g.frame++
-	g.drainMessages()
-
-	for _, s := range g.snakes {
-		if !s.Alive {
-			if s.IsAI {
-				s.RespawnTmr--
-				if s.RespawnTmr <= 0 {
-					g.respawnAI(s)
+// simulate advances the world by one frame: AI, the physics step (see
+// physics.go), trails and food refill. The physics runs in substeps()
+// steps per game tick when SimRate is above TickRate, and TimeScale above
+// 1 adds steps for the extra game ticks.
+func (g *Game) simulate() {
+	t := g.lapStart()
+	g.updatePacks()
+	t = g.lap(stageAI, t)
+	per := g.substeps()
+	n := per * max(g.clockSteps, 1)
+	for sub := 0; sub < n; sub++ {
+		if sub == 0 {
+			g.prepareTick()
+			t = g.lap(stageAI, t)
+		}
+		g.step(sub, n, per)
+		t = g.lap(stageMove, t)
+		g.applyEvents()
+		if sub == 0 {
+			for _, s := range g.snakes {
+				if s.Alive {
+					g.updateBoost(s)
 				}
 			}
-			continue
 		}
-		if s.IsAI {
-			g.updateAI(s)
+		if g.cfg.LaserTail {
+			if sub == n-1 {
+				g.updateTrails()
+			}
+			g.checkTrailCollisions()
 		}
-		g.updateSnake(s)
-		g.checkFoodCollision(s)
+		t = g.lap(stageCollide, t)
 	}
+	g.recordTraces()
+	g.updateGlow()
+	g.updateDecay()
+	g.updatePredators()
+	g.updatePortals()
 
-	g.checkSnakeCollisions()
-
+	g.expireFood()
 	for len(g.foods) < g.cfg.FoodCount {
-		g.foods = append(g.foods, g.newFood())
+		g.addFood(g.newFood())
 	}
+	g.lap(stageFood, t)
+}
+
+func (g *Game) tick() {
+	start := time.Now()
+	sentBefore := g.totalBytesSent
+
+	g.frame++
+	t := g.lapStart()
+	g.drainMessages()
+	g.lap(stageDrain, t)
+	g.advanceClock()
+	g.hookBeforeTick()
+
+	g.updateRound()
+	g.updateRotation()
+	if !g.roundFrozen() {
+		g.simulate()
+	}
+	g.updateBounty()
+	g.updateAnnouncements()
+	g.updateChatSnake()
+	if g.frame%TickRate == 0 {
+		g.updateFill()
+		g.updateDifficulty()
+		g.updateSizeClasses()
+		g.checkAchievements()
+		g.updateChallenges()
+		g.updateDirector()
+	}
+	g.hookAfterTick()
 
 	if g.frame%NetTickRate == 0 {
+		t := g.lapStart()
 		g.netTick++
-		includeFood := g.netTick%FoodSyncRate == 0
-		includeSummary := g.netTick%2 == 0
-		g.broadcast(includeFood, includeSummary)
+		g.broadcast()
+		g.recordReplay()
+		g.lap(stageBroadcast, t)
 	}
 
 	// Track tick performance
 	elapsed := time.Since(start)
 	g.tickDurations[g.tickDurIdx%len(g.tickDurations)] = elapsed
+	g.recordStages()
 	g.tickDurIdx++
+	g.updateWatchdog(elapsed)
 	ms := float64(elapsed.Nanoseconds()) / 1e6
 	if ms > g.maxTickMs {
 		g.maxTickMs = ms
 	}
+	g.history.addTick(elapsed, g.totalBytesSent-sentBefore)
+	if g.frame%HistoryInterval == 0 {
+		g.history.sample(len(g.players), g.totalKills)
+	}
 
 	// Flush bandwidth accumulator every second (every TickRate frames)
 	if g.frame-g.bwLastSec >= TickRate {
@@ -919,19 +1598,63 @@ func (g *Game) tick() {
 		g.bwLastSec = g.frame
 	}
 
+	// Push stats to subscribers once per second
+	if g.frame%TickRate == 0 && len(g.statsSubs) > 0 {
+		snap := g.buildSnapshot()
+		for ch := range g.statsSubs {
+			select {
+			case ch <- snap:
+			default: // subscriber hasn't consumed the last one yet
+			}
+		}
+	}
+
+	if g.autosaveEvery > 0 && g.frame%g.autosaveEvery == 0 {
+		g.autosave()
+	}
+
 	// Periodic stats every ~30 seconds
 	if g.frame%1800 == 0 {
 		snap := g.buildSnapshot()
-		log.Printf("[STATS] uptime=%s players=%d peak=%d ai=%d kills=%d food=%d avgTick=%.2fms maxTick=%.2fms bw=%.1fKB/s",
-			snap.Uptime, snap.CurrentPlayers, snap.PeakPlayers, snap.AICount,
-			snap.TotalKills, snap.FoodCount, snap.AvgTickMs, snap.MaxTickMs, snap.BandwidthKBps)
+		g.log.Info("stats", "uptime", snap.Uptime, "players", snap.CurrentPlayers, "peak", snap.PeakPlayers,
+			"ai", snap.AICount, "kills", snap.TotalKills, "food", snap.FoodCount,
+			"avgTickMs", snap.AvgTickMs, "maxTickMs", snap.MaxTickMs, "bandwidthKBps", snap.BandwidthKBps)
 	}
 }
 
 func (g *Game) Run() {
-	ticker := time.NewTicker(time.Second / TickRate)
+	interval := time.Second / TickRate
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
-	for range ticker.C {
+	g.lastActive = time.Now()
+	for {
+		g.lastLoop.Store(time.Now().UnixNano())
+		select {
+		case <-ticker.C:
+		case done := <-g.stopCh:
+			g.stop()
+			close(g.stopped)
+			close(done)
+			return
+		case <-g.wakeCh:
+			g.lastActive = time.Now()
+			if g.idle == idleAwake {
+				continue
+			}
+		}
+		if s := g.idleTarget(time.Now()); s != g.idle {
+			g.log.Info("idle state changed", "from", g.idle, "to", s)
+			g.idle = s
+		}
+		// Also picks up IdleTickRate changes made at runtime
+		if d := g.tickInterval(g.idle); d != interval {
+			interval = d
+			ticker.Reset(d)
+		}
+		if g.idle == idlePaused {
+			g.drainMessages()
+			continue
+		}
 		g.tick()
 	}
 }
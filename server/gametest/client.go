@@ -0,0 +1,301 @@
+package gametest
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"snake-server/wire"
+)
+
+// ---------------------------------------------------------------------------
+// In-process WebSocket client
+//
+// A Client reads in the background from Dial until Close. v1 state frames
+// are decoded and queued for NextState, JSON text messages are queued as
+// events for NextEvent, and pings are answered at once so the server sees
+// a healthy connection. Each queue keeps the newest stateQueue or
+// eventQueue entries; a test that stops reading for a while misses the old
+// frames, as a slow browser would, but never blocks the server.
+// ---------------------------------------------------------------------------
+
+const (
+	stateQueue = 64
+	eventQueue = 256
+)
+
+// Event is a JSON text message from the server, such as "death" or "chat".
+type Event map[string]any
+
+// Type returns the event's "t" field.
+func (e Event) Type() string {
+	t, _ := e["t"].(string)
+	return t
+}
+
+// Client is a player connection to a Server.
+type Client struct {
+	ID      int   // player ID from the welcome message
+	Welcome Event // the welcome message
+
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	states  chan *wire.State
+	events  chan Event
+	done    chan struct{}
+	err     error // why the read loop ended, set before done is closed
+}
+
+// Dial connects a new client to the server's /ws and waits for the
+// welcome message.
+func (s *Server) Dial() (*Client, error) {
+	return s.DialPath("/ws")
+}
+
+// DialPath is Dial for a WebSocket handler at path, e.g. "/ws?room=duel".
+func (s *Server) DialPath(path string) (*Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	d := websocket.Dialer{NetDialContext: s.ln.dial, HandshakeTimeout: defaultTimeout}
+	conn, _, err := d.DialContext(ctx, "ws://gametest"+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("gametest: dial %s: %w", path, err)
+	}
+	conn.SetReadDeadline(time.Now().Add(defaultTimeout))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("gametest: reading welcome: %w", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+	var welcome Event
+	if err := json.Unmarshal(data, &welcome); err != nil || welcome.Type() != "welcome" {
+		conn.Close()
+		return nil, fmt.Errorf("gametest: expected a welcome message, got %q", data)
+	}
+	pid, _ := welcome["pid"].(float64)
+	c := &Client{
+		ID:      int(pid),
+		Welcome: welcome,
+		conn:    conn,
+		states:  make(chan *wire.State, stateQueue),
+		events:  make(chan Event, eventQueue),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *Client) readLoop() {
+	defer close(c.done)
+	for {
+		typ, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.err = err
+			return
+		}
+		if typ == websocket.TextMessage {
+			var ev Event
+			if err := json.Unmarshal(data, &ev); err != nil {
+				c.err = fmt.Errorf("gametest: bad text message %q: %w", data, err)
+				return
+			}
+			push(c.events, ev)
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		switch data[0] {
+		case wire.TypeState:
+			st, err := wire.DecodeState(data)
+			if err != nil {
+				c.err = fmt.Errorf("gametest: bad state frame: %w", err)
+				return
+			}
+			push(c.states, st)
+		case wire.TypePing:
+			if len(data) >= 5 {
+				pong := []byte{wire.TypePong, 0, 0, 0, 0}
+				copy(pong[1:], data[1:5])
+				c.write(websocket.BinaryMessage, pong)
+			}
+		}
+	}
+}
+
+// push queues v, dropping the oldest entry if the queue is full. The read
+// loop is the only sender.
+func push[T any](ch chan T, v T) {
+	for {
+		select {
+		case ch <- v:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+func (c *Client) write(typ int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(defaultTimeout))
+	return c.conn.WriteMessage(typ, data)
+}
+
+// Send sends msg as a JSON text message.
+func (c *Client) Send(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.write(websocket.TextMessage, data)
+}
+
+// SendBinary sends a raw binary message, e.g. a malformed one.
+func (c *Client) SendBinary(data []byte) error {
+	return c.write(websocket.BinaryMessage, data)
+}
+
+// Join joins the game as name, asking for v1 state frames with uint32
+// scores.
+func (c *Client) Join(name string) error {
+	return c.Send(map[string]any{"t": "join", "name": name, "proto": 1, "wideScores": true})
+}
+
+// Respawn asks for a new snake after a death.
+func (c *Client) Respawn() error {
+	return c.Send(map[string]any{"t": "respawn"})
+}
+
+// Steer sends an input: head for angle (radians), boosting or not.
+func (c *Client) Steer(angle float64, boost bool) error {
+	return c.SendBinary(inputFrame(angle, boost))
+}
+
+// SteerSeq is Steer with a sequence number for input acknowledgements.
+func (c *Client) SteerSeq(angle float64, boost bool, seq uint16) error {
+	return c.SendBinary(binary.BigEndian.AppendUint16(inputFrame(angle, boost), seq))
+}
+
+func inputFrame(angle float64, boost bool) []byte {
+	angle = math.Remainder(angle, 2*math.Pi) // the wire holds (-π, π]
+	buf := []byte{wire.TypeInput, 0, 0, 0}
+	binary.BigEndian.PutUint16(buf[1:], uint16(int16(math.Round(angle*10000))))
+	if boost {
+		buf[3] = 1
+	}
+	return buf
+}
+
+// ErrTimeout is returned when nothing matching arrived in time.
+var ErrTimeout = errors.New("gametest: timed out")
+
+// NextState returns the next state frame.
+func (c *Client) NextState(timeout time.Duration) (*wire.State, error) {
+	return next(c, c.states, timeout)
+}
+
+// NextEvent returns the next JSON event.
+func (c *Client) NextEvent(timeout time.Duration) (Event, error) {
+	return next(c, c.events, timeout)
+}
+
+func next[T any](c *Client, ch chan T, timeout time.Duration) (T, error) {
+	var zero T
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case v := <-ch:
+		return v, nil
+	case <-timer.C:
+		return zero, ErrTimeout
+	case <-c.done:
+		select {
+		case v := <-ch: // queued before the connection closed
+			return v, nil
+		default:
+		}
+		return zero, fmt.Errorf("gametest: connection closed: %w", c.err)
+	}
+}
+
+// WaitState returns the first state frame within timeout for which cond
+// is true.
+func (c *Client) WaitState(timeout time.Duration, cond func(*wire.State) bool) (*wire.State, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		st, err := c.NextState(time.Until(deadline))
+		if err != nil {
+			return nil, err
+		}
+		if cond(st) {
+			return st, nil
+		}
+	}
+}
+
+// WaitEvent returns the first event of type typ within timeout, skipping
+// other events.
+func (c *Client) WaitEvent(timeout time.Duration, typ string) (Event, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		ev, err := c.NextEvent(time.Until(deadline))
+		if err != nil {
+			return nil, err
+		}
+		if ev.Type() == typ {
+			return ev, nil
+		}
+	}
+}
+
+// Expect is WaitState that fails the test with what was expected.
+func (c *Client) Expect(t testing.TB, timeout time.Duration, what string, cond func(*wire.State) bool) *wire.State {
+	t.Helper()
+	st, err := c.WaitState(timeout, cond)
+	if err != nil {
+		t.Fatalf("player %d: expected %s: %v", c.ID, what, err)
+	}
+	return st
+}
+
+// ExpectEvent is WaitEvent that fails the test with the event type.
+func (c *Client) ExpectEvent(t testing.TB, timeout time.Duration, typ string) Event {
+	t.Helper()
+	ev, err := c.WaitEvent(timeout, typ)
+	if err != nil {
+		t.Fatalf("player %d: expected %q event: %v", c.ID, typ, err)
+	}
+	return ev
+}
+
+// Own returns the client's own snake in st, if it is in the frame. v1
+// frames identify snakes by player ID.
+func (c *Client) Own(st *wire.State) (wire.Snake, bool) {
+	for _, s := range st.Snakes {
+		if s.ID == c.ID {
+			return s, true
+		}
+	}
+	return wire.Snake{}, false
+}
+
+// Close closes the connection; the server handles it as a leave.
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
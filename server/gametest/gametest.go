@@ -0,0 +1,123 @@
+// Package gametest runs end-to-end tests of the game server in process.
+// A Server serves an http.Handler, usually a mux with the /ws handler of
+// a running Game, on an in-memory listener, and its Clients connect to it
+// over WebSocket as a browser would: they join, steer and read the v1
+// state frames and JSON events the server sends, decoded with package
+// wire. Nothing touches the network, so tests don't depend on free ports
+// or loopback timing, and a change to the protocol or the collision rules
+// shows up as a failed expectation instead of a broken client.
+//
+//	srv := gametest.NewServer(mux)
+//	defer srv.Close()
+//	c, err := srv.Dial()
+//	...
+//	c.Join("Alice")
+//	st := c.Expect(t, 2*time.Second, "own snake alive", func(st *wire.State) bool {
+//		s, ok := c.Own(st)
+//		return ok && s.Alive()
+//	})
+package gametest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// Server serves a handler on an in-memory listener.
+type Server struct {
+	ln  *pipeListener
+	srv *http.Server
+}
+
+// NewServer starts serving h. Close it when done.
+func NewServer(h http.Handler) *Server {
+	s := &Server{ln: newPipeListener(), srv: &http.Server{Handler: h}}
+	go s.srv.Serve(s.ln)
+	return s
+}
+
+// Close stops the server and closes its connections.
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// Client returns an http.Client whose requests go to s, for the HTTP
+// endpoints next to /ws. The host part of request URLs is ignored.
+func (s *Server) Client() *http.Client {
+	return &http.Client{Transport: &http.Transport{DialContext: s.ln.dial}}
+}
+
+// errListenerClosed is returned by Accept and dial after Close.
+var errListenerClosed = errors.New("gametest: listener closed")
+
+// pipeListener is a net.Listener whose connections are net.Pipe pairs.
+// Each connection gets its own client address, 127.0.x.y, so per-IP
+// limits see separate clients.
+type pipeListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+
+	mu   sync.Mutex
+	next uint16
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr{netip.MustParseAddrPort("127.0.0.1:80")} }
+
+func (l *pipeListener) dial(ctx context.Context, _, _ string) (net.Conn, error) {
+	l.mu.Lock()
+	l.next++
+	n := l.next
+	l.mu.Unlock()
+	client := pipeAddr{netip.AddrPortFrom(netip.AddrFrom4([4]byte{127, 0, byte(n >> 8), byte(n)}), 1024+n%60000)}
+
+	c, s := net.Pipe()
+	select {
+	case l.conns <- &pipeConn{Conn: s, local: l.Addr(), remote: client}:
+		return &pipeConn{Conn: c, local: client, remote: l.Addr()}, nil
+	case <-l.done:
+		return nil, errListenerClosed
+	case <-ctx.Done():
+		return nil, fmt.Errorf("gametest: dial: %w", ctx.Err())
+	}
+}
+
+type pipeAddr struct{ netip.AddrPort }
+
+func (pipeAddr) Network() string { return "tcp" }
+
+// pipeConn gives one end of a net.Pipe its addresses.
+type pipeConn struct {
+	net.Conn
+	local, remote net.Addr
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return c.local }
+func (c *pipeConn) RemoteAddr() net.Addr { return c.remote }
+
+// defaultTimeout bounds Dial.
+const defaultTimeout = 5 * time.Second
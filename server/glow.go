@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Boost glow and afterimages
+//
+// Every snake has a glow level from 0 to 1 that the server drives, so all
+// clients draw boost effects the same way. While a snake boosts, the level
+// rises by glowRise per game tick up to 1; when it stops, the level fades
+// by glowFade per tick, a third of a second from full. Game.Glow lets custom
+// rules (hooks, power-ups) light a snake up for a while without boosting.
+//
+// While the level is above zero the snake records its head every
+// afterimageEvery game ticks, keeping the last afterimageLen positions,
+// which clients draw as fading copies of the head behind it. The record is
+// cleared once the glow is gone. v11 frames and the glow and afterimage
+// fields of protobuf frames carry both; older formats only have the
+// boosting flag.
+// ---------------------------------------------------------------------------
+
+const (
+	glowRise        = 0.25     // per game tick while boosting
+	glowFade        = 1.0 / 20 // per game tick after boosting
+	afterimageEvery = 3        // game ticks between recorded head positions
+	afterimageLen   = 6
+)
+
+// glowState is the boost glow of one snake life.
+type glowState struct {
+	level       float64
+	forced      float64 // level held by Glow until forcedUntil
+	forcedUntil int     // game clock
+	heads       [afterimageLen]Vec2
+	n           int // heads recorded; the next goes to n % afterimageLen
+}
+
+// Glow lights s up at level (0 to 1) for the given game ticks, as if it
+// were boosting. Game loop only, e.g. from a hook.
+func (g *Game) Glow(s *Snake, level float64, ticks int) {
+	s.glow.forced = sim.Clamp(level, 0, 1)
+	s.glow.forcedUntil = g.clock + ticks
+}
+
+// updateGlow moves every snake's glow towards its target and records the
+// afterimages. Called once per tick.
+func (g *Game) updateGlow() {
+	steps := float64(g.clockSteps)
+	for _, s := range g.snakes {
+		gl := &s.glow
+		if !s.Alive || len(s.Segments) == 0 {
+			*gl = glowState{}
+			continue
+		}
+		target := 0.0
+		if s.IsBoosting {
+			target = 1
+		}
+		if g.clock < gl.forcedUntil {
+			target = max(target, gl.forced)
+		}
+		if gl.level < target {
+			gl.level = min(gl.level+glowRise*steps, target)
+		} else {
+			gl.level = max(gl.level-glowFade*steps, target)
+		}
+		if gl.level == 0 {
+			gl.n = 0
+			continue
+		}
+		if g.every(afterimageEvery) {
+			gl.heads[gl.n%afterimageLen] = s.Segments[0]
+			gl.n++
+		}
+	}
+}
+
+// afterimage returns the recorded head positions of s, newest first.
+func (s *Snake) afterimage() []Vec2 {
+	gl := &s.glow
+	n := min(gl.n, afterimageLen)
+	heads := make([]Vec2, n)
+	for i := range heads {
+		heads[i] = gl.heads[(gl.n-1-i)%afterimageLen]
+	}
+	return heads
+}
+
+// appendGlow appends the v11 glow record of s: level(uint8, 255 = full),
+// and if it is above zero, count(uint8) and count × dx(int8), dy(int8),
+// the afterimages newest first, each relative to the one before and the
+// first relative to the head.
+func appendGlow(buf []byte, s *Snake) []byte {
+	level := byte(math.Round(s.glow.level * 255))
+	buf = append(buf, level)
+	if level == 0 {
+		return buf
+	}
+	heads := s.afterimage()
+	buf = append(buf, byte(len(heads)))
+	px := clampInt(int(math.Round(s.Segments[0].X)), 0, 65535)
+	py := clampInt(int(math.Round(s.Segments[0].Y)), 0, 65535)
+	for _, h := range heads {
+		dx := clampInt(clampInt(int(math.Round(h.X)), 0, 65535)-px, -128, 127)
+		dy := clampInt(clampInt(int(math.Round(h.Y)), 0, 65535)-py, -128, 127)
+		buf = append(buf, byte(int8(dx)), byte(int8(dy)))
+		px += dx
+		py += dy
+	}
+	return buf
+}
@@ -0,0 +1,47 @@
+package main
+
+import "math"
+
+// ---------------------------------------------------------------------------
+// Body spacing and growth smoothing
+//
+// A snake's length is TargetLen body points and it adds one point per game
+// tick (see sim/step.go), so the simulation's points are BaseSpeed apart,
+// more while boosting. SegmentSpacing is the distance between the points a
+// body is drawn with, independent of how densely it is simulated:
+//
+//   - a freshly spawned body is laid out SegmentSpacing apart
+//   - state frames carry every segmentStride()-th point, the stride that
+//     comes closest to SegmentSpacing at base speed (3 with the defaults);
+//     the init event's rules tell clients how many points to interpolate
+//     between two sent ones
+//   - the early-outs of the collision, assist and shard checks bound a
+//     body by bodySpan
+//
+// TargetLen jumps when a snake eats, kills or collects a bonus, but the
+// body (bodyLen) follows it at GrowthRate points per game tick, so a big
+// orb or a kill extends the tail over several ticks. At the default of 1
+// the body grows as fast as the snake adds points; below 1 it grows more
+// slowly, and above 1 the extra points are stacked on the tail and unfold
+// as the snake moves. Shrinking (boosting, decay) takes effect at once.
+// ---------------------------------------------------------------------------
+
+const (
+	DefaultSegmentSpacing = 8.0
+	DefaultGrowthRate     = 1.0
+
+	MaxSegmentSpacing = 64.0
+	MaxGrowthRate     = 10.0
+)
+
+// segmentStride returns how many body points apart the points in state
+// frames are.
+func (g *Game) segmentStride() int {
+	return max(int(math.Round(g.cfg.SegmentSpacing/g.cfg.BaseSpeed)), 1)
+}
+
+// bodySpan returns how far the body of s can reach from its head, for
+// broad-phase checks.
+func (g *Game) bodySpan(s *Snake) float64 {
+	return float64(len(s.Segments)) * math.Max(g.cfg.SegmentSpacing, g.cfg.BoostSpeed)
+}
@@ -0,0 +1,352 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative controlpb/control.proto
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "snake-server/controlpb"
+)
+
+// ---------------------------------------------------------------------------
+// gRPC control plane (see controlpb/control.proto)
+// ---------------------------------------------------------------------------
+
+type controlServer struct {
+	pb.UnimplementedControlServer
+	rooms *Rooms
+}
+
+var errNoAdminToken = errors.New("the control API requires an admin token")
+
+// ServeControl runs the gRPC control-plane API on addr. Blocks until the
+// listener fails. It refuses to run without an admin token: the API kicks
+// players, changes the config and lists player addresses.
+func ServeControl(rooms *Rooms, addr string, adminToken string) error {
+	if adminToken == "" {
+		return errNoAdminToken
+	}
+	lis, err := listen(addr)
+	if err != nil {
+		return err
+	}
+	auth := tokenAuth{token: adminToken}
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(auth.unary),
+		grpc.StreamInterceptor(auth.stream),
+	)
+	pb.RegisterControlServer(srv, &controlServer{rooms: rooms})
+	slog.Info("control API (gRPC) listening", "addr", lis.Addr().String())
+	return srv.Serve(lis)
+}
+
+// room returns the game of room id. The empty ID is shorthand for the
+// default room.
+func (s *controlServer) room(id string) (*Game, error) {
+	g, ok := s.rooms.Get(id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "room %q not found", id)
+	}
+	return g, nil
+}
+
+func (s *controlServer) ListRooms(ctx context.Context, req *pb.ListRoomsRequest) (*pb.ListRoomsResponse, error) {
+	resp := &pb.ListRoomsResponse{}
+	for _, r := range s.rooms.List() {
+		resp.Rooms = append(resp.Rooms, roomToProto(r))
+	}
+	return resp, nil
+}
+
+func (s *controlServer) CreateRoom(ctx context.Context, req *pb.CreateRoomRequest) (*pb.Room, error) {
+	var apply func(*GameConfig)
+	if req.Config != nil {
+		apply = func(c *GameConfig) { applyProtoConfig(c, req.Config) }
+	}
+	r, err := s.rooms.Create(req.Id, []byte(req.RulesJson), apply)
+	switch {
+	case errors.Is(err, errRoomExists):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, errTooManyRooms):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case err != nil:
+		return nil, invalidConfig(err)
+	}
+	return roomToProto(r), nil
+}
+
+func (s *controlServer) GetStats(ctx context.Context, req *pb.GetStatsRequest) (*pb.Stats, error) {
+	game, err := s.room(req.RoomId)
+	if err != nil {
+		return nil, err
+	}
+	return statsToProto(req.RoomId, game.GetStats()), nil
+}
+
+func (s *controlServer) StreamStats(req *pb.StreamStatsRequest, stream pb.Control_StreamStatsServer) error {
+	game, err := s.room(req.RoomId)
+	if err != nil {
+		return err
+	}
+	interval := time.Duration(req.IntervalMs) * time.Millisecond
+	if interval == 0 {
+		interval = time.Second
+	}
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := stream.Send(statsToProto(req.RoomId, game.GetStats())); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *controlServer) ListPlayers(ctx context.Context, req *pb.ListPlayersRequest) (*pb.ListPlayersResponse, error) {
+	game, err := s.room(req.RoomId)
+	if err != nil {
+		return nil, err
+	}
+	resp := &pb.ListPlayersResponse{}
+	for _, p := range game.GetPlayers() {
+		resp.Players = append(resp.Players, &pb.Player{
+			Id: int32(p.ID), Name: p.Name, Score: int32(p.Score), Alive: p.Alive,
+			RttMs: int32(p.RTTMs), Ip: p.IP,
+		})
+	}
+	return resp, nil
+}
+
+func (s *controlServer) KickPlayer(ctx context.Context, req *pb.KickPlayerRequest) (*pb.KickPlayerResponse, error) {
+	game, err := s.room(req.RoomId)
+	if err != nil {
+		return nil, err
+	}
+	reason := req.Reason
+	if reason == "" {
+		reason = "kicked via control API"
+	}
+	if !game.KickPlayer(int(req.PlayerId), reason) {
+		return nil, status.Errorf(codes.NotFound, "player %d not found", req.PlayerId)
+	}
+	return &pb.KickPlayerResponse{}, nil
+}
+
+func (s *controlServer) GetConfig(ctx context.Context, req *pb.GetConfigRequest) (*pb.Config, error) {
+	game, err := s.room(req.RoomId)
+	if err != nil {
+		return nil, err
+	}
+	return configToProto(game.GetConfig()), nil
+}
+
+func (s *controlServer) UpdateConfig(ctx context.Context, req *pb.UpdateConfigRequest) (*pb.Config, error) {
+	game, err := s.room(req.RoomId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Config == nil {
+		return nil, status.Error(codes.InvalidArgument, "config is required")
+	}
+	cfg, err := game.UpdateConfig(func(c *GameConfig) error {
+		applyProtoConfig(c, req.Config)
+		if game != s.rooms.main {
+			return checkRuleBounds(*c) // extra rooms stay within their bounds
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, invalidConfig(err)
+	}
+	return configToProto(cfg), nil
+}
+
+// invalidConfig returns INVALID_ARGUMENT for a rejected config, with a
+// BadRequest field violation per ConfigError (fields are JSON keys).
+func invalidConfig(err error) error {
+	st := status.New(codes.InvalidArgument, err.Error())
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		return st.Err()
+	}
+	br := &errdetails.BadRequest{}
+	for _, e := range errs {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field: e.Field, Description: e.Reason,
+		})
+	}
+	if ds, derr := st.WithDetails(br); derr == nil {
+		return ds.Err()
+	}
+	return st.Err()
+}
+
+// ---------------------------------------------------------------------------
+// Conversions
+// ---------------------------------------------------------------------------
+
+func statsToProto(roomID string, snap StatsSnapshot) *pb.Stats {
+	if roomID == "" {
+		roomID = DefaultRoomID
+	}
+	st := &pb.Stats{
+		RoomId:           roomID,
+		Version:          snap.Version,
+		UptimeSec:        snap.UptimeSec,
+		TotalJoins:       snap.TotalJoins,
+		TotalLeaves:      snap.TotalLeaves,
+		TotalKills:       snap.TotalKills,
+		PeakPlayers:      int32(snap.PeakPlayers),
+		CurrentPlayers:   int32(snap.CurrentPlayers),
+		AiCount:          int32(snap.AICount),
+		FoodCount:        int32(snap.FoodCount),
+		AvgTickMs:        snap.AvgTickMs,
+		MaxTickMs:        snap.MaxTickMs,
+		BandwidthKbps:    snap.BandwidthKBps,
+		TotalBytesSent:   snap.TotalBytesSent,
+		TotalBytesRecv:   snap.TotalBytesRecv,
+		Frame:            int64(snap.Frame),
+		AvgRttMs:         snap.AvgRTTMs,
+		MaxRttMs:         snap.MaxRTTMs,
+		ThrottledPlayers: int32(snap.Throttled),
+	}
+	for _, e := range snap.Leaderboard {
+		st.Leaderboard = append(st.Leaderboard, &pb.LeaderboardEntry{
+			Name: e.Name, Score: int32(e.Score), IsAi: e.IsAI, Alive: e.IsAlive,
+		})
+	}
+	return st
+}
+
+func roomToProto(r RoomStatus) *pb.Room {
+	return &pb.Room{
+		Id:        r.ID,
+		Players:   int32(r.Players),
+		AiCount:   int32(r.AICount),
+		WorldSize: int32(r.WorldSize),
+	}
+}
+
+func configToProto(c GameConfig) *pb.Config {
+	i32 := func(v int) *int32 { n := int32(v); return &n }
+	f64 := func(v float64) *float64 { return &v }
+	return &pb.Config{
+		WorldSize:      i32(c.WorldSize),
+		FoodCount:      i32(c.FoodCount),
+		AiCount:        i32(c.AICount),
+		BaseSpeed:      f64(c.BaseSpeed),
+		BoostSpeed:     f64(c.BoostSpeed),
+		TurnSpeed:      f64(c.TurnSpeed),
+		MaxBoost:       f64(c.MaxBoost),
+		BoostDrain:     f64(c.BoostDrain),
+		BoostRegen:     f64(c.BoostRegen),
+		BaseSnakeLen:   i32(c.BaseSnakeLen),
+		KillFoodCount:  i32(c.KillFoodCount),
+		BoundaryMargin: f64(c.BoundaryMargin),
+		AiRespawnTicks: i32(c.AIRespawnTicks),
+		LaserTail:      &c.LaserTail,
+		TrailLifetime:  i32(c.TrailLifetime),
+		TimeScale:      f64(c.TimeScale),
+	}
+}
+
+// applyProtoConfig copies the fields that are set in p onto c.
+func applyProtoConfig(c *GameConfig, p *pb.Config) {
+	if p.WorldSize != nil {
+		c.WorldSize = int(*p.WorldSize)
+	}
+	if p.FoodCount != nil {
+		c.FoodCount = int(*p.FoodCount)
+	}
+	if p.AiCount != nil {
+		c.AICount = int(*p.AiCount)
+	}
+	if p.BaseSpeed != nil {
+		c.BaseSpeed = *p.BaseSpeed
+	}
+	if p.BoostSpeed != nil {
+		c.BoostSpeed = *p.BoostSpeed
+	}
+	if p.TurnSpeed != nil {
+		c.TurnSpeed = *p.TurnSpeed
+	}
+	if p.MaxBoost != nil {
+		c.MaxBoost = *p.MaxBoost
+	}
+	if p.BoostDrain != nil {
+		c.BoostDrain = *p.BoostDrain
+	}
+	if p.BoostRegen != nil {
+		c.BoostRegen = *p.BoostRegen
+	}
+	if p.BaseSnakeLen != nil {
+		c.BaseSnakeLen = int(*p.BaseSnakeLen)
+	}
+	if p.KillFoodCount != nil {
+		c.KillFoodCount = int(*p.KillFoodCount)
+	}
+	if p.BoundaryMargin != nil {
+		c.BoundaryMargin = *p.BoundaryMargin
+	}
+	if p.AiRespawnTicks != nil {
+		c.AIRespawnTicks = int(*p.AiRespawnTicks)
+	}
+	if p.LaserTail != nil {
+		c.LaserTail = *p.LaserTail
+	}
+	if p.TrailLifetime != nil {
+		c.TrailLifetime = int(*p.TrailLifetime)
+	}
+	if p.TimeScale != nil {
+		c.TimeScale = *p.TimeScale
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Auth: "authorization: Bearer <admin token>" metadata on every call
+// ---------------------------------------------------------------------------
+
+type tokenAuth struct {
+	token string
+}
+
+func (a tokenAuth) check(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if validAdminToken(a.token, strings.TrimPrefix(v, "Bearer ")) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid admin token")
+}
+
+func (a tokenAuth) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := a.check(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a tokenAuth) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.check(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
@@ -0,0 +1,77 @@
+package main
+
+import "math"
+
+// ---------------------------------------------------------------------------
+// Minimap heatmap
+//
+// With HeatmapSize > 0, frames that carry the summary also carry a
+// HeatmapSize x HeatmapSize grid over the world about once a second
+// (HeatmapEvery). Each cell is one byte: snake body mass in the high nibble,
+// food mass in the low nibble, each 0-15 on a square-root scale relative to
+// the densest cell, so clients can shade the minimap instead of plotting
+// every head.
+// ---------------------------------------------------------------------------
+
+const (
+	HeatmapEvery   = 30 // net ticks between heatmaps per player (1 s)
+	MaxHeatmapSize = 64
+)
+
+// heatmapFor returns the heatmap cells to send p in this frame, or nil.
+func (g *Game) heatmapFor(p *Player, includeSummary bool, sh *frameShared) []byte {
+	if !includeSummary || g.cfg.HeatmapSize <= 0 || g.netTick < p.nextHeatmap {
+		return nil
+	}
+	p.nextHeatmap = g.netTick + HeatmapEvery
+	if sh.heatmap == nil {
+		sh.heatmap = g.buildHeatmap(g.cfg.HeatmapSize)
+	}
+	return sh.heatmap
+}
+
+// buildHeatmap bins snake segments and food value into size x size cells.
+func (g *Game) buildHeatmap(size int) []byte {
+	cell := float64(g.cfg.WorldSize) / float64(size)
+	index := func(x, y float64) int {
+		cx := clampInt(int(x/cell), 0, size-1)
+		cy := clampInt(int(y/cell), 0, size-1)
+		return cy*size + cx
+	}
+	mass := make([]float64, size*size)
+	food := make([]float64, size*size)
+	for _, s := range g.snakes {
+		if !s.Alive {
+			continue
+		}
+		for _, seg := range s.Segments {
+			mass[index(seg.X, seg.Y)]++
+		}
+	}
+	for _, f := range g.foods {
+		food[index(f.X, f.Y)] += f.Value
+	}
+
+	out := make([]byte, size*size)
+	maxMass, maxFood := maxOf(mass), maxOf(food)
+	for i := range out {
+		out[i] = heatLevel(mass[i], maxMass)<<4 | heatLevel(food[i], maxFood)
+	}
+	return out
+}
+
+// heatLevel maps v in [0, top] to 0-15; anything above zero gets at least 1.
+func heatLevel(v, top float64) byte {
+	if v <= 0 || top <= 0 {
+		return 0
+	}
+	return byte(clampInt(int(math.Ceil(15*math.Sqrt(v/top))), 1, 15))
+}
+
+func maxOf(vs []float64) float64 {
+	m := 0.0
+	for _, v := range vs {
+		m = math.Max(m, v)
+	}
+	return m
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Metrics history: rolling window of coarse samples for dashboard sparklines
+// ---------------------------------------------------------------------------
+
+const (
+	HistoryInterval = 10 * TickRate // frames between samples (10 s)
+	HistorySamples  = 360           // samples kept (1 hour)
+)
+
+type HistorySample struct {
+	Time          int64   `json:"t"` // unix seconds
+	Players       int     `json:"players"`
+	AvgTickMs     float64 `json:"avgTickMs"`
+	BandwidthKBps float64 `json:"bandwidthKBps"`
+	KillsPerMin   float64 `json:"killsPerMin"`
+}
+
+type HistorySnapshot struct {
+	IntervalSec int             `json:"intervalSec"`
+	Samples     []HistorySample `json:"samples"` // oldest first
+}
+
+type metricsHistory struct {
+	samples [HistorySamples]HistorySample
+	idx     int // next write position
+	count   int
+
+	// Accumulators for the current interval
+	tickNs    int64
+	ticks     int
+	bytesSent int64
+	lastKills int64
+}
+
+// addTick accumulates one tick's duration and outbound bytes.
+func (h *metricsHistory) addTick(d time.Duration, bytesSent int64) {
+	h.tickNs += d.Nanoseconds()
+	h.ticks++
+	h.bytesSent += bytesSent
+}
+
+// sample closes the current interval and stores it in the ring buffer.
+func (h *metricsHistory) sample(players int, totalKills int64) {
+	avgMs := 0.0
+	if h.ticks > 0 {
+		avgMs = float64(h.tickNs) / float64(h.ticks) / 1e6
+	}
+	const secs = HistoryInterval / TickRate
+	h.samples[h.idx] = HistorySample{
+		Time:          time.Now().Unix(),
+		Players:       players,
+		AvgTickMs:     math.Round(avgMs*100) / 100,
+		BandwidthKBps: math.Round(float64(h.bytesSent)/secs/1024*100) / 100,
+		KillsPerMin:   float64(totalKills-h.lastKills) * 60 / secs,
+	}
+	h.idx = (h.idx + 1) % HistorySamples
+	if h.count < HistorySamples {
+		h.count++
+	}
+	h.tickNs, h.ticks, h.bytesSent = 0, 0, 0
+	h.lastKills = totalKills
+}
+
+func (h *metricsHistory) snapshot() HistorySnapshot {
+	out := make([]HistorySample, 0, h.count)
+	start := (h.idx - h.count + HistorySamples) % HistorySamples
+	for i := 0; i < h.count; i++ {
+		out = append(out, h.samples[(start+i)%HistorySamples])
+	}
+	return HistorySnapshot{IntervalSec: HistoryInterval / TickRate, Samples: out}
+}
+
+// GetHistory requests the metrics history from the game loop (thread-safe).
+func (g *Game) GetHistory() HistorySnapshot {
+	reply := make(chan HistorySnapshot, 1)
+	h, _ := ask(g, g.historyReqCh, reply, reply)
+	return h
+}
+
+func HandleStatsHistory(game *Game, w http.ResponseWriter, r *http.Request) {
+	hist := game.GetHistory()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hist)
+}
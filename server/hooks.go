@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Tick loop hooks: extension points for custom rules
+//
+// Hooks run on the game loop goroutine and may read and modify the world
+// directly (e.g. raise Food.Value in OnFoodSpawn, grow the killer in
+// OnKill). They must return quickly: a call that takes longer than
+// HookBudget is logged, and a hook that panics is logged and disabled.
+// ---------------------------------------------------------------------------
+
+const HookBudget = 2 * time.Millisecond
+
+// Hooks is a set of optional callbacks; nil fields are skipped.
+type Hooks struct {
+	Name string // used in log lines
+
+	BeforeTick func(g *Game) // after queued messages are handled, before simulation
+	AfterTick  func(g *Game) // after simulation, before the broadcast
+
+	// OnKill runs after a snake died and the killer was rewarded. killer is
+	// nil for deaths without one (boundary).
+	OnKill      func(g *Game, victim, killer *Snake)
+	OnFoodSpawn func(g *Game, f *Food) // f is already in the world and has its ID
+	OnJoin      func(g *Game, p *Player)
+	OnDegrade   func(g *Game, level int) // the watchdog's load shedding level changed (see watchdog.go)
+}
+
+type hook struct {
+	Hooks
+	disabled bool
+	lastWarn time.Time
+}
+
+// AddHook registers h. Hooks run in registration order. Must be called
+// before Run.
+func (g *Game) AddHook(h Hooks) {
+	if h.Name == "" {
+		h.Name = fmt.Sprintf("hook%d", len(g.hooks)+1)
+	}
+	g.hooks = append(g.hooks, &hook{Hooks: h})
+}
+
+// callHook runs fn for h, enforcing the budget and recovering panics.
+func (g *Game) callHook(h *hook, event string, fn func()) {
+	if h.disabled {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			h.disabled = true
+			g.log.Error("hook panicked, disabling it", "hook", h.Name, "event", event, "panic", r)
+		}
+	}()
+	start := time.Now()
+	fn()
+	if d := time.Since(start); d > HookBudget && time.Since(h.lastWarn) > 10*time.Second {
+		h.lastWarn = time.Now()
+		g.log.Warn("hook exceeded its time budget", "hook", h.Name, "event", event,
+			"ms", float64(d.Microseconds())/1000, "budgetMs", float64(HookBudget.Microseconds())/1000)
+	}
+}
+
+func (g *Game) hookBeforeTick() {
+	for _, h := range g.hooks {
+		if h.BeforeTick != nil {
+			g.callHook(h, "BeforeTick", func() { h.BeforeTick(g) })
+		}
+	}
+}
+
+func (g *Game) hookAfterTick() {
+	for _, h := range g.hooks {
+		if h.AfterTick != nil {
+			g.callHook(h, "AfterTick", func() { h.AfterTick(g) })
+		}
+	}
+}
+
+func (g *Game) hookKill(victim, killer *Snake) {
+	for _, h := range g.hooks {
+		if h.OnKill != nil {
+			g.callHook(h, "OnKill", func() { h.OnKill(g, victim, killer) })
+		}
+	}
+}
+
+func (g *Game) hookFoodSpawn(f *Food) {
+	for _, h := range g.hooks {
+		if h.OnFoodSpawn != nil {
+			g.callHook(h, "OnFoodSpawn", func() { h.OnFoodSpawn(g, f) })
+		}
+	}
+}
+
+func (g *Game) hookJoin(p *Player) {
+	for _, h := range g.hooks {
+		if h.OnJoin != nil {
+			g.callHook(h, "OnJoin", func() { h.OnJoin(g, p) })
+		}
+	}
+}
+
+func (g *Game) hookDegrade(level int) {
+	for _, h := range g.hooks {
+		if h.OnDegrade != nil {
+			g.callHook(h, "OnDegrade", func() { h.OnDegrade(g, level) })
+		}
+	}
+}
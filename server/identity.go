@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Persistent player identity (signed tokens, no accounts)
+//
+// Every join gets an identity token back in an "identity" event; clients
+// keep it and send it as "identity" in later join messages. A token is
+// base64url(payload) "." base64url(HMAC-SHA256(IdentityKey, payload)) with
+// a JSON payload {"id", "exp"}. It is reissued on every join, so an
+// identity only expires after IdentityExpiryDays without playing.
+//
+// Profiles (color, best score, games, kills, XP) are keyed by identity. They
+// live in the game loop, are saved with world snapshots and are forgotten
+// once their identity has expired, except for accounts (see accounts.go).
+// Every tokenless join creates a profile, so one that hasn't earned
+// anything yet (no score, kill, XP, achievement or challenge progress) isn't
+// written to storage and is forgotten after emptyProfileTTL without
+// playing, even when identities never expire.
+// ---------------------------------------------------------------------------
+
+const (
+	profilePruneInterval = time.Minute
+	emptyProfileTTL      = 24 * time.Hour
+)
+
+var errBadIdentity = errors.New("invalid identity token")
+
+// IdentitySigner issues and verifies identity tokens. Safe for concurrent
+// use; the key and expiry are fixed at startup.
+type IdentitySigner struct {
+	key []byte
+	ttl time.Duration // 0 = tokens don't expire
+}
+
+type identityClaims struct {
+	ID  string `json:"id"`
+	Exp int64  `json:"exp,omitempty"` // unix seconds
+}
+
+// NewIdentitySigner uses cfg.IdentityKey, or a random key if it is empty
+// (identities then don't survive a restart).
+func NewIdentitySigner(cfg GameConfig) *IdentitySigner {
+	key := []byte(cfg.IdentityKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &IdentitySigner{key: key, ttl: time.Duration(cfg.IdentityExpiryDays) * 24 * time.Hour}
+}
+
+// Resolve returns the identity in token and a refreshed token for it, or
+// a new identity if token is missing, forged or expired.
+func (is *IdentitySigner) Resolve(token string, now time.Time) (id, fresh string) {
+	id, err := is.Verify(token, now)
+	if err != nil {
+		if token != "" {
+			slog.Debug("identity token rejected", "err", err)
+		}
+		id = newIdentityID()
+	}
+	return id, is.Issue(id, now)
+}
+
+// Issue signs a token for id.
+func (is *IdentitySigner) Issue(id string, now time.Time) string {
+	c := identityClaims{ID: id}
+	if is.ttl > 0 {
+		c.Exp = now.Add(is.ttl).Unix()
+	}
+	payload, _ := json.Marshal(c)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(is.sign(payload))
+}
+
+// Verify returns the identity of a token with a valid signature that
+// hasn't expired.
+func (is *IdentitySigner) Verify(token string, now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", errBadIdentity
+	}
+	payload, err1 := enc.DecodeString(body)
+	mac, err2 := enc.DecodeString(sig)
+	if err1 != nil || err2 != nil || !hmac.Equal(mac, is.sign(payload)) {
+		return "", errBadIdentity
+	}
+	var c identityClaims
+	if err := json.Unmarshal(payload, &c); err != nil || c.ID == "" {
+		return "", errBadIdentity
+	}
+	if c.Exp != 0 && now.Unix() > c.Exp {
+		return "", errors.New("identity token expired")
+	}
+	return c.ID, nil
+}
+
+func (is *IdentitySigner) sign(payload []byte) []byte {
+	m := hmac.New(sha256.New, is.key)
+	m.Write(payload)
+	return m.Sum(nil)
+}
+
+func newIdentityID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Profile is what the server remembers about an identity.
+type Profile struct {
+	Color    int       `json:"color"`
+	Best     int       `json:"best"`  // best final score
+	Games    int       `json:"games"` // snakes that ended (death or leaving)
+	Kills    int       `json:"kills"`
+	LastSeen time.Time `json:"lastSeen"`
+
+	Food         int                  `json:"food"`                   // food items eaten
+	Achievements map[string]time.Time `json:"achievements,omitempty"` // by ID, unlock time (see achievements.go)
+	Challenges   map[string]int       `json:"challenges,omitempty"`   // progress by challenge key (see challenges.go)
+	XP           int                  `json:"xp"`                     // see xp.go
+	Rating       int                  `json:"rating"`                 // 0 = not rated yet (see rating.go)
+	Skin         int                  `json:"skin"`
+
+	// Accounts only
+	Name     string `json:"name,omitempty"`     // display name
+	Provider string `json:"provider,omitempty"` // OAuth provider, "" = guest
+}
+
+// earned reports whether the profile holds anything worth keeping beyond
+// its color and skin.
+func (p *Profile) earned() bool {
+	return p.Provider != "" || p.Best > 0 || p.Kills > 0 || p.XP > 0 ||
+		len(p.Achievements) > 0 || len(p.Challenges) > 0
+}
+
+// clone returns a copy that doesn't share the maps.
+func (p *Profile) clone() *Profile {
+	c := *p
+	c.Achievements = maps.Clone(p.Achievements)
+	c.Challenges = maps.Clone(p.Challenges)
+	return &c
+}
+
+type identityEvent struct {
+	Type  string `json:"t"` // "identity"
+	ID    string `json:"id"`
+	Token string `json:"token"`
+	Best  int    `json:"best"`
+	Games int    `json:"games"`
+	Kills int    `json:"kills"`
+
+	Name     string `json:"name,omitempty"`     // accounts: display name
+	Provider string `json:"provider,omitempty"` // accounts: OAuth provider
+
+	Challenges map[string]int `json:"challenges,omitempty"` // progress on the active challenges
+
+	LevelInfo
+	Skin   int `json:"skin"`
+	Rating int `json:"rating"`
+}
+
+// joinProfile applies p's saved color to its new snake and sends p its
+// identity token and profile. Called from handleJoin.
+func (g *Game) joinProfile(p *Player) {
+	if p.identity == "" {
+		return
+	}
+	prof, ok := g.profiles[p.identity]
+	if ok {
+		p.snake.ColorIdx = prof.Color
+	} else {
+		g.pruneProfiles()
+		prof = &Profile{Color: p.snake.ColorIdx}
+		g.profiles[p.identity] = prof
+	}
+	prof.LastSeen = time.Now()
+	g.persistProfile(p.identity, prof)
+	g.dressSnake(p)
+	g.sendEvent(p, identityEvent{
+		Type: "identity", ID: p.identity, Token: p.identityToken,
+		Best: prof.Best, Games: prof.Games, Kills: prof.Kills,
+		Name: prof.Name, Provider: prof.Provider, Challenges: maps.Clone(prof.Challenges),
+		LevelInfo: g.levelFor(prof.XP), Skin: p.snake.skin, Rating: prof.rating(),
+	})
+}
+
+// recordProfile adds the game p's snake just finished to p's profile and
+// returns the best score (0 without an identity).
+func (g *Game) recordProfile(p *Player) int {
+	prof := g.profiles[p.identity]
+	if prof == nil || p.snake == nil {
+		return 0
+	}
+	prof.Games++
+	prof.Kills += p.snake.kills
+	prof.Food += p.snake.foodEaten
+	prof.Best = max(prof.Best, p.snake.Score)
+	prof.LastSeen = time.Now()
+	g.awardXP(p, prof)
+	g.persistProfile(p.identity, prof)
+	g.persistGame(p)
+	return prof.Best
+}
+
+// profileCustomize remembers a color or skin change, and a name change for
+// accounts.
+func (g *Game) profileCustomize(p *Player, name string, color, skin int) {
+	prof := g.profiles[p.identity]
+	if prof == nil {
+		return
+	}
+	if color >= 0 {
+		prof.Color = color
+	}
+	if skin >= 0 {
+		prof.Skin = skin
+	}
+	if name != "" && prof.Provider != "" {
+		prof.Name = name
+	}
+	g.persistProfile(p.identity, prof)
+}
+
+// pruneProfiles forgets guest profiles whose identity token has expired,
+// and those that haven't earned anything after emptyProfileTTL, at most
+// once per profilePruneInterval.
+func (g *Game) pruneProfiles() {
+	if time.Since(g.lastProfilePrune) < profilePruneInterval {
+		return
+	}
+	g.lastProfilePrune = time.Now()
+	emptyCutoff := time.Now().Add(-emptyProfileTTL)
+	var cutoff time.Time // zero: identities don't expire
+	if g.cfg.IdentityExpiryDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -g.cfg.IdentityExpiryDays)
+	}
+	var expired []string
+	for id, prof := range g.profiles {
+		if prof.Provider == "" && (prof.LastSeen.Before(cutoff) || !prof.earned() && prof.LastSeen.Before(emptyCutoff)) {
+			delete(g.profiles, id)
+			expired = append(expired, id)
+		}
+	}
+	if len(expired) > 0 {
+		g.persist(func(st Storage) error { return st.DeleteProfiles(expired) })
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdentityResolve(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.IdentityKey = "test key"
+	cfg.IdentityExpiryDays = 90
+	is := NewIdentitySigner(cfg)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	valid := is.Issue("alice", now)
+
+	other := cfg
+	other.IdentityKey = "other key"
+	body, sig, _ := strings.Cut(valid, ".")
+	forgedBody := base64.RawURLEncoding.EncodeToString([]byte(`{"id":"mallory"}`))
+
+	tests := []struct {
+		name  string
+		token string
+		now   time.Time
+		keep  bool // resolves to alice
+	}{
+		{"valid", valid, now, true},
+		{"valid a day before expiry", valid, now.AddDate(0, 0, 89), true},
+		{"expired", valid, now.AddDate(0, 0, 91), false},
+		{"other key", NewIdentitySigner(other).Issue("alice", now), now, false},
+		{"forged payload", forgedBody + "." + sig, now, false},
+		{"forged signature", body + "." + base64.RawURLEncoding.EncodeToString(make([]byte, 32)), now, false},
+		{"no signature", body, now, false},
+		{"empty", "", now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, fresh := is.Resolve(tt.token, tt.now)
+			if (id == "alice") != tt.keep || id == "" || id == "mallory" {
+				t.Fatalf("identity %q", id)
+			}
+			// The fresh token is for id and runs from tt.now.
+			if got, err := is.Verify(fresh, tt.now.AddDate(0, 0, 89)); err != nil || got != id {
+				t.Errorf("fresh token: %q, %v", got, err)
+			}
+		})
+	}
+}
+
+// TestPruneProfiles checks that a guest profile that hasn't earned anything
+// is dropped after emptyProfileTTL without playing, even when identities
+// never expire, and that the others are kept.
+func TestPruneProfiles(t *testing.T) {
+	g := NewGame(DefaultConfig())
+	g.cfg.IdentityExpiryDays = 0
+	old := time.Now().Add(-emptyProfileTTL - time.Hour)
+	g.profiles = map[string]*Profile{
+		"empty":   {Color: 3, Skin: 1, LastSeen: old},
+		"recent":  {LastSeen: time.Now().Add(-time.Hour)},
+		"scored":  {Best: 40, LastSeen: old},
+		"xp":      {XP: 5, LastSeen: old},
+		"account": {Provider: "github", LastSeen: old},
+	}
+	g.pruneProfiles()
+	if _, ok := g.profiles["empty"]; ok {
+		t.Error("empty guest profile kept")
+	}
+	for _, id := range []string{"recent", "scored", "xp", "account"} {
+		if _, ok := g.profiles[id]; !ok {
+			t.Errorf("profile %q dropped", id)
+		}
+	}
+
+	g.profiles["empty"] = &Profile{LastSeen: old}
+	g.pruneProfiles() // within profilePruneInterval
+	if _, ok := g.profiles["empty"]; !ok {
+		t.Error("pruned again within profilePruneInterval")
+	}
+}
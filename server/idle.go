@@ -0,0 +1,87 @@
+package main
+
+import "time"
+
+// ---------------------------------------------------------------------------
+// Idle power saving
+//
+// With no players connected nobody watches the AI snakes, so there is no
+// point simulating them 60 times a second. IdleGrace after the last player
+// left, the game loop drops to IdleTickRate ticks per second, and after
+// IdlePause seconds it stops simulating altogether and only polls its
+// channels, so stats, admin and join requests are still answered. A new
+// WebSocket connection wakes the loop right away (see Wake), before the
+// client has even sent its join. Jobs counted in ticks, like the stats push
+// and the history samples, slow down with the loop.
+// ---------------------------------------------------------------------------
+
+const (
+	IdleGrace      = 5 * time.Second // time without players before the loop slows down
+	pausedPollRate = 4               // channel polls per second while paused
+)
+
+type idleState uint8
+
+const (
+	idleAwake idleState = iota
+	idleSlow
+	idlePaused
+)
+
+func (s idleState) String() string {
+	switch s {
+	case idleAwake:
+		return "awake"
+	case idleSlow:
+		return "slow"
+	case idlePaused:
+		return "paused"
+	}
+	return "unknown"
+}
+
+// Wake brings an idle game loop back to full speed. Safe to call from any
+// goroutine; HandleWS calls it for every new connection.
+func (g *Game) Wake() {
+	select {
+	case g.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// idleTarget returns the state the loop should be in at now.
+func (g *Game) idleTarget(now time.Time) idleState {
+	if len(g.players) > 0 || len(g.dir.viewers) > 0 {
+		g.lastActive = now
+	}
+	quiet := now.Sub(g.lastActive)
+	switch {
+	case g.cfg.IdlePause > 0 && quiet >= time.Duration(g.cfg.IdlePause)*time.Second:
+		return idlePaused
+	case g.cfg.IdleTickRate > 0 && quiet >= IdleGrace:
+		return idleSlow
+	}
+	return idleAwake
+}
+
+// tickInterval returns the time between loop iterations in state s.
+func (g *Game) tickInterval(s idleState) time.Duration {
+	switch s {
+	case idleSlow:
+		return time.Second / time.Duration(g.cfg.IdleTickRate)
+	case idlePaused:
+		return time.Second / pausedPollRate
+	}
+	return time.Second / TickRate
+}
+
+// tickRate returns the current ticks per second, 0 while paused.
+func (g *Game) tickRate() int {
+	switch g.idle {
+	case idleSlow:
+		return g.cfg.IdleTickRate
+	case idlePaused:
+		return 0
+	}
+	return TickRate
+}
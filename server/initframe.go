@@ -0,0 +1,154 @@
+package main
+
+import "math"
+
+// ---------------------------------------------------------------------------
+// World init frame
+//
+// The welcome message only carries what a client needs to join. Right after
+// the join the server sends an "init" event with everything else a client
+// would otherwise hard-code: the movement and boost rules, the enabled
+// features and their timings, the running mode and mutators, the tournament
+// round and the shape of the arena.
+// It is sent again to everyone when a mode switch or the control API
+// changes the config, so clients always simulate with the live values.
+// ---------------------------------------------------------------------------
+
+type initEvent struct {
+	Type  string     `json:"t"`              // "init"
+	Mode  string     `json:"mode,omitempty"` // rotation mode, "" without a rotation
+	Round *initRound `json:"round,omitempty"`
+	Rules initRules  `json:"rules"`
+	World initWorld  `json:"world"`
+}
+
+// initWorld describes the arena (see arena.go). Snakes die when their head
+// gets closer than Margin to the edge, unless WallBrake holds them there;
+// Warning is the width of the warning band inside that line (see sim/border.go).
+// Portals are the portal pairs in the world.
+type initWorld struct {
+	Shape     string       `json:"shape"` // "square", "circle" or "hexagon"
+	Size      int          `json:"size"`  // side of the world square
+	Margin    float64      `json:"margin"`
+	Warning   float64      `json:"warning"`
+	WallBrake bool         `json:"wallBrake,omitempty"`
+	Center    [2]float64   `json:"center"`
+	Radius    float64      `json:"radius,omitempty"`   // circle radius or hexagon circumradius
+	Vertices  [][2]float64 `json:"vertices,omitempty"` // hexagon corners in order
+
+	Portals      [][4]float64 `json:"portals,omitempty"` // linked pairs: x1, y1, x2, y2 (see portal.go)
+	PortalRadius float64      `json:"portalRadius,omitempty"`
+}
+
+// initRound is the tournament round in progress (tournament mode only).
+type initRound struct {
+	Round     int    `json:"round"`     // 0 before the first round
+	Phase     string `json:"phase"`     // "countdown", "playing" or "results"
+	Remaining int    `json:"remaining"` // seconds left in the phase
+	Duration  int    `json:"duration"`  // seconds per round
+}
+
+// initRules are the config values clients need to predict and draw the
+// game. Speeds and rates are per tick at TickRate; TimeScale applies on top.
+type initRules struct {
+	WorldSize       int     `json:"worldSize"`
+	BoundaryMargin  float64 `json:"boundaryMargin"`
+	BaseSpeed       float64 `json:"baseSpeed"`
+	BoostSpeed      float64 `json:"boostSpeed"`
+	TurnSpeed       float64 `json:"turnSpeed"`
+	TimeScale       float64 `json:"timeScale"`
+	Deterministic   bool    `json:"deterministic"`
+	HeadRadius      float64 `json:"headRadius"`
+	BodyRadius      float64 `json:"bodyRadius"`
+	BaseSnakeLen    int     `json:"baseSnakeLen"`
+	SegmentStride   int     `json:"segmentStride"` // body points between those sent in frames
+	BoostMode       string  `json:"boostMode"`     // "meter" or "charge"
+	MaxBoost        float64 `json:"maxBoost"`
+	BoostDrain      float64 `json:"boostDrain"`
+	BoostRegen      float64 `json:"boostRegen"`
+	BoostCooldown   int     `json:"boostCooldown,omitempty"` // charge mode only
+	SpawnProtection int     `json:"spawnProtection"`         // ticks
+	LaserTail       bool    `json:"laserTail"`
+	TrailLifetime   int     `json:"trailLifetime,omitempty"` // ticks, laser tail only
+	BountyInterval  int     `json:"bountyInterval"`          // seconds, 0 = off
+	DecayThreshold  int     `json:"decayThreshold"`          // 0 = off
+	ChatRadius      float64 `json:"chatRadius"`              // reach of proximity chat
+	ChatNearOnly    bool    `json:"chatNearOnly,omitempty"`  // no all-chat (ProximityChatOnly)
+	Predators       int     `json:"predators"`               // roaming eels, 0 = none
+	PredatorRadius  float64 `json:"predatorRadius,omitempty"`
+
+	// Mutators (see mutators.go). TurnSpeed above already includes them.
+	Mutators  []string `json:"mutators,omitempty"`
+	SizeScale float64  `json:"sizeScale"`           // factor on all head and body radii
+	FogRadius float64  `json:"fogRadius,omitempty"` // world units around the camera a player sees at zoom 1, 0 = no fog
+}
+
+// initEvent describes the world as it is now.
+func (g *Game) initEvent() initEvent {
+	c := &g.cfg
+	ev := initEvent{
+		Type: "init",
+		Mode: g.modeName(),
+		Rules: initRules{
+			WorldSize: c.WorldSize, BoundaryMargin: c.BoundaryMargin,
+			BaseSpeed: c.BaseSpeed, BoostSpeed: c.BoostSpeed, TurnSpeed: g.rules.TurnSpeed,
+			TimeScale: c.TimeScale, Deterministic: c.Deterministic,
+			HeadRadius: HeadRadius, BodyRadius: BodyRadius, BaseSnakeLen: c.BaseSnakeLen,
+			BoostMode: g.boost.Name(), MaxBoost: c.MaxBoost, BoostDrain: c.BoostDrain, BoostRegen: c.BoostRegen,
+			SpawnProtection: c.SpawnProtection, LaserTail: c.LaserTail,
+			BountyInterval: c.BountyInterval, DecayThreshold: c.DecayThreshold,
+			ChatRadius: c.ChatRadius, ChatNearOnly: c.ProximityChatOnly,
+			Predators: c.Predators, SegmentStride: g.segmentStride(),
+			Mutators: g.activeMutators(), SizeScale: g.rules.SizeScale,
+		},
+	}
+	if g.boost.Name() == "charge" {
+		ev.Rules.BoostCooldown = c.BoostCooldown
+	}
+	if c.LaserTail {
+		ev.Rules.TrailLifetime = c.TrailLifetime
+	}
+	if c.Predators > 0 {
+		ev.Rules.PredatorRadius = PredatorRadius
+	}
+	if g.rules.ViewScale < 1 {
+		ev.Rules.FogRadius = FoodViewDist * g.rules.ViewScale
+	}
+	ev.World = g.initWorld()
+	if g.roundsEnabled() {
+		ev.Round = &initRound{
+			Round: g.round.round, Phase: g.round.phase.String(),
+			Remaining: g.roundRemaining(), Duration: c.RoundDuration,
+		}
+	}
+	return ev
+}
+
+// sendInit sends the init event to a player that just joined.
+func (g *Game) sendInit(p *Player) {
+	g.sendEvent(p, g.initEvent())
+}
+
+// announceInit resends the init event to everyone after the config changed.
+func (g *Game) announceInit() {
+	g.announce(g.initEvent())
+}
+
+func (g *Game) initWorld() initWorld {
+	c := g.worldCenter()
+	w := initWorld{
+		Shape: g.arena.Name(), Size: g.cfg.WorldSize, Margin: g.cfg.BoundaryMargin,
+		Warning: g.cfg.WarningBand, WallBrake: g.cfg.WallBrake, Center: [2]float64{c.X, c.Y},
+	}
+	if w.Shape != "square" {
+		w.Radius = float64(g.cfg.WorldSize) / 2
+	}
+	for _, v := range g.arena.Vertices() {
+		w.Vertices = append(w.Vertices, [2]float64{math.Round(v.X*100) / 100, math.Round(v.Y*100) / 100})
+	}
+	for _, pair := range g.portals {
+		w.Portals = append(w.Portals, [4]float64{math.Round(pair[0].X), math.Round(pair[0].Y), math.Round(pair[1].X), math.Round(pair[1].Y)})
+		w.PortalRadius = PortalRadius
+	}
+	return w
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"snake-server/wire"
+)
+
+// ---------------------------------------------------------------------------
+// Input recording for cheat review
+//
+// With InputLogMinutes set, every player connection keeps the raw steering
+// inputs of its last InputLogMinutes minutes in a ring buffer: when each
+// arrived, the angle, boost and sequence number exactly as sent. The read
+// pump records them before the game loop sees them, so the timing is the
+// connection's own.
+//
+// Each connection also keeps a suspicion score. It rises by one for every
+// second with more than inputFloodRate inputs (the game client sends one
+// per display frame) and for every v7 sequence number that doesn't follow
+// the one before (the client counts up by one), and it decays by
+// inputSuspicionDecay a second. When it reaches inputSuspicionTrip, the
+// server logs a warning and keeps a copy of the player's buffer as a
+// capture, the newest MaxInputCaptures of them, for moderators to review:
+//
+//	GET /admin/inputs               captures, newest first, and the scores of
+//	                                the connected players (no inputs)
+//	GET /admin/inputs?capture=ID    one capture with its inputs
+//	GET /admin/inputs?player=ID     the buffer of a connected player, now
+//
+// All take ?room=<id> (default: the main room) and need the admin token.
+// ---------------------------------------------------------------------------
+
+const (
+	MaxInputLogMinutes  = 10
+	MaxInputCaptures    = 50
+	inputLogRate        = 150 // ring slots per second of InputLogMinutes
+	inputFloodRate      = 250 // inputs in one second that count as a flood
+	inputSuspicionTrip  = 20
+	inputSuspicionDecay = 0.98 // per second, about half every 35 s
+)
+
+// inputEntry is one recorded input, compact as there are many.
+type inputEntry struct {
+	ms    uint32 // since the connection opened
+	angle float32
+	seq   uint16
+	flags uint8 // bit0 = boost, bit1 = has seq
+}
+
+// InputRecord is an input in a dump.
+type InputRecord struct {
+	Ms    uint32  `json:"ms"`    // since the connection opened
+	Angle float64 `json:"angle"` // radians, as sent
+	Boost bool    `json:"boost"`
+	Seq   *uint16 `json:"seq,omitempty"` // v7 and later
+}
+
+// inputLog is one connection's recording. The read pump writes it; admin
+// requests read it.
+type inputLog struct {
+	mu       sync.Mutex
+	playerID int
+	addr     netip.Addr
+	name     string // set at join
+	identity string
+	opened   time.Time
+	window   time.Duration
+	ring     []inputEntry
+	next     int
+	full     bool
+
+	score    float64
+	tripped  bool
+	second   int64 // second since opened that inSecond counts
+	inSecond int
+	lastSeq  uint16
+	hasSeq   bool
+}
+
+// InputCapture is a player's recording, taken when the score tripped or
+// on request.
+type InputCapture struct {
+	ID       int           `json:"id"`
+	PlayerID int           `json:"playerId"`
+	Name     string        `json:"name"`
+	Identity string        `json:"identity,omitempty"`
+	IP       string        `json:"ip"`
+	At       time.Time     `json:"at"`
+	Opened   time.Time     `json:"opened"` // ms in inputs count from here
+	Score    float64       `json:"score"`
+	Reason   string        `json:"reason"` // "flood", "sequence" or "request"
+	Count    int           `json:"count"`
+	Inputs   []InputRecord `json:"inputs,omitempty"`
+}
+
+// inputReview holds a room's live recordings and captures.
+type inputReview struct {
+	mu       sync.Mutex
+	live     map[int]*inputLog
+	captures []InputCapture // oldest first
+	nextID   int
+}
+
+// openInputLog starts recording p's inputs, if InputLogMinutes is set.
+// Called by HandleWS; closeInputLog ends it.
+func (g *Game) openInputLog(p *Player, minutes int) {
+	if minutes <= 0 {
+		return
+	}
+	l := &inputLog{
+		playerID: p.id, addr: p.addr, opened: time.Now(),
+		window: time.Duration(minutes) * time.Minute,
+		ring:   make([]inputEntry, minutes*60*inputLogRate),
+	}
+	p.inputs = l
+	g.review.mu.Lock()
+	if g.review.live == nil {
+		g.review.live = make(map[int]*inputLog)
+	}
+	g.review.live[p.id] = l
+	g.review.mu.Unlock()
+}
+
+func (g *Game) closeInputLog(p *Player) {
+	if p.inputs == nil {
+		return
+	}
+	g.review.mu.Lock()
+	delete(g.review.live, p.id)
+	g.review.mu.Unlock()
+}
+
+// identify names the recording after the join.
+func (l *inputLog) identify(name, identity string) {
+	l.mu.Lock()
+	l.name, l.identity = name, identity
+	l.mu.Unlock()
+}
+
+// recordInput records an input of p and updates its suspicion score,
+// taking a capture if the score trips.
+func (g *Game) recordInput(p *Player, in wire.Input) {
+	l := p.inputs
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := time.Since(l.opened)
+	e := inputEntry{ms: uint32(now.Milliseconds()), angle: float32(in.Angle), seq: in.Seq}
+	if in.Boost {
+		e.flags |= 1
+	}
+	if in.HasSeq {
+		e.flags |= 2
+	}
+	l.ring[l.next] = e
+	l.next++
+	if l.next == len(l.ring) {
+		l.next, l.full = 0, true
+	}
+
+	reason := ""
+	if sec := int64(now / time.Second); sec != l.second {
+		l.score *= math.Pow(inputSuspicionDecay, float64(sec-l.second))
+		l.second, l.inSecond = sec, 0
+	}
+	l.inSecond++
+	if l.inSecond == inputFloodRate+1 {
+		l.score++
+		reason = "flood"
+	}
+	if in.HasSeq {
+		if l.hasSeq && in.Seq != l.lastSeq+1 {
+			l.score++
+			reason = "sequence"
+		}
+		l.lastSeq, l.hasSeq = in.Seq, true
+	}
+	trip := false
+	if l.score >= inputSuspicionTrip && !l.tripped {
+		l.tripped, trip = true, true
+	} else if l.score < inputSuspicionTrip/2 {
+		l.tripped = false // may trip again later
+	}
+	var c InputCapture
+	if trip {
+		c = l.captureLocked(reason, true)
+	}
+	l.mu.Unlock()
+
+	if trip {
+		c = g.review.add(c)
+		g.log.Warn("input suspicion tripped", "playerID", c.PlayerID, "name", c.Name, "ip", c.IP,
+			"reason", reason, "score", c.Score, "capture", c.ID)
+	}
+}
+
+// captureLocked copies the recording of the last window, with the inputs
+// if withInputs is set. l.mu must be held.
+func (l *inputLog) captureLocked(reason string, withInputs bool) InputCapture {
+	c := InputCapture{
+		PlayerID: l.playerID, Name: l.name, Identity: l.identity, IP: l.addr.String(),
+		At: time.Now(), Opened: l.opened, Score: math.Round(l.score*10) / 10, Reason: reason,
+	}
+	n := l.next
+	if l.full {
+		n = len(l.ring)
+	}
+	since := uint32(max(time.Since(l.opened)-l.window, 0).Milliseconds())
+	for i := 0; i < n; i++ {
+		e := l.ring[(l.next-n+i+len(l.ring))%len(l.ring)]
+		if e.ms < since {
+			continue
+		}
+		c.Count++
+		if !withInputs {
+			continue
+		}
+		rec := InputRecord{Ms: e.ms, Angle: float64(e.angle), Boost: e.flags&1 != 0}
+		if e.flags&2 != 0 {
+			seq := e.seq
+			rec.Seq = &seq
+		}
+		c.Inputs = append(c.Inputs, rec)
+	}
+	return c
+}
+
+// add stores a capture, dropping the oldest beyond MaxInputCaptures.
+func (r *inputReview) add(c InputCapture) InputCapture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	c.ID = r.nextID
+	r.captures = append(r.captures, c)
+	if len(r.captures) > MaxInputCaptures {
+		r.captures = append(r.captures[:0], r.captures[1:]...)
+	}
+	return c
+}
+
+// HandleInputs serves /admin/inputs (see the top of this file).
+func HandleInputs(rs *Rooms, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	rm, ok := rs.find(q.Get("room"))
+	if !ok {
+		http.Error(w, "room not found", http.StatusNotFound)
+		return
+	}
+	rev := &rm.game.review
+	w.Header().Set("Content-Type", "application/json")
+
+	if s := q.Get("player"); s != "" {
+		id, _ := strconv.Atoi(s)
+		rev.mu.Lock()
+		l := rev.live[id]
+		rev.mu.Unlock()
+		if l == nil {
+			http.Error(w, "player not found or not recorded", http.StatusNotFound)
+			return
+		}
+		l.mu.Lock()
+		c := l.captureLocked("request", true)
+		l.mu.Unlock()
+		json.NewEncoder(w).Encode(c)
+		return
+	}
+
+	rev.mu.Lock()
+	defer rev.mu.Unlock()
+	if s := q.Get("capture"); s != "" {
+		id, _ := strconv.Atoi(s)
+		for _, c := range rev.captures {
+			if c.ID == id {
+				json.NewEncoder(w).Encode(c)
+				return
+			}
+		}
+		http.Error(w, "capture not found", http.StatusNotFound)
+		return
+	}
+
+	captures := make([]InputCapture, 0, len(rev.captures))
+	for i := len(rev.captures) - 1; i >= 0; i-- {
+		c := rev.captures[i]
+		c.Inputs = nil
+		captures = append(captures, c)
+	}
+	players := make([]InputCapture, 0, len(rev.live))
+	for _, l := range rev.live {
+		l.mu.Lock()
+		players = append(players, l.captureLocked("", false))
+		l.mu.Unlock()
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].Score > players[j].Score })
+	json.NewEncoder(w).Encode(map[string]any{"room": rm.id, "captures": captures, "players": players})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Latency: application-level ping frames echoed by the client
+//
+// Server → client (type 3): type(1)=3, serverTimeMs(uint32 BE), rttMs(uint16 BE)
+//   rttMs is the player's current smoothed RTT (0 until measured).
+// Client → server (type 4): type(1)=4, serverTimeMs(uint32 BE) echoed back.
+// ---------------------------------------------------------------------------
+
+const (
+	PingInterval = 2 * time.Second
+	LaggyRTTMs   = 300 // players above this get every other state frame
+	maxRTTSample = 10000
+)
+
+var serverStart = time.Now()
+
+// serverMillis is a wrapping millisecond clock for ping timestamps.
+func serverMillis() uint32 {
+	return uint32(time.Since(serverStart).Milliseconds())
+}
+
+func (p *Player) pingFrame() []byte {
+	buf := make([]byte, 7)
+	buf[0] = 3
+	binary.BigEndian.PutUint32(buf[1:], serverMillis())
+	rtt := p.rttMs.Load()
+	if rtt > 65535 {
+		rtt = 65535
+	}
+	binary.BigEndian.PutUint16(buf[5:], uint16(rtt))
+	return buf
+}
+
+// handlePong folds an echoed ping timestamp into the smoothed RTT (EWMA,
+// 1/8 weight). Called from readPump only.
+func (p *Player) handlePong(sentMs uint32) {
+	sample := int64(serverMillis() - sentMs)
+	if sample > maxRTTSample { // bogus or wrapped timestamp
+		return
+	}
+	if sample < 1 {
+		sample = 1 // 0 means "not measured yet"
+	}
+	prev := p.rttMs.Load()
+	if prev == 0 {
+		p.rttMs.Store(sample)
+	} else {
+		p.rttMs.Store((prev*7 + sample) / 8)
+	}
+}
+
+// laggy reports whether the player's RTT is high enough to halve its
+// update rate.
+func (p *Player) laggy() bool {
+	return p.rttMs.Load() > LaggyRTTMs
+}
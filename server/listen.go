@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ---------------------------------------------------------------------------
+// Listeners: TCP, Unix domain sockets and systemd socket activation
+//
+// Listen addresses take one of these forms:
+//   host:port       TCP
+//   unix:/path      Unix domain socket (a stale socket file is replaced)
+//   systemd         the next unused socket passed by systemd
+//   systemd:name    the socket with FileDescriptorName=name
+// ---------------------------------------------------------------------------
+
+// systemd passes activated sockets starting at this descriptor.
+const sdListenFDsStart = 3
+
+// activated holds the systemd sockets, taken from the environment once.
+var (
+	activatedMu    sync.Mutex
+	activated      map[string]*os.File
+	activatedOrder []string
+)
+
+func listen(addr string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		path := strings.TrimPrefix(addr, "unix:")
+		if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+		return net.Listen("unix", path)
+
+	case addr == "systemd" || strings.HasPrefix(addr, "systemd:"):
+		activatedMu.Lock()
+		defer activatedMu.Unlock()
+		if activated == nil {
+			takeActivatedSockets()
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(addr, "systemd"), ":")
+		if name == "" {
+			for _, n := range activatedOrder {
+				if activated[n] != nil {
+					name = n // first socket not yet taken
+					break
+				}
+			}
+		}
+		f, ok := activated[name]
+		if !ok {
+			return nil, fmt.Errorf("no systemd socket %q (got %d sockets: %s)",
+				name, len(activatedOrder), strings.Join(activatedOrder, ", "))
+		}
+		delete(activated, name)
+		defer f.Close()
+		return net.FileListener(f)
+
+	default:
+		return net.Listen("tcp", addr)
+	}
+}
+
+// takeActivatedSockets reads LISTEN_PID/LISTEN_FDS/LISTEN_FDNAMES (see
+// sd_listen_fds(3)) and unsets them so child processes don't inherit them.
+func takeActivatedSockets() {
+	activated = make(map[string]*os.File)
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < n; i++ {
+		name := strconv.Itoa(sdListenFDsStart + i) // systemd's default is "unknown"
+		if i < len(names) && names[i] != "" && names[i] != "unknown" {
+			name = names[i]
+		}
+		activated[name] = os.NewFile(uintptr(sdListenFDsStart+i), name)
+		activatedOrder = append(activatedOrder, name)
+	}
+	slog.Info("systemd socket activation", "sockets", activatedOrder)
+}
+
+// Serve runs srv on an existing listener. Blocks until the listener fails
+// or srv is shut down, which isn't an error.
+func Serve(srv *http.Server, l net.Listener) error {
+	if err := srv.Serve(l); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// listenURL formats addr for log lines.
+func listenURL(scheme string, l net.Listener) string {
+	if l.Addr().Network() == "unix" {
+		return "unix:" + l.Addr().String()
+	}
+	return scheme + "://" + l.Addr().String()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// ---------------------------------------------------------------------------
+// Logging (log/slog with text or JSON output)
+//
+// Game events use consistent fields so logs can be queried in Loki/ELK:
+//   room      - room ID (on every record of a room, see Game.log)
+//   playerID  - connection/player ID (humans only)
+//   snakeID   - wire ID of the snake involved (negative for AI)
+//   killerID  - wire ID of the killing snake (kill events)
+// ---------------------------------------------------------------------------
+
+func setupLogging(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q (debug, info, warn, error)", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch format {
+	case "text":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid log format %q (text, json)", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// fatal logs at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// snakeAttrs returns the standard fields identifying a snake.
+func snakeAttrs(s *Snake) []any {
+	attrs := []any{"snakeID", s.PlayerID, "name", s.Name, "score", s.Score}
+	if !s.IsAI {
+		attrs = append(attrs, "playerID", s.PlayerID)
+	}
+	return attrs
+}
+
+// killAttrs returns the fields for a kill event: victim fields plus killer.
+func killAttrs(victim, killer *Snake) []any {
+	return append(snakeAttrs(victim), "killerID", killer.PlayerID, "killer", killer.Name)
+}
@@ -5,9 +5,14 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"snake-server/sim"
 )
 
 const Version = "1.0.0"
@@ -17,6 +22,10 @@ var indexHTML []byte
 
 func main() {
 	port := flag.Int("port", 8080, "Server port")
+	listenAddr := flag.String("addr", "", "Game listen address: host:port, unix:/path or systemd[:name] (overrides -port)")
+	adminAddr := flag.String("admin-addr", "", "Separate listen address for stats, dashboard and admin endpoints (default: the game listener)")
+	grpcPort := flag.Int("grpc-port", 0, "gRPC control API port (0 = disabled, needs -admin-token)")
+	grpcAddr := flag.String("grpc-addr", "", "gRPC control API listen address (overrides -grpc-port)")
 	configFile := flag.String("config", "", "Path to JSON config file")
 	worldSize := flag.Int("world-size", 0, "World size (default 10000)")
 	foodCount := flag.Int("food-count", 0, "Food item count (default 3000)")
@@ -28,77 +37,605 @@ func main() {
 	boostDrain := flag.Float64("boost-drain", 0, "Boost drain rate (default 0.6)")
 	boostRegen := flag.Float64("boost-regen", 0, "Boost regen rate (default 0.15)")
 	baseSnakeLen := flag.Int("base-snake-len", 0, "Base snake length (default 10)")
+	segmentSpacing := flag.Float64("segment-spacing", 0, "Distance between drawn body points (default 8)")
+	growthRate := flag.Float64("growth-rate", 0, "Body points a snake grows per tick at most (default 1)")
 	killFoodCount := flag.Int("kill-food-count", 0, "Food dropped on kill (default 8)")
 	boundaryMargin := flag.Float64("boundary-margin", 0, "Boundary margin (default 50)")
+	warningBand := flag.Float64("warning-band", 0, "Width of the warning haze inside the boundary, 0 = none (default 150)")
+	wallBrake := flag.Bool("wall-brake", false, "Players slow down and stop at the boundary instead of dying")
 	aiRespawnTicks := flag.Int("ai-respawn-ticks", 0, "AI respawn delay in ticks (default 180)")
+	fillBots := flag.Int("fill-bots", 0, "Living snakes to keep near every player with filler bots (0 = off, max 64)")
+	fillRadius := flag.Float64("fill-radius", 0, "Distance within which -fill-bots counts snakes as near (default 1500)")
+	fixedDifficulty := flag.Bool("fixed-difficulty", false, "Keep the bots as configured instead of tuning them to a solo player's deaths")
+	simRate := flag.Int("sim-rate", 0, "Movement and collision steps per second, a multiple of 60 (default 60)")
+	spawnProtection := flag.Int("spawn-protection", 0, "Ticks a new snake is invulnerable, ended early by the player's first boost or turn (default 120)")
+	spawnClearance := flag.Float64("spawn-clearance", 0, "Preferred distance from other snakes when spawning (default 400)")
+	respawnCooldown := flag.Int("respawn-cooldown", 0, "Ticks after a death before a player may respawn, 0 = none (max 3600)")
+	spectateKiller := flag.Bool("spectate-killer", false, "Players watch their killer for 5 seconds before they may respawn")
+	heatmapSize := flag.Int("heatmap-size", 0, "Minimap heatmap cells per side, 0 = off (default 32)")
+	frameBudget := flag.Int("frame-budget", 0, "Bytes per state message before protocol v5 frames are split, 0 = never (default 16384)")
+	deterministic := flag.Bool("deterministic", false, "Fixed-point snake movement, bit-identical across CPU architectures")
+	timeScale := flag.Float64("time-scale", 0, "Game time per real time, 0.25 (slow motion) to 2 (default 1)")
+	idleTickRate := flag.Int("idle-tick-rate", 0, "Ticks per second while no players are connected (0 = full rate)")
+	idlePause := flag.Int("idle-pause", 0, "Seconds without players before the simulation pauses (0 = never)")
+	tickBudget := flag.Float64("tick-budget", 0, "Average tick time in ms above which the watchdog sheds load (0 = off)")
+	shardCells := flag.Int("shard-cells", 0, "Split the world into N×N shard cells with their own collision workers (0 = unsharded)")
+	collisionPrecision := flag.Int("collision-precision", 0, "Body collision precision: 0 = point test, N = swept head vs every Nth body point (default 1)")
+	arena := flag.String("arena", "", "Arena shape: square, circle or hexagon (default square)")
+	killReward := flag.String("kill-reward", "", "Kill reward model: percent (of the victim's length), flat or diminishing (default percent)")
+	killRewardPercent := flag.Float64("kill-reward-percent", 0, "Fraction of the victim's length a kill is worth with -kill-reward percent or diminishing (default 0.3)")
+	killRewardFlat := flag.Int("kill-reward-flat", 0, "Length a kill is worth with -kill-reward flat (default 20)")
+	killRewardKnee := flag.Int("kill-reward-knee", 0, "Victim length worth half the percent reward with -kill-reward diminishing (default 200)")
+	headOn := flag.String("head-on", "", "Head-on collisions: longer (longer snake wins), faster or both (both die) (default longer)")
+	foodSpawn := flag.String("food-spawn", "", "Food distribution: uniform, biome (rich centre) or clusters (moving blooms)")
+	foodBlooms := flag.Int("food-blooms", 0, "Blooms open at a time with -food-spawn clusters (default 5)")
+	foodTTL := flag.Int("food-ttl", 0, "Seconds a pellet lies before it despawns and is replaced elsewhere, 0 = forever (max 3600)")
+	boostMode := flag.String("boost-mode", "", "Boost model: meter (regenerating) or charge (refilled by charge pellets)")
+	laserTail := flag.Bool("laser-tail", false, "Boosting snakes leave a deadly trail")
+	trailLifetime := flag.Int("trail-lifetime", 0, "Laser tail lifetime in ticks (default 90)")
+	roundDuration := flag.Int("round-duration", 0, "Tournament round length in seconds (0 = endless play)")
+	webhookURL := flag.String("webhook-url", "", "URL that receives round results as JSON (tournament mode)")
+	mutators := flag.String("mutators", "", "Comma-separated mutators always on: lowGravity, doubleFood, tinySnakes, infiniteBoost, fog")
+	randomMutators := flag.Int("random-mutators", 0, "Mutators drawn at random for each tournament round, 0 = none")
+	decayThreshold := flag.Int("decay-threshold", 0, "Length above which snakes slowly shrink (0 = disabled)")
+	decayRate := flag.Float64("decay-rate", 0, "Fraction of the excess length lost per second (default 0.01)")
+	decayDropFood := flag.Bool("decay-drop-food", false, "Drop decayed length as food")
+	aiPackSize := flag.Int("ai-pack-size", 0, "Max AI bots per hunting pack, 0 = no pack hunting (default 3)")
+	aiScripts := flag.String("ai-scripts", "", "Directory of Lua AI personality scripts (hot-reloaded)")
+	predators := flag.Int("predators", 0, "Roaming predator eels that kill snakes they touch (0 = none)")
+	predatorSpeed := flag.Float64("predator-speed", 0, "Predator speed in units per tick (default 2.8)")
+	portals := flag.Int("portals", 0, "Linked portal pairs that teleport snakes (0 = none, max 8)")
+	portalCooldown := flag.Int("portal-cooldown", 0, "Ticks after a portal jump before a snake may take a portal again (default 180)")
+	streakBonus := flag.Int("streak-bonus", 0, "Score per kill-streak kill before multipliers, 0 = no bonus (default 10)")
+	bountyInterval := flag.Int("bounty-interval", 0, "Seconds between golden-snake bounties (0 = disabled)")
+	xpPerKill := flag.Int("xp-per-kill", 0, "XP per kill, on top of the final score (default 50)")
+	xpLevelBase := flag.Int("xp-level-base", 0, "XP needed to reach level 2 (default 500)")
+	xpLevelGrowth := flag.Float64("xp-level-growth", 0, "Factor by which each further level needs more XP (default 1.2)")
+	maxLevel := flag.Int("max-level", 0, "Highest player level, at most 255 (default 50)")
+	ratingK := flag.Float64("rating-k", 0, "Elo K-factor for skill ratings, 0 = ratings off (default 24)")
+	ratingSpawn := flag.Bool("rating-spawn", false, "Spawn players away from much higher-rated players")
+	dataDir := flag.String("data-dir", "", "Directory for the SQLite database (profiles, leaderboard, bans, match history)")
+	eventLog := flag.String("event-log", "", "Append joins, deaths, rounds and config changes to this file as NDJSON")
+	eventLogMaxMB := flag.Int("event-log-max-mb", 0, "Size in MB at which the event log is rotated (default 100)")
+	eventLogKeep := flag.Int("event-log-keep", 0, "Rotated event log files to keep, 0 = none (default 5)")
+	inputLogMinutes := flag.Int("input-log-minutes", 0, "Minutes of raw inputs kept per player for cheat review (/admin/inputs), 0 = off, max 10")
+	chatSnakeChannel := flag.String("chat-snake-channel", "", "Twitch channel whose chat steers the chat snake (left, right, boost), \"\" = off")
+	replays := flag.Int("replays", 0, "Tournament rounds to keep as replays in <data-dir>/replays, 0 = off (needs -data-dir)")
+	restore := flag.String("restore", "", "Restore the world from a snapshot file at startup (if it exists)")
+	autosave := flag.String("autosave", "", "Periodically save the world to this snapshot file")
+	autosaveInterval := flag.Duration("autosave-interval", 5*time.Minute, "Autosave interval")
+	drainTimeout := flag.Duration("drain-timeout", DefaultDrainTimeout, "On SIGTERM, time players get to leave before the server stops")
+	healthcheck := flag.Bool("healthcheck", false, "Check /healthz of the server running with the same -port/-addr, exit 0 if healthy and 1 if not")
+	benchmark := flag.Int("benchmark", 0, "Run N ticks headless (no network), print per-stage timing and allocations, and exit")
+	benchBots := flag.Int("benchmark-bots", 20, "Scripted players joined for -benchmark, each receiving state frames")
+	benchSeed := flag.Int64("benchmark-seed", 1, "World seed for -benchmark")
+	enablePprof := flag.Bool("pprof", false, "Enable /debug/pprof endpoints (requires -admin-token)")
+	logLevel := flag.String("log-level", "info", "Log level (debug, info, warn, error)")
+	logFormat := flag.String("log-format", "text", "Log format (text, json)")
+	identityKey := flag.String("identity-key", "", "HMAC key for player identity tokens (default: random, identities end on restart)")
+	identityExpiry := flag.Int("identity-expiry-days", 0, "Days without play before a player identity expires, 0 = never (default 90)")
+	publicURL := flag.String("public-url", "", "Public base URL of the server, used for OAuth redirects and cluster routing (default: from the request)")
+	clusterRedis := flag.String("cluster-redis", "", "Redis address (host:port or redis://) of the cluster room directory (default: standalone)")
+	instanceID := flag.String("instance-id", "", "Room ID of this server in the cluster directory (default: host name and process ID)")
+	maxRooms := flag.Int("max-rooms", 0, "Extra rooms with custom rulesets that can be created at runtime, 0 = main room only (default 4)")
+	minLength := flag.Int("min-length", 0, "Snakes spawning shorter are moved to another room (0 = no lower bound)")
+	maxLength := flag.Int("max-length", 0, "Snakes growing longer are moved to another room (0 = no upper bound)")
+	adminToken := flag.String("admin-token", "", "Admin token (allows reserved names)")
+	nameBlocklist := flag.String("name-blocklist", "", "Path to name blocklist file (one word per line)")
+	chatRadius := flag.Float64("chat-radius", 0, "Reach of proximity chat in world units (default 1500)")
+	proximityChatOnly := flag.Bool("proximity-chat-only", false, "Turn off all-chat, every message only reaches nearby players")
+	nameWidth := flag.Int("name-width", 0, "Display width of player names, wide characters count 2 (default 15)")
+	maxConnsPerIP := flag.Int("max-conns-per-ip", 0, "Concurrent connections allowed per IP (0 = unlimited)")
+	banFile := flag.String("ban-file", "", "Path to the persistent ban list (IPs/CIDRs, one per line)")
+	allowedOrigins := flag.String("allowed-origins", "", "Comma-separated browser origins allowed to connect (empty = any)")
 	flag.Parse()
+	if err := checkEnvNames(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var cmdLine []string // before flagsFromEnv marks flags from the environment as set
+	flag.Visit(func(f *flag.Flag) { cmdLine = append(cmdLine, f.Name) })
+	if err := flagsFromEnv(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	addr := fmt.Sprintf("0.0.0.0:%d", *port)
+	if *listenAddr != "" {
+		addr = *listenAddr
+	}
+	if *healthcheck {
+		if err := runHealthcheck(addr); err != nil {
+			fmt.Fprintln(os.Stderr, "unhealthy:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	log.SetFlags(log.Ldate | log.Ltime)
-	log.Printf("Snake.io server v%s starting...", Version)
+	if *benchmark > 0 && !flagSet("log-level") {
+		*logLevel = "warn" // keep per-event logging out of the timings
+	}
+	if err := setupLogging(*logLevel, *logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.Info("Snake.io server starting", "version", Version)
 
-	// Build config: defaults → config file → CLI overrides
+	// Build config: defaults → config file → environment → CLI overrides
 	cfg := DefaultConfig()
 
+	var fileKeys map[string]json.RawMessage
 	if *configFile != "" {
 		data, err := os.ReadFile(*configFile)
 		if err != nil {
-			log.Fatalf("Failed to read config file: %v", err)
+			fatal("failed to read config file", "path", *configFile, "err", err)
 		}
 		if err := json.Unmarshal(data, &cfg); err != nil {
-			log.Fatalf("Failed to parse config file: %v", err)
+			fatal("failed to parse config file", "path", *configFile, "err", err)
 		}
-		log.Printf("Loaded config from %s", *configFile)
+		json.Unmarshal(data, &fileKeys)
+		slog.Info("loaded config", "path", *configFile)
+	}
+	used, err := configFromEnv(&cfg)
+	if err != nil {
+		fatal("invalid environment config", "err", err)
+	}
+	if len(used) > 0 {
+		slog.Info("config from environment", "vars", used)
+	}
+	if unknown := unknownEnv(); len(unknown) > 0 {
+		slog.Warn("unknown environment variables", "vars", unknown)
 	}
 
-	// CLI flag overrides (non-zero values override config file)
-	if *worldSize > 0 {
+	// CLI flag overrides: every flag given on the command line or in the
+	// environment overrides the config file, zero and negative values too
+	if flagSet("world-size") {
 		cfg.WorldSize = *worldSize
 	}
-	if *foodCount > 0 {
+	if flagSet("food-count") {
 		cfg.FoodCount = *foodCount
 	}
-	if *aiCount > 0 {
+	if flagSet("ai-count") {
 		cfg.AICount = *aiCount
 	}
-	if *baseSpeed > 0 {
+	if flagSet("base-speed") {
 		cfg.BaseSpeed = *baseSpeed
 	}
-	if *boostSpeed > 0 {
+	if flagSet("boost-speed") {
 		cfg.BoostSpeed = *boostSpeed
 	}
-	if *turnSpeed > 0 {
+	if flagSet("turn-speed") {
 		cfg.TurnSpeed = *turnSpeed
 	}
-	if *maxBoost > 0 {
+	if flagSet("max-boost") {
 		cfg.MaxBoost = *maxBoost
 	}
-	if *boostDrain > 0 {
+	if flagSet("boost-drain") {
 		cfg.BoostDrain = *boostDrain
 	}
-	if *boostRegen > 0 {
+	if flagSet("boost-regen") {
 		cfg.BoostRegen = *boostRegen
 	}
-	if *baseSnakeLen > 0 {
+	if flagSet("base-snake-len") {
 		cfg.BaseSnakeLen = *baseSnakeLen
 	}
-	if *killFoodCount > 0 {
+	if flagSet("segment-spacing") {
+		cfg.SegmentSpacing = *segmentSpacing
+	}
+	if flagSet("growth-rate") {
+		cfg.GrowthRate = *growthRate
+	}
+	if flagSet("kill-food-count") {
 		cfg.KillFoodCount = *killFoodCount
 	}
-	if *boundaryMargin > 0 {
+	if flagSet("boundary-margin") {
 		cfg.BoundaryMargin = *boundaryMargin
 	}
-	if *aiRespawnTicks > 0 {
+	if flagSet("warning-band") {
+		cfg.WarningBand = *warningBand
+	}
+	if flagSet("wall-brake") {
+		cfg.WallBrake = *wallBrake
+	}
+	if flagSet("ai-respawn-ticks") {
 		cfg.AIRespawnTicks = *aiRespawnTicks
 	}
+	if flagSet("fill-bots") {
+		cfg.FillBots = *fillBots
+	}
+	if flagSet("fill-radius") {
+		cfg.FillRadius = *fillRadius
+	}
+	if flagSet("fixed-difficulty") {
+		cfg.FixedDifficulty = *fixedDifficulty
+	}
+	if flagSet("sim-rate") {
+		cfg.SimRate = *simRate
+	}
+	if flagSet("spawn-protection") {
+		cfg.SpawnProtection = *spawnProtection
+	}
+	if flagSet("spawn-clearance") {
+		cfg.SpawnClearance = *spawnClearance
+	}
+	if flagSet("respawn-cooldown") {
+		cfg.RespawnCooldown = *respawnCooldown
+	}
+	if flagSet("spectate-killer") {
+		cfg.SpectateKiller = *spectateKiller
+	}
+	if flagSet("heatmap-size") {
+		cfg.HeatmapSize = *heatmapSize
+	}
+	if flagSet("frame-budget") {
+		cfg.FrameBudget = *frameBudget
+	}
+	if flagSet("deterministic") {
+		cfg.Deterministic = *deterministic
+	}
+	if flagSet("time-scale") {
+		cfg.TimeScale = *timeScale
+	}
+	if flagSet("idle-tick-rate") {
+		cfg.IdleTickRate = *idleTickRate
+	}
+	if flagSet("idle-pause") {
+		cfg.IdlePause = *idlePause
+	}
+	if flagSet("tick-budget") {
+		cfg.TickBudget = *tickBudget
+	}
+	if flagSet("shard-cells") {
+		cfg.ShardCells = *shardCells
+	}
+	if flagSet("collision-precision") {
+		cfg.CollisionPrecision = *collisionPrecision
+	}
+	if flagSet("arena") {
+		cfg.Arena = *arena
+	}
+	if flagSet("kill-reward") {
+		cfg.KillReward = *killReward
+	}
+	if flagSet("kill-reward-percent") {
+		cfg.KillRewardPercent = *killRewardPercent
+	}
+	if flagSet("kill-reward-flat") {
+		cfg.KillRewardFlat = *killRewardFlat
+	}
+	if flagSet("kill-reward-knee") {
+		cfg.KillRewardKnee = *killRewardKnee
+	}
+	if flagSet("head-on") {
+		cfg.HeadOn = *headOn
+	}
+	if flagSet("food-spawn") {
+		cfg.FoodSpawn = *foodSpawn
+	}
+	if flagSet("food-blooms") {
+		cfg.FoodBlooms = *foodBlooms
+	}
+	if flagSet("food-ttl") {
+		cfg.FoodTTL = *foodTTL
+	}
+	if flagSet("boost-mode") {
+		cfg.BoostMode = *boostMode
+	}
+	if flagSet("laser-tail") {
+		cfg.LaserTail = *laserTail
+	}
+	if flagSet("trail-lifetime") {
+		cfg.TrailLifetime = *trailLifetime
+	}
+	if flagSet("round-duration") {
+		cfg.RoundDuration = *roundDuration
+	}
+	if flagSet("webhook-url") {
+		cfg.WebhookURL = *webhookURL
+	}
+	if flagSet("mutators") {
+		cfg.Mutators = nil
+		for _, m := range strings.Split(*mutators, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				cfg.Mutators = append(cfg.Mutators, m)
+			}
+		}
+	}
+	if flagSet("random-mutators") {
+		cfg.RandomMutators = *randomMutators
+	}
+	if flagSet("decay-threshold") {
+		cfg.DecayThreshold = *decayThreshold
+	}
+	if flagSet("decay-rate") {
+		cfg.DecayRate = *decayRate
+	}
+	if flagSet("decay-drop-food") {
+		cfg.DecayDropFood = *decayDropFood
+	}
+	if flagSet("ai-pack-size") {
+		cfg.AIPackSize = *aiPackSize
+	}
+	if flagSet("ai-scripts") {
+		cfg.AIScriptDir = *aiScripts
+	}
+	if flagSet("streak-bonus") {
+		cfg.StreakBonus = *streakBonus
+	}
+	if flagSet("bounty-interval") {
+		cfg.BountyInterval = *bountyInterval
+	}
+	if flagSet("predators") {
+		cfg.Predators = *predators
+	}
+	if flagSet("predator-speed") {
+		cfg.PredatorSpeed = *predatorSpeed
+	}
+	if flagSet("portals") {
+		cfg.Portals = *portals
+	}
+	if flagSet("portal-cooldown") {
+		cfg.PortalCooldown = *portalCooldown
+	}
+	if flagSet("admin-token") {
+		cfg.AdminToken = *adminToken
+	}
+	if flagSet("event-log") {
+		cfg.EventLog = *eventLog
+	}
+	if flagSet("event-log-max-mb") {
+		cfg.EventLogMaxMB = *eventLogMaxMB
+	}
+	if flagSet("event-log-keep") {
+		cfg.EventLogKeep = *eventLogKeep
+	}
+	if flagSet("replays") {
+		cfg.Replays = *replays
+	}
+	if flagSet("input-log-minutes") {
+		cfg.InputLogMinutes = *inputLogMinutes
+	}
+	if flagSet("chat-snake-channel") {
+		cfg.ChatSnake.Channel = *chatSnakeChannel
+	}
+	if flagSet("name-width") {
+		cfg.NameWidth = *nameWidth
+	}
+	if flagSet("chat-radius") {
+		cfg.ChatRadius = *chatRadius
+	}
+	if flagSet("proximity-chat-only") {
+		cfg.ProximityChatOnly = *proximityChatOnly
+	}
+	if flagSet("identity-key") {
+		cfg.IdentityKey = *identityKey
+	}
+	if flagSet("identity-expiry-days") {
+		cfg.IdentityExpiryDays = *identityExpiry
+	}
+	if flagSet("xp-per-kill") {
+		cfg.XPPerKill = *xpPerKill
+	}
+	if flagSet("xp-level-base") {
+		cfg.XPLevelBase = *xpLevelBase
+	}
+	if flagSet("xp-level-growth") {
+		cfg.XPLevelGrowth = *xpLevelGrowth
+	}
+	if flagSet("max-level") {
+		cfg.MaxLevel = *maxLevel
+	}
+	if flagSet("rating-k") {
+		cfg.RatingK = *ratingK
+	}
+	if flagSet("rating-spawn") {
+		cfg.RatingSpawn = *ratingSpawn
+	}
+	if flagSet("data-dir") {
+		cfg.DataDir = *dataDir
+	}
+	if flagSet("public-url") {
+		cfg.PublicURL = *publicURL
+	}
+	if flagSet("cluster-redis") {
+		cfg.ClusterRedis = *clusterRedis
+	}
+	if flagSet("instance-id") {
+		cfg.InstanceID = *instanceID
+	}
+	if flagSet("max-rooms") {
+		cfg.MaxRooms = *maxRooms
+	}
+	if flagSet("min-length") {
+		cfg.MinLength = *minLength
+	}
+	if flagSet("max-length") {
+		cfg.MaxLength = *maxLength
+	}
+	if flagSet("max-conns-per-ip") {
+		cfg.MaxConnsPerIP = *maxConnsPerIP
+	}
+	if flagSet("ban-file") {
+		cfg.BanFile = *banFile
+	}
+	if flagSet("allowed-origins") {
+		cfg.AllowedOrigins = nil
+		for _, o := range strings.Split(*allowedOrigins, ",") {
+			if strings.TrimSpace(o) == "" {
+				continue
+			}
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, strings.TrimSpace(o))
+		}
+	}
+	if *nameBlocklist != "" {
+		words, err := readWordList(*nameBlocklist)
+		if err != nil {
+			fatal("failed to read name blocklist", "path", *nameBlocklist, "err", err)
+		}
+		cfg.NameBlocklist = append(cfg.NameBlocklist, words...)
+		slog.Info("loaded name blocklist", "path", *nameBlocklist, "words", len(words))
+	}
+
+	clamped, err := cfg.Validate()
+	for _, c := range clamped {
+		slog.Warn("config value clamped", "field", c.Field, "reason", c.Reason)
+	}
+	if err != nil {
+		errs := err.(ConfigErrors)
+		for _, e := range errs {
+			slog.Error("invalid config", "field", e.Field, "reason", e.Reason)
+		}
+		fatal("invalid config", "errors", len(errs))
+	}
 
-	log.Printf("Config: worldSize=%d food=%d ai=%d speed=%.1f boost=%.1f",
-		cfg.WorldSize, cfg.FoodCount, cfg.AICount, cfg.BaseSpeed, cfg.BoostSpeed)
+	slog.Info("config", "worldSize", cfg.WorldSize, "food", cfg.FoodCount, "ai", cfg.AICount,
+		"speed", cfg.BaseSpeed, "boost", cfg.BoostSpeed)
+	if cfg.RoundDuration > 0 {
+		slog.Info("tournament mode", "roundSec", cfg.RoundDuration, "countdownSec", cfg.RoundCountdown,
+			"resultsSec", cfg.RoundResultsTime, "webhook", cfg.WebhookURL != "")
+	}
+	if cfg.SimRate != TickRate {
+		slog.Info("substepped simulation", "simRate", cfg.SimRate, "substeps", cfg.SimRate/TickRate)
+	}
+	if cfg.Deterministic {
+		slog.Info("deterministic physics", "angleUnits", sim.AngleUnits, "posScale", sim.PosScale)
+	}
+	if cfg.TimeScale != 1 {
+		slog.Info("time scale", "timeScale", cfg.TimeScale)
+	}
+	if cfg.IdleTickRate > 0 || cfg.IdlePause > 0 {
+		slog.Info("idle power saving", "idleTickRate", cfg.IdleTickRate, "idlePause", cfg.IdlePause)
+	}
+	if cfg.Predators > 0 {
+		slog.Info("predators enabled", "predators", cfg.Predators, "speed", cfg.PredatorSpeed)
+	}
+	if cfg.Portals > 0 {
+		slog.Info("portals enabled", "pairs", cfg.Portals, "cooldownTicks", cfg.PortalCooldown)
+	}
+	if cfg.ShardCells > 1 {
+		slog.Info("sharded world", "cells", cfg.ShardCells*cfg.ShardCells,
+			"cellSize", cfg.WorldSize/cfg.ShardCells)
+	}
+	if cfg.Arena != "" && cfg.Arena != "square" {
+		slog.Info("arena shape", "arena", cfg.Arena)
+	}
+	if cfg.FoodSpawn != "" && cfg.FoodSpawn != "uniform" {
+		slog.Info("food distribution", "foodSpawn", cfg.FoodSpawn, "blooms", cfg.FoodBlooms)
+	}
+	if cfg.BoostMode == "charge" {
+		slog.Info("charge boost mode", "chargeValue", cfg.ChargeValue, "chargeFoodRatio", cfg.ChargeFoodRatio,
+			"cooldownTicks", cfg.BoostCooldown)
+	}
+	if cfg.IdentityKey == "" {
+		slog.Info("identity tokens use a random key; set -identity-key to keep player identities across restarts")
+	}
+	if cfg.DecayThreshold > 0 {
+		slog.Info("length decay", "threshold", cfg.DecayThreshold, "rate", cfg.DecayRate, "dropFood", cfg.DecayDropFood)
+	}
+
+	access, err := NewAccessControl(cfg.MaxConnsPerIP, cfg.BanFile)
+	if err != nil {
+		fatal("failed to load ban list", "path", cfg.BanFile, "err", err)
+	}
+	if cfg.MaxConnsPerIP > 0 || cfg.BanFile != "" {
+		slog.Info("access control", "maxConnsPerIp", cfg.MaxConnsPerIP, "banFile", cfg.BanFile,
+			"bans", len(access.Bans()))
+	}
+
+	origins, err := NewOriginPolicy(cfg.AllowedOrigins)
+	if err != nil {
+		fatal("invalid origin allow-list", "err", err)
+	}
+	upgrader.CheckOrigin = origins.Allowed
+	if origins.Strict() {
+		slog.Info("origin allow-list", "origins", cfg.AllowedOrigins)
+	}
 
 	game := NewGame(cfg)
+	if *benchmark > 0 {
+		runBenchmark(game, benchmarkOptions{Ticks: *benchmark, Bots: *benchBots, Seed: *benchSeed})
+		return
+	}
+	if *restore != "" {
+		if err := game.LoadSnapshotFile(*restore); err == nil {
+			slog.Info("restored world from snapshot", "path", *restore, "frame", game.frame)
+		} else if os.IsNotExist(err) {
+			slog.Info("no snapshot to restore, starting fresh", "path", *restore)
+		} else {
+			fatal("failed to restore snapshot", "path", *restore, "err", err)
+		}
+	}
+	if cfg.DataDir != "" {
+		store, err := OpenSQLite(cfg.DataDir)
+		if err != nil {
+			fatal("failed to open storage", "dir", cfg.DataDir, "err", err)
+		}
+		if err := game.EnableStorage(store); err != nil {
+			fatal("failed to load profiles", "dir", cfg.DataDir, "err", err)
+		}
+		if err := access.UseStorage(store); err != nil {
+			fatal("failed to load stored bans", "dir", cfg.DataDir, "err", err)
+		}
+		slog.Info("storage enabled", "backend", store.Name(), "dir", cfg.DataDir,
+			"profiles", len(game.profiles), "bans", len(access.Bans()))
+	}
+	if cfg.EventLog != "" {
+		if err := game.EnableEventLog(cfg.EventLog, cfg.EventLogMaxMB, cfg.EventLogKeep); err != nil {
+			fatal("failed to open event log", "path", cfg.EventLog, "err", err)
+		}
+		slog.Info("event log enabled", "path", cfg.EventLog, "maxMB", cfg.EventLogMaxMB, "keep", cfg.EventLogKeep)
+	}
+	if cfg.Replays > 0 {
+		if cfg.DataDir == "" {
+			fatal("replays need -data-dir")
+		}
+		dir := filepath.Join(cfg.DataDir, "replays")
+		if err := game.EnableReplays(dir, cfg.Replays); err != nil {
+			fatal("failed to open replay directory", "dir", dir, "err", err)
+		}
+		slog.Info("replays enabled", "dir", dir, "keep", cfg.Replays)
+	}
+	if cfg.ChatSnake.enabled() {
+		go game.RunChatConnector(cfg.ChatSnake)
+		slog.Info("chat snake enabled", "channel", cfg.ChatSnake.Channel, "name", cfg.ChatSnake.name())
+	}
+	if cfg.AIScriptDir != "" {
+		if err := game.EnableAIScripts(cfg.AIScriptDir); err != nil {
+			fatal("failed to load AI scripts", "dir", cfg.AIScriptDir, "err", err)
+		}
+		slog.Info("AI scripts enabled", "dir", cfg.AIScriptDir, "personalities", len(game.scripts.list))
+	}
+	if *autosave != "" {
+		game.EnableAutosave(*autosave, *autosaveInterval)
+		slog.Info("autosave enabled", "path", *autosave, "interval", *autosaveInterval)
+	}
+	rooms := NewRooms(game, cfg)
 	go game.Run()
+	health := NewHealth(rooms)
+
+	if *grpcAddr == "" && *grpcPort > 0 {
+		*grpcAddr = fmt.Sprintf("0.0.0.0:%d", *grpcPort)
+	}
+	if *grpcAddr != "" && cfg.AdminToken == "" {
+		slog.Warn("control API disabled: -grpc-port requires an admin token")
+	} else if *grpcAddr != "" {
+		go func() {
+			if err := ServeControl(rooms, *grpcAddr, cfg.AdminToken); err != nil {
+				fatal("control API failed", "err", err)
+			}
+		}()
+	}
+
+	// Game endpoints go on mux; stats, dashboard and admin endpoints on
+	// adminMux, which is the same mux unless -admin-addr is set.
+	mux := http.NewServeMux()
+	adminMux := mux
+	if *adminAddr != "" {
+		adminMux = http.NewServeMux()
+	}
 
 	// Serve embedded index.html
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/" {
 			http.NotFound(w, r)
 			return
@@ -107,25 +644,179 @@ func main() {
 		w.Write(indexHTML)
 	})
 
-	// WebSocket endpoint
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		HandleWS(game, w, r)
+	// WebSocket endpoint; ?room= picks an extra room (see rooms.go)
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		if health.Draining() {
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		g, ok := rooms.Get(r.URL.Query().Get("room"))
+		if !ok {
+			http.Error(w, "room not found", http.StatusNotFound)
+			return
+		}
+		HandleWS(g, access, w, r)
 	})
 
-	// Stats API and dashboard
-	http.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
-		HandleStats(game, w, r)
-	})
-	http.HandleFunc("/dashboard", HandleDashboard)
-	http.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+	mux.HandleFunc("/healthz", health.HandleHealthz)
+	mux.HandleFunc("/challenges", origins.CORS(HandleChallenges))
+	mux.HandleFunc("/world", origins.CORS(func(w http.ResponseWriter, r *http.Request) {
+		HandleWorld(rooms, w, r)
+	}))
+	mux.HandleFunc("/ping", origins.CORS(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
 		w.Write([]byte("ok"))
-	})
+	}))
 
-	addr := fmt.Sprintf("0.0.0.0:%d", *port)
-	log.Printf("Listening on http://%s", addr)
-	log.Printf("WebSocket: ws://%s/ws", addr)
-	log.Printf("Dashboard: http://%s/dashboard", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	accounts, err := NewAccounts(game, cfg)
+	if err != nil {
+		fatal("invalid OAuth config", "err", err)
+	}
+	mux.Handle("/auth/", accounts)
+	if len(cfg.OAuth) > 0 {
+		slog.Info("accounts enabled", "providers", accounts.Names(), "publicUrl", cfg.PublicURL)
+	}
+
+	// Stats API and dashboard
+	adminMux.HandleFunc("/stats", origins.CORS(func(w http.ResponseWriter, r *http.Request) {
+		HandleStats(game, w, r)
+	}))
+	adminMux.HandleFunc("/stats/stream", origins.CORS(func(w http.ResponseWriter, r *http.Request) {
+		HandleStatsStream(game, w, r)
+	}))
+	adminMux.HandleFunc("/stats/history", origins.CORS(func(w http.ResponseWriter, r *http.Request) {
+		HandleStatsHistory(game, w, r)
+	}))
+	adminMux.HandleFunc("/stats/achievements", origins.CORS(func(w http.ResponseWriter, r *http.Request) {
+		HandleAchievements(game, w, r)
+	}))
+	adminMux.HandleFunc("/dashboard", HandleDashboard)
+	adminMux.HandleFunc("/debug/ai", origins.CORS(func(w http.ResponseWriter, r *http.Request) {
+		HandleAIDebug(game, w, r)
+	}))
+	if game.store != nil {
+		adminMux.HandleFunc("/leaderboard", origins.CORS(func(w http.ResponseWriter, r *http.Request) {
+			HandleLeaderboard(game.store, w, r)
+		}))
+		adminMux.HandleFunc("/matches", origins.CORS(func(w http.ResponseWriter, r *http.Request) {
+			HandleMatches(game.store, w, r)
+		}))
+	}
+	if game.replays != nil {
+		replays := NewReplayStore(game.replays.dir)
+		adminMux.HandleFunc("/replays", origins.CORS(func(w http.ResponseWriter, r *http.Request) {
+			HandleReplays(replays, w, r)
+		}))
+		mux.HandleFunc("/replay/", origins.CORS(func(w http.ResponseWriter, r *http.Request) {
+			HandleReplay(replays, access, w, r)
+		}))
+	}
+
+	if cfg.AdminToken != "" {
+		adminMux.Handle("/admin/bans", adminOnly(cfg.AdminToken, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			HandleBans(game, access, w, r)
+		})))
+		adminMux.Handle("/admin/degrade", adminOnly(cfg.AdminToken, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			HandleDegrade(game, w, r)
+		})))
+		sources := startupSources(fileKeys, cmdLine)
+		configView := adminOnly(cfg.AdminToken, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			HandleConfigView(rooms, sources, w, r)
+		}))
+		adminMux.Handle("/admin/config", configView)
+		adminMux.Handle("/admin/config/diff", configView)
+		adminMux.Handle("/admin/inputs", adminOnly(cfg.AdminToken, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			HandleInputs(rooms, w, r)
+		})))
+		adminMux.Handle("/admin/rooms", adminOnly(cfg.AdminToken, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			HandleRooms(rooms, w, r)
+		})))
+	}
+	if *enablePprof {
+		registerDebugHandlers(adminMux, cfg.AdminToken)
+	}
+
+	if cfg.ClusterRedis != "" {
+		if cfg.PublicURL == "" {
+			fatal("cluster mode needs -public-url, the address other servers send players to")
+		}
+		dir, err := OpenRedisDirectory(cfg.ClusterRedis)
+		if err != nil {
+			fatal("failed to open cluster directory", "err", err)
+		}
+		id := cfg.InstanceID
+		if id == "" {
+			host, _ := os.Hostname()
+			id = fmt.Sprintf("%s-%d", host, os.Getpid())
+		}
+		cluster := NewCluster(game, dir, id, cfg.PublicURL)
+		mux.HandleFunc("/play", cluster.HandlePlay)
+		adminMux.HandleFunc("/cluster/rooms", origins.CORS(cluster.HandleRooms))
+		adminMux.HandleFunc("/cluster/stats", origins.CORS(cluster.HandleStats))
+		slog.Info("cluster mode", "directory", dir.Name(), "instance", id, "url", cfg.PublicURL)
+	}
+
+	ln, err := listen(addr)
+	if err != nil {
+		fatal("failed to listen", "addr", addr, "err", err)
+	}
+	srv := &http.Server{Handler: mux}
+	servers := []*http.Server{srv}
+	adminLn := ln
+	if *adminAddr != "" {
+		if adminLn, err = listen(*adminAddr); err != nil {
+			fatal("failed to listen", "addr", *adminAddr, "err", err)
+		}
+		adminSrv := &http.Server{Handler: adminMux}
+		servers = append(servers, adminSrv)
+		go func() {
+			if err := Serve(adminSrv, adminLn); err != nil {
+				fatal("admin http server failed", "err", err)
+			}
+		}()
+	}
+	slog.Info("listening", "http", listenURL("http", ln), "ws", listenURL("ws", ln)+"/ws",
+		"dashboard", listenURL("http", adminLn)+"/dashboard")
+	go func() {
+		if err := Serve(srv, ln); err != nil {
+			fatal("http server failed", "err", err)
+		}
+	}()
+
+	waitForShutdown(health, rooms, *drainTimeout, servers...)
+	if game.store != nil {
+		if err := game.store.Close(); err != nil {
+			slog.Error("failed to close storage", "err", err)
+		}
+	}
+	slog.Info("server stopped")
+}
+
+// readWordList reads one entry per line, skipping blank lines and # comments.
+func readWordList(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var words []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words, nil
+}
+
+// flagSet reports whether the flag was given, on the command line or in
+// the environment.
+func flagSet(name string) bool {
+	found := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
 }
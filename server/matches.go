@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Match history
+//
+// With storage enabled, every finished tournament round is recorded as a
+// match: when it started and ended, the podium, and every snake that was in
+// the round at the end with its score and kills. /matches pages through them
+// newest first, and the dashboard has a history tab on top of it.
+// ---------------------------------------------------------------------------
+
+// MatchRecord is one finished round.
+type MatchRecord struct {
+	ID           int64              `json:"id"` // assigned by the storage backend
+	Round        int                `json:"round"`
+	StartedAt    time.Time          `json:"startedAt"`
+	EndedAt      time.Time          `json:"endedAt"`
+	Winners      []string           `json:"winners"`      // podium, first place first
+	Participants []MatchParticipant `json:"participants"` // by final score
+}
+
+type MatchParticipant struct {
+	Name       string `json:"name"`
+	Identity   string `json:"identity,omitempty"` // players only (see identity.go)
+	IsAI       bool   `json:"isAI"`
+	Score      int    `json:"score"`
+	Kills      int    `json:"kills"`
+	Assists    int    `json:"assists"`    // kill assists (see assist.go)
+	BestStreak int    `json:"bestStreak"` // longest kill streak of the snake's last life (see streak.go)
+}
+
+// recordMatch stores the round that just ended. Called from endRound.
+func (g *Game) recordMatch(res RoundResults) {
+	if g.store == nil {
+		return
+	}
+	m := MatchRecord{Round: res.Round, StartedAt: g.round.startedAt, EndedAt: res.EndedAt}
+	for _, e := range res.Podium {
+		m.Winners = append(m.Winners, e.Name)
+	}
+	for _, s := range g.snakes {
+		mp := MatchParticipant{Name: s.Name, IsAI: s.IsAI, Score: s.Score, Kills: s.kills, Assists: s.assists, BestStreak: s.streak.best}
+		if p, ok := g.players[s.PlayerID]; ok && !s.IsAI {
+			mp.Identity = p.identity
+		}
+		m.Participants = append(m.Participants, mp)
+	}
+	sort.SliceStable(m.Participants, func(i, j int) bool { return m.Participants[i].Score > m.Participants[j].Score })
+	g.persist(func(st Storage) error { return st.AddMatch(m) })
+}
+
+// HandleMatches serves /matches?limit=N&before=ID: up to N matches older
+// than match ID, newest first. "next" is the before value of the next page,
+// 0 on the last one.
+func HandleMatches(st Storage, w http.ResponseWriter, r *http.Request) {
+	limit := queryLimit(r, 10)
+	before, _ := strconv.ParseInt(r.URL.Query().Get("before"), 10, 64)
+	matches, err := st.Matches(before, limit)
+	if err != nil {
+		slog.Error("failed to read match history", "err", err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	var next int64
+	if len(matches) == limit {
+		next = matches[len(matches)-1].ID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"matches": matches, "next": next})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ---------------------------------------------------------------------------
+// Mutators
+//
+// A mutator changes one rule of the game for a while:
+//
+//	lowGravity     snakes turn at half the turn speed, so curves get wide
+//	doubleFood     every pellet grows a snake twice as much
+//	tinySnakes     heads and bodies are 60% as thick, so snakes squeeze
+//	               through gaps that would otherwise be fatal
+//	infiniteBoost  the boost meter never runs out (boosting still sheds
+//	               length)
+//	fog            players see half as far: less food, fewer snakes and
+//	               shorter bodies are sent, and clients dim the world past
+//	               the fog radius
+//
+// Mutators are decorators over the rules the core simulation reads
+// (Rules): each one gets the rules the mutators before it left and changes
+// or wraps what it cares about, so any set of them composes, in the order
+// of mutatorList. The simulation never asks which mutators are on.
+//
+// Mutators lists the ones always on in a room (custom rooms and rotation
+// modes set their own), and can be changed at runtime. In tournament mode,
+// RandomMutators more are drawn at random from the rest when each round's
+// countdown starts and stay on until the next one. The active mutators and
+// the rules they result in go to clients in the init event, which is sent
+// again whenever they change.
+// ---------------------------------------------------------------------------
+
+const (
+	lowGravityTurn = 0.5 // of the turn speed
+	tinySnakeScale = 0.6 // of the head and body radius
+	fogViewScale   = 0.5 // of the view distances
+)
+
+// Rules are the values the core simulation reads that mutators may change.
+type Rules struct {
+	TurnSpeed float64               // radians per tick
+	SizeScale float64               // factor on head and body radii
+	ViewScale float64               // factor on the view distances of players, 1 without fog
+	FoodValue func(f *Food) float64 // length a snake grows by eating f
+	Boost     BoostPolicy
+}
+
+// Mutator changes the rules while it is active. Decorate runs whenever the
+// rules are rebuilt, on the game loop goroutine.
+type Mutator interface {
+	Name() string
+	Decorate(g *Game, r *Rules)
+}
+
+var mutatorList = []Mutator{lowGravity{}, doubleFood{}, tinySnakes{}, infiniteBoost{}, fogOfWar{}}
+
+func mutatorFor(name string) (Mutator, error) {
+	for _, m := range mutatorList {
+		if m.Name() == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown mutator %q (want lowGravity, doubleFood, tinySnakes, infiniteBoost or fog)", name)
+}
+
+// validateMutators checks the names of the always-on mutators.
+func validateMutators(names []string) error {
+	for i, name := range names {
+		if _, err := mutatorFor(name); err != nil {
+			return err
+		}
+		if slices.Contains(names[:i], name) {
+			return fmt.Errorf("mutator %q is listed twice", name)
+		}
+	}
+	return nil
+}
+
+type lowGravity struct{}
+
+func (lowGravity) Name() string { return "lowGravity" }
+
+func (lowGravity) Decorate(g *Game, r *Rules) { r.TurnSpeed *= lowGravityTurn }
+
+type doubleFood struct{}
+
+func (doubleFood) Name() string { return "doubleFood" }
+
+func (doubleFood) Decorate(g *Game, r *Rules) {
+	value := r.FoodValue
+	r.FoodValue = func(f *Food) float64 { return 2 * value(f) }
+}
+
+type tinySnakes struct{}
+
+func (tinySnakes) Name() string { return "tinySnakes" }
+
+func (tinySnakes) Decorate(g *Game, r *Rules) { r.SizeScale *= tinySnakeScale }
+
+type infiniteBoost struct{}
+
+func (infiniteBoost) Name() string { return "infiniteBoost" }
+
+func (infiniteBoost) Decorate(g *Game, r *Rules) { r.Boost = endlessBoost{r.Boost} }
+
+// endlessBoost is the boost model it decorates with a meter that is always
+// full.
+type endlessBoost struct{ BoostPolicy }
+
+func (endlessBoost) CanBoost(g *Game, s *Snake) bool { return true }
+
+func (b endlessBoost) Update(g *Game, s *Snake, boosting bool) {
+	b.BoostPolicy.Update(g, s, boosting)
+	s.Boost, s.boostCooldown = g.cfg.MaxBoost, 0
+}
+
+type fogOfWar struct{}
+
+func (fogOfWar) Name() string { return "fog" }
+
+func (fogOfWar) Decorate(g *Game, r *Rules) { r.ViewScale *= fogViewScale }
+
+// activeMutators returns the names of the mutators on now: the room's, then
+// the ones drawn for the round.
+func (g *Game) activeMutators() []string {
+	names := append([]string{}, g.cfg.Mutators...)
+	if g.roundsEnabled() && g.cfg.RandomMutators > 0 {
+		for _, name := range g.roundMutators {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// applyMutators rebuilds g.rules from the config and the active mutators.
+// Called at startup and whenever the config or the round's mutators change.
+func (g *Game) applyMutators() {
+	r := Rules{
+		TurnSpeed: g.cfg.TurnSpeed, SizeScale: 1, ViewScale: 1,
+		FoodValue: func(f *Food) float64 { return f.Value },
+		Boost:     g.boost,
+	}
+	active := g.activeMutators()
+	for _, m := range mutatorList {
+		if slices.Contains(active, m.Name()) {
+			m.Decorate(g, &r)
+		}
+	}
+	g.rules = r
+}
+
+// drawRoundMutators draws RandomMutators mutators for the next round from
+// the ones that aren't always on. Called when a round's countdown starts.
+func (g *Game) drawRoundMutators() {
+	prev := g.roundMutators
+	g.roundMutators = nil
+	if g.cfg.RandomMutators > 0 {
+		var pool []string
+		for _, m := range mutatorList {
+			if !slices.Contains(g.cfg.Mutators, m.Name()) {
+				pool = append(pool, m.Name())
+			}
+		}
+		for _, i := range g.rng.Perm(len(pool))[:min(g.cfg.RandomMutators, len(pool))] {
+			g.roundMutators = append(g.roundMutators, pool[i])
+		}
+		g.log.Info("round mutators drawn", "round", g.round.round, "mutators", g.roundMutators)
+	}
+	if len(prev) > 0 || len(g.roundMutators) > 0 {
+		g.applyMutators()
+		g.announceInit()
+	}
+}
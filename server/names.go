@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// ---------------------------------------------------------------------------
+// Name policy (sanitizing, blocklist, reserved names, deduplication)
+//
+// Names may use any script. They are limited by display width rather than
+// bytes or runes: East Asian wide and fullwidth characters (CJK, most emoji)
+// take two cells, combining marks none, everything else one, so a Japanese
+// name and a Latin one end up about as long on screen. Names are NFKC
+// normalized first, which turns "fancy font" spam (fullwidth letters,
+// mathematical bold and script, circled letters) back into plain text.
+// Blocklist, reserved name and duplicate checks additionally fold Cyrillic
+// and Greek look-alikes to Latin, so "Аdmin" with a Cyrillic A is still Admin.
+// ---------------------------------------------------------------------------
+
+const (
+	DefaultNameWidth = 15  // display cells per name
+	MaxNameWidth     = 32  // upper bound for GameConfig.NameWidth
+	maxCombiningRun  = 2   // combining marks kept per base character
+	maxWireName      = 255 // bytes of a name in v1 frames (uint8 length)
+	defaultName      = "Player"
+)
+
+// oversizedGlyphs are single code points that render many times wider/taller
+// than a normal character and are used to flood name tags.
+var oversizedGlyphs = map[rune]bool{
+	'﷽':          true, // ARABIC LIGATURE BISMILLAH
+	'꧅':          true, // JAVANESE PADA LUHUR
+	'\U00012219': true, // CUNEIFORM SIGN LUGAL OPPOSING LUGAL
+	'\U0001242B': true, // CUNEIFORM NUMERIC SIGN NINE SHAR2
+	'௵':          true, // TAMIL YEAR SIGN
+}
+
+// leetFold maps common digit/symbol substitutions back to letters so the
+// blocklist can't be bypassed with "b4dw0rd".
+var leetFold = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's',
+	'7': 't', '8': 'b', '@': 'a', '$': 's', '!': 'i',
+}
+
+// homoglyphFold maps lowercase Cyrillic and Greek letters that look like
+// Latin ones to those, for name comparisons only; display names keep them.
+var homoglyphFold = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i', 'ї': 'i',
+	'ј': 'j', 'ԁ': 'd', 'һ': 'h', 'ԛ': 'q', 'ԝ': 'w',
+	'α': 'a', 'β': 'b', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+	'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	'ı': 'i', 'ɡ': 'g', 'ʏ': 'y',
+}
+
+type NamePolicy struct {
+	blocked    []string
+	reserved   map[string]bool
+	adminToken string
+	width      int // display cells per name
+}
+
+func NewNamePolicy(cfg GameConfig) *NamePolicy {
+	np := &NamePolicy{
+		reserved:   make(map[string]bool, len(cfg.ReservedNames)),
+		adminToken: cfg.AdminToken,
+		width:      cfg.NameWidth,
+	}
+	if np.width <= 0 {
+		np.width = DefaultNameWidth
+	}
+	for _, w := range cfg.NameBlocklist {
+		if k := matchKey(w); k != "" {
+			np.blocked = append(np.blocked, k)
+		}
+	}
+	for _, n := range cfg.ReservedNames {
+		if k := matchKey(n); k != "" {
+			np.reserved[k] = true
+		}
+	}
+	return np
+}
+
+// Resolve turns a client-supplied name into a display name. Names that
+// sanitize to nothing, hit the blocklist, or claim a reserved name without
+// the admin token fall back to the default name.
+func (np *NamePolicy) Resolve(raw, token string) (string, error) {
+	name := sanitizeName(raw, np.width)
+	if name == "" {
+		return defaultName, nil
+	}
+	if np.Blocked(name) {
+		return defaultName, fmt.Errorf("name %q matches blocklist", name)
+	}
+	if np.reserved[matchKey(name)] && !validAdminToken(np.adminToken, token) {
+		return defaultName, fmt.Errorf("name %q is reserved", name)
+	}
+	return name, nil
+}
+
+// Blocked reports whether text contains a blocklisted word.
+func (np *NamePolicy) Blocked(text string) bool {
+	key := matchKey(text)
+	for _, w := range np.blocked {
+		if strings.Contains(key, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeName NFKC-normalizes raw, strips control, format and private-use
+// characters, caps runs of combining marks, collapses whitespace and
+// truncates to maxWidth display cells without splitting a rune.
+func sanitizeName(raw string, maxWidth int) string {
+	return sanitize(norm.NFKC.String(raw), maxWidth, runeWidth)
+}
+
+// sanitizeText cleans up chat messages like sanitizeName, without the
+// normalization and with a limit of maxRunes.
+func sanitizeText(raw string, maxRunes int) string {
+	return sanitize(raw, maxRunes, func(rune) int { return 1 })
+}
+
+// runeWidth returns the display cells of a printable, non-combining rune.
+func runeWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// sanitize does the work of sanitizeName and sanitizeText: limit is in
+// cells as counted by cells, spaces count one.
+func sanitize(raw string, limit int, cells func(rune) int) string {
+	var b strings.Builder
+	used := 0
+	marks := 0
+	pendingSpace := false
+
+	for _, r := range raw {
+		switch {
+		case r == utf8.RuneError:
+			continue
+		case unicode.IsSpace(r):
+			pendingSpace = used > 0
+			continue
+		case unicode.IsControl(r), unicode.In(r, unicode.Cf, unicode.Co, unicode.Cs):
+			continue
+		case oversizedGlyphs[r]:
+			continue
+		case unicode.In(r, unicode.Mn, unicode.Me):
+			if used == 0 || pendingSpace || marks >= maxCombiningRun {
+				continue
+			}
+			marks++
+			b.WriteRune(r)
+			continue
+		}
+
+		need := cells(r)
+		if pendingSpace {
+			need++
+		}
+		if used+need > limit {
+			break
+		}
+		if pendingSpace {
+			b.WriteByte(' ')
+			pendingSpace = false
+		}
+		b.WriteRune(r)
+		used += need
+		marks = 0
+	}
+	return b.String()
+}
+
+// truncateWidth cuts a sanitized name to at most n display cells, keeping
+// combining marks with their base character.
+func truncateWidth(s string, n int) string {
+	used := 0
+	for pos, r := range s {
+		if unicode.In(r, unicode.Mn, unicode.Me) {
+			continue
+		}
+		used += runeWidth(r)
+		if used > n {
+			return s[:pos]
+		}
+	}
+	return s
+}
+
+// wireName cuts name to the maxWireName bytes a v1 nameLen can describe,
+// without splitting a rune. Sanitized names only get there with a wide
+// NameWidth and many combining marks.
+func wireName(name string) string {
+	if len(name) <= maxWireName {
+		return name
+	}
+	i := maxWireName
+	for i > 0 && !utf8.RuneStart(name[i]) {
+		i--
+	}
+	return name[:i]
+}
+
+// foldHomoglyphs lowercases s and replaces look-alike letters from other
+// scripts with their Latin counterparts.
+func foldHomoglyphs(s string) string {
+	return strings.Map(func(r rune) rune {
+		if f, ok := homoglyphFold[r]; ok {
+			return f
+		}
+		return r
+	}, strings.ToLower(norm.NFKC.String(s)))
+}
+
+// matchKey normalizes a name for blocklist/reserved comparison: lowercase,
+// homoglyphs and leetspeak folded, everything but letters removed.
+func matchKey(s string) string {
+	var b strings.Builder
+	for _, r := range foldHomoglyphs(s) {
+		if f, ok := leetFold[r]; ok {
+			r = f
+		}
+		if unicode.IsLetter(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// uniqueName appends a numeric suffix if another snake already uses name
+// (ignoring case and homoglyphs). Called from the game loop only.
+func (g *Game) uniqueName(name string) string {
+	return g.uniqueNameExcept(name, nil)
+}
+
+// uniqueNameExcept is uniqueName ignoring self's current name (for renames).
+func (g *Game) uniqueNameExcept(name string, self *Snake) string {
+	taken := make(map[string]bool, len(g.snakes))
+	for _, s := range g.snakes {
+		if s != self {
+			taken[foldHomoglyphs(s.Name)] = true
+		}
+	}
+	if !taken[foldHomoglyphs(name)] {
+		return name
+	}
+	for n := 2; ; n++ {
+		suffix := fmt.Sprintf(" %d", n)
+		cand := strings.TrimRight(truncateWidth(name, g.names.width-len(suffix)), " ") + suffix
+		if !taken[foldHomoglyphs(cand)] {
+			return cand
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestNamePolicyResolve(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AdminToken = "secret"
+	cfg.NameBlocklist = []string{"badword"}
+	np := NewNamePolicy(cfg)
+
+	tests := []struct {
+		name, raw, token string
+		want             string
+		err              bool
+	}{
+		{name: "plain", raw: "Alice", want: "Alice"},
+		{name: "spaces collapsed", raw: "  Bob \t the  Snake ", want: "Bob the Snake"},
+		{name: "fullwidth folded", raw: "Ｃａｒｏｌ", want: "Carol"},
+		{name: "empty", raw: "", want: defaultName},
+		{name: "only controls", raw: "\x00\u200b\x7f", want: defaultName},
+		{name: "too long", raw: "abcdefghijklmnopqrstuvwxyz", want: "abcdefghijklmno"},
+		{name: "too long at a space", raw: "abcdefghijklmn opq", want: "abcdefghijklmn"},
+		{name: "too long in wide characters", raw: "日本語の名前はとても長い", want: "日本語の名前は"},
+		{name: "blocked", raw: "my b4dw0rd", want: defaultName, err: true},
+		{name: "reserved", raw: "Admin", want: defaultName, err: true},
+		{name: "reserved look-alike", raw: "Аdmin", want: defaultName, err: true}, // Cyrillic A
+		{name: "reserved, wrong token", raw: "Admin", token: "guess", want: defaultName, err: true},
+		{name: "reserved, admin token", raw: "Admin", token: "secret", want: "Admin"},
+		{name: "reserved look-alike, admin token", raw: "Mоderator", token: "secret", want: "Mоderator"}, // Cyrillic o
+		{name: "blocked, admin token", raw: "badword", token: "secret", want: defaultName, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := np.Resolve(tt.raw, tt.token)
+			if got != tt.want || (err != nil) != tt.err {
+				t.Errorf("Resolve(%q, %q) = %q, %v; want %q, error %v", tt.raw, tt.token, got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
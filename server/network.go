@@ -3,14 +3,18 @@ package main
 import (
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"net/http"
+	"net/netip"
 	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"snake-server/wire"
 )
 
 // ---------------------------------------------------------------------------
@@ -20,11 +24,40 @@ import (
 type Player struct {
 	id          int
 	name        string
+	addr        netip.Addr // client IP, for bans and the roster
 	conn        *websocket.Conn
 	snake       *Snake
 	sendCh      chan []byte
+	textCh      chan []byte // JSON events (e.g. round results)
 	done        chan struct{}
-	knownSnakes map[int]bool // snake IDs whose metadata has been sent
+	knownSnakes map[uint32]bool // entity IDs of snakes whose metadata has been sent
+	knownFood   map[uint32]bool // food IDs the client holds (delta formats)
+	rttMs       atomic.Int64    // smoothed round-trip time, written by readPump
+	serializer  Serializer      // wire format, set by the first join and fixed after it
+	names       *nameTable      // v2 name string table
+	camera      *Snake          // killer being watched while dead (see spectate.go)
+	cameraID    uint32
+	respawnAt   int  // game clock before which a respawn is refused (see respawn.go)
+	beginner    bool // first session under this name (see spawnpolicy.go)
+	director    bool // observer without a snake, sees the whole world (see director.go)
+	nextHeatmap int  // net tick the next heatmap is due (see heatmap.go)
+
+	inputSeq    uint16 // last input sequence number applied (see prediction.go)
+	hasInputSeq bool
+
+	wideScores    bool   // v1 frames with uint32 scores, asked for at join
+	locale        string // announcement language from the join message (see announce.go)
+	identity      string // stable player identity, set at join (see identity.go)
+	identityToken string // refreshed token sent back to the client
+
+	inputs *inputLog // recent raw inputs for cheat review, nil = off (see inputlog.go)
+
+	sizeTicket string // size class ticket from the join message (see sizeclass.go)
+
+	// Congestion control (game loop only, see adaptRate)
+	sendEvery  int  // send state every Nth net tick (power of two)
+	calmSends  int  // consecutive send slots that found sendCh empty
+	foodResync bool // food section frames were dropped; send a keyframe next
 }
 
 var playerIDCounter int64
@@ -33,6 +66,7 @@ func nextPlayerID() int {
 	return int(atomic.AddInt64(&playerIDCounter, 1))
 }
 
+// upgrader's CheckOrigin is set from the origin policy at startup.
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 4096,
@@ -43,48 +77,77 @@ var upgrader = websocket.Upgrader{
 // WebSocket handler
 // ---------------------------------------------------------------------------
 
-func HandleWS(game *Game, w http.ResponseWriter, r *http.Request) {
-	log.Printf("[WS] HTTP upgrade request from %s", r.RemoteAddr)
+func HandleWS(game *Game, ac *AccessControl, w http.ResponseWriter, r *http.Request) {
+	game.log.Debug("websocket upgrade request", "remote", r.RemoteAddr)
+	addr := clientAddr(r)
+	release, err := ac.Admit(addr)
+	if err != nil {
+		game.log.Warn("connection rejected", "remote", r.RemoteAddr, "err", err)
+		status := http.StatusTooManyRequests
+		if errors.Is(err, errBanned) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer release()
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		game.log.Warn("websocket upgrade failed", "remote", r.RemoteAddr, "err", err)
 		return
 	}
-	log.Printf("[WS] Upgrade complete for %s", r.RemoteAddr)
 
+	game.Wake()
 	id := nextPlayerID()
 	p := &Player{
 		id:          id,
 		name:        fmt.Sprintf("Player %d", id),
+		addr:        addr,
 		conn:        conn,
-		sendCh:      make(chan []byte, 8),
+		sendCh:      make(chan []byte, SendBufferSize),
+		textCh:      make(chan []byte, 4),
 		done:        make(chan struct{}),
-		knownSnakes: make(map[int]bool),
+		knownSnakes: make(map[uint32]bool),
+		sendEvery:   1,
+		serializer:  serializerV1,
+		names:       newNameTable(),
 	}
 
 	// Send welcome (JSON, includes world size)
-	welcome := fmt.Sprintf(`{"t":"welcome","pid":%d,"ws":%d,"v":"%s"}`, id, game.cfg.WorldSize, Version)
+	challenges, _ := json.Marshal(CurrentChallenges(time.Now()))
+	welcome := fmt.Sprintf(`{"t":"welcome","pid":%d,"ws":%d,"v":"%s","pv":%d,"tr":%d,"nr":%d,"challenges":%s}`,
+		id, game.cfg.WorldSize, Version, MaxProtocol, TickRate, TickRate/NetTickRate, challenges)
 	conn.WriteMessage(websocket.TextMessage, []byte(welcome))
-	log.Printf("[WS] Welcome sent to player %d (%s)", id, r.RemoteAddr)
+	game.log.Debug("welcome sent", "playerID", id, "remote", r.RemoteAddr)
 
 	// Start writer
 	go p.writePump()
 
 	// Reader blocks here until disconnect
+	game.openInputLog(p, game.cfg.InputLogMinutes)
 	p.readPump(game)
 
 	// Cleanup
+	game.closeInputLog(p)
 	close(p.done)
-	game.leaveCh <- id
+	tell(game, game.leaveCh, id)
 	conn.Close()
-	log.Printf("Player %d (%s) disconnected", id, p.name)
+	game.log.Debug("player disconnected", "playerID", id, "remote", r.RemoteAddr)
 }
 
 // ---------------------------------------------------------------------------
 // Read pump - one goroutine per player, reads client messages
 // ---------------------------------------------------------------------------
 
+// CustomizeInterval is the minimum time between appearance changes.
+const CustomizeInterval = 5 * time.Second
+
 func (p *Player) readPump(game *Game) {
+	var token string // join token, reused to authorize later renames
+	var lastCustomize, lastChat time.Time
+	var joined, directing bool // a connection is either a player or a director
+
 	p.conn.SetReadLimit(512)
 	p.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	p.conn.SetPongHandler(func(string) error {
@@ -103,30 +166,122 @@ func (p *Player) readPump(game *Game) {
 		p.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 
 		if msgType == websocket.TextMessage {
-			var msg map[string]interface{}
-			if err := json.Unmarshal(data, &msg); err != nil {
+			msg, err := wire.DecodeText(data)
+			if err != nil {
 				continue
 			}
-			switch msg["t"] {
+			switch msg.Type {
 			case "join":
-				name, _ := msg["name"].(string)
-				if name == "" {
-					name = "Player"
+				// Only the first join sets up the player: after it the
+				// game loop reads these fields.
+				if joined || directing {
+					continue
+				}
+				joined = true
+				token = msg.Token
+				resolved, err := game.names.Resolve(msg.Name, token)
+				if err != nil {
+					game.log.Warn("name rejected", "playerID", p.id, "err", err)
+				}
+				p.name = resolved
+				p.identity, p.identityToken = game.identities.Resolve(msg.Identity, time.Now())
+				p.wideScores = msg.WideScores
+				p.locale = normalizeLocale(msg.Locale)
+				p.sizeTicket = msg.Ticket
+				if p.inputs != nil {
+					p.inputs.identify(p.name, p.identity)
+				}
+				if ser, ok := serializerFor(msg.Proto); ok {
+					p.serializer = ser
+				}
+				if !tell(game, game.joinCh, p) {
+					return
+				}
+			case "director":
+				if joined || directing {
+					continue
 				}
-				if len(name) > 15 {
-					name = name[:15]
+				token = msg.Token
+				if !validAdminToken(game.names.adminToken, token) {
+					game.log.Warn("director rejected", "playerID", p.id, "remote", p.addr)
+					return
+				}
+				if ser, ok := serializerFor(msg.Proto); ok {
+					p.serializer = ser
+				}
+				directing = true
+				if !tell(game, game.directorCh, p) {
+					return
 				}
-				p.name = name
-				game.joinCh <- p
-				log.Printf("Player %d joined as '%s'", p.id, p.name)
 			case "respawn":
-				game.respawnCh <- p.id
+				if !tell(game, game.respawnCh, p.id) {
+					return
+				}
+			case "customize":
+				if time.Since(lastCustomize) < CustomizeInterval {
+					game.log.Debug("customize rate limited", "playerID", p.id)
+					continue
+				}
+				if msg.Color >= NumColors || msg.Skin >= len(skins) {
+					continue
+				}
+				req := CustomizeMsg{PlayerID: p.id, ColorIdx: msg.Color, Skin: msg.Skin}
+				if msg.HasName {
+					name, err := game.names.Resolve(msg.Name, token)
+					if err != nil {
+						game.log.Warn("name change rejected", "playerID", p.id, "err", err)
+						continue
+					}
+					req.Name = name
+				}
+				if req.Name == "" && req.ColorIdx < 0 && req.Skin < 0 {
+					continue
+				}
+				lastCustomize = time.Now()
+				if !tell(game, game.customizeCh, req) {
+					return
+				}
+			case "chat":
+				if time.Since(lastChat) < ChatInterval {
+					game.log.Debug("chat rate limited", "playerID", p.id)
+					continue
+				}
+				text := sanitizeText(msg.Text, MaxChatRunes)
+				if text == "" {
+					continue
+				}
+				if game.names.Blocked(text) {
+					game.log.Warn("chat message blocked", "playerID", p.id)
+					continue
+				}
+				if msg.Channel != "" && msg.Channel != "all" && msg.Channel != chatNear {
+					continue
+				}
+				lastChat = time.Now()
+				if !tell(game, game.chatCh, ChatMsg{PlayerID: p.id, Text: text, Near: msg.Channel == chatNear}) {
+					return
+				}
+			case "vote":
+				if msg.Choice >= 0 {
+					if !tell(game, game.voteCh, VoteMsg{PlayerID: p.id, Choice: msg.Choice}) {
+						return
+					}
+				}
+			}
+		} else if msgType == websocket.BinaryMessage && len(data) > 0 {
+			switch data[0] {
+			case wire.TypeInput:
+				if in, err := wire.DecodeInput(data); err == nil {
+					game.recordInput(p, in)
+					if !tell(game, game.inputCh, InputMsg{PlayerID: p.id, Angle: in.Angle, Boost: in.Boost, Seq: in.Seq, HasSeq: in.HasSeq}) {
+						return
+					}
+				}
+			case wire.TypePong:
+				if sentMs, err := wire.DecodePong(data); err == nil {
+					p.handlePong(sentMs)
+				}
 			}
-		} else if msgType == websocket.BinaryMessage && len(data) == 4 && data[0] == 2 {
-			// Input: type(1) + angle_int16(2) + boost(1)
-			angle := float64(int16(binary.BigEndian.Uint16(data[1:3]))) / 10000.0
-			boost := data[3]&1 != 0
-			game.inputCh <- InputMsg{PlayerID: p.id, Angle: angle, Boost: boost}
 		}
 	}
 }
@@ -138,6 +293,8 @@ func (p *Player) readPump(game *Game) {
 func (p *Player) writePump() {
 	pingTicker := time.NewTicker(30 * time.Second)
 	defer pingTicker.Stop()
+	rttTicker := time.NewTicker(PingInterval)
+	defer rttTicker.Stop()
 
 	for {
 		select {
@@ -149,11 +306,21 @@ func (p *Player) writePump() {
 			if err := p.conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
 				return
 			}
+		case msg := <-p.textCh:
+			p.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			if err := p.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
 		case <-pingTicker.C:
 			p.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
 			if err := p.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+		case <-rttTicker.C:
+			p.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			if err := p.conn.WriteMessage(websocket.BinaryMessage, p.pingFrame()); err != nil {
+				return
+			}
 		case <-p.done:
 			return
 		}
@@ -161,34 +328,78 @@ func (p *Player) writePump() {
 }
 
 // ---------------------------------------------------------------------------
-// State serialization (binary protocol - must match client exactly)
+// State serialization (binary protocol v1 - must match client exactly;
+// see protocol_v2.go for the compact format)
 //
 // Header: type(1)=1, flags(1), snakeCount(uint16 BE)
-//   flags: bit0=hasFood, bit1=hasSummary
+//   flags: bit0=hasFood, bit1=hasSummary, bit2=hasTrails, bit3=hasRound,
+//          bit4=hasHeatmap, bit5=wideScores
 // Per snake:
 //   playerId(int16 BE),
-//   flags(uint8: bit0=alive, bit1=boosting, bit2=isPlayer, bit3=hasMeta),
+//   flags(uint8: bit0=alive, bit1=boosting, bit2=isPlayer, bit3=hasMeta, bit4=golden),
 //   [if hasMeta: nameLen(uint8), name[nameLen], colorIdx(uint8)],
 //   score(uint16 BE), angle*10000(int16 BE), boost(uint8),
 //   targetLen(uint16 BE), invTimer(uint8),
-//   segCount(uint16 BE), segments[segCount * 4](uint16 x + uint16 y, BE) — every 3rd segment
+//   (score and targetLen are uint32 BE with wideScores)
+//   segCount(uint16 BE), segments[segCount * 4](uint16 x + uint16 y, BE) — every segmentStride()-th segment (see growth.go)
 // If hasFood:
 //   foodCount(uint16 BE)
 //   Per food(7 bytes): x(uint16), y(uint16), colorIdx(uint8),
 //                      radius*10(uint8), value*10(uint8)
+// If hasTrails (laser tail mode):
+//   trailCount(uint16 BE)
+//   Per trail point(6 bytes): x(uint16), y(uint16), colorIdx(uint8),
+//                             life(uint8, 255=fresh → 0=expired)
 // If hasSummary (appended by broadcast):
 //   summaryCount(uint16 BE)
 //   Per alive snake: playerId(int16), headX(uint16), headY(uint16),
-//                    score(uint16), colorIdx(uint8), nameLen(uint8), name[nameLen]
+//                    score(uint16, uint32 with wideScores), colorIdx(uint8),
+//                    nameLen(uint8), name[nameLen]
+// If hasRound (tournament mode, appended by broadcast):
+//   phase(uint8: 0=countdown, 1=playing, 2=results), round(uint16 BE),
+//   remainingSec(uint16 BE)
+// If hasHeatmap (with the summary, last in the frame):
+//   size(uint8), size*size cells(uint8: snake mass << 4 | food mass),
+//   row-major from the top-left corner (see heatmap.go)
+//
+// Names are UTF-8 and nameLen counts bytes; the rare name over 255 bytes is
+// cut at a rune boundary (see wireName).
+//
+// wideScores is set for clients that join with "wideScores": true. Older
+// v1 clients get scores and target lengths capped at 65535, which long
+// sessions in food-rich worlds exceed; v2 and later send varints and
+// protobuf int32, so they never cap.
 // ---------------------------------------------------------------------------
 
-func (g *Game) serializeStateFor(p *Player, includeFood bool) []byte {
+// viewSet is what one player can see this frame.
+type viewSet struct {
+	snakes        []*Snake
+	hasMeta       []bool
+	foods         []*Food
+	trails        []*Trail
+	includeTrails bool
+	cx, cy        float64 // camera position the view is centred on
+	scale         float64 // recommended camera zoom (see zoom.go)
+	view          float64 // view distance factor, below 1 in fog (see mutators.go)
+	full          bool    // whole world, no segment culling (directors)
+	predators     []*Predator
+}
+
+// visibleFor selects the viewport-filtered snakes, food and trails for p, or
+// everything for a director, and updates its metadata cache.
+func (g *Game) visibleFor(p *Player, includeFood bool) viewSet {
 	// Determine visible snakes (viewport filtered)
 	var visible []*Snake
 	var cx, cy float64
-	if p.snake != nil && len(p.snake.Segments) > 0 {
-		cx = p.snake.Segments[0].X
-		cy = p.snake.Segments[0].Y
+	scale := p.cameraScaleFor()
+	view := 1.0
+	if !p.director {
+		view = g.rules.ViewScale
+	}
+	snakeDist := (ViewDist + 1000) * view
+	if c := p.cameraTarget(); c != nil && len(c.Segments) > 0 {
+		cx = c.Segments[0].X
+		cy = c.Segments[0].Y
 	} else {
 		cx = float64(g.cfg.WorldSize) / 2
 		cy = float64(g.cfg.WorldSize) / 2
@@ -198,63 +409,144 @@ func (g *Game) serializeStateFor(p *Player, includeFood bool) []byte {
 	if p.snake != nil {
 		visible = append(visible, p.snake)
 	}
-	for _, s := range g.snakes {
-		if s == p.snake {
-			continue
+	if p.director {
+		for _, s := range g.snakes {
+			if s.Alive && len(s.Segments) > 0 {
+				visible = append(visible, s)
+			}
 		}
-		if !s.Alive || len(s.Segments) == 0 {
-			continue
+	} else if g.shards != nil {
+		// Stitched from the shard cells around the camera
+		for _, s := range g.shards.visibleSnakes(cx, cy, snakeDist) {
+			if s != p.snake {
+				visible = append(visible, s)
+			}
 		}
-		sh := s.Segments[0]
-		dx := math.Abs(sh.X - cx)
-		dy := math.Abs(sh.Y - cy)
-		if dx < ViewDist+1000 && dy < ViewDist+1000 {
-			visible = append(visible, s)
+		visible = append(visible, g.passingSnakes(p.snake, cx, cy, snakeDist)...)
+	} else {
+		for _, s := range g.snakes {
+			if s == p.snake {
+				continue
+			}
+			if !s.Alive || len(s.Segments) == 0 {
+				continue
+			}
+			// By the head, or a body coming out of a portal (see portal.go)
+			for _, a := range bodyAnchors(s) {
+				if math.Abs(a.X-cx) < snakeDist && math.Abs(a.Y-cy) < snakeDist {
+					visible = append(visible, s)
+					break
+				}
+			}
 		}
 	}
 
 	// Build hasMeta flags: true for snakes whose metadata hasn't been sent yet
 	if p.knownSnakes == nil {
-		p.knownSnakes = make(map[int]bool)
+		p.knownSnakes = make(map[uint32]bool)
 	}
 	hasMeta := make([]bool, len(visible))
-	newKnown := make(map[int]bool, len(visible))
+	newKnown := make(map[uint32]bool, len(visible))
 	for i, s := range visible {
-		if !p.knownSnakes[s.PlayerID] {
+		if !p.knownSnakes[s.id] {
 			hasMeta[i] = true
 		}
-		newKnown[s.PlayerID] = true
+		newKnown[s.id] = true
 	}
 	p.knownSnakes = newKnown
 
 	// Determine visible food
 	var visibleFood []*Food
 	if includeFood {
+		foodDist := FoodViewDist * view / scale
 		for _, f := range g.foods {
-			if math.Abs(f.X-cx) < FoodViewDist && math.Abs(f.Y-cy) < FoodViewDist {
+			if p.director || math.Abs(f.X-cx) < foodDist && math.Abs(f.Y-cy) < foodDist {
 				visibleFood = append(visibleFood, f)
 			}
 		}
 	}
 
-	return serializeState(visible, hasMeta, visibleFood, includeFood)
+	// Determine visible trail points
+	includeTrails := g.cfg.LaserTail
+	var visibleTrails []*Trail
+	if includeTrails {
+		for _, t := range g.trails {
+			if p.director || math.Abs(t.X-cx) < ViewDist*view && math.Abs(t.Y-cy) < ViewDist*view {
+				visibleTrails = append(visibleTrails, t)
+			}
+		}
+	}
+
+	return viewSet{
+		snakes: visible, hasMeta: hasMeta, foods: visibleFood,
+		trails: visibleTrails, includeTrails: includeTrails,
+		cx: cx, cy: cy, scale: scale, view: view, full: p.director,
+		predators: g.visiblePredators(cx, cy, scale, p.director),
+	}
+}
+
+func (g *Game) serializeStateFor(p *Player, includeFood bool) []byte {
+	v := g.visibleFor(p, includeFood)
+	return serializeState(v.snakes, v.hasMeta, v.foods, includeFood, v.trails, v.includeTrails, g.cfg.TrailLifetime,
+		g.segmentStride(), p.wideScores)
+}
+
+// initialStateFor builds the full state sent right after join, in the
+// player's wire format, and any section frames it was split into.
+func (g *Game) initialStateFor(p *Player) ([]byte, []sectionFrame) {
+	return g.encodeState(p, true, false, &frameShared{tick: g.frame, sentMs: serverMillis()})
+}
+
+// snakeFlags returns the per-snake flag bits shared by all protocol versions
+// (hasMeta is added by the encoder).
+func snakeFlags(s *Snake) byte {
+	var flags byte
+	if s.Alive {
+		flags |= 1
+	}
+	if s.IsBoosting {
+		flags |= 2
+	}
+	if !s.IsAI {
+		flags |= 4
+	}
+	if s.golden {
+		flags |= 16
+	}
+	return flags
+}
+
+// wireID is s's ID in frames of the given protocol version: the entity ID
+// from v6 on, the player ID before.
+func wireID(s *Snake, version int) int64 {
+	if version >= ProtocolV6 {
+		return int64(s.id)
+	}
+	return int64(s.PlayerID)
 }
 
-func serializeState(snakes []*Snake, hasMeta []bool, foods []*Food, includeFood bool) []byte {
+func serializeState(snakes []*Snake, hasMeta []bool, foods []*Food, includeFood bool,
+	trails []*Trail, includeTrails bool, trailLifetime, stride int, wide bool) []byte {
 	// Calculate buffer size
 	size := 4 // header
 	for i, s := range snakes {
-		segCount := (len(s.Segments) + 2) / 3 // ceil(n/3)
+		segCount := (len(s.Segments) + stride - 1) / stride // ceil(n/stride)
 		// playerId(2) + flags(1) + score(2) + angle(2) + boost(1) + targetLen(2) + invTimer(1) + segCount(2) + segs
 		perSnake := 2 + 1 + 2 + 2 + 1 + 2 + 1 + 2 + segCount*4
+		if wide {
+			perSnake += 4 // score and targetLen take 4 bytes each
+		}
 		if hasMeta == nil || hasMeta[i] {
-			perSnake += 1 + len(s.Name) + 1 // nameLen + name + colorIdx
+			perSnake += 1 + len(wireName(s.Name)) + 1 // nameLen + name + colorIdx
 		}
 		size += perSnake
 	}
 	if includeFood {
 		size += 2 + len(foods)*7
 	}
+	if includeTrails {
+		size += 2 + len(trails)*6
+	}
 
 	buf := make([]byte, size)
 	o := 0
@@ -263,7 +555,13 @@ func serializeState(snakes []*Snake, hasMeta []bool, foods []*Food, includeFood
 	buf[o] = 1 // type = state
 	o++
 	if includeFood {
-		buf[o] = 1
+		buf[o] |= 1
+	}
+	if includeTrails {
+		buf[o] |= 4
+	}
+	if wide {
+		buf[o] |= 32
 	}
 	o++
 	binary.BigEndian.PutUint16(buf[o:], uint16(len(snakes)))
@@ -276,16 +574,7 @@ func serializeState(snakes []*Snake, hasMeta []bool, foods []*Food, includeFood
 		o += 2
 
 		// Flags with hasMeta bit
-		var flags byte
-		if s.Alive {
-			flags |= 1
-		}
-		if s.IsBoosting {
-			flags |= 2
-		}
-		if !s.IsAI {
-			flags |= 4
-		}
+		flags := snakeFlags(s)
 		meta := hasMeta == nil || hasMeta[i]
 		if meta {
 			flags |= 8
@@ -295,7 +584,7 @@ func serializeState(snakes []*Snake, hasMeta []bool, foods []*Food, includeFood
 
 		// Conditional metadata
 		if meta {
-			nameBytes := []byte(s.Name)
+			nameBytes := []byte(wireName(s.Name))
 			buf[o] = byte(len(nameBytes))
 			o++
 			copy(buf[o:], nameBytes)
@@ -305,12 +594,7 @@ func serializeState(snakes []*Snake, hasMeta []bool, foods []*Food, includeFood
 			o++
 		}
 
-		score := s.Score
-		if score > 65535 {
-			score = 65535
-		}
-		binary.BigEndian.PutUint16(buf[o:], uint16(score))
-		o += 2
+		o = putScore(buf, o, s.Score, wide)
 
 		// Angle normalized to [-PI, PI]
 		a := s.Angle
@@ -333,12 +617,7 @@ func serializeState(snakes []*Snake, hasMeta []bool, foods []*Food, includeFood
 		buf[o] = byte(boost)
 		o++
 
-		tl := s.TargetLen
-		if tl > 65535 {
-			tl = 65535
-		}
-		binary.BigEndian.PutUint16(buf[o:], uint16(tl))
-		o += 2
+		o = putScore(buf, o, s.TargetLen, wide)
 
 		inv := s.InvTimer
 		if inv > 255 {
@@ -347,11 +626,11 @@ func serializeState(snakes []*Snake, hasMeta []bool, foods []*Food, includeFood
 		buf[o] = byte(inv)
 		o++
 
-		// Segments (every 3rd)
-		segCount := (len(s.Segments) + 2) / 3
+		// Segments (every stride-th)
+		segCount := (len(s.Segments) + stride - 1) / stride
 		binary.BigEndian.PutUint16(buf[o:], uint16(segCount))
 		o += 2
-		for j := 0; j < len(s.Segments); j += 3 {
+		for j := 0; j < len(s.Segments); j += stride {
 			x := int(math.Round(s.Segments[j].X))
 			y := int(math.Round(s.Segments[j].Y))
 			if x < 0 {
@@ -413,6 +692,43 @@ func serializeState(snakes []*Snake, hasMeta []bool, foods []*Food, includeFood
 		}
 	}
 
+	// Trails
+	if includeTrails {
+		binary.BigEndian.PutUint16(buf[o:], uint16(len(trails)))
+		o += 2
+		for _, t := range trails {
+			x := int(math.Round(t.X))
+			y := int(math.Round(t.Y))
+			if x < 0 {
+				x = 0
+			}
+			if x > 65535 {
+				x = 65535
+			}
+			if y < 0 {
+				y = 0
+			}
+			if y > 65535 {
+				y = 65535
+			}
+			binary.BigEndian.PutUint16(buf[o:], uint16(x))
+			o += 2
+			binary.BigEndian.PutUint16(buf[o:], uint16(y))
+			o += 2
+			buf[o] = byte(t.ColorIdx)
+			o++
+			life := 255
+			if trailLifetime > 0 {
+				life = t.TTL * 255 / trailLifetime
+			}
+			if life > 255 {
+				life = 255
+			}
+			buf[o] = byte(life)
+			o++
+		}
+	}
+
 	return buf[:o]
 }
 
@@ -420,7 +736,18 @@ func serializeState(snakes []*Snake, hasMeta []bool, foods []*Food, includeFood
 // Global summary (leaderboard + minimap for ALL alive snakes, not viewport-filtered)
 // ---------------------------------------------------------------------------
 
-func (g *Game) buildSummaryBytes() []byte {
+// putScore writes a score or length at buf[o] as uint16, capped, or as
+// uint32 with wide, and returns the next offset.
+func putScore(buf []byte, o, v int, wide bool) int {
+	if wide {
+		binary.BigEndian.PutUint32(buf[o:], uint32(min(uint64(max(v, 0)), math.MaxUint32)))
+		return o + 4
+	}
+	binary.BigEndian.PutUint16(buf[o:], uint16(clampInt(v, 0, 65535)))
+	return o + 2
+}
+
+func (g *Game) buildSummaryBytes(wide bool) []byte {
 	var alive []*Snake
 	for _, s := range g.snakes {
 		if s.Alive && len(s.Segments) > 0 {
@@ -431,7 +758,10 @@ func (g *Game) buildSummaryBytes() []byte {
 	// Calculate size: 2 (count) + per snake: 2+2+2+2+1+1+nameLen
 	size := 2
 	for _, s := range alive {
-		size += 2 + 2 + 2 + 2 + 1 + 1 + len(s.Name)
+		size += 2 + 2 + 2 + 2 + 1 + 1 + len(wireName(s.Name))
+		if wide {
+			size += 2
+		}
 	}
 
 	buf := make([]byte, size)
@@ -462,17 +792,12 @@ func (g *Game) buildSummaryBytes() []byte {
 		binary.BigEndian.PutUint16(buf[o:], uint16(hy))
 		o += 2
 
-		score := s.Score
-		if score > 65535 {
-			score = 65535
-		}
-		binary.BigEndian.PutUint16(buf[o:], uint16(score))
-		o += 2
+		o = putScore(buf, o, s.Score, wide)
 
 		buf[o] = byte(s.ColorIdx)
 		o++
 
-		nameBytes := []byte(s.Name)
+		nameBytes := []byte(wireName(s.Name))
 		buf[o] = byte(len(nameBytes))
 		o++
 		copy(buf[o:], nameBytes)
@@ -486,36 +811,121 @@ func (g *Game) buildSummaryBytes() []byte {
 // Broadcast (called from game loop goroutine)
 // ---------------------------------------------------------------------------
 
-func (g *Game) broadcast(includeFood bool, includeSummary bool) {
-	var summaryBytes []byte
-	if includeSummary {
-		summaryBytes = g.buildSummaryBytes()
+// Per-connection congestion control: a player whose send buffer backs up
+// gets state every 2nd/4th/8th net tick (food and summary scaled the same
+// way) until the buffer stays empty again.
+const (
+	SendBufferSize     = 8
+	MaxSendEvery       = 8
+	calmSendsToRecover = 30 // empty-buffer send slots before doubling the rate again
+)
+
+// adaptRate adjusts p.sendEvery from the current send buffer backlog. Called
+// at each of the player's send slots.
+func (p *Player) adaptRate() {
+	switch backlog := len(p.sendCh); {
+	case backlog >= SendBufferSize/2:
+		p.calmSends = 0
+		if p.sendEvery < MaxSendEvery {
+			p.sendEvery *= 2
+			slog.Debug("throttling player updates", "playerID", p.id, "backlog", backlog, "every", p.sendEvery)
+		}
+	case backlog == 0:
+		p.calmSends++
+		if p.calmSends >= calmSendsToRecover && p.sendEvery > 1 {
+			p.sendEvery /= 2
+			p.calmSends = 0
+			slog.Debug("relaxing player updates", "playerID", p.id, "every", p.sendEvery)
+		}
+	}
+}
+
+// sendInterval returns the net-tick divisor for p: its congestion rate,
+// and at least 2 for laggy clients.
+func (p *Player) sendInterval() int {
+	if p.laggy() && p.sendEvery < 2 {
+		return 2
+	}
+	return p.sendEvery
+}
+
+func (g *Game) broadcast() {
+	sh := g.newFrameShared()
+	if g.shards != nil {
+		// Pick up snakes that spawned or moved since the collision pass
+		g.shards.assign(g.snakes, snakeHead(g.snakes))
 	}
 
 	for _, p := range g.players {
 		if p.snake == nil {
 			continue
 		}
-		oldKnown := p.knownSnakes
-		data := g.serializeStateFor(p, includeFood)
+		if g.netTick%p.sendEvery == 0 {
+			p.adaptRate()
+		}
+		g.sendState(p, max(p.sendInterval(), g.degradeSendEvery()), sh)
+	}
+	for _, p := range g.dir.viewers {
+		if g.netTick%p.sendEvery == 0 {
+			p.adaptRate()
+		}
+		g.sendState(p, max(p.sendInterval(), DirectorSendEvery), sh)
+	}
+}
+
+// sendState queues a state frame for p if one is due on this net tick, with
+// one frame every `every` net ticks.
+func (g *Game) sendState(p *Player, every int, sh *frameShared) {
+	if g.netTick%every != 0 {
+		return
+	}
+	foodEvery := FoodSyncRate
+	if p.serializer.DeltaFood() {
+		foodEvery = FoodKeyframeRate
+	}
+	foodEvery *= g.degradeFoodFactor()
+	includeFood := g.netTick%(foodEvery*every) == 0 || p.foodResync
+	includeSummary := g.netTick%(2*every) == 0
 
-		// Append global summary and set hasSummary flag (bit 1)
-		if includeSummary && len(summaryBytes) > 0 {
-			full := make([]byte, len(data)+len(summaryBytes))
-			copy(full, data)
-			copy(full[len(data):], summaryBytes)
-			full[1] |= 2 // flags bit 1 = hasSummary
-			data = full
+	oldKnown, oldFood := p.knownSnakes, p.knownFood
+	oldNames := p.markNames()
+	data, sections := g.encodeState(p, includeFood, includeSummary, sh)
+	if data == nil {
+		p.knownSnakes, p.knownFood = oldKnown, oldFood
+		p.rollbackNames(oldNames)
+		return
+	}
+
+	n := int64(len(data))
+	select {
+	case p.sendCh <- data:
+		g.totalBytesSent += n
+		g.bwAccum += n
+		if includeFood {
+			p.foodResync = false
 		}
+		g.queueSections(p, sections)
+	default:
+		// Buffer full, drop frame — restore caches so metadata and food
+		// changes are resent
+		p.knownSnakes, p.knownFood = oldKnown, oldFood
+		p.rollbackNames(oldNames)
+	}
+}
 
-		n := int64(len(data))
+// broadcastText queues a JSON event for every player and director. Players
+// whose queue is full miss the event.
+func (g *Game) broadcastText(data []byte) {
+	for _, p := range g.players {
 		select {
-		case p.sendCh <- data:
-			g.totalBytesSent += n
-			g.bwAccum += n
+		case p.textCh <- data:
+		default:
+		}
+	}
+	for _, p := range g.dir.viewers {
+		select {
+		case p.textCh <- data:
 		default:
-			// Buffer full, drop frame — restore knownSnakes so metadata is resent
-			p.knownSnakes = oldKnown
 		}
 	}
 }
@@ -524,20 +934,76 @@ func (g *Game) broadcast(includeFood bool, includeSummary bool) {
 // Stats API + Dashboard
 // ---------------------------------------------------------------------------
 
-// GetStats requests a stats snapshot from the game loop (thread-safe).
+// GetStats requests a stats snapshot from the game loop, or returns zero
+// stats if the room has stopped (thread-safe).
 func (g *Game) GetStats() StatsSnapshot {
 	reply := make(chan StatsSnapshot, 1)
-	g.statsReqCh <- reply
-	return <-reply
+	snap, _ := ask(g, g.statsReqCh, reply, reply)
+	return snap
 }
 
 func HandleStats(game *Game, w http.ResponseWriter, r *http.Request) {
 	snap := game.GetStats()
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	json.NewEncoder(w).Encode(snap)
 }
 
+// SubscribeStats registers a channel that receives a snapshot once per
+// second until UnsubscribeStats is called.
+func (g *Game) SubscribeStats() chan StatsSnapshot {
+	ch := make(chan StatsSnapshot, 1)
+	tell(g, g.statsSubCh, ch)
+	return ch
+}
+
+func (g *Game) UnsubscribeStats(ch chan StatsSnapshot) {
+	tell(g, g.statsUnsubCh, ch)
+}
+
+// HandleStatsStream pushes stats snapshots as Server-Sent Events.
+func HandleStatsStream(game *Game, w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := game.SubscribeStats()
+	defer game.UnsubscribeStats(ch)
+
+	send := func(snap StatsSnapshot) bool {
+		data, err := json.Marshal(snap)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	// First snapshot immediately, then whatever the game loop pushes
+	if !send(game.GetStats()) {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-game.stopped:
+			return
+		case snap := <-ch:
+			if !send(snap) {
+				return
+			}
+		}
+	}
+}
+
 func HandleDashboard(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	fmt.Fprint(w, dashboardHTML)
@@ -585,16 +1051,57 @@ const dashboardHTML = `<!DOCTYPE html>
   .badge.player { background: #0f3460; }
   .rank { color: #666; font-weight: bold; }
   .status-bar { font-size: 11px; color: #555; margin-top: 16px; text-align: right; }
+  .spark { background: #16213e; border-radius: 10px; padding: 14px 18px; }
+  .spark .label { font-size: 11px; text-transform: uppercase; color: #888; letter-spacing: 0.5px;
+                  display: flex; justify-content: space-between; }
+  .spark .label b { color: #00cc88; font-weight: 600; }
+  .spark svg { width: 100%; height: 48px; margin-top: 6px; display: block; }
+  .tabs { display: flex; gap: 8px; margin-bottom: 20px; }
+  .tabs button, .pager button { background: #16213e; color: #aaa; border: none; border-radius: 6px;
+                                padding: 8px 16px; font-size: 13px; cursor: pointer; }
+  .tabs button.active { background: #0f3460; color: #fff; }
+  .pager { display: flex; gap: 8px; justify-content: flex-end; margin-top: 12px; }
+  .pager button:disabled { opacity: 0.4; cursor: default; }
+  tr.match { cursor: pointer; }
+  td.players { color: #888; font-size: 12px; background: #121a33; }
+  .bar { height: 8px; border-radius: 4px; background: #00cc88; }
 </style>
 </head>
 <body>
 <h1><span><span class="dot"></span>Snake.io Server <span id="version" style="font-size:13px;font-weight:normal;color:rgba(255,255,255,0.5)"></span></span><span id="uptime" style="font-size:14px;font-weight:normal;color:rgba(255,255,255,0.7)"></span></h1>
+<div class="tabs">
+  <button class="active" data-tab="live">Live</button>
+  <button data-tab="matches">Match History</button>
+  <button data-tab="replays">Replays</button>
+</div>
+<div id="tab-live">
 <div class="grid" id="cards"></div>
+<h2>Tick Stages</h2>
+<table style="margin-bottom:28px">
+  <thead><tr><th>Stage</th><th>Avg</th><th>Max</th><th style="width:40%">Share of Avg Tick</th></tr></thead>
+  <tbody id="stages"></tbody>
+</table>
+<h2>Last Hour</h2>
+<div class="grid" id="history"></div>
 <h2>Leaderboard</h2>
 <table>
   <thead><tr><th>#</th><th>Name</th><th>Score</th><th>Type</th></tr></thead>
   <tbody id="lb"></tbody>
 </table>
+</div>
+<div id="tab-matches" style="display:none">
+<table>
+  <thead><tr><th>Round</th><th>Ended</th><th>Length</th><th>Winners</th><th>Snakes</th><th>Kills</th></tr></thead>
+  <tbody id="matches"></tbody>
+</table>
+<div class="pager"><button id="newer" disabled>Newer</button><button id="older" disabled>Older</button></div>
+</div>
+<div id="tab-replays" style="display:none">
+<table>
+  <thead><tr><th>Round</th><th>Started</th><th>Length</th><th>Winners</th><th>Top Score</th><th></th></tr></thead>
+  <tbody id="replays"></tbody>
+</table>
+</div>
 <div class="status-bar" id="status">Connecting...</div>
 <script>
 function fmtBw(v) { return v >= 1024 ? (v/1024).toFixed(1)+'<span class="unit"> MB/s</span>' : v+'<span class="unit"> KB/s</span>'; }
@@ -610,8 +1117,14 @@ const cardDefs = [
   {k:'aiCount',        label:'AI Snakes',      unit:''},
   {k:'foodCount',      label:'Food Items',     unit:''},
   {k:'totalKills',     label:'Total Kills',    unit:''},
+  {k:'playerKills',    label:'Player Kills',   unit:''},
+  {k:'aiKills',        label:'AI Kills',       unit:''},
+  {k:'boundaryDeaths', label:'Boundary Deaths', unit:''},
+  {k:'predatorKills',  label:'Predator Kills', unit:''},
   {k:'totalJoins',     label:'Total Joins',    unit:''},
   {k:'totalLeaves',    label:'Total Leaves',   unit:''},
+  {k:'idle',           label:'Simulation',     unit:''},
+  {k:'tickRate',       label:'Tick Rate',      unit:'Hz', perf:true},
   {k:'avgTickMs',      label:'Avg Tick',       unit:'ms', perf:true},
   {k:'maxTickMs',      label:'Max Tick',       unit:'ms', perf:true},
   {k:'bandwidthKBps',  label:'Bandwidth Out',  unit:'KB/s', perf:true, fmt:fmtBw},
@@ -630,6 +1143,13 @@ function render(d) {
             '<div class="value">'+valHtml+'</div></div>';
   }
   document.getElementById('cards').innerHTML = html;
+  let st = '';
+  (d.stages || []).forEach(function(s) {
+    const pct = d.avgTickMs > 0 ? Math.min(100, s.avgMs / d.avgTickMs * 100) : 0;
+    st += '<tr><td>'+s.stage+'</td><td>'+s.avgMs.toFixed(3)+' ms</td><td>'+s.maxMs.toFixed(3)+' ms</td>'+
+          '<td><div class="bar" style="width:'+pct.toFixed(1)+'%"></div></td></tr>';
+  });
+  document.getElementById('stages').innerHTML = st;
   let lb = '';
   if (d.leaderboard && d.leaderboard.length) {
     d.leaderboard.forEach(function(e, i) {
@@ -648,8 +1168,115 @@ function poll() {
   fetch('/stats').then(r=>r.json()).then(render)
     .catch(e=>{ document.getElementById('status').textContent='Error: '+e; });
 }
-poll();
-setInterval(poll, 1000);
+const histDefs = [
+  {k:'players',       label:'Players',    unit:''},
+  {k:'avgTickMs',     label:'Tick Time',  unit:' ms'},
+  {k:'bandwidthKBps', label:'Bandwidth',  unit:' KB/s'},
+  {k:'killsPerMin',   label:'Kills/min',  unit:''},
+];
+function sparkline(vals) {
+  if (vals.length < 2) return '';
+  const max = Math.max.apply(null, vals) || 1;
+  const pts = vals.map(function(v, i) {
+    return (i/(vals.length-1)*100).toFixed(2)+','+(46 - v/max*44).toFixed(2);
+  }).join(' ');
+  return '<polyline fill="none" stroke="#00cc88" stroke-width="1.5" vector-effect="non-scaling-stroke" points="'+pts+'"/>';
+}
+function renderHistory(h) {
+  let html = '';
+  for (const c of histDefs) {
+    const vals = h.samples.map(function(s) { return s[c.k]; });
+    const last = vals.length ? vals[vals.length-1] : '-';
+    html += '<div class="spark"><div class="label"><span>'+c.label+'</span><b>'+last+c.unit+'</b></div>'+
+            '<svg viewBox="0 0 100 48" preserveAspectRatio="none">'+sparkline(vals)+'</svg></div>';
+  }
+  document.getElementById('history').innerHTML = html;
+}
+function pollHistory() {
+  fetch('/stats/history').then(r=>r.json()).then(renderHistory).catch(function() {});
+}
+pollHistory();
+setInterval(pollHistory, 10000);
+
+// Match history tab (/matches, needs -data-dir). pages holds the "before"
+// cursor of every page up to the current one.
+let pages = [0];
+function loadMatches() {
+  fetch('/matches?limit=20&before=' + pages[pages.length-1]).then(function(r) {
+    if (!r.ok) throw new Error(r.status === 404 ? 'Match history needs -data-dir' : 'HTTP ' + r.status);
+    return r.json();
+  }).then(function(d) {
+    let html = '';
+    d.matches.forEach(function(m) {
+      const kills = m.participants.reduce(function(n, p) { return n + p.kills; }, 0);
+      const secs = Math.round((new Date(m.endedAt) - new Date(m.startedAt)) / 1000);
+      const players = m.participants.map(function(p, i) {
+        return (i+1) + '. ' + esc(p.name) + (p.isAI ? ' (AI)' : '') + ' ' + p.score + ' pts, ' + p.kills + ' kills';
+      }).join(' &middot; ');
+      html += '<tr class="match"><td class="rank">' + m.round + '</td><td>' + new Date(m.endedAt).toLocaleString() +
+              '</td><td>' + Math.floor(secs/60) + 'm ' + (secs%60) + 's</td><td>' + m.winners.map(esc).join(', ') +
+              '</td><td>' + m.participants.length + '</td><td>' + kills + '</td></tr>' +
+              '<tr style="display:none"><td class="players" colspan="6">' + players + '</td></tr>';
+    });
+    if (!html) html = '<tr><td colspan="6" style="color:#555;text-align:center">No matches recorded yet</td></tr>';
+    const tbody = document.getElementById('matches');
+    tbody.innerHTML = html;
+    tbody.querySelectorAll('tr.match').forEach(function(tr) {
+      tr.onclick = function() {
+        const next = tr.nextElementSibling;
+        next.style.display = next.style.display === 'none' ? '' : 'none';
+      };
+    });
+    document.getElementById('newer').disabled = pages.length < 2;
+    document.getElementById('older').disabled = !d.next;
+    document.getElementById('older').onclick = function() { pages.push(d.next); loadMatches(); };
+  }).catch(function(e) {
+    document.getElementById('matches').innerHTML =
+      '<tr><td colspan="6" style="color:#555;text-align:center">' + esc(e.message) + '</td></tr>';
+  });
+}
+document.getElementById('newer').onclick = function() { pages.pop(); loadMatches(); };
+
+// Replays tab (/replays, needs -replays). Watch opens the game page, which
+// plays the replay like a director connection.
+function loadReplays() {
+  fetch('/replays?limit=50').then(function(r) {
+    if (!r.ok) throw new Error(r.status === 404 ? 'Replays need -data-dir and -replays' : 'HTTP ' + r.status);
+    return r.json();
+  }).then(function(d) {
+    let html = '';
+    d.replays.forEach(function(m) {
+      const secs = Math.round(m.durationMs / 1000);
+      html += '<tr><td class="rank">' + m.round + '</td><td>' + new Date(m.startedAt).toLocaleString() +
+              '</td><td>' + Math.floor(secs/60) + 'm ' + (secs%60) + 's</td><td>' + (m.winners || []).map(esc).join(', ') +
+              '</td><td>' + m.topScore + '</td><td><a href="/?replay=' + encodeURIComponent(m.id) +
+              '" target="_blank" style="color:#00cc88">Watch</a></td></tr>';
+    });
+    if (!html) html = '<tr><td colspan="6" style="color:#555;text-align:center">No replays recorded yet</td></tr>';
+    document.getElementById('replays').innerHTML = html;
+  }).catch(function(e) {
+    document.getElementById('replays').innerHTML =
+      '<tr><td colspan="6" style="color:#555;text-align:center">' + esc(e.message) + '</td></tr>';
+  });
+}
+document.querySelectorAll('.tabs button').forEach(function(b) {
+  b.onclick = function() {
+    document.querySelectorAll('.tabs button').forEach(function(o) { o.classList.toggle('active', o === b); });
+    document.getElementById('tab-live').style.display = b.dataset.tab === 'live' ? '' : 'none';
+    document.getElementById('tab-matches').style.display = b.dataset.tab === 'matches' ? '' : 'none';
+    document.getElementById('tab-replays').style.display = b.dataset.tab === 'replays' ? '' : 'none';
+    if (b.dataset.tab === 'matches') loadMatches();
+    if (b.dataset.tab === 'replays') loadReplays();
+  };
+});
+if (window.EventSource) {
+  const es = new EventSource('/stats/stream');
+  es.onmessage = function(e) { render(JSON.parse(e.data)); };
+  es.onerror = function() { document.getElementById('status').textContent = 'Stream interrupted, reconnecting...'; };
+} else {
+  poll();
+  setInterval(poll, 1000);
+}
 </script>
 </body>
 </html>`
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"snake-server/gametest"
+	"snake-server/wire"
+)
+
+func TestJoinAndSteer(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.WorldSize = 3000
+	cfg.FoodCount = 50
+	cfg.AICount = 0
+	g := NewGame(cfg)
+	go g.Run()
+	defer g.Stop()
+	ac, err := NewAccessControl(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) { HandleWS(g, ac, w, r) })
+	srv := gametest.NewServer(mux)
+	defer srv.Close()
+
+	c, err := srv.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Join("Alice"); err != nil {
+		t.Fatal(err)
+	}
+	st := c.Expect(t, 2*time.Second, "own snake alive", func(st *wire.State) bool {
+		s, ok := c.Own(st)
+		return ok && s.Alive() && len(s.Segments) > 0
+	})
+	s, _ := c.Own(st)
+	if s.Flags&wire.SnakePlayer == 0 {
+		t.Errorf("own snake flags = %b, want the player bit", s.Flags)
+	}
+	start := s.Segments[0]
+
+	// Head for the world center, so the snake can't reach the wall.
+	mid := float64(cfg.WorldSize) / 2
+	angle := math.Atan2(mid-float64(start.Y), mid-float64(start.X))
+	if err := c.Steer(angle, false); err != nil {
+		t.Fatal(err)
+	}
+	c.Expect(t, 3*time.Second, "own snake turned and moved", func(st *wire.State) bool {
+		s, ok := c.Own(st)
+		if !ok || !s.Alive() || len(s.Segments) == 0 {
+			return false
+		}
+		head := s.Segments[0]
+		moved := math.Hypot(float64(head.X-start.X), float64(head.Y-start.Y))
+		turned := math.Abs(math.Remainder(s.Angle-angle, 2*math.Pi)) < 0.01
+		return moved > 50 && turned
+	})
+}
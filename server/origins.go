@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Origin allow-list for WebSocket upgrades and the CORS headers of the
+// stats endpoints
+//
+// An empty list is permissive (any origin, "Access-Control-Allow-Origin: *"),
+// which suits LAN play. A non-empty list is strict: browsers may only connect
+// from the page's own origin or a listed one. Requests without an Origin
+// header (native clients, bots, curl) are always allowed.
+// ---------------------------------------------------------------------------
+
+type OriginPolicy struct {
+	allowed map[string]bool // normalized "scheme://host[:port]"; nil = permissive
+}
+
+func NewOriginPolicy(origins []string) (*OriginPolicy, error) {
+	op := &OriginPolicy{}
+	for _, o := range origins {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			continue
+		}
+		if o == "*" {
+			return &OriginPolicy{}, nil
+		}
+		norm, ok := normalizeOrigin(o)
+		if !ok {
+			return nil, fmt.Errorf("invalid origin %q (want scheme://host[:port])", o)
+		}
+		if op.allowed == nil {
+			op.allowed = make(map[string]bool)
+		}
+		op.allowed[norm] = true
+	}
+	return op, nil
+}
+
+func normalizeOrigin(o string) (string, bool) {
+	u, err := url.Parse(o)
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return "", false
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), true
+}
+
+func (op *OriginPolicy) Strict() bool {
+	return op.allowed != nil
+}
+
+// Allowed reports whether r's Origin may use the server. Used as the
+// WebSocket upgrader's CheckOrigin.
+func (op *OriginPolicy) Allowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || !op.Strict() {
+		return true
+	}
+	norm, ok := normalizeOrigin(origin)
+	if !ok {
+		return false
+	}
+	if u, _ := url.Parse(norm); strings.EqualFold(u.Host, r.Host) {
+		return true // the embedded client
+	}
+	return op.allowed[norm]
+}
+
+// CORS wraps h with the Access-Control-Allow-Origin header for the policy.
+func (op *OriginPolicy) CORS(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !op.Strict() {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); origin != "" && op.Allowed(r) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// AI pack hunting
+//
+// Every PackThinkInterval the coordinator looks for snakes with several free
+// built-in bots nearby and assigns up to AIPackSize of them a shared target.
+// The bot furthest behind the target chases it; the others race ahead on
+// their side of its path and turn across it, so the target is boxed in
+// instead of just followed. Packs break up when the target dies, too few
+// members are left or PackMaxAge passes. GET /debug/ai shows the current
+// packs.
+// ---------------------------------------------------------------------------
+
+const (
+	PackThinkInterval = TickRate / 2
+	PackRange         = 800.0         // max distance from bot to target when forming a pack
+	PackMaxAge        = 15 * TickRate // ticks before a pack gives up
+	packLeadMin       = 120.0         // how far ahead of the target cut-off bots aim
+	packLeadMax       = 450.0
+	packCutoffSpread  = 0.35 // rad a cut-off bot's aim point is offset to its side
+)
+
+const (
+	packChase  = "chase"
+	packCutoff = "cutoff"
+)
+
+type aiPack struct {
+	id       int
+	target   *Snake
+	targetID uint32 // entity ID
+	members  []packMember
+	formed   int // frame
+}
+
+type packMember struct {
+	s    *Snake
+	id   uint32 // entity ID
+	role string
+	side float64 // cutoff: +1 or -1, the side of the target's path to approach from
+}
+
+// Debug view (GET /debug/ai)
+
+type AIDebugSnapshot struct {
+	PackSize    int            `json:"packSize"` // 0 = pack hunting off
+	PacksFormed int64          `json:"packsFormed"`
+	PackKills   int64          `json:"packKills"`
+	Packs       []PackInfo     `json:"packs"`
+	States      map[string]int `json:"states"` // living AI snakes per state
+}
+
+type PackInfo struct {
+	ID       int              `json:"id"`
+	TargetID int              `json:"targetId"`
+	Target   string           `json:"target"`
+	AgeSec   float64          `json:"ageSec"`
+	Members  []PackMemberInfo `json:"members"`
+}
+
+type PackMemberInfo struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+	Dist int    `json:"dist"` // to the target's head
+}
+
+func (p *aiPack) targetValid() bool {
+	return p.target.Alive && p.target.id == p.targetID
+}
+
+// live returns the members still alive and in the pack. respawnAI reuses
+// Snake values, so IDs are checked too.
+func (p *aiPack) live() []packMember {
+	live := p.members[:0]
+	for _, m := range p.members {
+		if m.s.Alive && m.s.id == m.id && m.s.pack == p {
+			live = append(live, m)
+		}
+	}
+	p.members = live
+	return live
+}
+
+// updatePacks dissolves finished packs and forms new ones. Called once per
+// tick from simulate.
+func (g *Game) updatePacks() {
+	if g.frame%PackThinkInterval != 0 {
+		return
+	}
+	kept := g.packs[:0]
+	for _, p := range g.packs {
+		if p.targetValid() && len(p.live()) >= 2 && g.frame-p.formed < PackMaxAge {
+			kept = append(kept, p)
+		} else {
+			g.dissolvePack(p)
+		}
+	}
+	g.packs = kept
+	if g.cfg.AIPackSize < 2 {
+		return
+	}
+
+	// At most half of the bots hunt in packs.
+	free, hunting := 0, 0
+	for _, s := range g.snakes {
+		switch {
+		case !s.IsAI || !s.Alive:
+		case s.pack != nil:
+			hunting++
+		case g.packEligible(s):
+			free++
+		}
+	}
+	budget := (free+hunting)/2 - hunting
+	if budget < 2 {
+		return
+	}
+
+	targeted := make(map[*Snake]bool, len(g.packs))
+	for _, p := range g.packs {
+		targeted[p.target] = true
+	}
+	for _, t := range g.packTargets() {
+		if budget < 2 {
+			break
+		}
+		if targeted[t] {
+			continue
+		}
+		if p := g.formPack(t, min(g.cfg.AIPackSize, budget)); p != nil {
+			budget -= len(p.members)
+		}
+	}
+}
+
+// packTargets returns hunt candidates, most wanted first: the golden snake,
+// then players, then other bots, each by score.
+func (g *Game) packTargets() []*Snake {
+	var ts []*Snake
+	for _, s := range g.snakes {
+		if s.Alive && s.InvTimer == 0 {
+			ts = append(ts, s)
+		}
+	}
+	rank := func(s *Snake) int {
+		switch {
+		case s == g.golden:
+			return 0
+		case !s.IsAI:
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(ts, func(i, j int) bool {
+		if ri, rj := rank(ts[i]), rank(ts[j]); ri != rj {
+			return ri < rj
+		}
+		return ts[i].Score > ts[j].Score
+	})
+	return ts
+}
+
+// packEligible reports whether bot s is free to join a pack.
+func (g *Game) packEligible(s *Snake) bool {
+	return s.IsAI && s.Alive && s.pack == nil && s.AIState != "flee" && s.AIState != "escape" &&
+		g.scripts.forSnake(s) == nil
+}
+
+// formPack assigns up to size free bots near t to hunt it. Returns nil if
+// fewer than two are available or they are too small together.
+func (g *Game) formPack(t *Snake, size int) *aiPack {
+	th := t.Segments[0]
+	type cand struct {
+		s *Snake
+		d float64
+	}
+	var cands []cand
+	for _, s := range g.snakes {
+		if s == t || !g.packEligible(s) {
+			continue
+		}
+		h := s.Segments[0]
+		if d := sim.Dist(h.X, h.Y, th.X, th.Y); d < PackRange {
+			cands = append(cands, cand{s, d})
+		}
+	}
+	if len(cands) < 2 {
+		return nil
+	}
+	sort.Slice(cands, func(i, j int) bool { return cands[i].d < cands[j].d })
+	cands = cands[:min(len(cands), size)]
+	total := 0
+	for _, c := range cands {
+		total += len(c.s.Segments)
+	}
+	if total < len(t.Segments) {
+		return nil
+	}
+
+	g.nextPackID++
+	p := &aiPack{id: g.nextPackID, target: t, targetID: t.id, formed: g.frame}
+	// The bot furthest behind the target chases; the rest cut it off on
+	// whichever side of its path they are.
+	cos, sin := math.Cos(t.Angle), math.Sin(t.Angle)
+	chase, behind := 0, math.Inf(1)
+	for i, c := range cands {
+		h := c.s.Segments[0]
+		if along := (h.X-th.X)*cos + (h.Y-th.Y)*sin; along < behind {
+			chase, behind = i, along
+		}
+	}
+	for i, c := range cands {
+		m := packMember{s: c.s, id: c.s.id, role: packCutoff, side: 1}
+		h := c.s.Segments[0]
+		if i == chase {
+			m.role = packChase
+		} else if (h.X-th.X)*sin-(h.Y-th.Y)*cos > 0 {
+			m.side = -1
+		}
+		c.s.pack = p
+		c.s.AIState = "pack"
+		p.members = append(p.members, m)
+	}
+	g.packs = append(g.packs, p)
+	g.packsFormed++
+	return p
+}
+
+func (g *Game) dissolvePack(p *aiPack) {
+	for _, m := range p.members {
+		if m.s.pack == p {
+			m.s.pack = nil
+			m.s.AIStateTimer = 0 // pick a new state next tick
+		}
+	}
+}
+
+// packSteer steers s toward its pack role. Returns false if s isn't in an
+// active pack.
+func (g *Game) packSteer(s *Snake, head Vec2) bool {
+	p := s.pack
+	if p == nil || !p.targetValid() {
+		return false
+	}
+	var m *packMember
+	for i := range p.members {
+		if p.members[i].s == s {
+			m = &p.members[i]
+		}
+	}
+	if m == nil {
+		return false
+	}
+
+	t := p.target
+	th := t.Segments[0]
+	d := sim.Dist(head.X, head.Y, th.X, th.Y)
+	s.AIState = "pack"
+	switch m.role {
+	case packChase:
+		px := th.X + math.Cos(t.Angle)*60
+		py := th.Y + math.Sin(t.Angle)*60
+		s.TargetAngle = math.Atan2(py-head.Y, px-head.X)
+		s.IsBoosting = d < 250 && s.Boost > 30
+	default:
+		// Aim at a point ahead of the target on our side of its path; once
+		// there, turn across the path to block it.
+		lead := sim.Clamp(d*0.7, packLeadMin, packLeadMax)
+		a := t.Angle + m.side*packCutoffSpread
+		px, py := th.X+math.Cos(a)*lead, th.Y+math.Sin(a)*lead
+		if dp := sim.Dist(head.X, head.Y, px, py); dp < 60 {
+			s.TargetAngle = t.Angle - m.side*math.Pi/2
+			s.IsBoosting = false
+		} else {
+			s.TargetAngle = math.Atan2(py-head.Y, px-head.X)
+			s.IsBoosting = dp > 250 && s.Boost > 40
+		}
+	}
+	return true
+}
+
+// creditPack counts kills of a pack's target by one of its members.
+func (g *Game) creditPack(victim, killer *Snake) {
+	if killer != nil && killer.pack != nil && killer.pack.target == victim {
+		g.packKills++
+	}
+}
+
+func (g *Game) buildAIDebug() AIDebugSnapshot {
+	snap := AIDebugSnapshot{
+		PackSize:    g.cfg.AIPackSize,
+		PacksFormed: g.packsFormed,
+		PackKills:   g.packKills,
+		Packs:       []PackInfo{},
+		States:      make(map[string]int),
+	}
+	for _, s := range g.snakes {
+		if s.IsAI && s.Alive {
+			snap.States[s.AIState]++
+		}
+	}
+	for _, p := range g.packs {
+		if !p.targetValid() {
+			continue
+		}
+		th := p.target.Segments[0]
+		info := PackInfo{
+			ID: p.id, TargetID: p.target.PlayerID, Target: p.target.Name,
+			AgeSec: float64(g.frame-p.formed) / TickRate,
+		}
+		for _, m := range p.live() {
+			h := m.s.Segments[0]
+			info.Members = append(info.Members, PackMemberInfo{
+				ID: m.s.PlayerID, Name: m.s.Name, Role: m.role, Dist: int(sim.Dist(h.X, h.Y, th.X, th.Y)),
+			})
+		}
+		snap.Packs = append(snap.Packs, info)
+	}
+	return snap
+}
+
+// GetAIDebug returns the AI coordinator state (thread-safe).
+func (g *Game) GetAIDebug() AIDebugSnapshot {
+	reply := make(chan AIDebugSnapshot, 1)
+	snap, _ := ask(g, g.aiDebugReqCh, reply, reply)
+	return snap
+}
+
+func HandleAIDebug(game *Game, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(game.GetAIDebug())
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"math"
+	"slices"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Physics step
+//
+// Steering, movement, eating and snake collisions are sim.Step (see package
+// sim). For every substep, step copies the snakes and food into g.physics,
+// runs sim.Step and copies the results back; g.snakes and g.foods line up
+// with the step's snakes and food by index. Everything with a player, a
+// score or a message attached stays here: applyEvents turns the step's
+// events into kills with their food drops, rewards and death reports,
+// boost refills and the portal and head-on counters, in the order they
+// happened. When both snakes of a head-on die, each is credited with the
+// other's kill and neither grows.
+//
+// The inputs go with the first substep of a tick: the heading and boost
+// players sent, or that bots picked in prepareTick. Bots that aren't
+// fleeing a laser trail or an eel steer around crowds on the way.
+// ---------------------------------------------------------------------------
+
+// prepareTick runs before the first substep of a tick: AI respawns and
+// decisions, and the timers the step reads.
+func (g *Game) prepareTick() {
+	g.avoid = g.avoid[:0]
+	for _, s := range g.snakes {
+		avoid := false
+		switch {
+		case s.Alive:
+			avoid = g.updateAI(s)
+			g.countDown(&s.InvTimer)
+			g.countDown(&s.portalCooldown)
+		case s.IsAI && !s.filler: // dead fillers are removed (see fill.go)
+			g.countDown(&s.RespawnTmr)
+			if s.RespawnTmr <= 0 {
+				g.respawnAI(s)
+			}
+		}
+		g.avoid = append(g.avoid, avoid)
+	}
+}
+
+// stepRules returns the physics settings for a tick of n substeps.
+func (g *Game) stepRules(n int) sim.Rules {
+	rule, _ := sim.HeadOnRuleFor(g.cfg.HeadOn) // validated with the config
+	r := sim.Rules{
+		WorldSize:          float64(g.cfg.WorldSize),
+		Arena:              g.arena,
+		BoundaryMargin:     g.cfg.BoundaryMargin,
+		WarningBand:        g.cfg.WarningBand,
+		WallBrake:          g.cfg.WallBrake,
+		Frac:               g.cfg.TimeScale / float64(n),
+		BaseSpeed:          g.cfg.BaseSpeed,
+		BoostSpeed:         g.cfg.BoostSpeed,
+		TurnSpeed:          g.rules.TurnSpeed,
+		GrowthRate:         g.cfg.GrowthRate,
+		MaxGap:             math.Max(g.cfg.SegmentSpacing, g.cfg.BoostSpeed),
+		SizeScale:          g.rules.SizeScale,
+		CollisionPrecision: g.cfg.CollisionPrecision,
+		Deterministic:      g.cfg.Deterministic,
+		HeadOn:             rule,
+		Portals:            g.portals,
+		PortalCooldown:     g.cfg.PortalCooldown,
+		Hazards:            g.predatorHazards(g.physics.Rules.Hazards[:0]),
+	}
+	if g.shards != nil {
+		g.shards.snakes = g.snakes
+		r.Broadphase = g.shards
+	}
+	return r
+}
+
+// step runs substep sub of n, with per substeps making up one game tick.
+// Each game tick adds one body point, which later substeps move along.
+func (g *Game) step(sub, n, per int) {
+	st := &g.physics
+	st.Rules = g.stepRules(n)
+	st.Snakes, st.Food, st.Density = st.Snakes[:0], st.Food[:0], nil
+	for _, s := range g.snakes {
+		if sub%per == 0 && s.Alive {
+			s.headPlaced = g.clockSteps == 0 // slow motion: keep moving the last point
+		}
+		if sub == 0 {
+			s.turnRate = 0
+		}
+		st.Snakes = append(st.Snakes, sim.Snake{
+			Body: s.Segments, Angle: s.Angle, TargetAngle: s.TargetAngle, Speed: s.Speed,
+			Boost: s.Boost, Boosting: s.IsBoosting, CanBoost: sub == 0 && s.Alive && g.canBoost(s),
+			TargetLen: s.TargetLen, BodyLen: s.bodyLen, Turn: s.turnRate,
+			Alive: s.Alive, Bot: s.IsAI, Invulnerable: s.InvTimer > 0, AddPoint: !s.headPlaced,
+			PortalGap: s.portalGap, PortalCooldown: s.portalCooldown,
+		})
+	}
+	for _, f := range g.foods {
+		st.Food = append(st.Food, sim.Food{
+			Pos: Vec2{X: f.X, Y: f.Y}, Radius: f.Radius, Value: int(math.Round(g.rules.FoodValue(f))),
+		})
+	}
+
+	var in []sim.Input
+	if sub == 0 {
+		in = g.inputs[:0]
+		for i, s := range g.snakes {
+			in = append(in, sim.Input{Angle: s.TargetAngle, Boost: s.IsBoosting, Avoid: g.avoid[i]})
+		}
+		g.inputs = in
+		if slices.Contains(g.avoid, true) {
+			st.Density = &g.bodyDensity().Density
+		}
+	}
+	*st = sim.Step(*st, in)
+
+	for i, s := range g.snakes {
+		ss := &st.Snakes[i]
+		s.Segments, s.Angle, s.TargetAngle, s.Speed = ss.Body, ss.Angle, ss.TargetAngle, ss.Speed
+		s.IsBoosting, s.TargetLen, s.bodyLen, s.turnRate = ss.Boosting, ss.TargetLen, ss.BodyLen, ss.Turn
+		s.headPlaced, s.portalGap, s.portalCooldown = !ss.AddPoint, ss.PortalGap, ss.PortalCooldown
+	}
+}
+
+// canBoost reports whether s may boost this tick.
+func (g *Game) canBoost(s *Snake) bool {
+	return g.rules.Boost.CanBoost(g, s) && len(s.Segments) > 12
+}
+
+// applyEvents acts on the events of the last step.
+func (g *Game) applyEvents() {
+	events := g.physics.Events
+	// Take the eaten food out of g.foods the way the step did, before kills
+	// drop more.
+	eaten := g.eaten[:0]
+	for _, e := range events {
+		if e.Kind == sim.Ate {
+			last := len(g.foods) - 1
+			eaten = append(eaten, g.foods[e.Other])
+			g.foods[e.Other] = g.foods[last]
+			g.foods = g.foods[:last]
+		}
+	}
+	g.eaten = eaten
+	defer clear(eaten)
+
+	for _, e := range events {
+		s := g.snakes[e.Snake]
+		switch e.Kind {
+		case sim.Ate:
+			f := eaten[0]
+			eaten = eaten[1:]
+			s.Score += int(math.Round(g.rules.FoodValue(f)))
+			g.rules.Boost.OnEat(g, s, f)
+			s.foodEaten++
+		case sim.Jumped:
+			s.glow.n = 0 // no afterimages across the map
+			g.portalJumps++
+		case sim.HitWall:
+			g.killSnake(s, nil, "boundary")
+			g.hookKill(s, nil)
+			g.reportDeath(s)
+		case sim.HitSnake:
+			g.collisionKill(s, g.snakes[e.Other])
+		case sim.HeadOn:
+			g.headOns++
+			g.log.Debug("head-on", "a", s.Name, "b", g.snakes[e.Other].Name, "rule", g.cfg.HeadOn)
+		case sim.Traded:
+			o := g.snakes[e.Other]
+			g.killSnake(s, o, "collision")
+			g.killSnake(o, s, "collision")
+			for _, v := range [2]*Snake{s, o} {
+				g.claimBounty(v, v.killedBy)
+				g.hookKill(v, v.killedBy)
+				g.reportDeath(v)
+			}
+		}
+	}
+}
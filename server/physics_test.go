@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"snake-server/sim"
+)
+
+// TestHeadOnReward runs a head-on in a game under every head-on rule and
+// kill reward model, and checks who dies and what the winner gains.
+func TestHeadOnReward(t *testing.T) {
+	for _, rule := range []string{"longer", "faster", "both"} {
+		for _, model := range []string{"percent", "flat", "diminishing"} {
+			t.Run(rule+"/"+model, func(t *testing.T) {
+				cfg := DefaultConfig()
+				cfg.AICount, cfg.FoodCount, cfg.StreakBonus = 0, 0, 0
+				cfg.SegmentSpacing = 3 // heads reach the bodies past collideStart
+				cfg.HeadOn, cfg.KillReward = rule, model
+				g := NewGame(cfg)
+				// The heads meet at right angles: a is longer, b faster.
+				mid := float64(cfg.WorldSize) / 2
+				a := headOnSnake(g, mid, 0, 60)
+				b := headOnSnake(g, mid, -math.Pi/2, 30)
+				b.IsBoosting = true
+				g.snakes = []*Snake{a, b}
+				lenA, lenB := a.TargetLen, b.TargetLen
+				g.tick()
+
+				if g.headOns != 1 {
+					t.Fatalf("%d head-ons", g.headOns)
+				}
+				var winner, loser *Snake
+				switch rule {
+				case "longer":
+					winner, loser = a, b
+				case "faster":
+					winner, loser = b, a
+				}
+				if winner == nil {
+					if a.Alive || b.Alive || a.TargetLen != lenA || b.TargetLen != lenB {
+						t.Fatalf("alive %v %v, lengths %d %d; want both dead at %d %d",
+							a.Alive, b.Alive, a.TargetLen, b.TargetLen, lenA, lenB)
+					}
+					return
+				}
+				before := lenA
+				if winner == b {
+					before = lenB
+				}
+				reward, _ := killRewardFor(model)
+				want := before + reward(&g.cfg, loser)
+				if !winner.Alive || loser.Alive || winner.TargetLen != want || loser.killedBy != winner {
+					t.Fatalf("winner alive %v at %d, loser alive %v; want length %d",
+						winner.Alive, winner.TargetLen, loser.Alive, want)
+				}
+			})
+		}
+	}
+}
+
+// headOnSnake returns a snake of n points with its head in the middle of
+// the world, facing angle, past its spawn protection.
+func headOnSnake(g *Game, mid, angle float64, n int) *Snake {
+	s := g.createSnake("s", mid, mid, 0, false, nextPlayerID())
+	s.Segments, s.Angle = sim.LineBody(Vec2{X: mid, Y: mid}, angle, n, g.cfg.SegmentSpacing, false)
+	s.TargetAngle, s.TargetLen, s.bodyLen, s.InvTimer = s.Angle, n, float64(n), 0
+	return s
+}
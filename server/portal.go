@@ -0,0 +1,187 @@
+package main
+
+import (
+	"math"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Portals
+//
+// With Portals N, the world holds N pairs of linked portals. A snake whose
+// head runs into one comes out of the other, moving the same way, and its
+// body follows through: the body is a trail of past head points, so the
+// points from before the jump stay at the entrance and drain into it tick
+// by tick while the new ones pile up at the exit. s.portalGap counts the
+// body points past the jump until the last one before it is gone. Until
+// then the snake can't take another portal, nor for PortalCooldown ticks
+// after a jump, so a snake has at most one gap in its body. The jump is
+// part of the physics step (see sim/step.go).
+//
+// Everything that bounds a body by its length from the head (collision,
+// assist and shard early-outs, the view box) also measures from the first
+// point past the jump (bodyAnchors), so a body coming out of a portal is
+// still solid and visible.
+//
+// Portals keep their places for the life of the world and are sent to
+// clients in the init event. Body points are sent as they are; protocol
+// v13 marks the jump with an empty culled run, v8 to v12 leave out the
+// first point past it, and protobuf frames carry its index in
+// portal_break. Clients before v8 draw the body stretched between the
+// portals while it passes through.
+//
+// Built-in bots know the portals nearby: wandering bots sometimes take
+// them, and hunting bots go through one when that is much the shorter way
+// to their prey.
+// ---------------------------------------------------------------------------
+
+const (
+	MaxPortals            = 8 // pairs
+	PortalRadius          = sim.PortalRadius
+	DefaultPortalCooldown = 3 * TickRate
+	MaxPortalCooldown     = 60 * TickRate
+	portalInset           = 600.0 // from the world edge, at most a quarter of the world
+	portalSpacing         = 400.0 // between any two portals
+	portalPairDist        = 0.3   // of WorldSize, between the ends of a pair
+	portalLureDist        = 600.0 // bots consider portals this close
+	portalLureChance      = 0.3   // per wander turn, that a bot heads into a portal nearby
+	portalShortcut        = 0.6   // bots hunt through a portal if that way is this much shorter
+)
+
+// PortalPair is two linked portals: a snake entering one leaves the other.
+type PortalPair = sim.PortalPair
+
+// updatePortals places or removes portal pairs until there are Portals of
+// them, telling clients if anything changed. Called once per tick.
+func (g *Game) updatePortals() {
+	if len(g.portals) == g.cfg.Portals {
+		return
+	}
+	if len(g.portals) > g.cfg.Portals {
+		g.portals = g.portals[:g.cfg.Portals]
+	}
+	for len(g.portals) < g.cfg.Portals {
+		g.portals = append(g.portals, g.placePortalPair())
+	}
+	g.announceInit()
+}
+
+// placePortalPair finds room for a new pair, far enough apart and clear of
+// the other portals if it can.
+func (g *Game) placePortalPair() PortalPair {
+	var pair PortalPair
+	minDist := float64(g.cfg.WorldSize) * portalPairDist
+	for attempts := 0; attempts < 64; attempts++ {
+		pair = PortalPair{g.portalPos(), g.portalPos()}
+		if sim.Dist(pair[0].X, pair[0].Y, pair[1].X, pair[1].Y) >= minDist &&
+			g.portalClear(pair[0]) && g.portalClear(pair[1]) {
+			break
+		}
+	}
+	return pair
+}
+
+// portalPos returns a random point at least portalInset inside the arena.
+func (g *Game) portalPos() Vec2 {
+	inset := math.Min(portalInset, float64(g.cfg.WorldSize)/4)
+	for attempts := 0; attempts < 32; attempts++ {
+		if p := g.randWorldPos(); g.arena.EdgeDist(p) >= inset {
+			return p
+		}
+	}
+	return g.worldCenter()
+}
+
+// portalClear reports whether p is portalSpacing away from every portal.
+func (g *Game) portalClear(p Vec2) bool {
+	for _, pair := range g.portals {
+		for _, q := range pair {
+			if sim.DistSq(p.X, p.Y, q.X, q.Y) < portalSpacing*portalSpacing {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// bodyAnchors returns the points the body of s hangs from: its head, and
+// while the body passes through a portal, the first point past the jump.
+// Every body point is within bodySpan of one of them.
+func bodyAnchors(s *Snake) []Vec2 {
+	return sim.BodyAnchors(s.Segments, s.portalGap)
+}
+
+// nearBody reports whether p is within r of an anchor of s's body.
+func nearBody(s *Snake, p Vec2, r float64) bool {
+	for _, a := range bodyAnchors(s) {
+		if sim.DistSq(p.X, p.Y, a.X, a.Y) <= r*r {
+			return true
+		}
+	}
+	return false
+}
+
+// passingSnakes returns the living snakes other than own whose head is
+// outside the box of half-side r around (cx, cy) but whose body coming out
+// of a portal is inside it. The sharded view, which finds snakes by their
+// heads, adds these.
+func (g *Game) passingSnakes(own *Snake, cx, cy, r float64) []*Snake {
+	var found []*Snake
+	for _, s := range g.snakes {
+		if s == own || !s.Alive {
+			continue
+		}
+		anchors := bodyAnchors(s)
+		if len(anchors) < 2 {
+			continue
+		}
+		h, a := anchors[0], anchors[1]
+		if (math.Abs(h.X-cx) >= r || math.Abs(h.Y-cy) >= r) && math.Abs(a.X-cx) < r && math.Abs(a.Y-cy) < r {
+			found = append(found, s)
+		}
+	}
+	return found
+}
+
+// portalBreak returns the index among the points sent with the given
+// stride of the first one past a portal jump, 0 if there is none.
+func portalBreak(s *Snake, stride int) int {
+	if s.portalGap == 0 {
+		return 0
+	}
+	k := (s.portalGap + stride - 1) / stride
+	if k*stride >= len(s.Segments) {
+		return 0
+	}
+	return k
+}
+
+// portalGate returns the nearest portal end within portalLureDist of head
+// that s may take, and the end it leads to.
+func (g *Game) portalGate(s *Snake, head Vec2) (gate, exit Vec2, ok bool) {
+	if s.portalGap > 0 || s.portalCooldown > 0 {
+		return
+	}
+	best := portalLureDist * portalLureDist
+	for _, pair := range g.portals {
+		for i, p := range pair {
+			if d := sim.DistSq(head.X, head.Y, p.X, p.Y); d < best {
+				best, gate, exit, ok = d, p, pair[1-i], true
+			}
+		}
+	}
+	return
+}
+
+// huntDist returns how far a bot at head with the portal gate nearby (see
+// portalGate) has to go to reach to, and whether that is through the gate.
+func huntDist(head, to Vec2, gate, exit Vec2, hasGate bool) (float64, bool) {
+	d := sim.Dist(head.X, head.Y, to.X, to.Y)
+	if hasGate {
+		if via := sim.Dist(head.X, head.Y, gate.X, gate.Y) + sim.Dist(exit.X, exit.Y, to.X, to.Y); via < d*portalShortcut {
+			return via, true
+		}
+	}
+	return d, false
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Roaming predators
+//
+// With Predators > 0 that many giant eels roam the world. They belong to
+// nobody and can't be killed by snakes: any snake whose head touches an eel,
+// or whose body the eel's head touches, dies (cause "predator"). An eel
+// wanders and stays away from the edge, but once a snake comes within
+// PredatorSight it chases it, lunging when close, and forgets about the edge.
+// A player can lure it into the boundary: the eel then dies, drops its body
+// as PredatorFood big pellets, and a new one appears predatorRespawn later
+// at a spawn point away from snakes.
+//
+// Eels move once per game tick at PredatorSpeed and turn at a third of a
+// snake's turn rate, so a snake that sees one coming can turn away. Bots
+// flee from eels near them and steer around their bodies (see sim/steer.go).
+// Clients get them in v10 frames and in the predators field of protobuf
+// frames; older formats don't carry them.
+// ---------------------------------------------------------------------------
+
+const (
+	DefaultPredatorSpeed = 2.8
+
+	MaxPredators   = 8
+	PredatorRadius = 26.0  // body radius
+	PredatorLen    = 90    // body points, one per tick of movement
+	PredatorSight  = 900.0 // distance at which an eel starts chasing a snake
+	PredatorFood   = 30    // pellets dropped when an eel hits the boundary
+
+	predatorTurn      = 0.03 // rad per tick
+	predatorLunge     = 1.6  // speed factor within predatorLungeDist of the target
+	predatorLungeDist = 300.0
+	predatorGiveUp    = 10 * TickRate // ticks of chasing one snake before losing interest
+	predatorRest      = 4 * TickRate  // ticks after giving up before chasing again
+	predatorRespawn   = 20 * TickRate // ticks until a fallen eel is replaced
+	predatorEdgeTurn  = 500.0         // edge distance at which a wandering eel turns back
+	predatorFleeDist  = 450.0         // bots flee from eel heads within this distance
+)
+
+// Predator is a roaming eel.
+type Predator struct {
+	id       uint32 // entity ID (see newEntityID)
+	Segments []Vec2 // head first
+	Angle    float64
+	target   *Snake
+	targetID uint32
+	chase    int // ticks spent on the current target
+	rest     int // ticks until it may chase again
+	wander   int // ticks until the next wander turn
+	heading  float64
+	lo, hi   Vec2 // bounding box of the body, grown by PredatorRadius
+}
+
+type predatorDownEvent struct {
+	Type string `json:"t"` // "predatorDown"
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+	By   string `json:"by,omitempty"` // snake it was chasing
+}
+
+// hunting reports whether the eel is chasing a snake.
+func (pr *Predator) hunting() bool {
+	return pr.target != nil
+}
+
+// updatePredators moves the eels, resolves what they touch and keeps their
+// number at cfg.Predators. Called once per tick from simulate.
+func (g *Game) updatePredators() {
+	for i := 0; i < len(g.predatorDue); i++ {
+		if g.clock >= g.predatorDue[i] {
+			g.predatorDue = append(g.predatorDue[:i], g.predatorDue[i+1:]...)
+			i--
+			g.spawnPredator()
+		}
+	}
+	for len(g.predators)+len(g.predatorDue) < g.cfg.Predators {
+		g.spawnPredator()
+	}
+	for len(g.predators) > g.cfg.Predators {
+		g.predators = g.predators[:len(g.predators)-1]
+	}
+	if len(g.predatorDue) > g.cfg.Predators-len(g.predators) {
+		g.predatorDue = g.predatorDue[:g.cfg.Predators-len(g.predators)]
+	}
+
+	for step := 0; step < g.clockSteps; step++ {
+		for i := 0; i < len(g.predators); i++ {
+			pr := g.predators[i]
+			g.movePredator(pr)
+			if g.outOfBounds(pr.Segments[0]) {
+				g.predatorDown(pr)
+				i--
+				continue
+			}
+			g.predatorBites(pr)
+		}
+	}
+}
+
+func (g *Game) spawnPredator() {
+	pos := g.clearSpawnPos()
+	angle := g.rng.Float64() * 2 * math.Pi
+	pr := &Predator{id: g.newEntityID(), Angle: angle, heading: angle, Segments: []Vec2{pos}}
+	pr.updateBounds()
+	g.predators = append(g.predators, pr)
+	g.log.Info("predator spawned", "entity", pr.id, "x", int(pos.X), "y", int(pos.Y))
+}
+
+// movePredator picks the eel's heading and advances it by one game tick.
+func (g *Game) movePredator(pr *Predator) {
+	head := pr.Segments[0]
+	g.predatorTarget(pr, head)
+	speed := g.cfg.PredatorSpeed
+	want := pr.heading
+	if t := pr.target; t != nil {
+		th := t.Segments[0]
+		want = math.Atan2(th.Y-head.Y, th.X-head.X)
+		if sim.DistSq(head.X, head.Y, th.X, th.Y) < predatorLungeDist*predatorLungeDist {
+			speed *= predatorLunge
+		}
+	} else {
+		pr.wander--
+		if pr.wander <= 0 {
+			pr.heading += (g.rng.Float64() - 0.5) * 1.5
+			pr.wander = TickRate + g.rng.Intn(2*TickRate)
+		}
+		if g.arena.EdgeDist(head) < predatorEdgeTurn {
+			pr.heading = g.angleToCenter(head)
+		}
+		want = pr.heading
+	}
+	pr.Angle += sim.Clamp(sim.AngleDiff(pr.Angle, want), -predatorTurn, predatorTurn)
+	next := Vec2{X: head.X + math.Cos(pr.Angle)*speed, Y: head.Y + math.Sin(pr.Angle)*speed}
+	pr.Segments = append(pr.Segments, Vec2{})
+	copy(pr.Segments[1:], pr.Segments)
+	pr.Segments[0] = next
+	if len(pr.Segments) > PredatorLen {
+		pr.Segments = pr.Segments[:PredatorLen]
+	}
+	pr.updateBounds()
+}
+
+// predatorTarget drops a target that died, escaped or was chased too long,
+// and picks the nearest unprotected snake in sight when there is none.
+func (g *Game) predatorTarget(pr *Predator, head Vec2) {
+	if t := pr.target; t != nil {
+		pr.chase++
+		th := t.Segments[0]
+		if !t.Alive || t.id != pr.targetID || pr.chase > predatorGiveUp ||
+			sim.DistSq(head.X, head.Y, th.X, th.Y) > 1.5*PredatorSight*1.5*PredatorSight {
+			pr.target, pr.chase, pr.rest = nil, 0, predatorRest
+			pr.heading = pr.Angle
+		}
+		return
+	}
+	if pr.rest > 0 {
+		pr.rest--
+		return
+	}
+	best := PredatorSight * PredatorSight
+	for _, s := range g.snakes {
+		if !s.Alive || len(s.Segments) == 0 || s.InvTimer > 0 {
+			continue
+		}
+		h := s.Segments[0]
+		if d := sim.DistSq(head.X, head.Y, h.X, h.Y); d < best {
+			best, pr.target, pr.targetID = d, s, s.id
+		}
+	}
+}
+
+// predatorBites kills every snake touching pr.
+func (g *Game) predatorBites(pr *Predator) {
+	head := pr.Segments[0]
+	for _, s := range g.snakes {
+		if !s.Alive || len(s.Segments) == 0 || s.InvTimer > 0 {
+			continue
+		}
+		if g.headHitsPredator(s, pr) || g.predatorHitsBody(head, s) {
+			g.killSnake(s, nil, "predator")
+			g.hookKill(s, nil)
+			g.reportDeath(s)
+		}
+	}
+}
+
+func (g *Game) headHitsPredator(s *Snake, pr *Predator) bool {
+	h := s.Segments[0]
+	r := g.headRadius(s)
+	if h.X < pr.lo.X-r || h.X > pr.hi.X+r || h.Y < pr.lo.Y-r || h.Y > pr.hi.Y+r {
+		return false
+	}
+	r += PredatorRadius
+	for _, p := range pr.Segments {
+		if sim.DistSq(h.X, h.Y, p.X, p.Y) < r*r {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Game) predatorHitsBody(head Vec2, s *Snake) bool {
+	r := PredatorRadius + g.bodyRadius(s)
+	for _, p := range s.Segments {
+		if sim.DistSq(head.X, head.Y, p.X, p.Y) < r*r {
+			return true
+		}
+	}
+	return false
+}
+
+// predatorDown removes an eel that hit the boundary, drops its body as big
+// pellets and schedules a replacement.
+func (g *Game) predatorDown(pr *Predator) {
+	for i, p := range g.predators {
+		if p == pr {
+			g.predators = append(g.predators[:i], g.predators[i+1:]...)
+			break
+		}
+	}
+	step := max(len(pr.Segments)/PredatorFood, 1)
+	for i := 0; i < len(pr.Segments); i += step {
+		seg := pr.Segments[i]
+		if g.outOfBounds(seg) {
+			continue
+		}
+		g.addFood(&Food{
+			X: seg.X + g.rng.Float64()*40 - 20, Y: seg.Y + g.rng.Float64()*40 - 20,
+			ColorIdx: g.rng.Intn(NumFoodColors),
+			Radius:   10 + g.rng.Float64()*4,
+			Value:    5 + g.rng.Float64()*5,
+		})
+	}
+	g.predatorDue = append(g.predatorDue, g.clock+predatorRespawn)
+	g.predatorsDown++
+
+	head := pr.Segments[0]
+	ev := predatorDownEvent{Type: "predatorDown", X: int(head.X), Y: int(head.Y)}
+	if t := pr.target; t != nil && t.Alive && t.id == pr.targetID {
+		ev.By = t.Name
+	}
+	g.log.Info("predator hit the boundary", "entity", pr.id, "x", ev.X, "y", ev.Y, "lured", ev.By)
+	g.logEvent("predator", "x", ev.X, "y", ev.Y, "lured", ev.By)
+	g.announce(ev)
+}
+
+func (pr *Predator) updateBounds() {
+	lo, hi := pr.Segments[0], pr.Segments[0]
+	for _, p := range pr.Segments[1:] {
+		lo.X, lo.Y = math.Min(lo.X, p.X), math.Min(lo.Y, p.Y)
+		hi.X, hi.Y = math.Max(hi.X, p.X), math.Max(hi.Y, p.Y)
+	}
+	pr.lo = Vec2{X: lo.X - PredatorRadius, Y: lo.Y - PredatorRadius}
+	pr.hi = Vec2{X: hi.X + PredatorRadius, Y: hi.Y + PredatorRadius}
+}
+
+// near reports whether p is within d of pr's bounding box.
+func (pr *Predator) near(p Vec2, d float64) bool {
+	return p.X > pr.lo.X-d && p.X < pr.hi.X+d && p.Y > pr.lo.Y-d && p.Y < pr.hi.Y+d
+}
+
+// predatorHazards appends the eels' bodies, with some room to spare, to h
+// for steering bot paths around them.
+func (g *Game) predatorHazards(h []sim.Hazard) []sim.Hazard {
+	for _, pr := range g.predators {
+		h = append(h, sim.Hazard{Body: pr.Segments, Radius: PredatorRadius + 30, Lo: pr.lo, Hi: pr.hi})
+	}
+	return h
+}
+
+// avoidPredators turns a bot away from an eel head that is close, boosting
+// if it can. It reports whether it took over the heading.
+func (g *Game) avoidPredators(s *Snake, head Vec2) bool {
+	for _, pr := range g.predators {
+		ph := pr.Segments[0]
+		if sim.DistSq(head.X, head.Y, ph.X, ph.Y) < predatorFleeDist*predatorFleeDist {
+			s.TargetAngle = math.Atan2(head.Y-ph.Y, head.X-ph.X)
+			s.IsBoosting = s.Boost > sim.SteerBoostMin
+			return true
+		}
+	}
+	return false
+}
+
+// visiblePredators returns the eels within view of (cx, cy), or all of them
+// for a full view.
+func (g *Game) visiblePredators(cx, cy, scale float64, full bool) []*Predator {
+	var out []*Predator
+	d := ViewDist / scale
+	for _, pr := range g.predators {
+		if full || pr.near(Vec2{X: cx, Y: cy}, d) {
+			out = append(out, pr)
+		}
+	}
+	return out
+}
+
+// appendPredators appends the v10 predator section: count(uvarint), per
+// predator id(uvarint), flags(uint8: bit0=hunting), angle(uint8),
+// segCount(uvarint), head x, y (uint16 BE) and int8 deltas for every 3rd
+// body point after it.
+func appendPredators(b []byte, prs []*Predator) []byte {
+	b = binary.AppendUvarint(b, uint64(len(prs)))
+	for _, pr := range prs {
+		b = binary.AppendUvarint(b, uint64(pr.id))
+		var flags byte
+		if pr.hunting() {
+			flags |= 1
+		}
+		a := math.Mod(pr.Angle, 2*math.Pi)
+		if a < 0 {
+			a += 2 * math.Pi
+		}
+		b = append(b, flags, byte(int(math.Round(a/(2*math.Pi)*256))&255))
+		segCount := (len(pr.Segments) + 2) / 3
+		b = binary.AppendUvarint(b, uint64(segCount))
+		var px, py int
+		for k := 0; k < segCount; k++ {
+			x := clampInt(int(math.Round(pr.Segments[k*3].X)), 0, 65535)
+			y := clampInt(int(math.Round(pr.Segments[k*3].Y)), 0, 65535)
+			if k == 0 {
+				b = binary.BigEndian.AppendUint16(b, uint16(x))
+				b = binary.BigEndian.AppendUint16(b, uint16(y))
+				px, py = x, y
+				continue
+			}
+			dx := clampInt(x-px, -128, 127)
+			dy := clampInt(y-py, -128, 127)
+			b = append(b, byte(int8(dx)), byte(int8(dy)))
+			px += dx
+			py += dy
+		}
+	}
+	return b
+}
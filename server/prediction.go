@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// ---------------------------------------------------------------------------
+// Input acknowledgement for client-side prediction (protocol v7)
+//
+// Clients may append a sequence number to their input messages (6 bytes
+// instead of 4, see readPump). The game loop remembers the last one it
+// applied, and every v7 and protobuf frame echoes it together with the
+// authoritative head position and heading of the player's own snake after
+// that input was simulated. A predicting client steers its snake locally,
+// keeps the inputs the server hasn't acknowledged yet, and on each frame
+// resets to the acknowledged pose and replays the rest, instead of waiting
+// a full round trip for every turn.
+// ---------------------------------------------------------------------------
+
+// ownPose returns the head position and heading of p's snake, or false
+// while it is dead.
+func (p *Player) ownPose() (x, y, angle float64, ok bool) {
+	s := p.snake
+	if s == nil || !s.Alive || len(s.Segments) == 0 {
+		return 0, 0, 0, false
+	}
+	return s.Segments[0].X, s.Segments[0].Y, s.Angle, true
+}
+
+// appendInputAck appends the v7 acknowledgement block for p:
+// flags(uint8: bit0=hasAck, bit1=hasPose), [if hasAck: seq(uint16 BE)],
+// [if hasPose: headX, headY, angle (float32 BE each)].
+func appendInputAck(b []byte, p *Player) []byte {
+	i := len(b)
+	b = append(b, 0)
+	if p.hasInputSeq {
+		b[i] |= 1
+		b = binary.BigEndian.AppendUint16(b, p.inputSeq)
+	}
+	if x, y, angle, ok := p.ownPose(); ok {
+		b[i] |= 2
+		b = binary.BigEndian.AppendUint32(b, math.Float32bits(float32(x)))
+		b = binary.BigEndian.AppendUint32(b, math.Float32bits(float32(y)))
+		b = binary.BigEndian.AppendUint32(b, math.Float32bits(float32(angle)))
+	}
+	return b
+}
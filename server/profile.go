@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Tick stage profile
+//
+// tick and simulate time their stages with lap into g.stages, which is reset
+// every tick. The last 60 ticks are kept for the per-stage averages and
+// maxima in the stats, and -benchmark adds them up over the whole run.
+// ---------------------------------------------------------------------------
+
+type tickStage int
+
+const (
+	stageDrain     tickStage = iota // queued joins, inputs and requests
+	stageAI                         // packs, AI decisions and AI respawns
+	stageMove                       // the physics step: bot steering, movement, eating, snake collisions
+	stageFood                       // decay, food expiry and refill
+	stageCollide                    // kills and the rest of the step's events, boost, trail collisions
+	stageBroadcast                  // serializing and queueing state frames
+	numStages
+)
+
+var stageNames = [numStages]string{"drain", "ai", "movement", "food", "collisions", "broadcast"}
+
+// stageTimes holds the time spent in each stage.
+type stageTimes [numStages]time.Duration
+
+// StageTiming is one stage's share of the tick in StatsSnapshot.
+type StageTiming struct {
+	Stage string  `json:"stage"`
+	AvgMs float64 `json:"avgMs"`
+	MaxMs float64 `json:"maxMs"`
+}
+
+// lapStart returns the start of the first lap.
+func (g *Game) lapStart() time.Time {
+	return time.Now()
+}
+
+// lap adds the time since t to stage and returns the start of the next lap.
+func (g *Game) lap(stage tickStage, t time.Time) time.Time {
+	now := time.Now()
+	g.stages[stage] += now.Sub(t)
+	return now
+}
+
+// recordStages stores the finished tick's stage times next to its duration
+// in the tickDurations ring. Called once per tick, before tickDurIdx moves.
+func (g *Game) recordStages() {
+	g.stageDurations[g.tickDurIdx%len(g.stageDurations)] = g.stages
+	g.stages = stageTimes{}
+}
+
+// stageStats returns the average and maximum time of every stage over the
+// last 60 ticks.
+func (g *Game) stageStats() []StageTiming {
+	ticks := min(g.tickDurIdx, len(g.stageDurations))
+	out := make([]StageTiming, numStages)
+	for i := range out {
+		var sum, peak time.Duration
+		for _, st := range g.stageDurations[:ticks] {
+			sum += st[i]
+			peak = max(peak, st[i])
+		}
+		out[i].Stage = stageNames[i]
+		if ticks > 0 {
+			out[i].AvgMs = math.Round(float64(sum.Nanoseconds())/float64(ticks)/1e3) / 1000
+		}
+		out[i].MaxMs = math.Round(float64(peak.Nanoseconds())/1e3) / 1000
+	}
+	return out
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// ---------------------------------------------------------------------------
+// Binary protocol v2 (negotiated: welcome advertises "pv", join sends "proto")
+//
+// Same sections and flags as v1, roughly half the size: varints, a
+// per-connection name string table, quantized angles and minimap positions,
+// int8 segment deltas and compact food entries. Food is synced by ID as
+// keyframes plus deltas (see fooddelta.go).
+//
+// Header: type(1)=5, flags(1)
+//   flags: bit0=hasFood, bit1=hasSummary, bit2=hasTrails, bit3=hasRound,
+//          bit4=hasNames, bit5=resetNames, bit6=hasFoodDelta, bit7=hasMotion
+// If hasNames: newCount(uvarint), per name: len(uvarint), bytes[len]
+//   New names take the next indices of the client's table; resetNames
+//   clears the table first.
+// snakeCount(uvarint)
+// Per snake:
+//   id(zigzag varint), flags(uint8, as v1),
+//   [if hasMeta: nameIdx(uvarint), colorIdx(uint8)],
+//   score(uvarint), angle(uint8, 256 steps per turn), boost(uint8),
+//   targetLen(uvarint), invTimer(uint8),
+//   [if hasMotion: speed*16(uint8, units/tick), turn*512(int8, rad/tick)],
+//   segCount(uvarint),
+//   [if segCount > 0: headX(uint16 BE), headY(uint16 BE),
+//    (segCount-1) × dx(int8), dy(int8) relative to the previous point]
+//   Segments are every segmentStride()-th, as in v1. Motion is the head's speed and
+//   heading change over the last 1/TickRate s tick, for extrapolation.
+// If hasFood (keyframe, replaces the client's food list):
+//   count(uvarint), count × food entry
+//   Food entry: id(uvarint), x(uint16 BE), y(uint16 BE),
+//     packed(uint8: bits0-3=colorIdx, bit7=customSize),
+//     [if customSize: radius*10(uint8), value*10(uint8)]
+// If hasFoodDelta: removedCount(uvarint), removedCount × id(uvarint),
+//   addedCount(uvarint), addedCount × food entry
+// If hasTrails: count(uvarint), per point 6 bytes as v1
+// If hasSummary: count(uvarint), per alive snake: id(zigzag varint),
+//   headX(uint8), headY(uint8) (world/256 units), score(uvarint),
+//   colorIdx(uint8), nameIdx(uvarint),
+//   then heatmapSize(uint8, 0 = none), heatmapSize² cells (see heatmap.go)
+// If hasRound: 5 bytes as v1
+//
+// Protocol v3 is v2 with two more metadata bytes per snake, after colorIdx:
+// level(uint8, 0 for AI) and skin(uint8) (see xp.go).
+//
+// Protocol v4 is v3 with timing for interpolation buffers right after the
+// flags byte: tick(uvarint, game frame at TickRate per second) and
+// serverTimeMs(uint32 BE, the send time on the clock of ping frames, see
+// latency.go).
+//
+// Protocol v5 is v4 plus section frames (type 7), which carry food and
+// summary split off frames over the frame budget (see framebudget.go).
+//
+// Protocol v6 is v5 with entity IDs (see newEntityID) instead of player IDs
+// in the id fields of snakes and summary entries. A respawned snake is a new
+// entity, so the client can't find its own snake by player ID any more:
+// snake flag bit5 marks it.
+//
+// Protocol v7 is v6 with an input acknowledgement right after the timing
+// fields: ackFlags(uint8: bit0=hasAck, bit1=hasPose), [if hasAck:
+// seq(uint16 BE), the last input sequence number applied], [if hasPose:
+// headX, headY, angle (float32 BE each), the own snake after that input].
+// See prediction.go.
+//
+// Protocol v8 is v7 with segment culling (see cull.go). segCount still
+// counts every sampled point, but points far from the camera may be left
+// out: a dx byte of -128 (0x80) starts a culled run and is followed by
+// skip(uvarint), the number of points left out, instead of dy. The point
+// after a run, if any, is absolute: x(uint16 BE), y(uint16 BE). Other dx
+// values stay in -127..127.
+//
+// Protocol v9 is v8 with a camera zoom hint right after the input
+// acknowledgement: scale(uint8), the recommended zoom times 100, where 100
+// is the default view and smaller values show more of the world. The food
+// view and the cull box grow by the inverse of the scale. See zoom.go.
+//
+// Protocol v10 is v9 with a predator section right after the zoom hint:
+// count(uvarint), per predator in view: id(uvarint, entity ID),
+// flags(uint8: bit0=hunting), angle(uint8, as for snakes),
+// segCount(uvarint), headX(uint16 BE), headY(uint16 BE),
+// (segCount-1) × dx(int8), dy(int8). Points are every 3rd, as for snakes;
+// the body radius is in the init event. See predator.go.
+//
+// Protocol v11 is v10 with a glow record per snake right after the motion
+// bytes: glow(uint8, 255 = full), and if glow > 0, count(uint8) and count ×
+// dx(int8), dy(int8), the recent head positions newest first, each
+// relative to the one before and the first relative to the head. See
+// glow.go.
+//
+// Protocol v12 is v11 with food aging: bit6 of a food entry's packed byte
+// marks a pellet that is going to despawn, and is followed by life(uint8),
+// the tenths of a second it has left (after the size bytes, if any).
+// Clients fade such a pellet out over its last two seconds. See foodage.go.
+//
+// Protocol v13 is v12 with portal jumps in snake bodies: an empty culled
+// run (dx byte 0x80, skip 0) before a point marks the first point past a
+// jump, which follows absolute as after any culled run and is not joined
+// to the point before it. v8 to v12 frames leave that point out instead.
+// See portal.go.
+// ---------------------------------------------------------------------------
+
+const (
+	ProtocolV1  = 1
+	ProtocolV2  = 2
+	ProtocolV3  = 3
+	ProtocolV4  = 4
+	ProtocolV5  = 5
+	ProtocolV6  = 6
+	ProtocolV7  = 7
+	ProtocolV8  = 8
+	ProtocolV9  = 9
+	ProtocolV10 = 10
+	ProtocolV11 = 11
+	ProtocolV12 = 12
+	ProtocolV13 = 13
+	MaxProtocol = ProtocolV13
+
+	maxNameTable = 1024 // names per connection before the table is reset
+)
+
+// nameTable maps names to the indices a v2 client has been sent.
+type nameTable struct {
+	idx  map[string]int
+	list []string
+}
+
+func newNameTable() *nameTable {
+	return &nameTable{idx: make(map[string]int)}
+}
+
+// v2Frame accumulates one v2 state frame for a player.
+type v2Frame struct {
+	names    *nameTable
+	newNames []string
+	buf      []byte
+	life     func(*Food) int // v12: remaining food life, -1 = not sent (see foodage.go)
+}
+
+func (f *v2Frame) nameRef(name string) int {
+	if i, ok := f.names.idx[name]; ok {
+		return i
+	}
+	i := len(f.names.list)
+	f.names.idx[name] = i
+	f.names.list = append(f.names.list, name)
+	f.newNames = append(f.newNames, name)
+	return i
+}
+
+func (f *v2Frame) uvarint(v int) {
+	if v < 0 {
+		v = 0
+	}
+	f.buf = binary.AppendUvarint(f.buf, uint64(v))
+}
+
+func (f *v2Frame) u16(v int) {
+	f.buf = binary.BigEndian.AppendUint16(f.buf, uint16(clampInt(v, 0, 65535)))
+}
+
+func (f *v2Frame) food(fd *Food) {
+	f.buf = binary.AppendUvarint(f.buf, uint64(fd.ID))
+	f.u16(int(math.Round(fd.X)))
+	f.u16(int(math.Round(fd.Y)))
+	packed := byte(fd.ColorIdx & 15)
+	custom := fd.Radius != FoodRadiusVal || fd.Value != FoodValueVal
+	if custom {
+		packed |= 128
+	}
+	life := -1
+	if f.life != nil {
+		life = f.life(fd)
+	}
+	if life >= 0 {
+		packed |= 64
+	}
+	f.buf = append(f.buf, packed)
+	if custom {
+		f.buf = append(f.buf,
+			byte(clampInt(int(math.Round(fd.Radius*10)), 0, 255)),
+			byte(clampInt(int(math.Round(fd.Value*10)), 0, 255)))
+	}
+	if life >= 0 {
+		f.buf = append(f.buf, byte(life))
+	}
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// nameTableMark records a player's name table so it can be restored if the
+// frame that extended it is dropped.
+type nameTableMark struct {
+	table *nameTable
+	n     int
+}
+
+func (p *Player) markNames() nameTableMark {
+	return nameTableMark{table: p.names, n: len(p.names.list)}
+}
+
+func (p *Player) rollbackNames(m nameTableMark) {
+	if p.names == m.table {
+		for _, name := range p.names.list[m.n:] {
+			delete(p.names.idx, name)
+		}
+		p.names.list = p.names.list[:m.n]
+	}
+	p.names = m.table
+}
+
+// serializeStateV2For builds a v2 (or later) state frame for p, including the
+// global summary (with heatmap, if any) and round sections when requested.
+// With a budget (v5 only), a frame larger than budget bytes comes back
+// without food and summary, which follow as section frames (see
+// framebudget.go).
+func (g *Game) serializeStateV2For(p *Player, version int, includeFood, includeSummary bool, sh *frameShared, heatmap []byte, budget int) ([]byte, []sectionFrame) {
+	vis := g.visibleFor(p, true)
+
+	flags := byte(128) // hasMotion
+	reset := false
+	if len(p.names.list) >= maxNameTable {
+		p.names = newNameTable()
+		reset = true
+		flags |= 32
+		// Indices are about to be reused; resend all metadata.
+		for i := range vis.hasMeta {
+			vis.hasMeta[i] = true
+		}
+	}
+	f := &v2Frame{names: p.names, buf: make([]byte, 0, 256+len(vis.snakes)*64)}
+	if version >= ProtocolV12 {
+		f.life = g.foodLife
+	}
+
+	// Snakes
+	f.uvarint(len(vis.snakes))
+	stride := g.segmentStride()
+	for i, s := range vis.snakes {
+		f.buf = binary.AppendVarint(f.buf, wireID(s, version))
+		sf := snakeFlags(s)
+		if vis.hasMeta[i] {
+			sf |= 8
+		}
+		if version >= ProtocolV6 && s == p.snake {
+			sf |= 32
+		}
+		f.buf = append(f.buf, sf)
+		if vis.hasMeta[i] {
+			f.uvarint(f.nameRef(s.Name))
+			f.buf = append(f.buf, byte(s.ColorIdx))
+			if version >= ProtocolV3 {
+				f.buf = append(f.buf, byte(s.level), byte(s.skin))
+			}
+		}
+		f.uvarint(s.Score)
+		a := math.Mod(s.Angle, 2*math.Pi)
+		if a < 0 {
+			a += 2 * math.Pi
+		}
+		f.buf = append(f.buf, byte(int(math.Round(a/(2*math.Pi)*256))&255))
+		f.buf = append(f.buf, byte(clampInt(int(math.Round(s.Boost)), 0, 255)))
+		f.uvarint(s.TargetLen)
+		f.buf = append(f.buf, byte(clampInt(s.InvTimer, 0, 255)))
+		f.buf = append(f.buf,
+			byte(clampInt(int(math.Round(g.headSpeed(s)*16)), 0, 255)),
+			byte(int8(clampInt(int(math.Round(s.turnRate*512)), -128, 127))))
+		if version >= ProtocolV11 {
+			f.buf = appendGlow(f.buf, s)
+		}
+
+		segCount := (len(s.Segments) + stride - 1) / stride
+		f.uvarint(segCount)
+		cull := segmentCuller{segs: s.Segments, stride: stride, cx: vis.cx, cy: vis.cy, dist: SegmentViewDist * vis.view / vis.scale, off: version < ProtocolV8 || vis.full}
+		// A portal jump (see portal.go): marked from v13, the point after it
+		// left out from v8
+		brk := portalBreak(s, stride)
+		switch {
+		case version >= ProtocolV13:
+		case version >= ProtocolV8:
+			cull.drop, brk = brk, 0
+		default:
+			brk = 0
+		}
+		minDelta := -128
+		if version >= ProtocolV8 {
+			minDelta = -127 // -128 marks a culled run
+		}
+		var px, py int
+		for k := 0; k < segCount; k++ {
+			if !cull.keep(k) {
+				run := 1
+				for k+run < segCount && !cull.keep(k+run) {
+					run++
+				}
+				f.buf = append(f.buf, 0x80)
+				f.uvarint(run)
+				k += run - 1
+				continue
+			}
+			x := clampInt(int(math.Round(s.Segments[k*stride].X)), 0, 65535)
+			y := clampInt(int(math.Round(s.Segments[k*stride].Y)), 0, 65535)
+			jump := k == brk && k > 0 && cull.keep(k-1)
+			if jump {
+				f.buf = append(f.buf, 0x80, 0) // an empty culled run
+			}
+			if k == 0 || !cull.keep(k-1) || jump {
+				f.u16(x)
+				f.u16(y)
+				px, py = x, y
+				continue
+			}
+			// Deltas are taken from the reconstructed point so rounding and
+			// clamping errors don't accumulate along the body.
+			dx := clampInt(x-px, minDelta, 127)
+			dy := clampInt(y-py, -128, 127)
+			f.buf = append(f.buf, byte(int8(dx)), byte(int8(dy)))
+			px += dx
+			py += dy
+		}
+	}
+
+	// Food: keyframe or delta
+	snakesEnd := len(f.buf)
+	delta := p.syncFood(vis.foods, includeFood)
+	if includeFood {
+		flags |= 1
+		f.uvarint(len(vis.foods))
+		for _, fd := range vis.foods {
+			f.food(fd)
+		}
+	} else if !delta.empty() {
+		flags |= 64
+		f.uvarint(len(delta.removed))
+		for _, id := range delta.removed {
+			f.buf = binary.AppendUvarint(f.buf, uint64(id))
+		}
+		f.uvarint(len(delta.added))
+		for _, fd := range delta.added {
+			f.food(fd)
+		}
+	}
+
+	// Trails
+	foodEnd := len(f.buf)
+	if vis.includeTrails {
+		flags |= 4
+		f.uvarint(len(vis.trails))
+		for _, t := range vis.trails {
+			f.u16(int(math.Round(t.X)))
+			f.u16(int(math.Round(t.Y)))
+			life := 255
+			if g.cfg.TrailLifetime > 0 {
+				life = clampInt(t.TTL*255/g.cfg.TrailLifetime, 0, 255)
+			}
+			f.buf = append(f.buf, byte(t.ColorIdx), byte(life))
+		}
+	}
+
+	// Summary
+	trailsEnd := len(f.buf)
+	if includeSummary {
+		flags |= 2
+		cell := float64(g.cfg.WorldSize) / 256
+		n := 0
+		for _, s := range g.snakes {
+			if s.Alive && len(s.Segments) > 0 {
+				n++
+			}
+		}
+		f.uvarint(n)
+		for _, s := range g.snakes {
+			if !s.Alive || len(s.Segments) == 0 {
+				continue
+			}
+			f.buf = binary.AppendVarint(f.buf, wireID(s, version))
+			f.buf = append(f.buf,
+				byte(clampInt(int(s.Segments[0].X/cell), 0, 255)),
+				byte(clampInt(int(s.Segments[0].Y/cell), 0, 255)))
+			f.uvarint(s.Score)
+			f.buf = append(f.buf, byte(s.ColorIdx))
+			f.uvarint(f.nameRef(s.Name))
+		}
+		if heatmap != nil {
+			f.buf = append(f.buf, byte(g.cfg.HeatmapSize))
+			f.buf = append(f.buf, heatmap...)
+		} else {
+			f.buf = append(f.buf, 0)
+		}
+	}
+
+	summaryEnd := len(f.buf)
+	if sh.round != nil {
+		flags |= 8
+		f.buf = append(f.buf, sh.round...)
+	}
+
+	var sections []sectionFrame
+	if version >= ProtocolV5 && budget > 0 && len(f.buf)+len(f.newNames)*12 > budget {
+		var food []*Food
+		if includeFood {
+			food = vis.foods
+		}
+		sections = splitSections(f.buf[trailsEnd:summaryEnd], food, delta, flags, sh, budget, f.life)
+		body := append(f.buf[:snakesEnd:snakesEnd], f.buf[foodEnd:trailsEnd]...)
+		f.buf = append(body, f.buf[summaryEnd:]...)
+		flags &^= 1 | 2 | 64
+	}
+
+	// Header and new names go in front of the body
+	head := make([]byte, 2, 28+len(f.buf)+len(f.newNames)*12)
+	head[0] = 5
+	if version >= ProtocolV4 {
+		head = binary.AppendUvarint(head, uint64(sh.tick))
+		head = binary.BigEndian.AppendUint32(head, sh.sentMs)
+	}
+	if version >= ProtocolV7 {
+		head = appendInputAck(head, p)
+	}
+	if version >= ProtocolV9 {
+		head = appendCameraScale(head, vis.scale)
+	}
+	if version >= ProtocolV10 {
+		head = appendPredators(head, vis.predators)
+	}
+	if len(f.newNames) > 0 || reset {
+		flags |= 16
+		head = binary.AppendUvarint(head, uint64(len(f.newNames)))
+		for _, name := range f.newNames {
+			head = binary.AppendUvarint(head, uint64(len(name)))
+			head = append(head, name...)
+		}
+	}
+	head[1] = flags
+	return append(head, f.buf...), sections
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"snake-server/wire"
+)
+
+// TestV2RoundTrip runs a world with a v2 player and decodes every frame
+// the player is sent with wire.V2Decoder, as a client would: each must
+// match the world as it was when the frame was sent, with names from the
+// connection's table and food from keyframes and deltas.
+func TestV2RoundTrip(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.WorldSize = 2000
+	cfg.FoodCount = 300
+	cfg.AICount = 12
+	g := NewGame(cfg)
+	id := nextPlayerID()
+	p := &Player{
+		id:          id,
+		name:        "Alice",
+		sendCh:      make(chan []byte, SendBufferSize),
+		textCh:      make(chan []byte, 64),
+		done:        make(chan struct{}),
+		knownSnakes: make(map[uint32]bool),
+		sendEvery:   1,
+		serializer:  serializerV2,
+		names:       newNameTable(),
+	}
+	g.handleJoin(p)
+
+	var d wire.V2Decoder
+	names := make(map[int]string) // what the client knows, by snake ID
+	var frames, deltas, summaries int
+	// The frames sent since the last call, the join's first
+	receive := func() {
+		for len(p.textCh) > 0 {
+			<-p.textCh
+		}
+		for len(p.sendCh) > 0 {
+			data := <-p.sendCh
+			if data[0] != wire.TypeStateV2 {
+				continue
+			}
+			st, err := d.Decode(data)
+			if err != nil {
+				t.Fatalf("frame %d: %v", frames, err)
+			}
+			frames++
+			if data[1]&64 != 0 {
+				deltas++
+			}
+			if st.Summary != nil {
+				summaries++
+			}
+			checkV2Snakes(t, g, st, names)
+			if st.Food != nil {
+				checkV2Food(t, g, p, st)
+			}
+		}
+	}
+	receive()
+	for tick := 0; tick < 10*TickRate; tick++ {
+		g.tick()
+		receive()
+	}
+	if frames < 5*NetTickRate || deltas == 0 || summaries == 0 {
+		t.Fatalf("%d frames, %d with food deltas, %d with a summary", frames, deltas, summaries)
+	}
+}
+
+func checkV2Snakes(t *testing.T, g *Game, st *wire.State, names map[int]string) {
+	t.Helper()
+	byID := make(map[int]*Snake)
+	for _, s := range g.snakes {
+		byID[int(wireID(s, ProtocolV2))] = s
+	}
+	stride := g.segmentStride()
+	for _, ws := range st.Snakes {
+		s := byID[ws.ID]
+		if s == nil {
+			t.Fatalf("snake %d isn't in the world", ws.ID)
+		}
+		if ws.HasMeta() {
+			names[ws.ID] = ws.Name
+		}
+		if names[ws.ID] != s.Name || ws.Score != s.Score || ws.TargetLen != s.TargetLen {
+			t.Fatalf("snake %d: name %q, score %d, target length %d; want %q, %d, %d",
+				ws.ID, names[ws.ID], ws.Score, ws.TargetLen, s.Name, s.Score, s.TargetLen)
+		}
+		if diff := math.Abs(math.Remainder(ws.Angle-s.Angle, 2*math.Pi)); diff > math.Pi/256+1e-9 {
+			t.Fatalf("snake %d: angle %g, want %g", ws.ID, ws.Angle, s.Angle)
+		}
+		if want := (len(s.Segments) + stride - 1) / stride; len(ws.Segments) != want {
+			t.Fatalf("snake %d: %d points, want %d", ws.ID, len(ws.Segments), want)
+		}
+		for k, pt := range ws.Segments {
+			seg := s.Segments[k*stride]
+			if math.Abs(float64(pt.X)-seg.X) > 1 || math.Abs(float64(pt.Y)-seg.Y) > 1 {
+				t.Fatalf("snake %d: point %d at %v, want %v", ws.ID, k, pt, seg)
+			}
+		}
+	}
+}
+
+func checkV2Food(t *testing.T, g *Game, p *Player, st *wire.State) {
+	t.Helper()
+	byID := make(map[uint32]*Food)
+	for _, f := range g.foods {
+		byID[f.ID] = f
+	}
+	if len(st.Food) != len(p.knownFood) {
+		t.Fatalf("client holds %d pellets, server sent %d", len(st.Food), len(p.knownFood))
+	}
+	for _, wf := range st.Food {
+		f := byID[wf.ID]
+		if f == nil || !p.knownFood[wf.ID] {
+			t.Fatalf("pellet %d: in the world %v, sent %v", wf.ID, f != nil, p.knownFood[wf.ID])
+		}
+		if wf.X != int(math.Round(f.X)) || wf.Y != int(math.Round(f.Y)) || wf.ColorIdx != f.ColorIdx {
+			t.Fatalf("pellet %d: %+v, want %+v", wf.ID, wf, *f)
+		}
+	}
+}
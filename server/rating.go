@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+)
+
+// ---------------------------------------------------------------------------
+// Skill rating
+//
+// Every identity has an Elo-style rating, starting at InitialRating. A kill
+// counts as a won game for the killer and a lost one for the victim: both
+// move by RatingK times how unexpected the result was, so killing a
+// stronger player gains more than killing a weaker one. AI snakes are fixed
+// AIRating opponents and aren't rated themselves; deaths without a killer
+// leave ratings alone. Ratings live in the profile, rank
+// /leaderboard?by=rating and, with RatingSpawn, keep players away from much
+// stronger ones when they spawn (see spawnpolicy.go).
+// ---------------------------------------------------------------------------
+
+const (
+	InitialRating  = 1200
+	AIRating       = 1000
+	MinRating      = 100
+	RatingSpawnGap = 200 // rating lead that makes a player one to spawn away from
+)
+
+// rating returns the profile's rating (profiles from before ratings have 0).
+func (p *Profile) rating() int {
+	if p.Rating == 0 {
+		return InitialRating
+	}
+	return p.Rating
+}
+
+// snakeRating returns the rating of s and the profile it belongs to (nil
+// for AI snakes and players without one).
+func (g *Game) snakeRating(s *Snake) (int, *Profile) {
+	if s.IsAI {
+		return AIRating, nil
+	}
+	p, ok := g.players[s.PlayerID]
+	if !ok || p.snake != s {
+		return InitialRating, nil
+	}
+	prof := g.profiles[p.identity]
+	if prof == nil {
+		return InitialRating, nil
+	}
+	return prof.rating(), prof
+}
+
+// rateKill updates the ratings of killer and victim and returns the change
+// of the victim's (zero or negative). Called from reportDeath, before the
+// victim's profile is saved.
+func (g *Game) rateKill(victim, killer *Snake) int {
+	if g.cfg.RatingK <= 0 || victim == killer {
+		return 0
+	}
+	wr, wp := g.snakeRating(killer)
+	lr, lp := g.snakeRating(victim)
+	if wp == nil && lp == nil {
+		return 0
+	}
+	expected := 1 / (1 + math.Pow(10, float64(lr-wr)/400))
+	delta := int(math.Round(g.cfg.RatingK * (1 - expected)))
+	if wp != nil {
+		wp.Rating = wr + delta
+	}
+	if lp == nil {
+		return 0
+	}
+	lp.Rating = max(lr-delta, MinRating)
+	return lp.Rating - lr
+}
+
+// strongerSnakes returns the living player snakes rated RatingSpawnGap or
+// more above p.
+func (g *Game) strongerSnakes(p *Player) []*Snake {
+	own := InitialRating
+	if prof := g.profiles[p.identity]; prof != nil {
+		own = prof.rating()
+	}
+	var strong []*Snake
+	for _, s := range g.snakes {
+		if !s.Alive || s.IsAI || s == p.snake || len(s.Segments) == 0 {
+			continue
+		}
+		if r, _ := g.snakeRating(s); r-own >= RatingSpawnGap {
+			strong = append(strong, s)
+		}
+	}
+	return strong
+}
@@ -0,0 +1,721 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ---------------------------------------------------------------------------
+// Round replays
+//
+// With Replays set (and a DataDir), every tournament round is recorded as a
+// replay in <DataDir>/replays, and the last Replays of them are kept. A
+// replay is the round as a director sees it: every ReplayFrameEvery net
+// ticks a v1 state frame of the whole world with the summary, food in every
+// replayKeyframeEvery-th frame, and the snake a camera would follow (the
+// leader, held for directorMinHold). Like the event log, the game loop only
+// builds the frames; a writer goroutine gzips them into <id>.replay and
+// writes <id>.json with the metadata when the round ends. A round that is
+// cut short (rounds switched off, shutdown) is thrown away.
+//
+//	GET /replays               stored replays, newest first (?limit=N)
+//	GET /replay/{id}           metadata of one replay
+//	GET /replay/{id}/watch     WebSocket that plays it back
+//
+// A watch connection gets a welcome with "replay" (the metadata) and the
+// round's init event, then the frames at the pace they were recorded, so
+// the game page plays it like a director connection (/?replay={id}). The
+// start is set with ?at=<ms> and the pace with ?speed=, and both can be
+// changed while watching with text messages:
+//
+//	{"t":"seek","at":60000}  jump to a time in ms
+//	{"t":"speed","x":2}      play at 0.25 to 8 times the recorded pace
+//	{"t":"pause"}, {"t":"play"}
+//
+// The server answers each with {"t":"replayPos","at","duration","speed",
+// "paused"}, which it also sends once a second, sends a "director" event
+// when the camera moves to another snake, and {"t":"replayEnd"} at the end.
+// Playback runs in the HTTP handler from the file, without the game loop;
+// at most MaxReplayWatchers replays are played at a time, as each one holds
+// its round in memory.
+// ---------------------------------------------------------------------------
+
+const (
+	ReplayFrameEvery    = DirectorSendEvery // net ticks per recorded frame (10 Hz)
+	MaxReplayFrames     = 30 * 60 * 10      // 30 minutes; longer rounds are cut off
+	MaxReplayWatchers   = 8
+	replayKeyframeEvery = 10 // frames per food list
+	replayQueueSize     = 256
+	replayStep          = 20 * time.Millisecond // playback timer
+	minReplaySpeed      = 0.25
+	maxReplaySpeed      = 8
+)
+
+// ReplayMeta describes a stored replay.
+type ReplayMeta struct {
+	ID         string    `json:"id"` // "20260102-150405-r12": start time and round
+	Round      int       `json:"round"`
+	Mode       string    `json:"mode,omitempty"` // rotation mode, "" without a rotation
+	StartedAt  time.Time `json:"startedAt"`
+	EndedAt    time.Time `json:"endedAt"`
+	DurationMs int       `json:"durationMs"`
+	Frames     int       `json:"frames"`
+	WorldSize  int       `json:"worldSize"`
+	Winners    []string  `json:"winners"` // podium, first place first
+	TopScore   int       `json:"topScore"`
+	Bytes      int64     `json:"bytes"` // size of the compressed recording
+}
+
+var replayIDPattern = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}-r[0-9]+$`)
+
+// replayMsg is one message from the game loop to the writer: the start of
+// a recording (init set), a frame, or its end (meta set) or abort.
+type replayMsg struct {
+	id     string
+	init   []byte
+	frame  []byte
+	ms     uint32
+	camera int16
+	meta   *ReplayMeta
+	abort  bool
+}
+
+// replayRecorder records rounds. The take is only used by the game loop.
+type replayRecorder struct {
+	dir     string
+	keep    int
+	ch      chan replayMsg
+	take    *replayTake
+	dropped int
+}
+
+// replayTake is the round being recorded.
+type replayTake struct {
+	id         string
+	round      int
+	startedAt  time.Time
+	startFrame int
+	frames     int
+	camera     *Snake
+	cameraID   uint32
+	cameraAt   int // frame the camera was picked
+}
+
+// EnableReplays starts recording rounds into dir, keeping the newest keep.
+// Must be called before Run.
+func (g *Game) EnableReplays(dir string, keep int) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// Recordings of rounds a crash cut short
+	tmp, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	for _, path := range tmp {
+		os.Remove(path)
+	}
+	rec := &replayRecorder{dir: dir, keep: keep, ch: make(chan replayMsg, replayQueueSize)}
+	g.replays = rec
+	g.writers.Add(1)
+	go func() {
+		defer g.writers.Done()
+		rec.write()
+	}()
+	return nil
+}
+
+// queue hands m to the writer, dropping it if the writer is behind.
+func (r *replayRecorder) queue(m replayMsg) {
+	select {
+	case r.ch <- m:
+	default:
+		r.dropped++
+		if r.dropped&(r.dropped-1) == 0 {
+			slog.Warn("replay queue full, dropping frames", "dropped", r.dropped)
+		}
+	}
+}
+
+// startReplay starts recording the round that just started.
+func (g *Game) startReplay() {
+	r := g.replays
+	if r == nil {
+		return
+	}
+	if r.take != nil {
+		r.queue(replayMsg{id: r.take.id, abort: true})
+	}
+	now := time.Now()
+	r.take = &replayTake{
+		id:    fmt.Sprintf("%s-r%d", now.UTC().Format("20060102-150405"), g.round.round),
+		round: g.round.round, startedAt: now, startFrame: g.frame,
+	}
+	init, err := json.Marshal(g.initEvent())
+	if err != nil {
+		g.log.Error("failed to encode replay init", "err", err)
+		r.take = nil
+		return
+	}
+	r.queue(replayMsg{id: r.take.id, init: init})
+	g.log.Debug("replay started", "id", r.take.id)
+}
+
+// recordReplay records a frame of the round being recorded. Called every
+// net tick after the broadcast.
+func (g *Game) recordReplay() {
+	r := g.replays
+	if r == nil || r.take == nil {
+		return
+	}
+	t := r.take
+	if !g.roundsEnabled() || g.round.round != t.round || g.round.phase != PhasePlaying {
+		r.queue(replayMsg{id: t.id, abort: true})
+		r.take = nil
+		g.log.Debug("replay dropped, round cut short", "id", t.id)
+		return
+	}
+	if g.netTick%ReplayFrameEvery != 0 || t.frames >= MaxReplayFrames {
+		return
+	}
+	var alive []*Snake
+	for _, s := range g.snakes {
+		if s.Alive && len(s.Segments) > 0 {
+			alive = append(alive, s)
+		}
+	}
+	food := t.frames%replayKeyframeEvery == 0
+	data := serializeState(alive, nil, g.foods, food, g.trails, g.cfg.LaserTail, g.cfg.TrailLifetime,
+		g.segmentStride(), true)
+	if summary := g.buildSummaryBytes(true); len(summary) > 0 {
+		data = append(data, summary...)
+		data[1] |= 2 // flags bit 1 = hasSummary
+	}
+
+	// Follow the leader like the director, without cutting back and forth
+	if c := t.camera; c == nil || !c.Alive || c.id != t.cameraID || g.frame-t.cameraAt >= directorMinHold {
+		if l := g.leader(); l != nil {
+			t.camera, t.cameraID, t.cameraAt = l, l.id, g.frame
+		}
+	}
+	var camera int16
+	if t.camera != nil {
+		camera = int16(t.camera.PlayerID)
+	}
+	ms := uint32((g.frame - t.startFrame) * 1000 / TickRate)
+	r.queue(replayMsg{id: t.id, frame: data, ms: ms, camera: camera})
+	t.frames++
+}
+
+// finishReplay stores the round that just ended. Called from endRound.
+func (g *Game) finishReplay(res RoundResults) {
+	r := g.replays
+	if r == nil || r.take == nil {
+		return
+	}
+	t := r.take
+	r.take = nil
+	meta := &ReplayMeta{
+		ID: t.id, Round: t.round, StartedAt: t.startedAt, EndedAt: res.EndedAt,
+		DurationMs: (g.frame - t.startFrame) * 1000 / TickRate,
+		WorldSize:  g.cfg.WorldSize, Mode: g.modeName(),
+	}
+	for _, e := range res.Podium {
+		meta.Winners = append(meta.Winners, e.Name)
+	}
+	if len(res.Podium) > 0 {
+		meta.TopScore = res.Podium[0].Score
+	}
+	r.queue(replayMsg{id: t.id, meta: meta})
+}
+
+// replayFile is a recording being written.
+type replayFile struct {
+	id     string
+	f      *os.File
+	gz     *gzip.Writer
+	frames int
+}
+
+// write runs the writer until the queue is closed.
+func (r *replayRecorder) write() {
+	var cur *replayFile
+	discard := func() {
+		if cur != nil {
+			cur.f.Close()
+			os.Remove(cur.f.Name())
+			cur = nil
+		}
+	}
+	for m := range r.ch {
+		switch {
+		case m.init != nil:
+			discard()
+			f, err := r.create(m.id, m.init)
+			if err != nil {
+				slog.Error("failed to start replay", "id", m.id, "err", err)
+				continue
+			}
+			cur = f
+		case cur == nil || m.id != cur.id:
+			// the start was dropped or failed
+		case m.abort:
+			discard()
+		case m.meta != nil:
+			if err := r.finish(cur, m.meta); err != nil {
+				slog.Error("failed to store replay", "id", m.id, "err", err)
+				discard()
+				continue
+			}
+			cur = nil
+			r.prune()
+		default:
+			var hdr [10]byte
+			binary.BigEndian.PutUint32(hdr[0:], m.ms)
+			binary.BigEndian.PutUint16(hdr[4:], uint16(m.camera))
+			binary.BigEndian.PutUint32(hdr[6:], uint32(len(m.frame)))
+			cur.gz.Write(hdr[:])
+			if _, err := cur.gz.Write(m.frame); err != nil {
+				slog.Error("replay write failed", "id", m.id, "err", err)
+				discard()
+				continue
+			}
+			cur.frames++
+		}
+	}
+	discard()
+}
+
+// create opens <id>.tmp and writes the init event. The file is a gzip
+// stream of the init event's length (uint32 BE) and JSON, then one record
+// per frame: ms since the start (uint32 BE), camera player ID (int16 BE),
+// frame length (uint32 BE) and the v1 frame.
+func (r *replayRecorder) create(id string, init []byte) (*replayFile, error) {
+	f, err := os.Create(filepath.Join(r.dir, id+".tmp"))
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write(binary.BigEndian.AppendUint32(nil, uint32(len(init))))
+	if _, err := gz.Write(init); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &replayFile{id: id, f: f, gz: gz}, nil
+}
+
+// finish closes a recording and moves it in place next to its metadata.
+func (r *replayRecorder) finish(rf *replayFile, meta *ReplayMeta) error {
+	if err := rf.gz.Close(); err != nil {
+		return err
+	}
+	st, err := rf.f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	meta.Frames, meta.Bytes = rf.frames, st.Size()
+	if err := os.Rename(rf.f.Name(), filepath.Join(r.dir, rf.id+".replay")); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The metadata is what lists the replay, so it goes last
+	tmp := filepath.Join(r.dir, rf.id+".json.tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(r.dir, rf.id+".json")); err != nil {
+		return err
+	}
+	slog.Info("replay stored", "id", rf.id, "frames", rf.frames, "bytes", meta.Bytes)
+	return nil
+}
+
+// prune removes all but the newest keep replays.
+func (r *replayRecorder) prune() {
+	ids := replayIDs(r.dir)
+	for len(ids) > r.keep {
+		id := ids[len(ids)-1]
+		ids = ids[:len(ids)-1]
+		os.Remove(filepath.Join(r.dir, id+".json"))
+		os.Remove(filepath.Join(r.dir, id+".replay"))
+		slog.Debug("replay removed", "id", id)
+	}
+}
+
+// replayIDs returns the IDs of the stored replays in dir, newest first.
+func replayIDs(dir string) []string {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	var ids []string
+	for _, path := range paths {
+		if id := strings.TrimSuffix(filepath.Base(path), ".json"); replayIDPattern.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+	// IDs start with the UTC start time, so they sort by age
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids
+}
+
+// ---------------------------------------------------------------------------
+// Playback
+// ---------------------------------------------------------------------------
+
+// ReplayStore serves the replays in a directory. It is safe for concurrent
+// use; the recorder only ever renames finished files into it.
+type ReplayStore struct {
+	dir      string
+	watchers chan struct{} // one token per replay being played
+}
+
+func NewReplayStore(dir string) *ReplayStore {
+	return &ReplayStore{dir: dir, watchers: make(chan struct{}, MaxReplayWatchers)}
+}
+
+// List returns the metadata of up to limit replays, newest first.
+func (rs *ReplayStore) List(limit int) []ReplayMeta {
+	list := []ReplayMeta{}
+	for _, id := range replayIDs(rs.dir) {
+		if len(list) == limit {
+			break
+		}
+		if m, err := rs.Meta(id); err == nil {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+// Meta returns a replay's metadata, or an error satisfying os.IsNotExist.
+func (rs *ReplayStore) Meta(id string) (ReplayMeta, error) {
+	var m ReplayMeta
+	if !replayIDPattern.MatchString(id) {
+		return m, os.ErrNotExist
+	}
+	data, err := os.ReadFile(filepath.Join(rs.dir, id+".json"))
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// replayFrame is one recorded frame.
+type replayFrame struct {
+	ms     int
+	camera int16
+	data   []byte
+}
+
+func (f replayFrame) hasFood() bool { return f.data[1]&1 != 0 }
+
+// load reads a recording: its init event and frames.
+func (rs *ReplayStore) load(id string) ([]byte, []replayFrame, error) {
+	f, err := os.Open(filepath.Join(rs.dir, id+".replay"))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, nil, err
+	}
+	var n [4]byte
+	if _, err := io.ReadFull(gz, n[:]); err != nil {
+		return nil, nil, err
+	}
+	init := make([]byte, binary.BigEndian.Uint32(n[:]))
+	if _, err := io.ReadFull(gz, init); err != nil {
+		return nil, nil, err
+	}
+	var frames []replayFrame
+	for {
+		var hdr [10]byte
+		if _, err := io.ReadFull(gz, hdr[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		fr := replayFrame{
+			ms:     int(binary.BigEndian.Uint32(hdr[0:])),
+			camera: int16(binary.BigEndian.Uint16(hdr[4:])),
+			data:   make([]byte, binary.BigEndian.Uint32(hdr[6:])),
+		}
+		if _, err := io.ReadFull(gz, fr.data); err != nil {
+			return nil, nil, err
+		}
+		if len(fr.data) < 4 {
+			return nil, nil, errors.New("short frame")
+		}
+		frames = append(frames, fr)
+	}
+	return init, frames, nil
+}
+
+// HandleReplays serves /replays?limit=N.
+func HandleReplays(rs *ReplayStore, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"replays": rs.List(queryLimit(r, 20))})
+}
+
+// HandleReplay serves /replay/{id} and /replay/{id}/watch.
+func HandleReplay(rs *ReplayStore, ac *AccessControl, w http.ResponseWriter, r *http.Request) {
+	id, watch := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/replay/"), "/watch")
+	meta, err := rs.Meta(id)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		slog.Error("failed to read replay", "id", id, "err", err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	if !watch {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(meta)
+		return
+	}
+	watchReplay(rs, ac, meta, w, r)
+}
+
+type replayWelcome struct {
+	Type       string     `json:"t"` // "welcome"
+	PlayerID   int        `json:"pid"`
+	WorldSize  int        `json:"ws"`
+	Version    string     `json:"v"`
+	Protocol   int        `json:"pv"`
+	TickRate   int        `json:"tr"`
+	FrameRate  int        `json:"nr"`
+	Challenges []any      `json:"challenges"`
+	Replay     ReplayMeta `json:"replay"`
+}
+
+type replayPosEvent struct {
+	Type     string  `json:"t"` // "replayPos"
+	At       int     `json:"at"`
+	Duration int     `json:"duration"`
+	Speed    float64 `json:"speed"`
+	Paused   bool    `json:"paused"`
+}
+
+type replayControl struct {
+	Type string  `json:"t"`
+	At   int     `json:"at"`
+	X    float64 `json:"x"`
+}
+
+// watchReplay plays a replay to a WebSocket connection.
+func watchReplay(rs *ReplayStore, ac *AccessControl, meta ReplayMeta, w http.ResponseWriter, r *http.Request) {
+	release, err := ac.Admit(clientAddr(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	defer release()
+	select {
+	case rs.watchers <- struct{}{}:
+		defer func() { <-rs.watchers }()
+	default:
+		http.Error(w, "too many replays playing", http.StatusServiceUnavailable)
+		return
+	}
+	init, frames, err := rs.load(meta.ID)
+	if err != nil {
+		slog.Error("failed to load replay", "id", meta.ID, "err", err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	slog.Debug("replay watcher joined", "id", meta.ID, "remote", r.RemoteAddr)
+
+	q := r.URL.Query()
+	speed := replaySpeed(q.Get("speed"))
+	at := 0.0
+	fmt.Sscan(q.Get("at"), &at)
+
+	send := func(typ int, data []byte) error {
+		conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		return conn.WriteMessage(typ, data)
+	}
+	sendJSON := func(v any) error {
+		data, _ := json.Marshal(v)
+		return send(websocket.TextMessage, data)
+	}
+	welcome := replayWelcome{
+		Type: "welcome", WorldSize: meta.WorldSize, Version: Version, Protocol: 1,
+		TickRate: TickRate, FrameRate: TickRate / NetTickRate / ReplayFrameEvery,
+		Challenges: []any{}, Replay: meta,
+	}
+	if sendJSON(welcome) != nil || send(websocket.TextMessage, init) != nil {
+		return
+	}
+
+	// Control messages come in on their own goroutine; this one writes
+	ctl := make(chan replayControl, 8)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(512)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var c replayControl
+			if json.Unmarshal(data, &c) == nil {
+				select {
+				case ctl <- c:
+				default:
+				}
+			}
+		}
+	}()
+
+	duration := 0
+	if len(frames) > 0 {
+		duration = frames[len(frames)-1].ms
+	}
+	var (
+		pos     int  // next frame to send
+		camera  = -1 // camera sent last
+		paused  bool
+		ended   bool
+		base    = -1 // last frame with food sent
+		last    = time.Now()
+		lastPos time.Time
+	)
+	sendFrame := func(i int) error {
+		f := frames[i]
+		if int(f.camera) != camera {
+			camera = int(f.camera)
+			if err := sendJSON(directorEvent{Type: "director", Camera: camera, Reason: "leader"}); err != nil {
+				return err
+			}
+		}
+		if f.hasFood() {
+			base = i
+		}
+		return send(websocket.BinaryMessage, f.data)
+	}
+	sendPos := func() error {
+		lastPos = time.Now()
+		return sendJSON(replayPosEvent{Type: "replayPos", At: int(at), Duration: duration, Speed: speed, Paused: paused})
+	}
+	// seek moves to the frame at ms and sends the latest food before it
+	seek := func(ms float64) error {
+		at = math.Max(0, math.Min(ms, float64(duration)))
+		pos = sort.Search(len(frames), func(i int) bool { return float64(frames[i].ms) >= at })
+		ended = false
+		for k := min(pos, len(frames)-1); k >= 0; k-- {
+			if frames[k].hasFood() {
+				if k != base {
+					return sendFrame(k)
+				}
+				break
+			}
+		}
+		return nil
+	}
+	if seek(at) != nil || sendPos() != nil {
+		return
+	}
+
+	ticker := time.NewTicker(replayStep)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case c := <-ctl:
+			var err error
+			switch c.Type {
+			case "seek":
+				err = seek(float64(c.At))
+			case "speed":
+				speed = math.Max(minReplaySpeed, math.Min(c.X, maxReplaySpeed))
+			case "pause":
+				paused = true
+			case "play":
+				if ended {
+					err = seek(0)
+				}
+				paused = false
+			default:
+				continue
+			}
+			if err != nil || sendPos() != nil {
+				return
+			}
+		case now := <-ticker.C:
+			if !paused {
+				at += float64(now.Sub(last).Milliseconds()) * speed
+			}
+			last = now
+			// Send the newest due frame, with the newest food list it skipped
+			due := pos
+			for due < len(frames) && float64(frames[due].ms) <= at {
+				due++
+			}
+			if due > pos {
+				newest := due - 1
+				if !frames[newest].hasFood() {
+					for k := newest - 1; k >= pos; k-- {
+						if frames[k].hasFood() {
+							if sendFrame(k) != nil {
+								return
+							}
+							break
+						}
+					}
+				}
+				if sendFrame(newest) != nil {
+					return
+				}
+				pos = due
+			}
+			if pos == len(frames) && !ended {
+				ended, paused, at = true, true, float64(duration)
+				if sendJSON(map[string]string{"t": "replayEnd"}) != nil || sendPos() != nil {
+					return
+				}
+			}
+			if now.Sub(lastPos) >= time.Second {
+				if sendPos() != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// replaySpeed parses a ?speed= value, 1 if it is missing or invalid.
+func replaySpeed(s string) float64 {
+	var x float64
+	if _, err := fmt.Sscan(s, &x); err != nil || x <= 0 {
+		return 1
+	}
+	return math.Max(minReplaySpeed, math.Min(x, maxReplaySpeed))
+}
@@ -0,0 +1,62 @@
+package main
+
+import "math"
+
+// ---------------------------------------------------------------------------
+// Respawn cooldown
+//
+// Respawn requests used to be honored at once, so a player could spawn, run
+// into someone on purpose and spawn again as fast as the requests arrived.
+// With RespawnCooldown, a player whose snake died may respawn only that many
+// game ticks after the death. With SpectateKiller, a player killed by a
+// snake that is still alive also has to watch it on the killer camera (see
+// spectate.go) for spectateKillerTicks first, whatever RespawnCooldown is.
+//
+// The death event tells the client how long the wait is, in milliseconds of
+// real time ("respawnIn"), so the client can count it down. A respawn
+// request that comes too early is refused with a "respawnWait" event that
+// carries the time still left. Both waits follow the game clock, so slow
+// motion makes them longer in real time.
+// ---------------------------------------------------------------------------
+
+const (
+	MaxRespawnCooldown  = 60 * TickRate // ticks
+	spectateKillerTicks = 5 * TickRate
+)
+
+type respawnWaitEvent struct {
+	Type      string `json:"t"`         // "respawnWait"
+	RespawnIn int    `json:"respawnIn"` // ms until a respawn is accepted
+}
+
+// startRespawnCooldown sets when p may respawn after their snake died, with
+// the killer camera on target (nil if there is none), and returns the wait
+// in ms for the death event.
+func (g *Game) startRespawnCooldown(p *Player, target *Snake) int {
+	wait := g.cfg.RespawnCooldown
+	if g.cfg.SpectateKiller && target != nil {
+		wait = max(wait, spectateKillerTicks)
+	}
+	p.respawnAt = g.clock + wait
+	return g.respawnIn(p)
+}
+
+// respawnIn returns the real time in ms until p may respawn, 0 if now.
+func (g *Game) respawnIn(p *Player) int {
+	ticks := p.respawnAt - g.clock
+	if ticks <= 0 {
+		return 0
+	}
+	return int(math.Ceil(float64(ticks) / g.cfg.TimeScale * 1000 / TickRate))
+}
+
+// respawnAllowed reports whether p's cooldown is over, telling p how long
+// it still has to wait if not.
+func (g *Game) respawnAllowed(p *Player) bool {
+	ms := g.respawnIn(p)
+	if ms == 0 {
+		return true
+	}
+	g.sendEvent(p, respawnWaitEvent{Type: "respawnWait", RespawnIn: ms})
+	return false
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// ---------------------------------------------------------------------------
+// Kill rewards
+//
+// A snake that kills another grows by the kill reward, whether the victim
+// ran into its body or its laser trail. KillReward picks the model:
+//
+//	percent      KillRewardPercent of the victim's length (default, 30%)
+//	flat         KillRewardFlat, whatever the victim's size
+//	diminishing  as percent for small victims, but the share falls off as
+//	             the victim grows: a victim KillRewardKnee points long
+//	             yields half the percent reward, and no victim yields more
+//	             than KillRewardPercent × KillRewardKnee
+//
+// The fields are ordinary config fields, so rooms and rotation modes set
+// their own economy in their ruleset, e.g. a flat reward for a battle
+// royale where big snakes shouldn't snowball.
+// ---------------------------------------------------------------------------
+
+const (
+	DefaultKillRewardPercent = 0.3
+	DefaultKillRewardFlat    = 20
+	DefaultKillRewardKnee    = 200
+)
+
+// killReward returns the length a killer gains for victim.
+type killReward func(cfg *GameConfig, victim *Snake) int
+
+func killRewardFor(model string) (killReward, error) {
+	switch model {
+	case "", "percent":
+		return percentReward, nil
+	case "flat":
+		return flatReward, nil
+	case "diminishing":
+		return diminishingReward, nil
+	}
+	return nil, fmt.Errorf("unknown kill reward %q (want percent, flat or diminishing)", model)
+}
+
+func percentReward(cfg *GameConfig, victim *Snake) int {
+	return int(float64(len(victim.Segments)) * cfg.KillRewardPercent)
+}
+
+func flatReward(cfg *GameConfig, victim *Snake) int {
+	return cfg.KillRewardFlat
+}
+
+func diminishingReward(cfg *GameConfig, victim *Snake) int {
+	n, knee := float64(len(victim.Segments)), float64(cfg.KillRewardKnee)
+	return int(math.Round(cfg.KillRewardPercent * n * knee / (knee + n)))
+}
+
+// rewardKill grows killer by the kill reward for victim, if it survived
+// the kill.
+func (g *Game) rewardKill(victim, killer *Snake) {
+	if !killer.Alive {
+		return
+	}
+	reward, _ := killRewardFor(g.cfg.KillReward) // validated with the config
+	g.growSnake(killer, reward(&g.cfg, victim))
+}
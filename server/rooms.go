@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Rooms with custom rulesets
+//
+// Next to the main room the server can host up to MaxRooms extra rooms,
+// created at runtime through the control API (CreateRoom, see grpc.go) or
+// POST /admin/rooms. Each room is a world of its own with its own game loop,
+// and is joined with /ws?room=<id> (the bundled client passes on the room
+// query parameter of the page).
+//
+// A room's ruleset is a JSON object in the format of the config file. Its
+// fields override the server's startup config, so a ruleset can change
+// everything from the world size and arena to the boost model, tournament
+// rounds and a mode rotation of its own. Rulesets are validated as strictly
+// as the startup config, and on top of that every numeric field must be
+// within the bounds in ruleBounds (mode rotation configs too), so a ruleset
+// can't take the whole server down with a giant world or thousands of bots.
+//
+// Settings that belong to the process (storage, event log, cluster,
+// identity, accounts, access control and the admin token) can't be set
+// per room. Extra rooms have no storage: their players aren't ranked and
+// their matches aren't recorded. Rooms live until the server restarts.
+// ---------------------------------------------------------------------------
+
+const DefaultMaxRooms = 4
+
+var roomIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// ruleBound is the range a ruleset may set a numeric config field to.
+type ruleBound struct {
+	field    string
+	min, max float64
+	get      func(*GameConfig) float64
+}
+
+var ruleBounds = []ruleBound{
+	{"worldSize", 1000, 30000, func(c *GameConfig) float64 { return float64(c.WorldSize) }},
+	{"foodCount", 0, 20000, func(c *GameConfig) float64 { return float64(c.FoodCount) }},
+	{"aiCount", 0, 200, func(c *GameConfig) float64 { return float64(c.AICount) }},
+	{"baseSpeed", 0.5, 20, func(c *GameConfig) float64 { return c.BaseSpeed }},
+	{"boostSpeed", 0.5, 30, func(c *GameConfig) float64 { return c.BoostSpeed }},
+	{"turnSpeed", 0.01, 1, func(c *GameConfig) float64 { return c.TurnSpeed }},
+	{"maxBoost", 1, 1000, func(c *GameConfig) float64 { return c.MaxBoost }},
+	{"baseSnakeLen", 1, 500, func(c *GameConfig) float64 { return float64(c.BaseSnakeLen) }},
+	{"killFoodCount", 1, 100, func(c *GameConfig) float64 { return float64(c.KillFoodCount) }},
+	{"aiRespawnTicks", 0, 3600, func(c *GameConfig) float64 { return float64(c.AIRespawnTicks) }},
+	{"respawnCooldown", 0, 10 * TickRate, func(c *GameConfig) float64 { return float64(c.RespawnCooldown) }},
+	{"trailLifetime", 1, 600, func(c *GameConfig) float64 { return float64(c.TrailLifetime) }},
+	{"simRate", TickRate, 4 * TickRate, func(c *GameConfig) float64 { return float64(c.SimRate) }},
+	{"roundDuration", 0, 3600, func(c *GameConfig) float64 { return float64(c.RoundDuration) }},
+	{"bountyBonus", 0, 10000, func(c *GameConfig) float64 { return float64(c.BountyBonus) }},
+	{"streakBonus", 0, 1000, func(c *GameConfig) float64 { return float64(c.StreakBonus) }},
+	{"aiPackSize", 0, 10, func(c *GameConfig) float64 { return float64(c.AIPackSize) }},
+	{"fillBots", 0, 20, func(c *GameConfig) float64 { return float64(c.FillBots) }},
+	{"killRewardPercent", 0, 5, func(c *GameConfig) float64 { return c.KillRewardPercent }},
+	{"killRewardFlat", 0, 10000, func(c *GameConfig) float64 { return float64(c.KillRewardFlat) }},
+}
+
+// checkRuleBounds rejects configs with fields outside their ruleBounds.
+func checkRuleBounds(cfg GameConfig) error {
+	var errs ConfigErrors
+	for _, b := range ruleBounds {
+		if v := b.get(&cfg); v < b.min || v > b.max {
+			errs = append(errs, ConfigError{b.field, fmt.Sprintf("must be in [%g, %g] in a custom room", b.min, b.max)})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkRoomScope rejects rulesets that change process-wide settings.
+func checkRoomScope(base, cfg GameConfig) error {
+	var errs ConfigErrors
+	perProcess := func(field string, changed bool) {
+		if changed {
+			errs = append(errs, ConfigError{field, "can't be set per room"})
+		}
+	}
+	perProcess("dataDir", cfg.DataDir != base.DataDir)
+	perProcess("eventLog", cfg.EventLog != base.EventLog)
+	perProcess("eventLogMaxMB", cfg.EventLogMaxMB != base.EventLogMaxMB)
+	perProcess("eventLogKeep", cfg.EventLogKeep != base.EventLogKeep)
+	perProcess("replays", cfg.Replays != base.Replays)
+	perProcess("chatSnake", cfg.ChatSnake.chat() != base.ChatSnake.chat())
+	perProcess("clusterRedis", cfg.ClusterRedis != base.ClusterRedis)
+	perProcess("instanceId", cfg.InstanceID != base.InstanceID)
+	perProcess("publicUrl", cfg.PublicURL != base.PublicURL)
+	perProcess("identityKey", cfg.IdentityKey != base.IdentityKey)
+	perProcess("identityExpiryDays", cfg.IdentityExpiryDays != base.IdentityExpiryDays)
+	perProcess("oauth", !reflect.DeepEqual(cfg.OAuth, base.OAuth))
+	perProcess("maxConnsPerIp", cfg.MaxConnsPerIP != base.MaxConnsPerIP)
+	perProcess("banFile", cfg.BanFile != base.BanFile)
+	perProcess("allowedOrigins", !reflect.DeepEqual(cfg.AllowedOrigins, base.AllowedOrigins))
+	perProcess("aiScriptDir", cfg.AIScriptDir != base.AIScriptDir)
+	perProcess("adminToken", cfg.AdminToken != base.AdminToken)
+	perProcess("maxRooms", cfg.MaxRooms != base.MaxRooms)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkRoomConfig validates a room's config (see GameConfig.Validate) and
+// checks ruleBounds for it and every mode of its rotation.
+func checkRoomConfig(cfg *GameConfig) ([]ConfigError, error) {
+	clamped, err := cfg.Validate()
+	if err != nil {
+		return clamped, err
+	}
+	if err := checkRuleBounds(*cfg); err != nil {
+		return clamped, err
+	}
+	for _, m := range cfg.Rotation.Modes {
+		next := *cfg
+		m.apply(&next) // checked by Validate
+		if err := checkRuleBounds(next); err != nil {
+			return clamped, ConfigErrors{{"rotation", fmt.Sprintf("mode %q: %v", m.Name, err)}}
+		}
+	}
+	return clamped, nil
+}
+
+// ParseRuleset returns base with the ruleset applied, and the fields
+// Validate clamped. Unknown fields are rejected so typos don't go
+// unnoticed.
+func ParseRuleset(base GameConfig, rules []byte) (GameConfig, []ConfigError, error) {
+	cfg := base
+	cfg.MinLength, cfg.MaxLength = 0, 0 // the size class is the room's own (see sizeclass.go)
+	if len(bytes.TrimSpace(rules)) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(rules))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return base, nil, fmt.Errorf("invalid ruleset: %w", err)
+		}
+	}
+	if err := checkRoomScope(base, cfg); err != nil {
+		return base, nil, err
+	}
+	clamped, err := checkRoomConfig(&cfg)
+	if err != nil {
+		return base, clamped, err
+	}
+	return cfg, clamped, nil
+}
+
+// RoomStatus describes a room of this server for the control API and
+// /admin/rooms.
+type RoomStatus struct {
+	ID        string     `json:"id"`
+	Players   int        `json:"players"`
+	AICount   int        `json:"aiCount"`
+	WorldSize int        `json:"worldSize"`
+	Created   *time.Time `json:"created,omitempty"` // nil for the main room
+
+	MinLength int `json:"minLength,omitempty"` // size class (see sizeclass.go)
+	MaxLength int `json:"maxLength,omitempty"`
+}
+
+type room struct {
+	id      string
+	game    *Game
+	cfg     GameConfig // config the room started with
+	created time.Time
+}
+
+// Rooms is the main room and the extra rooms of the server.
+type Rooms struct {
+	main *Game
+	base GameConfig
+
+	mu      sync.RWMutex
+	extra   map[string]*room
+	tickets map[string]sizeTicket // size class moves by token (see sizeclass.go)
+}
+
+// NewRooms must be called before main runs.
+func NewRooms(main *Game, base GameConfig) *Rooms {
+	rs := &Rooms{main: main, base: base, extra: make(map[string]*room), tickets: make(map[string]sizeTicket)}
+	main.rooms = rs
+	return rs
+}
+
+// Get returns the game of room id; "" is the main room.
+func (rs *Rooms) Get(id string) (*Game, bool) {
+	if id == "" || id == DefaultRoomID {
+		return rs.main, true
+	}
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	r, ok := rs.extra[id]
+	if !ok {
+		return nil, false
+	}
+	return r.game, true
+}
+
+// find returns room id; "" is the main room.
+func (rs *Rooms) find(id string) (*room, bool) {
+	if id == "" || id == DefaultRoomID {
+		return &room{id: DefaultRoomID, game: rs.main, cfg: rs.base}, true
+	}
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	r, ok := rs.extra[id]
+	return r, ok
+}
+
+// List returns the main room followed by the extra rooms by ID.
+func (rs *Rooms) List() []RoomStatus {
+	rs.mu.RLock()
+	extra := make([]*room, 0, len(rs.extra))
+	for _, r := range rs.extra {
+		extra = append(extra, r)
+	}
+	rs.mu.RUnlock()
+	sort.Slice(extra, func(i, j int) bool { return extra[i].id < extra[j].id })
+
+	list := []RoomStatus{roomStatus(&room{id: DefaultRoomID, game: rs.main, cfg: rs.base})}
+	for _, r := range extra {
+		list = append(list, roomStatus(r))
+	}
+	return list
+}
+
+func roomStatus(r *room) RoomStatus {
+	snap := r.game.GetStats()
+	st := RoomStatus{ID: r.id, Players: snap.CurrentPlayers, AICount: snap.AICount, WorldSize: r.game.cfg.WorldSize,
+		MinLength: r.cfg.MinLength, MaxLength: r.cfg.MaxLength}
+	if !r.created.IsZero() {
+		st.Created = &r.created
+	}
+	return st
+}
+
+// Create starts room id with the server's startup config overridden by
+// the ruleset rules and then by apply, if not nil.
+func (rs *Rooms) Create(id string, rules []byte, apply func(*GameConfig)) (RoomStatus, error) {
+	if !roomIDPattern.MatchString(id) {
+		return RoomStatus{}, fmt.Errorf("invalid room ID %q (want 1 to 32 of a-z, 0-9 and -)", id)
+	}
+	cfg, clamped, err := ParseRuleset(rs.base, rules)
+	if err != nil {
+		return RoomStatus{}, err
+	}
+	if apply != nil {
+		apply(&cfg)
+		more, err := checkRoomConfig(&cfg)
+		if err != nil {
+			return RoomStatus{}, err
+		}
+		clamped = append(clamped, more...)
+	}
+	for _, c := range clamped {
+		slog.Warn("room config value clamped", "room", id, "field", c.Field, "reason", c.Reason)
+	}
+
+	rs.mu.Lock()
+	switch {
+	case id == DefaultRoomID || rs.extra[id] != nil:
+		rs.mu.Unlock()
+		return RoomStatus{}, errRoomExists
+	case len(rs.extra) >= rs.base.MaxRooms:
+		rs.mu.Unlock()
+		return RoomStatus{}, errTooManyRooms
+	}
+	g := NewGame(cfg)
+	g.SetRoom(id)
+	g.rooms = rs
+	if cfg.AIScriptDir != "" {
+		if err := g.EnableAIScripts(cfg.AIScriptDir); err != nil {
+			slog.Warn("room without AI scripts", "room", id, "err", err)
+		}
+	}
+	r := &room{id: id, game: g, cfg: cfg, created: time.Now()}
+	rs.extra[id] = r
+	rs.mu.Unlock()
+
+	go g.Run()
+	slog.Info("room created", "room", id, "worldSize", cfg.WorldSize, "arena", cfg.Arena,
+		"ai", cfg.AICount, "food", cfg.FoodCount, "rotation", len(cfg.Rotation.Modes))
+	return roomStatus(r), nil
+}
+
+// SetRoom names the room g runs in its log records. Must be called before
+// Run.
+func (g *Game) SetRoom(id string) {
+	g.roomID = id
+	g.log = slog.With("room", id)
+}
+
+var (
+	errRoomExists   = errors.New("room already exists")
+	errTooManyRooms = errors.New("room limit reached")
+)
+
+// HandleRooms lists the rooms (GET) or creates one (POST {"id", "rules"}).
+func HandleRooms(rs *Rooms, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]any{"rooms": rs.List()})
+
+	case http.MethodPost:
+		var req struct {
+			ID    string          `json:"id"`
+			Rules json.RawMessage `json:"rules"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		info, err := rs.Create(req.ID, req.Rules, nil)
+		var errs ConfigErrors
+		switch {
+		case errors.Is(err, errRoomExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, errTooManyRooms):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		case errors.As(err, &errs):
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"errors": errs})
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(info)
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
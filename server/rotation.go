@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Mode rotation
+//
+// A mode is a named set of config fields (e.g. laser tail on, faster
+// boost) applied over the startup config. The rotation switches modes every
+// Interval seconds, or in tournament mode after every Rounds rounds, and
+// resets the world on each switch. Countdown seconds before a timed switch
+// (or when the last round of a mode starts) the next mode is announced with
+// a "rotation" event. With Vote, connected players pick among up to 3
+// random candidates with /vote or a {"t":"vote","choice":N} message; the
+// most votes win, ties go to the earlier candidate. Without Vote the modes
+// run in order.
+// ---------------------------------------------------------------------------
+
+const MaxVoteCandidates = 3
+
+type RotationConfig struct {
+	Modes     []RotationMode `json:"modes"`     // two or more modes enable the rotation
+	Interval  int            `json:"interval"`  // seconds per mode, 0 = switch after rounds only
+	Rounds    int            `json:"rounds"`    // tournament rounds per mode, 0 = switch by interval
+	Vote      bool           `json:"vote"`      // let players vote for the next mode
+	Countdown int            `json:"countdown"` // seconds the next mode is announced before a timed switch
+}
+
+type RotationMode struct {
+	Name   string          `json:"name"`
+	Config json.RawMessage `json:"config"` // GameConfig fields this mode sets
+}
+
+type VoteMsg struct {
+	PlayerID int
+	Choice   int // 1-based candidate index
+}
+
+type rotationCandidate struct {
+	Name  string `json:"name"`
+	Votes int    `json:"votes"`
+}
+
+// rotationEvent announces the next mode, and the vote standings while a
+// vote is open. Clients count In down themselves.
+type rotationEvent struct {
+	Type       string              `json:"t"` // "rotation"
+	Current    string              `json:"current"`
+	Next       string              `json:"next,omitempty"` // without a vote
+	Candidates []rotationCandidate `json:"candidates,omitempty"`
+	In         int                 `json:"in"` // seconds until the switch
+}
+
+type modeEvent struct {
+	Type string `json:"t"` // "mode"
+	Name string `json:"name"`
+}
+
+type rotation struct {
+	cfg  RotationConfig
+	base GameConfig // startup config the modes are applied over
+
+	current    int
+	modeRound  int   // first tournament round of the current mode
+	candidates []int // mode indexes announced for the next switch, nil = not announced
+	votes      map[int]int
+	switchAt   int  // frame of a timed switch, 0 = after the current round
+	dirty      bool // votes changed since the last rotation event
+}
+
+// validateRotation checks that every mode can be applied to a running
+// world started with cfg.
+func validateRotation(cfg GameConfig) error {
+	rc := cfg.Rotation
+	if len(rc.Modes) == 0 {
+		return nil
+	}
+	if len(rc.Modes) < 2 {
+		return errors.New("a rotation needs at least two modes")
+	}
+	if rc.Interval < 0 || rc.Rounds < 0 || rc.Countdown < 0 {
+		return errors.New("rotation interval, rounds and countdown must not be negative")
+	}
+	if rc.Interval == 0 && (rc.Rounds == 0 || cfg.RoundDuration == 0) {
+		return errors.New("a rotation needs an interval, or rounds in tournament mode")
+	}
+	if rc.Interval > 0 && rc.Countdown >= rc.Interval {
+		return errors.New("rotation countdown must be shorter than the interval")
+	}
+	for _, m := range rc.Modes {
+		if m.Name == "" {
+			return errors.New("every rotation mode needs a name")
+		}
+		next := cfg
+		if err := m.apply(&next); err != nil {
+			return fmt.Errorf("mode %q: %w", m.Name, err)
+		}
+		if err := checkRuntimeConfig(cfg, next); err != nil {
+			return fmt.Errorf("mode %q: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// apply sets the fields of m on cfg.
+func (m RotationMode) apply(cfg *GameConfig) error {
+	if len(m.Config) == 0 {
+		return nil
+	}
+	return json.Unmarshal(m.Config, cfg)
+}
+
+// restore resets the fields m sets to their values in base, leaving the
+// rest of cfg (including runtime changes) alone.
+func (m RotationMode) restore(cfg *GameConfig, base GameConfig) {
+	var keys map[string]json.RawMessage
+	if len(m.Config) == 0 || json.Unmarshal(m.Config, &keys) != nil {
+		return
+	}
+	data, _ := json.Marshal(base)
+	var all map[string]json.RawMessage
+	json.Unmarshal(data, &all)
+	old := make(map[string]json.RawMessage, len(keys))
+	for k := range keys {
+		for name, v := range all {
+			if strings.EqualFold(name, k) {
+				old[name] = v
+			}
+		}
+	}
+	data, _ = json.Marshal(old)
+	json.Unmarshal(data, cfg)
+}
+
+// enableRotation starts the rotation with its first mode. Called from
+// NewGame before the world is built.
+func (g *Game) enableRotation() {
+	if len(g.cfg.Rotation.Modes) < 2 {
+		return
+	}
+	g.rotation = &rotation{cfg: g.cfg.Rotation, base: g.cfg, modeRound: 1}
+	g.rotation.cfg.Modes[0].apply(&g.cfg)
+	g.rotation.scheduleSwitch(g.frame)
+	if g.rotation.cfg.Vote {
+		g.AddCommand(Command{Name: "vote", Usage: "<number or name>", Help: "Vote for the next mode", Run: cmdRotationVote})
+	}
+	g.log.Info("mode rotation", "modes", len(g.cfg.Rotation.Modes), "intervalSec", g.cfg.Rotation.Interval,
+		"rounds", g.cfg.Rotation.Rounds, "vote", g.cfg.Rotation.Vote, "mode", g.modeName())
+}
+
+// rotationByRounds reports whether modes switch after tournament rounds.
+func (g *Game) rotationByRounds() bool {
+	return g.rotation.cfg.Rounds > 0 && g.roundsEnabled()
+}
+
+// scheduleSwitch times the next switch for a mode started at frame.
+func (r *rotation) scheduleSwitch(frame int) {
+	r.switchAt = 0
+	if r.cfg.Interval > 0 {
+		r.switchAt = frame + r.cfg.Interval*TickRate
+	}
+}
+
+// modeName returns the name of the current mode, "" without a rotation.
+func (g *Game) modeName() string {
+	if g.rotation == nil {
+		return ""
+	}
+	return g.rotation.cfg.Modes[g.rotation.current].Name
+}
+
+// updateRotation announces the next mode when its countdown starts, sends
+// vote updates at most once a second and switches timed modes. Called once
+// per tick.
+func (g *Game) updateRotation() {
+	r := g.rotation
+	if r == nil {
+		return
+	}
+	if g.rotationByRounds() {
+		last := g.round.round-r.modeRound+1 >= r.cfg.Rounds
+		if r.candidates == nil && last && g.round.phase == PhasePlaying {
+			g.announceCandidates()
+		}
+	} else if r.switchAt > 0 {
+		if r.candidates == nil && g.frame >= r.switchAt-r.cfg.Countdown*TickRate {
+			g.announceCandidates()
+		}
+		if g.frame >= r.switchAt {
+			g.switchMode()
+			g.resetWorld()
+			return
+		}
+	}
+	if r.dirty && g.frame%TickRate == 0 {
+		r.dirty = false
+		g.announce(g.rotationEvent())
+	}
+}
+
+// rotateAfterRound switches the mode if the round that just ended was the
+// last of the current mode. Called right before the world is reset for the
+// next round.
+func (g *Game) rotateAfterRound() {
+	if g.rotation == nil || !g.rotationByRounds() || g.rotation.candidates == nil {
+		return
+	}
+	g.switchMode()
+	g.rotation.modeRound = g.round.round + 1
+}
+
+// announceCandidates picks the candidates for the next switch and opens the
+// vote.
+func (g *Game) announceCandidates() {
+	r := g.rotation
+	if r.cfg.Vote {
+		r.candidates = make([]int, 0, MaxVoteCandidates)
+		for _, i := range g.rng.Perm(len(r.cfg.Modes)) {
+			if i != r.current && len(r.candidates) < MaxVoteCandidates {
+				r.candidates = append(r.candidates, i)
+			}
+		}
+	} else {
+		r.candidates = []int{(r.current + 1) % len(r.cfg.Modes)}
+	}
+	r.votes = make(map[int]int)
+	r.dirty = false
+	ev := g.rotationEvent()
+	g.log.Info("next mode announced", "current", ev.Current, "next", ev.Next, "candidates", len(ev.Candidates), "inSec", ev.In)
+	g.announce(ev)
+}
+
+// switchMode applies the winning candidate. The caller resets the world.
+func (g *Game) switchMode() {
+	r := g.rotation
+	if r.candidates == nil {
+		g.announceCandidates()
+	}
+	tally := make([]int, len(r.candidates))
+	for _, c := range r.votes {
+		tally[c]++
+	}
+	win := 0
+	for i, n := range tally {
+		if n > tally[win] {
+			win = i
+		}
+	}
+	next := r.candidates[win]
+
+	prev := g.cfg
+	r.cfg.Modes[r.current].restore(&g.cfg, r.base)
+	if err := r.cfg.Modes[next].apply(&g.cfg); err != nil {
+		g.log.Error("failed to apply mode", "mode", r.cfg.Modes[next].Name, "err", err)
+	}
+	g.syncAICount(prev.AICount)
+	g.syncFoodSpawner(prev.FoodSpawn)
+	g.applyMutators()
+	g.log.Info("mode switched", "from", g.modeName(), "to", r.cfg.Modes[next].Name, "votes", tally[win], "voters", len(r.votes))
+	from := g.modeName()
+	r.current = next
+	g.logMode(from, tally[win], len(r.votes))
+	g.logConfig(prev)
+	r.candidates, r.votes, r.dirty = nil, nil, false
+	r.scheduleSwitch(g.frame)
+	g.announce(modeEvent{Type: "mode", Name: g.modeName()})
+	g.announceInit()
+}
+
+// rotationEvent describes the announced switch.
+func (g *Game) rotationEvent() rotationEvent {
+	r := g.rotation
+	ev := rotationEvent{Type: "rotation", Current: g.modeName()}
+	if r.switchAt > 0 && !g.rotationByRounds() {
+		ev.In = (r.switchAt - g.frame + TickRate - 1) / TickRate
+	} else {
+		ev.In = g.roundRemaining()
+		if g.round.phase == PhasePlaying {
+			ev.In += g.cfg.RoundResultsTime
+		}
+	}
+	if !r.cfg.Vote {
+		if len(r.candidates) > 0 {
+			ev.Next = r.cfg.Modes[r.candidates[0]].Name
+		}
+		return ev
+	}
+	ev.Candidates = make([]rotationCandidate, len(r.candidates))
+	for i, m := range r.candidates {
+		ev.Candidates[i].Name = r.cfg.Modes[m].Name
+	}
+	for _, c := range r.votes {
+		ev.Candidates[c].Votes++
+	}
+	return ev
+}
+
+// rotationJoin tells a joining player about an open vote.
+func (g *Game) rotationJoin(p *Player) {
+	if g.rotation != nil && g.rotation.candidates != nil {
+		g.sendEvent(p, g.rotationEvent())
+	}
+}
+
+// castVote records p's vote for candidate choice (1-based) and returns the
+// reply for p.
+func (g *Game) castVote(p *Player, choice int) string {
+	r := g.rotation
+	if r == nil || !r.cfg.Vote || r.candidates == nil {
+		return "There is no vote running"
+	}
+	if choice < 1 || choice > len(r.candidates) {
+		return fmt.Sprintf("Vote with a number from 1 to %d", len(r.candidates))
+	}
+	r.votes[p.id] = choice - 1
+	r.dirty = true
+	return "You voted for " + r.cfg.Modes[r.candidates[choice-1]].Name
+}
+
+func (g *Game) handleVote(msg VoteMsg) {
+	if p, ok := g.players[msg.PlayerID]; ok {
+		g.castVote(p, msg.Choice)
+	}
+}
+
+// cmdRotationVote is /vote while the rotation takes votes: a candidate
+// number or a prefix of its name.
+func cmdRotationVote(g *Game, p *Player, args []string) string {
+	r := g.rotation
+	if len(args) == 0 || r.candidates == nil {
+		return g.castVote(p, 0)
+	}
+	if n, err := strconv.Atoi(args[0]); err == nil {
+		return g.castVote(p, n)
+	}
+	want := strings.ToLower(strings.Join(args, " "))
+	for i, m := range r.candidates {
+		if strings.HasPrefix(strings.ToLower(r.cfg.Modes[m].Name), want) {
+			return g.castVote(p, i+1)
+		}
+	}
+	return g.castVote(p, 0)
+}
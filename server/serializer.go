@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+
+	"snake-server/statepb"
+)
+
+// ---------------------------------------------------------------------------
+// State serializers: one per wire format, chosen per player at join
+//
+// "proto" in the join message selects the format: 1 (default) to 11 for the
+// hand-rolled binary protocols, "protobuf" for statepb.State frames.
+// ---------------------------------------------------------------------------
+
+// Serializer encodes a player's state frame. Encoders run on the game loop
+// goroutine and may update the player's caches (knownSnakes, knownFood,
+// name table); broadcast rolls those back when the frame is dropped.
+type Serializer interface {
+	Name() string
+	// DeltaFood reports whether the format sends food as keyframes plus
+	// deltas (see fooddelta.go) rather than periodic full lists.
+	DeltaFood() bool
+	Encode(g *Game, p *Player, includeFood, includeSummary bool, sh *frameShared) []byte
+}
+
+// frameShared holds the parts of a broadcast common to every player.
+type frameShared struct {
+	tick      int       // game frame the broadcast belongs to
+	sentMs    uint32    // send time on the ping clock (see latency.go)
+	round     []byte    // binary round section, nil outside tournament mode
+	summaryV1 [2][]byte // v1 summary section, without and with wideScores, built on first use
+	heatmap   []byte    // heatmap cells, built on first use (see heatmap.go)
+}
+
+func (g *Game) newFrameShared() *frameShared {
+	sh := &frameShared{tick: g.frame, sentMs: serverMillis()}
+	if g.roundsEnabled() && g.round.round > 0 {
+		sh.round = make([]byte, 5)
+		sh.round[0] = byte(g.round.phase)
+		binary.BigEndian.PutUint16(sh.round[1:], uint16(g.round.round))
+		binary.BigEndian.PutUint16(sh.round[3:], uint16(g.roundRemaining()))
+	}
+	return sh
+}
+
+var (
+	serializerV1       Serializer = v1Serializer{}
+	serializerV2       Serializer = v2Serializer{ProtocolV2}
+	serializerV3       Serializer = v2Serializer{ProtocolV3}
+	serializerV4       Serializer = v2Serializer{ProtocolV4}
+	serializerV5       Serializer = v2Serializer{ProtocolV5}
+	serializerV6       Serializer = v2Serializer{ProtocolV6}
+	serializerV7       Serializer = v2Serializer{ProtocolV7}
+	serializerV8       Serializer = v2Serializer{ProtocolV8}
+	serializerV9       Serializer = v2Serializer{ProtocolV9}
+	serializerV10      Serializer = v2Serializer{ProtocolV10}
+	serializerV11      Serializer = v2Serializer{ProtocolV11}
+	serializerV12      Serializer = v2Serializer{ProtocolV12}
+	serializerV13      Serializer = v2Serializer{ProtocolV13}
+	serializerProtobuf Serializer = protobufSerializer{}
+)
+
+// serializerFor maps a join message's "proto" value to a serializer.
+func serializerFor(v any) (Serializer, bool) {
+	switch v := v.(type) {
+	case float64:
+		switch int(v) {
+		case ProtocolV1:
+			return serializerV1, true
+		case ProtocolV2:
+			return serializerV2, true
+		case ProtocolV3:
+			return serializerV3, true
+		case ProtocolV4:
+			return serializerV4, true
+		case ProtocolV5:
+			return serializerV5, true
+		case ProtocolV6:
+			return serializerV6, true
+		case ProtocolV7:
+			return serializerV7, true
+		case ProtocolV8:
+			return serializerV8, true
+		case ProtocolV9:
+			return serializerV9, true
+		case ProtocolV10:
+			return serializerV10, true
+		case ProtocolV11:
+			return serializerV11, true
+		case ProtocolV12:
+			return serializerV12, true
+		case ProtocolV13:
+			return serializerV13, true
+		}
+	case string:
+		if v == "protobuf" {
+			return serializerProtobuf, true
+		}
+	}
+	return nil, false
+}
+
+type v1Serializer struct{}
+
+func (v1Serializer) Name() string    { return "v1" }
+func (v1Serializer) DeltaFood() bool { return false }
+
+func (v1Serializer) Encode(g *Game, p *Player, includeFood, includeSummary bool, sh *frameShared) []byte {
+	data := g.serializeStateFor(p, includeFood)
+
+	// Append global summary and set hasSummary flag (bit 1)
+	if includeSummary {
+		wide := 0
+		if p.wideScores {
+			wide = 1
+		}
+		if sh.summaryV1[wide] == nil {
+			sh.summaryV1[wide] = g.buildSummaryBytes(p.wideScores)
+		}
+		if len(sh.summaryV1[wide]) > 0 {
+			data = append(data, sh.summaryV1[wide]...)
+			data[1] |= 2 // flags bit 1 = hasSummary
+		}
+	}
+	if sh.round != nil {
+		data = append(data, sh.round...)
+		data[1] |= 8 // flags bit 3 = hasRound
+	}
+	// Last, so older clients that stop after the round section still parse
+	if hm := g.heatmapFor(p, includeSummary, sh); hm != nil {
+		data = append(data, byte(g.cfg.HeatmapSize))
+		data = append(data, hm...)
+		data[1] |= 16 // flags bit 4 = hasHeatmap
+	}
+	return data
+}
+
+// v2Serializer also encodes v3 to v11, which only add to v2.
+type v2Serializer struct{ version int }
+
+func (s v2Serializer) Name() string { return "v" + strconv.Itoa(s.version) }
+
+func (v2Serializer) DeltaFood() bool { return true }
+
+func (s v2Serializer) Encode(g *Game, p *Player, includeFood, includeSummary bool, sh *frameShared) []byte {
+	data, _ := g.serializeStateV2For(p, s.version, includeFood, includeSummary, sh, g.heatmapFor(p, includeSummary, sh), 0)
+	return data
+}
+
+func (s v2Serializer) EncodeSplit(g *Game, p *Player, includeFood, includeSummary bool, sh *frameShared, budget int) ([]byte, []sectionFrame) {
+	return g.serializeStateV2For(p, s.version, includeFood, includeSummary, sh, g.heatmapFor(p, includeSummary, sh), budget)
+}
+
+// protobufSerializer sends statepb.State frames prefixed with type byte 6.
+// Names and colors are sent with every snake, so clients need no cache.
+type protobufSerializer struct{}
+
+func (protobufSerializer) Name() string    { return "protobuf" }
+func (protobufSerializer) DeltaFood() bool { return true }
+
+func (protobufSerializer) Encode(g *Game, p *Player, includeFood, includeSummary bool, sh *frameShared) []byte {
+	vis := g.visibleFor(p, true)
+	st := &statepb.State{
+		PlayerId: int32(p.id), HasFood: includeFood, HasSummary: includeSummary,
+		Tick: uint64(sh.tick), ServerTimeMs: sh.sentMs,
+	}
+
+	stride := g.segmentStride()
+	for _, s := range vis.snakes {
+		ps := &statepb.Snake{
+			Id: int32(s.PlayerID), Name: s.Name, ColorIdx: int32(s.ColorIdx),
+			Alive: s.Alive, Boosting: s.IsBoosting, IsPlayer: !s.IsAI, Golden: s.golden,
+			Score: int32(s.Score), Angle: float32(s.Angle), Boost: int32(math.Round(s.Boost)),
+			TargetLen: int32(s.TargetLen), InvTimer: int32(s.InvTimer),
+			Speed: float32(g.headSpeed(s)), TurnRate: float32(s.turnRate), Level: int32(s.level), Skin: int32(s.skin),
+			EntityId: s.id, PortalBreak: uint32(portalBreak(s, stride)),
+			Segments: make([]*statepb.Point, 0, (len(s.Segments)+stride-1)/stride),
+		}
+		for j := 0; j < len(s.Segments); j += stride {
+			ps.Segments = append(ps.Segments, pbPoint(s.Segments[j].X, s.Segments[j].Y))
+		}
+		if s.glow.level > 0 {
+			ps.Glow = float32(s.glow.level)
+			for _, h := range s.afterimage() {
+				ps.Afterimage = append(ps.Afterimage, pbPoint(h.X, h.Y))
+			}
+		}
+		st.Snakes = append(st.Snakes, ps)
+	}
+	delta := p.syncFood(vis.foods, includeFood)
+	if includeFood {
+		for _, f := range vis.foods {
+			st.Foods = append(st.Foods, pbFood(f, g.foodLife(f)))
+		}
+	}
+	for _, f := range delta.added {
+		st.AddedFood = append(st.AddedFood, pbFood(f, g.foodLife(f)))
+	}
+	st.RemovedFood = delta.removed
+	for _, t := range vis.trails {
+		life := float32(1)
+		if g.cfg.TrailLifetime > 0 {
+			life = float32(t.TTL) / float32(g.cfg.TrailLifetime)
+		}
+		st.Trails = append(st.Trails, &statepb.TrailPoint{
+			X: int32(math.Round(t.X)), Y: int32(math.Round(t.Y)), ColorIdx: int32(t.ColorIdx), Life: life,
+		})
+	}
+	if includeSummary {
+		for _, s := range g.snakes {
+			if !s.Alive || len(s.Segments) == 0 {
+				continue
+			}
+			st.Summary = append(st.Summary, &statepb.SummaryEntry{
+				Id: int32(s.PlayerID), Name: s.Name, ColorIdx: int32(s.ColorIdx), Score: int32(s.Score),
+				Head: pbPoint(s.Segments[0].X, s.Segments[0].Y), EntityId: s.id,
+			})
+		}
+	}
+	if p.hasInputSeq {
+		st.InputAck = proto.Uint32(uint32(p.inputSeq))
+	}
+	if x, y, angle, ok := p.ownPose(); ok {
+		st.OwnX, st.OwnY, st.OwnAngle = proto.Float32(float32(x)), proto.Float32(float32(y)), proto.Float32(float32(angle))
+	}
+	st.CameraScale = float32(vis.scale)
+	for _, pr := range vis.predators {
+		pp := &statepb.Predator{Id: pr.id, Angle: float32(pr.Angle), Hunting: pr.hunting(),
+			Segments: make([]*statepb.Point, 0, (len(pr.Segments)+2)/3)}
+		for j := 0; j < len(pr.Segments); j += 3 {
+			pp.Segments = append(pp.Segments, pbPoint(pr.Segments[j].X, pr.Segments[j].Y))
+		}
+		st.Predators = append(st.Predators, pp)
+	}
+	if hm := g.heatmapFor(p, includeSummary, sh); hm != nil {
+		st.Heatmap = &statepb.Heatmap{Size: uint32(g.cfg.HeatmapSize), Cells: hm}
+	}
+	if sh.round != nil {
+		st.Round = &statepb.Round{
+			Phase: statepb.Round_Phase(g.round.phase), Round: int32(g.round.round),
+			RemainingSec: int32(g.roundRemaining()),
+		}
+	}
+
+	data, err := proto.MarshalOptions{}.MarshalAppend([]byte{6}, st)
+	if err != nil {
+		g.log.Error("failed to encode protobuf state", "playerID", p.id, "err", err)
+		return nil
+	}
+	return data
+}
+
+// pbFood converts f, with life from foodLife (-1 = not sent).
+func pbFood(f *Food, life int) *statepb.Food {
+	return &statepb.Food{
+		Id: f.ID, X: int32(math.Round(f.X)), Y: int32(math.Round(f.Y)), ColorIdx: int32(f.ColorIdx),
+		Radius: float32(f.Radius), Value: float32(f.Value), Life: uint32(max(life, 0)),
+	}
+}
+
+func pbPoint(x, y float64) *statepb.Point {
+	return &statepb.Point{X: int32(math.Round(x)), Y: int32(math.Round(y))}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// World sharding
+//
+// With ShardCells N > 1 the world is split into N×N square cells, each with
+// a worker goroutine that runs until the room stops. A snake belongs to the
+// cell its head is in and is handed off to the neighbouring cell when the
+// head crosses a boundary. It is also a ghost in every cell its body can
+// reach, so work done for one cell sees the snakes of the cells around it.
+//
+// The game loop still owns all state. The grid is the broad phase of the
+// physics step (see sim.Broadphase): each worker tests the heads it owns
+// against its ghosts, then sim.Step applies the hits in snake order,
+// exactly as the single-threaded pass would. At serialization time a player's view is
+// stitched together from the cells overlapping the viewport instead of a
+// scan of every snake.
+// ---------------------------------------------------------------------------
+
+const (
+	MaxShardCells = 16
+	shardMargin   = HeadRadius + 6 + 50 // largest head radius plus the collision early-out slack
+)
+
+type shardCell struct {
+	owned  []*Snake // head in this cell, in g.snakes order
+	ghosts []*Snake // bodies that can reach into this cell, in g.snakes order
+	jobs   chan func()
+}
+
+type shardGrid struct {
+	n        int
+	size     float64 // cell side
+	cells    []*shardCell
+	wg       sync.WaitGroup
+	handoffs int64    // snakes that moved to another cell
+	snakes   []*Snake // g.snakes, in the order of the step's snakes
+	hits     [][]int  // collision candidates per snake, by step index
+}
+
+func newShardGrid(n int, worldSize float64) *shardGrid {
+	sg := &shardGrid{n: n, size: worldSize / float64(n)}
+	for i := 0; i < n*n; i++ {
+		c := &shardCell{jobs: make(chan func())}
+		sg.cells = append(sg.cells, c)
+		go func() {
+			for job := range c.jobs {
+				job()
+				sg.wg.Done()
+			}
+		}()
+	}
+	return sg
+}
+
+// close stops the cell workers. The grid must not be used afterwards.
+func (sg *shardGrid) close() {
+	for _, c := range sg.cells {
+		close(c.jobs)
+	}
+}
+
+// cellAt returns the index of the cell containing p.
+func (sg *shardGrid) cellAt(p Vec2) int {
+	x := clampInt(int(p.X/sg.size), 0, sg.n-1)
+	y := clampInt(int(p.Y/sg.size), 0, sg.n-1)
+	return y*sg.n + x
+}
+
+// cellRange returns the cell columns and rows overlapping the square of
+// half-side r around p.
+func (sg *shardGrid) cellRange(p Vec2, r float64) (x0, y0, x1, y1 int) {
+	x0 = clampInt(int((p.X-r)/sg.size), 0, sg.n-1)
+	y0 = clampInt(int((p.Y-r)/sg.size), 0, sg.n-1)
+	x1 = clampInt(int((p.X+r)/sg.size), 0, sg.n-1)
+	y1 = clampInt(int((p.Y+r)/sg.size), 0, sg.n-1)
+	return
+}
+
+// assign hands living snakes to the cells their heads are in. head
+// returns the head of snakes[i] and whether the snake lives.
+func (sg *shardGrid) assign(snakes []*Snake, head func(i int) (Vec2, bool)) {
+	for _, c := range sg.cells {
+		c.owned = c.owned[:0]
+	}
+	for i, s := range snakes {
+		s.order = i
+		h, ok := head(i)
+		if !ok {
+			continue
+		}
+		idx := sg.cellAt(h)
+		if s.cell >= 0 && s.cell != idx {
+			sg.handoffs++
+		}
+		s.cell = idx
+		sg.cells[idx].owned = append(sg.cells[idx].owned, s)
+	}
+}
+
+// snakeHead is the head func of assign for snakes outside a physics step.
+func snakeHead(snakes []*Snake) func(int) (Vec2, bool) {
+	return func(i int) (Vec2, bool) {
+		s := snakes[i]
+		if !s.Alive || len(s.Segments) == 0 {
+			return Vec2{}, false
+		}
+		return s.Segments[0], true
+	}
+}
+
+// stitch registers every snake living in st as a ghost in the cells its
+// body can reach. Uses the same reach bound as the collision early-out,
+// from each of its body anchors (see portal.go).
+func (sg *shardGrid) stitch(st *sim.State) {
+	for _, c := range sg.cells {
+		c.ghosts = c.ghosts[:0]
+	}
+	for i, s := range sg.snakes {
+		ss := &st.Snakes[i]
+		if !ss.Alive || len(ss.Body) == 0 {
+			continue
+		}
+		reach := st.Span(i) + shardMargin
+		anchors := sim.BodyAnchors(ss.Body, ss.PortalGap)
+		for k, a := range anchors {
+			x0, y0, x1, y1 := sg.cellRange(a, reach)
+			for y := y0; y <= y1; y++ {
+				for x := x0; x <= x1; x++ {
+					if k > 0 && sg.inRange(anchors[0], reach, x, y) {
+						continue // already a ghost here
+					}
+					c := sg.cells[y*sg.n+x]
+					c.ghosts = append(c.ghosts, s)
+				}
+			}
+		}
+	}
+}
+
+// inRange reports whether cell (x, y) overlaps the square of half-side r
+// around p.
+func (sg *shardGrid) inRange(p Vec2, r float64, x, y int) bool {
+	x0, y0, x1, y1 := sg.cellRange(p, r)
+	return x >= x0 && x <= x1 && y >= y0 && y <= y1
+}
+
+// run executes job for every cell on the cell workers and waits for all.
+func (sg *shardGrid) run(job func(c *shardCell)) {
+	sg.wg.Add(len(sg.cells))
+	for _, c := range sg.cells {
+		c := c
+		c.jobs <- func() { job(c) }
+	}
+	sg.wg.Wait()
+}
+
+// Hits finds the collisions of the physics step over the cells (see
+// sim.Broadphase). A head only ever hits bodies registered in its own cell.
+func (sg *shardGrid) Hits(st *sim.State) [][]int {
+	sg.assign(sg.snakes, func(i int) (Vec2, bool) {
+		ss := &st.Snakes[i]
+		if !ss.Alive || len(ss.Body) == 0 {
+			return Vec2{}, false
+		}
+		return ss.Body[0], true
+	})
+	sg.stitch(st)
+	for len(sg.hits) < len(st.Snakes) {
+		sg.hits = append(sg.hits, nil)
+	}
+	hits := sg.hits[:len(st.Snakes)]
+	for i := range hits {
+		hits[i] = hits[i][:0]
+	}
+	sg.run(func(c *shardCell) {
+		for _, s := range c.owned {
+			if st.Snakes[s.order].Invulnerable {
+				continue
+			}
+			for _, o := range c.ghosts {
+				if o != s && st.Touches(s.order, o.order) {
+					hits[s.order] = append(hits[s.order], o.order)
+				}
+			}
+		}
+	})
+	return hits
+}
+
+// visibleSnakes returns the living snakes whose heads are within r of
+// (cx, cy) on both axes, from the cells overlapping that square.
+func (sg *shardGrid) visibleSnakes(cx, cy, r float64) []*Snake {
+	var visible []*Snake
+	x0, y0, x1, y1 := sg.cellRange(Vec2{X: cx, Y: cy}, r)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			for _, s := range sg.cells[y*sg.n+x].owned {
+				if !s.Alive || len(s.Segments) == 0 {
+					continue
+				}
+				h := s.Segments[0]
+				if math.Abs(h.X-cx) < r && math.Abs(h.Y-cy) < r {
+					visible = append(visible, s)
+				}
+			}
+		}
+	}
+	sort.Slice(visible, func(i, j int) bool { return visible[i].order < visible[j].order })
+	return visible
+}
+
+// ShardStats is the per-cell load, for /stats.
+type ShardStats struct {
+	Cells    int   `json:"cells"` // per side
+	Handoffs int64 `json:"handoffs"`
+	Snakes   []int `json:"snakes"` // owned snakes per cell, row-major
+}
+
+func (sg *shardGrid) stats() *ShardStats {
+	st := &ShardStats{Cells: sg.n, Handoffs: sg.handoffs, Snakes: make([]int, len(sg.cells))}
+	for i, c := range sg.cells {
+		st.Snakes[i] = len(c.owned)
+	}
+	return st
+}
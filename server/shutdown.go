@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Health check and graceful shutdown
+//
+// GET /healthz answers 200 "ok" while the game loops of all rooms are
+// running, and 503 once one of them has been stuck for healthStallTime or
+// the server is shutting down. `snake-server -healthcheck` requests it from
+// the server's own game listener (-port or -addr) and exits 0 or 1, so an
+// image without a shell or curl can still have a HEALTHCHECK (see the
+// Dockerfile).
+//
+// On SIGTERM or SIGINT the server drains: /healthz and new /ws connections
+// get 503 so load balancers move on, connected players get a "shutdown"
+// event with the seconds left, and the server waits up to -drain-timeout
+// for them to leave. Then every room's loop stops: players still connected
+// are disconnected, the world is autosaved one last time (with -autosave),
+// and the storage and event log queues are written out before the process
+// exits. A second signal exits at once.
+//
+// Requests to a room whose loop has stopped, such as GetStats on a room
+// that was just removed, don't block: they return zero values, or
+// errRoomStopped where they return an error. Messages to it, such as a
+// leave, a drain or player input, are dropped, and the connection's read
+// loop ends.
+// ---------------------------------------------------------------------------
+
+const (
+	DefaultDrainTimeout = 20 * time.Second
+
+	healthStallTime    = 10 * time.Second // game loop silence that fails /healthz
+	healthcheckTimeout = 3 * time.Second
+	drainPollInterval  = 250 * time.Millisecond
+	httpShutdownTime   = 5 * time.Second
+)
+
+type shutdownEvent struct {
+	Type string `json:"t"`  // "shutdown"
+	In   int    `json:"in"` // seconds until the server stops
+}
+
+// Health answers /healthz and tracks whether the server is draining.
+type Health struct {
+	rooms    *Rooms
+	draining atomic.Bool
+}
+
+func NewHealth(rooms *Rooms) *Health {
+	return &Health{rooms: rooms}
+}
+
+// Draining reports whether the server is shutting down.
+func (h *Health) Draining() bool { return h.draining.Load() }
+
+func (h *Health) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	if h.Draining() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	for _, rm := range h.rooms.all() {
+		last := rm.game.lastLoop.Load()
+		if last == 0 {
+			continue // not running yet
+		}
+		if stalled := time.Since(time.Unix(0, last)); stalled > healthStallTime {
+			http.Error(w, fmt.Sprintf("room %s: game loop stalled for %s", rm.id, stalled.Round(time.Second)),
+				http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Write([]byte("ok"))
+}
+
+// runHealthcheck requests /healthz from the game listener at addr (see
+// listen.go) and fails unless it answers 200.
+func runHealthcheck(addr string) error {
+	client := &http.Client{Timeout: healthcheckTimeout}
+	url := "http://" + addr + "/healthz"
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		path := strings.TrimPrefix(addr, "unix:")
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+		url = "http://unix/healthz"
+	case addr == "systemd" || strings.HasPrefix(addr, "systemd:"):
+		return errors.New("can't find a systemd socket from outside the server, pass its address with -addr")
+	default:
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+		switch host {
+		case "", "0.0.0.0":
+			host = "127.0.0.1"
+		case "::":
+			host = "::1"
+		}
+		url = "http://" + net.JoinHostPort(host, port) + "/healthz"
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Drain tells the players of g that the server stops in d. Thread-safe.
+func (g *Game) Drain(d time.Duration) {
+	tell(g, g.shutdownCh, d)
+}
+
+func (g *Game) announceShutdown(d time.Duration) {
+	g.log.Info("announcing shutdown", "in", d, "players", len(g.players))
+	g.announce(shutdownEvent{Type: "shutdown", In: int(d.Round(time.Second).Seconds())})
+}
+
+var errRoomStopped = errors.New("room stopped")
+
+// Stop ends Run after disconnecting the remaining players, a final autosave
+// and writing out the storage and event log queues. Thread-safe; blocks
+// until done.
+func (g *Game) Stop() {
+	done := make(chan struct{})
+	select {
+	case g.stopCh <- done:
+		<-done
+	case <-g.stopped:
+	}
+}
+
+// ask sends req to the game loop on ch and returns its answer on reply.
+// ok is false if the loop has stopped. Thread-safe.
+func ask[R, T any](g *Game, ch chan<- R, req R, reply <-chan T) (v T, ok bool) {
+	select {
+	case ch <- req:
+	case <-g.stopped:
+		return v, false
+	}
+	select {
+	case v = <-reply:
+		return v, true
+	case <-g.stopped:
+	}
+	select {
+	case v = <-reply: // answered during the final drain
+		return v, true
+	default:
+		return v, false
+	}
+}
+
+// tell sends msg to the game loop on ch. It drops msg and returns false if
+// the loop has stopped. Thread-safe.
+func tell[T any](g *Game, ch chan<- T, msg T) bool {
+	select {
+	case ch <- msg:
+		return true
+	case <-g.stopped:
+		return false
+	}
+}
+
+func (g *Game) stop() {
+	g.drainMessages() // leaves that are already queued
+	for id, p := range g.players {
+		p.conn.Close()
+		g.handleLeave(id)
+	}
+	if g.autosavePath != "" {
+		for g.autosaveBusy.Load() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		snap := g.captureSnapshot()
+		if err := writeSnapshotFile(g.autosavePath, snap); err != nil {
+			g.log.Error("final autosave failed", "path", g.autosavePath, "err", err)
+		} else {
+			g.log.Info("saved world", "path", g.autosavePath, "snakes", len(snap.Snakes))
+		}
+	}
+	// Run is the only sender on both queues
+	if g.storeCh != nil {
+		close(g.storeCh)
+	}
+	if g.evlog != nil {
+		close(g.evlog.ch)
+	}
+	if g.replays != nil {
+		close(g.replays.ch)
+	}
+	g.writers.Wait()
+	if g.shards != nil {
+		g.shards.close()
+	}
+	g.log.Info("room stopped", "frame", g.frame)
+}
+
+// Shutdown announces the shutdown in every room, waits up to timeout for
+// the players to leave and then stops every room.
+func (rs *Rooms) Shutdown(timeout time.Duration) {
+	for _, rm := range rs.all() {
+		rm.game.Drain(timeout)
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		players := 0
+		for _, rm := range rs.all() {
+			players += rm.game.GetStats().CurrentPlayers
+		}
+		if players == 0 {
+			break
+		}
+		time.Sleep(drainPollInterval)
+	}
+	for _, rm := range rs.all() {
+		rm.game.Stop()
+	}
+}
+
+// all returns the main room followed by the extra rooms.
+func (rs *Rooms) all() []*room {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	list := []*room{{id: DefaultRoomID, game: rs.main, cfg: rs.base}}
+	for _, r := range rs.extra {
+		list = append(list, r)
+	}
+	return list
+}
+
+// waitForShutdown blocks until SIGTERM or SIGINT, then drains the rooms
+// and shuts down the HTTP servers.
+func waitForShutdown(h *Health, rooms *Rooms, timeout time.Duration, servers ...*http.Server) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, os.Interrupt)
+	sig := <-sigCh
+	slog.Info("shutting down", "signal", sig, "drainTimeout", timeout)
+	h.draining.Store(true)
+	go func() {
+		sig := <-sigCh
+		fatal("second signal, exiting without draining", "signal", sig)
+	}()
+
+	rooms.Shutdown(timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTime)
+	defer cancel()
+	for _, srv := range servers {
+		srv.Shutdown(ctx)
+	}
+}
@@ -0,0 +1,85 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+)
+
+// ---------------------------------------------------------------------------
+// Arena shapes
+//
+//	square   the whole world square (default)
+//	circle   the disc inscribed in the square, like slither.io
+//	hexagon  the regular hexagon inscribed in the square, with corners to the
+//	         left and right and flat edges at the top and bottom
+//
+// Every shape is centred on the middle of the world square.
+// ---------------------------------------------------------------------------
+
+// ArenaShape is the playable area inside the world square. Methods must be
+// safe to call from any goroutine.
+type ArenaShape interface {
+	Name() string
+	// EdgeDist returns how far p is inside the edge, negative outside.
+	EdgeDist(p Vec2) float64
+	// Vertices returns the corners of a polygonal edge in order, nil for
+	// the square and the circle.
+	Vertices() []Vec2
+}
+
+// ArenaShapeFor returns the shape called name in a world of the given size.
+func ArenaShapeFor(name string, size float64) (ArenaShape, error) {
+	switch name {
+	case "", "square":
+		return squareArena{size}, nil
+	case "circle":
+		return circleArena{size / 2}, nil
+	case "hexagon":
+		return hexArena{size / 2}, nil
+	}
+	return nil, fmt.Errorf("unknown arena shape %q (want square, circle or hexagon)", name)
+}
+
+type squareArena struct{ size float64 }
+
+func (squareArena) Name() string { return "square" }
+
+func (a squareArena) EdgeDist(p Vec2) float64 {
+	return min(p.X, p.Y, a.size-p.X, a.size-p.Y)
+}
+
+func (squareArena) Vertices() []Vec2 { return nil }
+
+// circleArena is centred on the world with radius r (half the world size).
+type circleArena struct{ r float64 }
+
+func (circleArena) Name() string { return "circle" }
+
+func (a circleArena) EdgeDist(p Vec2) float64 {
+	return a.r - math.Hypot(p.X-a.r, p.Y-a.r)
+}
+
+func (circleArena) Vertices() []Vec2 { return nil }
+
+// hexArena is centred on the world with circumradius r (half the world
+// size); its corners are at 0°, 60°, ... 300°.
+type hexArena struct{ r float64 }
+
+func (hexArena) Name() string { return "hexagon" }
+
+// EdgeDist measures against the edge normals at 30°, 90° and 150°, folded
+// into the first quadrant.
+func (a hexArena) EdgeDist(p Vec2) float64 {
+	dx, dy := math.Abs(p.X-a.r), math.Abs(p.Y-a.r)
+	apothem := a.r * math.Sqrt(3) / 2
+	return apothem - math.Max(dy, dx*math.Sqrt(3)/2+dy/2)
+}
+
+func (a hexArena) Vertices() []Vec2 {
+	vs := make([]Vec2, 6)
+	for i := range vs {
+		ang := float64(i) * math.Pi / 3
+		vs[i] = Vec2{X: a.r + a.r*math.Cos(ang), Y: a.r + a.r*math.Sin(ang)}
+	}
+	return vs
+}
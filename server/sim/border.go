@@ -0,0 +1,33 @@
+package sim
+
+// ---------------------------------------------------------------------------
+// World border warning band and wall brake
+//
+// The kill line runs BoundaryMargin inside the arena edge. WarningBand is
+// the width of a band inside the kill line that clients draw as a red haze,
+// so players see the wall coming before they hit it.
+//
+// With Rules.WallBrake, players aren't killed at the kill line. A player
+// heading outward inside the warning band slows down, to wallBrakeMin of
+// its speed at the line, and a head that would cross the line stays where
+// it is until the snake turns back inward. Bots turn away from the wall as
+// before.
+// ---------------------------------------------------------------------------
+
+const wallBrakeMin = 0.25 // speed factor at the kill line
+
+// brake returns the speed factor of a head at head heading along angle.
+func brake(r *Rules, head Vec2, angle float64) float64 {
+	if r.WarningBand <= 0 {
+		return 1
+	}
+	d := r.Arena.EdgeDist(head) - r.BoundaryMargin
+	if d >= r.WarningBand {
+		return 1
+	}
+	ahead := Advance(head, angle, 1, 1, r.Deterministic)
+	if r.Arena.EdgeDist(ahead) >= r.Arena.EdgeDist(head) {
+		return 1 // heading inward or along the wall
+	}
+	return wallBrakeMin + (1-wallBrakeMin)*Clamp(d/r.WarningBand, 0, 1)
+}
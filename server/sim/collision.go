@@ -0,0 +1,84 @@
+package sim
+
+// ---------------------------------------------------------------------------
+// Collision geometry
+//
+// A head is either a point, tested against every body point from start on
+// (PointsHit), or a capsule swept from its previous position to its current
+// one, tested against the polyline through every step-th body point
+// (PolylineHit), so fast heads can't tunnel through thin bodies between
+// samples.
+// ---------------------------------------------------------------------------
+
+// PointsHit reports whether p comes within sqrt(thresholdSq) of a point
+// of body, starting at index start.
+func PointsHit(p Vec2, body []Vec2, start int, thresholdSq float64) bool {
+	for k := start; k < len(body); k++ {
+		if DistSq(p.X, p.Y, body[k].X, body[k].Y) < thresholdSq {
+			return true
+		}
+	}
+	return false
+}
+
+// PolylineHit reports whether the segment a-b comes within
+// sqrt(thresholdSq) of the polyline through every step-th point of body
+// and its last point, starting at index start. step must be positive.
+func PolylineHit(a, b Vec2, body []Vec2, start, step int, thresholdSq float64) bool {
+	if start >= len(body) {
+		return false
+	}
+	last := len(body) - 1
+	for k := start; ; k += step {
+		end := min(k+step, last)
+		if SegDistSq(a, b, body[k], body[end]) < thresholdSq {
+			return true
+		}
+		if end == last {
+			return false
+		}
+	}
+}
+
+// SegDistSq returns the squared distance between segments p1-q1 and p2-q2
+// (Ericson, Real-Time Collision Detection, 5.1.9). Products are rounded
+// on their own so the result is the same on every architecture.
+func SegDistSq(p1, q1, p2, q2 Vec2) float64 {
+	d1 := Vec2{q1.X - p1.X, q1.Y - p1.Y}
+	d2 := Vec2{q2.X - p2.X, q2.Y - p2.Y}
+	r := Vec2{p1.X - p2.X, p1.Y - p2.Y}
+	a := Dot(d1.X, d1.Y, d1.X, d1.Y)
+	e := Dot(d2.X, d2.Y, d2.X, d2.Y)
+	f := Dot(d2.X, d2.Y, r.X, r.Y)
+
+	const eps = 1e-9
+	var s, t float64
+	switch {
+	case a <= eps && e <= eps:
+		return Dot(r.X, r.Y, r.X, r.Y)
+	case a <= eps:
+		t = Clamp(f/e, 0, 1)
+	default:
+		c := Dot(d1.X, d1.Y, r.X, r.Y)
+		if e <= eps {
+			s = Clamp(-c/a, 0, 1)
+		} else {
+			b := Dot(d1.X, d1.Y, d2.X, d2.Y)
+			denom := float64(a*e) - float64(b*b)
+			if denom > eps {
+				s = Clamp((float64(b*f)-float64(c*e))/denom, 0, 1)
+			}
+			t = (float64(b*s) + f) / e
+			if t < 0 {
+				t = 0
+				s = Clamp(-c/a, 0, 1)
+			} else if t > 1 {
+				t = 1
+				s = Clamp((b-c)/a, 0, 1)
+			}
+		}
+	}
+	c1 := Vec2{p1.X + float64(d1.X*s), p1.Y + float64(d1.Y*s)}
+	c2 := Vec2{p2.X + float64(d2.X*t), p2.Y + float64(d2.Y*t)}
+	return DistSq(c1.X, c1.Y, c2.X, c2.Y)
+}
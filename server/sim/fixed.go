@@ -0,0 +1,103 @@
+package sim
+
+import (
+	"math"
+	"sync"
+)
+
+// ---------------------------------------------------------------------------
+// Fixed-point trigonometry for deterministic physics
+//
+// Snake movement normally uses float trigonometry, whose last bits can
+// differ between CPU architectures and Go versions (fused multiply-add,
+// assembly implementations of math.Sin). In deterministic mode angles are
+// binary angles of 1/65536 turn and positions are fixed point with 1/256
+// world unit. Heads move by integer sine and cosine from a table built
+// with CORDIC, so the same inputs give bit-identical bodies everywhere,
+// which client-side prediction and replays need. The values are still
+// stored as floats; they are exact multiples of the units, so converting
+// back and forth loses nothing.
+//
+// Collision geometry keeps its float math, but every product is rounded
+// on its own (see Dot), so no architecture fuses it differently.
+// ---------------------------------------------------------------------------
+
+const (
+	AngleUnits = 1 << 16 // binary angle units per full turn
+	PosScale   = 256     // fixed-point position units per world unit
+
+	AngleUnit = 2 * math.Pi / AngleUnits // radians per binary angle unit
+
+	trigOne = 1 << 16 // fixed-point 1.0 in trigTable
+)
+
+var (
+	trigOnce  sync.Once
+	trigTable [AngleUnits / 4]int32 // cos of the first quadrant, trigOne = 1.0
+)
+
+// cordicAtan holds atan(2^-i) in units of 2^-32 turn.
+var cordicAtan = [...]int64{
+	536870912, 316933406, 167458907, 85004756, 42667331, 21354465, 10679838, 5340245,
+	2670163, 1335087, 667544, 333772, 166886, 83443, 41722, 20861,
+	10430, 5215, 2608, 1304, 652, 326, 163, 81,
+	41, 20, 10, 5, 3, 1,
+}
+
+const cordicGain = 652032874 // 2^30 times the product of 1/sqrt(1+2^-2i)
+
+// buildTrigTable fills trigTable using integer CORDIC rotations only.
+func buildTrigTable() {
+	for a := range trigTable {
+		x, y, z := int64(cordicGain), int64(0), int64(a)<<16
+		for i, at := range cordicAtan {
+			if z >= 0 {
+				x, y, z = x-y>>i, y+x>>i, z-at
+			} else {
+				x, y, z = x+y>>i, y-x>>i, z+at
+			}
+		}
+		trigTable[a] = int32((x + 1<<13) >> 14) // 2^30 → 2^16
+	}
+}
+
+// FixedCosSin returns cos and sin of binary angle u, scaled by trigOne.
+func FixedCosSin(u int) (int64, int64) {
+	trigOnce.Do(buildTrigTable)
+	u &= AngleUnits - 1
+	const q = AngleUnits / 4
+	r := u % q
+	c, s := int64(trigTable[r]), int64(0)
+	if r > 0 {
+		s = int64(trigTable[q-r]) // sin r = cos(quarter turn - r)
+	}
+	switch u / q {
+	case 1:
+		c, s = -s, c
+	case 2:
+		c, s = -c, -s
+	case 3:
+		c, s = s, -c
+	}
+	return c, s
+}
+
+// ToAngleUnits converts radians to binary angle units in [0, AngleUnits).
+func ToAngleUnits(rad float64) int {
+	return int(int64(math.Round(rad/AngleUnit)) & (AngleUnits - 1))
+}
+
+// ToFixed converts a world coordinate to fixed point.
+func ToFixed(v float64) int64 {
+	return int64(math.Round(v * PosScale))
+}
+
+// FixedOffset moves the point (x, y) dist world units along binary angle u.
+func FixedOffset(x, y float64, u int, dist float64) Vec2 {
+	c, s := FixedCosSin(u)
+	d := ToFixed(dist)
+	return Vec2{
+		X: float64(ToFixed(x)+(d*c+trigOne/2)>>16) / PosScale,
+		Y: float64(ToFixed(y)+(d*s+trigOne/2)>>16) / PosScale,
+	}
+}
@@ -0,0 +1,60 @@
+package sim
+
+import "fmt"
+
+// ---------------------------------------------------------------------------
+// Head-on rules
+//
+// A head-on is a step in which two snakes' heads each run into the other's
+// body. Resolved in snake order, the first snake checked would always die
+// and the other would take the kill, so Step leaves head-ons to a
+// HeadOnRule instead, the same whichever snake comes first:
+//
+//	longer  the snake with more body points wins; equal lengths both die
+//	        (default)
+//	faster  the snake moving faster this step wins (a boosting snake beats
+//	        a cruising one); equal speeds fall back to longer
+//	both    both snakes die
+//
+// An invulnerable snake can't lose a head-on; the other snake just runs
+// into it.
+// ---------------------------------------------------------------------------
+
+// HeadOnRule returns the winner of a head-on between a and b, or nil if
+// both die. It must not depend on the order of its arguments.
+type HeadOnRule func(a, b *Snake) *Snake
+
+// HeadOnRuleFor returns the rule called name.
+func HeadOnRuleFor(name string) (HeadOnRule, error) {
+	switch name {
+	case "", "longer":
+		return LongerWins, nil
+	case "faster":
+		return FasterWins, nil
+	case "both":
+		return func(a, b *Snake) *Snake { return nil }, nil
+	}
+	return nil, fmt.Errorf("unknown head-on rule %q (want longer, faster or both)", name)
+}
+
+// LongerWins is the longer rule.
+func LongerWins(a, b *Snake) *Snake {
+	switch {
+	case len(a.Body) > len(b.Body):
+		return a
+	case len(b.Body) > len(a.Body):
+		return b
+	}
+	return nil
+}
+
+// FasterWins is the faster rule.
+func FasterWins(a, b *Snake) *Snake {
+	switch {
+	case a.Speed > b.Speed:
+		return a
+	case b.Speed > a.Speed:
+		return b
+	}
+	return LongerWins(a, b)
+}
@@ -0,0 +1,60 @@
+package sim
+
+import "math"
+
+// ---------------------------------------------------------------------------
+// Head kinematics
+//
+// A snake head turns towards its target angle by at most a turn limit per
+// step, overshooting by turnOvershoot, and then moves along its heading.
+// With deterministic set both go through the fixed-point path (see
+// fixed.go), so the result is bit-identical on every architecture.
+// ---------------------------------------------------------------------------
+
+const turnOvershoot = 1.8
+
+// Steer turns angle towards target by at most limit radians and returns
+// the new angle and the turn.
+func Steer(angle, target, limit float64, deterministic bool) (float64, float64) {
+	if deterministic {
+		cur := ToAngleUnits(angle)
+		diff := int(int16(ToAngleUnits(target) - cur))
+		lim := int(math.Round(limit / AngleUnit))
+		turn := min(max(diff, -lim), lim) * 18 / 10
+		return float64((cur+turn)&(AngleUnits-1)) * AngleUnit, float64(turn) * AngleUnit
+	}
+	turn := Clamp(AngleDiff(angle, target), -limit, limit) * turnOvershoot
+	return angle + turn, turn
+}
+
+// Advance returns p moved speed×frac world units along angle.
+func Advance(p Vec2, angle, speed, frac float64, deterministic bool) Vec2 {
+	if deterministic {
+		return FixedOffset(p.X, p.Y, ToAngleUnits(angle), speed*frac)
+	}
+	return Vec2{
+		X: p.X + math.Cos(angle)*speed*frac,
+		Y: p.Y + math.Sin(angle)*speed*frac,
+	}
+}
+
+// LineBody lays out a straight body of n points spacing apart behind head,
+// facing angle. It returns the body and the heading, which the
+// deterministic path rounds to binary angle units.
+func LineBody(head Vec2, angle float64, n int, spacing float64, deterministic bool) ([]Vec2, float64) {
+	body := make([]Vec2, n)
+	if deterministic {
+		u := ToAngleUnits(angle)
+		for i := range body {
+			body[i] = FixedOffset(head.X, head.Y, u+AngleUnits/2, spacing*float64(i))
+		}
+		return body, float64(u) * AngleUnit
+	}
+	for i := range body {
+		body[i] = Vec2{
+			X: head.X - math.Cos(angle)*spacing*float64(i),
+			Y: head.Y - math.Sin(angle)*spacing*float64(i),
+		}
+	}
+	return body, angle
+}
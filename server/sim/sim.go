@@ -0,0 +1,54 @@
+// Package sim is the pure part of the snake simulation: vector math,
+// head kinematics, fixed-point trigonometry, collision geometry, arena
+// shapes and the physics step (Step). It has no channels, logging,
+// randomness or networking and keeps no state of its own, so everything in
+// it is a function of its arguments and can be tested, benchmarked and
+// fuzzed in isolation. The game loop (Game in package main) owns the world
+// and calls into it.
+package sim
+
+import "math"
+
+// Vec2 is a point or direction in world units.
+type Vec2 struct{ X, Y float64 }
+
+// DistSq returns the squared distance between (x1, y1) and (x2, y2).
+func DistSq(x1, y1, x2, y2 float64) float64 {
+	dx, dy := x2-x1, y2-y1
+	return Dot(dx, dy, dx, dy)
+}
+
+// Dist returns the distance between (x1, y1) and (x2, y2).
+func Dist(x1, y1, x2, y2 float64) float64 {
+	return math.Sqrt(DistSq(x1, y1, x2, y2))
+}
+
+// AngleDiff returns the signed turn from a to b in (-π, π].
+func AngleDiff(a, b float64) float64 {
+	d := b - a
+	for d > math.Pi {
+		d -= 2 * math.Pi
+	}
+	for d < -math.Pi {
+		d += 2 * math.Pi
+	}
+	return d
+}
+
+// Clamp limits v to [lo, hi].
+func Clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// Dot returns ax*bx + ay*by with each product rounded on its own. The
+// conversions keep the compiler from fusing them into a multiply-add,
+// which some architectures do and others don't.
+func Dot(ax, ay, bx, by float64) float64 {
+	return float64(ax*bx) + float64(ay*by)
+}
@@ -0,0 +1,171 @@
+package sim
+
+import "math"
+
+// ---------------------------------------------------------------------------
+// Bot steering: sampled paths over a body-density grid
+//
+// The world is binned into SteerCellSize cells holding the number of snake
+// body points in each. For a snake whose input asks to avoid danger, Step
+// scores a fan of candidate headings around the wanted one by sampling the
+// grid along the arc the snake would turn along: bodies, hazards and the
+// world boundary ahead cost more the closer they are, and turning away
+// from the wanted heading costs a little. The cheapest heading wins, so
+// bots route around crowds instead of reacting to the nearest body point.
+// If something is right in front, the snake boosts out when the chosen way
+// is clear and its meter allows.
+// ---------------------------------------------------------------------------
+
+const (
+	SteerCellSize  = 40.0
+	SteerLookahead = 240.0 // path length scored per candidate heading
+	SteerBoostMin  = 20.0  // boost meter needed to boost out of danger
+	steerSampleGap = 12.0  // distance between samples along the path
+	steerNearDist  = 80.0  // samples closer than this are immediate danger
+	steerWallCost  = 40.0  // per sample beyond the boundary margin or in a hazard
+	steerTurnCost  = 1.5   // per radian away from the wanted heading
+)
+
+var steerOffsets = []float64{0, 0.35, -0.35, 0.7, -0.7, 1.1, -1.1, 1.6, -1.6, 2.2, -2.2, math.Pi}
+
+// Hazard is a body other than a snake's that bots steer clear of, such as
+// an eel's.
+type Hazard struct {
+	Body   []Vec2
+	Radius float64 // distance to keep from every body point
+	Lo, Hi Vec2    // a box around the body; points outside it are clear
+}
+
+// Density counts body points per SteerCellSize cell of the world square.
+type Density struct {
+	n      int // cells per side
+	counts []int32
+}
+
+// Reset empties d for a world of the given size.
+func (d *Density) Reset(worldSize float64) {
+	n := int(math.Ceil(worldSize / SteerCellSize))
+	if d.n != n || d.counts == nil {
+		d.n = n
+		d.counts = make([]int32, n*n)
+		return
+	}
+	clear(d.counts)
+}
+
+// Add adds (delta 1) or removes (delta -1) the points of body.
+func (d *Density) Add(body []Vec2, delta int) {
+	for _, p := range body {
+		if i := d.cell(p.X, p.Y); i >= 0 {
+			d.counts[i] += int32(delta)
+		}
+	}
+}
+
+// cell returns the index of the cell holding (x, y), or -1 outside the world.
+func (d *Density) cell(x, y float64) int {
+	cx, cy := int(x/SteerCellSize), int(y/SteerCellSize)
+	if x < 0 || y < 0 || cx >= d.n || cy >= d.n {
+		return -1
+	}
+	return cy*d.n + cx
+}
+
+// Around returns the number of body points in the 3x3 cells around p.
+func (d *Density) Around(p Vec2) float64 {
+	return d.Within(p, SteerCellSize)
+}
+
+// Within returns the number of body points in the cells within r of p.
+func (d *Density) Within(p Vec2, r float64) float64 {
+	cx, cy := int(p.X/SteerCellSize), int(p.Y/SteerCellSize)
+	k := int(math.Ceil(r / SteerCellSize))
+	n := int32(0)
+	for y := max(cy-k, 0); y <= min(cy+k, d.n-1); y++ {
+		for x := max(cx-k, 0); x <= min(cx+k, d.n-1); x++ {
+			n += d.counts[y*d.n+x]
+		}
+	}
+	return float64(n)
+}
+
+// avoid adjusts the heading (and boost) of snake i so it avoids dense
+// areas, hazards and the boundary.
+func (st *State) avoid(i int) {
+	if st.Density == nil {
+		st.Density = new(Density)
+		st.Density.Reset(st.Rules.WorldSize)
+		for _, o := range st.Snakes {
+			if o.Alive {
+				st.Density.Add(o.Body, 1)
+			}
+		}
+	}
+	s, d := &st.Snakes[i], st.Density
+	// s's own body is harmless; take it out of the grid while scoring.
+	d.Add(s.Body, -1)
+	defer d.Add(s.Body, 1)
+
+	want := s.TargetAngle
+	bestScore, bestAngle := math.Inf(1), want
+	var wantNear, bestNear float64
+	for _, off := range steerOffsets {
+		danger, near := st.scorePath(s, want+off)
+		if off == 0 {
+			if danger == 0 {
+				return // the wanted heading is clear
+			}
+			wantNear = near
+		}
+		if score := danger + math.Abs(off)*steerTurnCost; score < bestScore {
+			bestScore, bestAngle, bestNear = score, want+off, near
+		}
+	}
+
+	s.TargetAngle = bestAngle
+	if wantNear > 0 {
+		// Something is right in front: sprint out if the chosen way is clear.
+		s.Boosting = bestNear == 0 && s.Boost > SteerBoostMin
+	}
+}
+
+// scorePath follows the arc s would take turning toward target at its turn
+// rate and sums the body density, hazard and boundary cost along it,
+// weighted by closeness. near is the part of the cost within steerNearDist.
+func (st *State) scorePath(s *Snake, target float64) (danger, near float64) {
+	r := &st.Rules
+	turn := r.TurnSpeed * turnOvershoot * steerSampleGap / s.Speed
+	pos, angle := s.Body[0], s.Angle
+	for dist := steerSampleGap; dist <= SteerLookahead; dist += steerSampleGap {
+		angle += Clamp(AngleDiff(angle, target), -turn, turn)
+		pos.X += math.Cos(angle) * steerSampleGap
+		pos.Y += math.Sin(angle) * steerSampleGap
+
+		var cost float64
+		if r.Arena.EdgeDist(pos) < r.BoundaryMargin {
+			cost = steerWallCost
+		} else {
+			cost = st.Density.Around(pos) + st.hazardCost(pos)
+		}
+		danger += cost * (1 - dist/(SteerLookahead+steerSampleGap))
+		if dist < steerNearDist {
+			near += cost
+		}
+	}
+	return danger, near
+}
+
+// hazardCost returns steerWallCost if p is inside a hazard.
+func (st *State) hazardCost(p Vec2) float64 {
+	for _, h := range st.Rules.Hazards {
+		if p.X <= h.Lo.X || p.X >= h.Hi.X || p.Y <= h.Lo.Y || p.Y >= h.Hi.Y {
+			continue
+		}
+		for _, q := range h.Body {
+			if DistSq(p.X, p.Y, q.X, q.Y) < h.Radius*h.Radius {
+				return steerWallCost
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,404 @@
+package sim
+
+import "math"
+
+// ---------------------------------------------------------------------------
+// Simulation step
+//
+// Step advances a world by one movement step: it applies the inputs, steers
+// the bots that ask for it around danger (see steer.go), turns and moves
+// every living snake, takes portals, eats food and resolves collisions
+// between snakes. It knows nothing of players, scores, boost meters or
+// timers. The game loop fills in a State before each step and turns the
+// Events of the result into kills, rewards and messages.
+//
+// Inputs come once per game tick, with its first step: they set a snake's
+// heading and whether it boosts, and with them its speed for the tick. A
+// step without an input for a snake keeps all three. Rules.Frac is the
+// game time one step covers, so the game loop can split a tick into
+// several steps.
+//
+// Snakes move in State order and each eats right after moving, so a snake
+// earlier in the list gets to contested food first. Collisions are checked
+// once everyone has moved: a head running into another snake's body kills
+// it, and when two heads each run into the other's body, Rules.HeadOn picks
+// the winner. A snake killed earlier in the pass is no longer an obstacle.
+//
+// Step works on the slices it is given. The returned state shares them, so
+// the state passed in must not be used afterwards.
+// ---------------------------------------------------------------------------
+
+const (
+	HeadRadius   = 12.0 // head radius of a new snake at size scale 1
+	BodyRadius   = 10.0 // body radius of a new snake at size scale 1
+	PortalRadius = 40.0
+
+	collideStart = 5  // body points behind a head that it can't run into
+	collideSlack = 4  // overlap of head and body radii that still counts as a miss
+	collideReach = 50 // slack of the early-out around a body's reach
+)
+
+// Rules are the physics settings of a world.
+type Rules struct {
+	WorldSize          float64 // side of the world square
+	Arena              ArenaShape
+	BoundaryMargin     float64 // the kill line runs this far inside the arena edge
+	WarningBand        float64 // width of the wall brake band inside the kill line
+	WallBrake          bool    // players stop at the kill line instead of dying (see border.go)
+	Frac               float64 // game ticks one step covers
+	BaseSpeed          float64 // units per tick
+	BoostSpeed         float64 // units per tick
+	TurnSpeed          float64 // radians per tick
+	GrowthRate         float64 // body points per tick a growing body gains
+	MaxGap             float64 // upper bound of the distance between neighbouring body points
+	SizeScale          float64 // factor on head and body radii
+	CollisionPrecision int     // 0 tests heads as points, n > 0 against every n-th body point (see collision.go)
+	Deterministic      bool    // fixed-point turning and movement (see fixed.go)
+	HeadOn             HeadOnRule
+	Portals            []PortalPair
+	PortalCooldown     int        // ticks after a jump in which a snake can't take another portal
+	Hazards            []Hazard   // besides snakes and the boundary, for steering
+	Broadphase         Broadphase // nil tests every pair of snakes
+}
+
+// PortalPair is two linked portals: a snake entering one leaves the other.
+type PortalPair [2]Vec2
+
+// Snake is the physical state of a snake.
+type Snake struct {
+	Body           []Vec2 // head first
+	Angle          float64
+	TargetAngle    float64
+	Speed          float64 // units per tick
+	Boost          float64 // boost meter; bots only boost out of danger with enough
+	Boosting       bool
+	CanBoost       bool // whether the snake may boost this tick
+	TargetLen      int
+	BodyLen        float64 // follows TargetLen at Rules.GrowthRate
+	Turn           float64 // total turn of the steps so far; the caller resets it
+	Alive          bool
+	Bot            bool // turns back at the boundary instead of dying
+	Invulnerable   bool // doesn't die running into others; they still die running into it
+	AddPoint       bool // the next step adds a head point instead of moving the head
+	PortalGap      int  // index of the first body point past a portal jump, 0 = none
+	PortalCooldown int  // ticks until the snake can take a portal again
+}
+
+// Food is a pellet.
+type Food struct {
+	Pos    Vec2
+	Radius float64
+	Value  int // body points a snake gains eating it
+}
+
+// Input is what a snake wants for the tick.
+type Input struct {
+	Angle float64 // heading to turn towards
+	Boost bool
+	Avoid bool // steer around danger first (see steer.go)
+}
+
+// EventKind is what happened in an Event.
+type EventKind int
+
+const (
+	Ate      EventKind = iota // Snake ate the food at index Other of State.Food as it was then
+	Jumped                    // Snake went through a portal
+	HitWall                   // Snake died at the boundary
+	HitSnake                  // Snake ran into Other and died
+	HeadOn                    // Snake and Other ran into each other; the next event says who died
+	Traded                    // Snake and Other both died in a head-on
+)
+
+// Event is something the game loop acts on. Snake and Other are indexes
+// in State.Snakes, except where noted.
+type Event struct {
+	Kind  EventKind
+	Snake int
+	Other int
+}
+
+// Broadphase finds collision candidates faster than testing every pair,
+// for example in parallel over parts of the world. Hits returns, for each
+// snake of st, the snakes whose bodies its head touches (see Touches), in
+// State order. It must return nothing for dead and invulnerable snakes.
+type Broadphase interface {
+	Hits(st *State) [][]int
+}
+
+// State is a world as Step sees it.
+type State struct {
+	Rules   Rules
+	Snakes  []Snake
+	Food    []Food
+	Density *Density // body points per cell for steering; Step counts them if nil
+	Events  []Event  // what the step that returned the state did, in order
+}
+
+// Step advances st by one step. in holds the inputs of the first len(in)
+// snakes, or nothing between a tick's inputs.
+func Step(st State, in []Input) State {
+	st.Events = st.Events[:0]
+	for i := range in {
+		s := &st.Snakes[i]
+		if !s.Alive {
+			continue
+		}
+		s.TargetAngle, s.Boosting = in[i].Angle, in[i].Boost
+		if in[i].Avoid {
+			st.avoid(i)
+		}
+	}
+	for i := range st.Snakes {
+		if st.Snakes[i].Alive {
+			st.move(i, i < len(in))
+		}
+		if st.Snakes[i].Alive {
+			st.eat(i)
+		}
+	}
+	st.collide()
+	return st
+}
+
+// move turns and moves snake i. input says it got an input this step.
+func (st *State) move(i int, input bool) {
+	s, r := &st.Snakes[i], &st.Rules
+	var turn float64
+	s.Angle, turn = Steer(s.Angle, s.TargetAngle, r.TurnSpeed*r.Frac, r.Deterministic)
+	s.Turn += turn
+	if input {
+		st.setSpeed(s)
+	}
+
+	head := s.Body[0]
+	next := Advance(head, s.Angle, s.Speed, r.Frac, r.Deterministic)
+	if r.Arena.EdgeDist(next) < r.BoundaryMargin {
+		switch {
+		case s.Bot:
+			c := r.WorldSize / 2
+			s.TargetAngle = math.Atan2(c-head.Y, c-head.X)
+		case !r.WallBrake:
+			s.Alive = false
+			st.Events = append(st.Events, Event{Kind: HitWall, Snake: i})
+		}
+		return // held at the wall
+	}
+
+	if !s.AddPoint {
+		s.Body[0] = next
+		st.portal(i, false)
+		return
+	}
+	s.AddPoint = false
+	s.Body = append([]Vec2{next}, s.Body...)
+	st.fit(s)
+	st.portal(i, true)
+}
+
+// setSpeed sets the speed of s for the tick of its input.
+func (st *State) setSpeed(s *Snake) {
+	r := &st.Rules
+	if s.Boosting && s.CanBoost {
+		s.Speed = r.BoostSpeed
+	} else {
+		s.Speed = r.BaseSpeed
+		s.Boosting = false
+	}
+	if r.WallBrake && !s.Bot {
+		s.Speed *= brake(r, s.Body[0], s.Angle)
+	}
+}
+
+// fit moves the body length of s, just given a new head point, one tick
+// towards TargetLen. Shrinking takes effect at once; above a GrowthRate of
+// 1 the extra points are stacked on the tail.
+func (st *State) fit(s *Snake) {
+	target := float64(s.TargetLen)
+	if s.BodyLen < target {
+		s.BodyLen = math.Min(s.BodyLen+st.Rules.GrowthRate, target)
+	} else {
+		s.BodyLen = target
+	}
+	n := max(int(s.BodyLen), 1)
+	if len(s.Body) > n {
+		s.Body = s.Body[:n]
+	}
+	for len(s.Body) < n {
+		s.Body = append(s.Body, s.Body[len(s.Body)-1])
+	}
+}
+
+// portal moves the head of snake i to the other end of a portal it entered.
+// While the body passes through, PortalGap follows the point past the jump;
+// added says the step added a head point.
+func (st *State) portal(i int, added bool) {
+	s, r := &st.Snakes[i], &st.Rules
+	if s.PortalGap > 0 {
+		if added {
+			s.PortalGap++
+		}
+		if s.PortalGap >= len(s.Body) {
+			s.PortalGap = 0 // the whole body is through
+		}
+		return
+	}
+	if s.PortalCooldown > 0 {
+		return
+	}
+	head := s.Body[0]
+	for _, pair := range r.Portals {
+		for k, p := range pair {
+			if DistSq(head.X, head.Y, p.X, p.Y) >= PortalRadius*PortalRadius {
+				continue
+			}
+			out := pair[1-k]
+			d := PortalRadius + HeadRadiusFor(len(s.Body), r.SizeScale)
+			s.Body[0] = Vec2{X: out.X + math.Cos(s.Angle)*d, Y: out.Y + math.Sin(s.Angle)*d}
+			s.PortalCooldown = r.PortalCooldown
+			if len(s.Body) > 1 {
+				s.PortalGap = 1
+			}
+			st.Events = append(st.Events, Event{Kind: Jumped, Snake: i})
+			return
+		}
+	}
+}
+
+// eat lets snake i eat the food its head touches.
+func (st *State) eat(i int) {
+	s := &st.Snakes[i]
+	head := s.Body[0]
+	hr := HeadRadiusFor(len(s.Body), st.Rules.SizeScale)
+	for k := len(st.Food) - 1; k >= 0; k-- {
+		f := st.Food[k]
+		if DistSq(head.X, head.Y, f.Pos.X, f.Pos.Y) >= (hr+f.Radius)*(hr+f.Radius) {
+			continue
+		}
+		s.TargetLen += f.Value
+		st.Events = append(st.Events, Event{Kind: Ate, Snake: i, Other: k})
+		st.Food[k] = st.Food[len(st.Food)-1]
+		st.Food = st.Food[:len(st.Food)-1]
+	}
+}
+
+// collide resolves the collisions between snakes, in State order.
+func (st *State) collide() {
+	var hits [][]int
+	if st.Rules.Broadphase != nil {
+		hits = st.Rules.Broadphase.Hits(st)
+	}
+	for i := range st.Snakes {
+		s := &st.Snakes[i]
+		if !s.Alive || s.Invulnerable {
+			continue
+		}
+		if hits != nil {
+			for _, j := range hits[i] {
+				if st.Snakes[j].Alive {
+					st.resolve(i, j)
+					break
+				}
+			}
+			continue
+		}
+		for j := range st.Snakes {
+			if j != i && st.Snakes[j].Alive && st.Touches(i, j) {
+				st.resolve(i, j)
+				break
+			}
+		}
+	}
+}
+
+// resolve handles the head of snake i running into snake j.
+func (st *State) resolve(i, j int) {
+	s, o := &st.Snakes[i], &st.Snakes[j]
+	if o.Invulnerable || !st.Touches(j, i) {
+		st.kill(i, j)
+		return
+	}
+	st.Events = append(st.Events, Event{Kind: HeadOn, Snake: i, Other: j})
+	rule := st.Rules.HeadOn
+	if rule == nil {
+		rule = LongerWins
+	}
+	switch rule(s, o) {
+	case s:
+		st.kill(j, i)
+	case o:
+		st.kill(i, j)
+	default:
+		s.Alive, o.Alive = false, false
+		st.Events = append(st.Events, Event{Kind: Traded, Snake: i, Other: j})
+	}
+}
+
+// kill kills snake i for running into snake j.
+func (st *State) kill(i, j int) {
+	st.Snakes[i].Alive = false
+	st.Events = append(st.Events, Event{Kind: HitSnake, Snake: i, Other: j})
+}
+
+// Touches reports whether the head of snake i runs into the body of snake
+// j. Read-only, so a Broadphase can call it concurrently.
+func (st *State) Touches(i, j int) bool {
+	s, o := &st.Snakes[i], &st.Snakes[j]
+	head := s.Body[0]
+	hr := HeadRadiusFor(len(s.Body), st.Rules.SizeScale)
+	if !nearBody(o, head, st.Span(j)+hr+collideReach) {
+		return false
+	}
+	threshold := hr + BodyRadiusFor(len(o.Body), st.Rules.SizeScale) - collideSlack
+	thresholdSq := threshold * threshold
+	if st.Rules.CollisionPrecision <= 0 {
+		return PointsHit(head, o.Body, collideStart, thresholdSq)
+	}
+	a, b := HeadPath(s.Body)
+	return PolylineHit(a, b, o.Body, collideStart, st.Rules.CollisionPrecision, thresholdSq)
+}
+
+// Span returns how far the body of snake i can reach from its head (or
+// the anchor past a portal jump), for broad-phase checks.
+func (st *State) Span(i int) float64 {
+	return float64(len(st.Snakes[i].Body)) * st.Rules.MaxGap
+}
+
+// HeadRadiusFor returns the head radius of a snake of n body points.
+func HeadRadiusFor(n int, scale float64) float64 {
+	return (HeadRadius + math.Min(float64(n)*0.03, 6)) * scale
+}
+
+// BodyRadiusFor returns the body radius of a snake of n body points.
+func BodyRadiusFor(n int, scale float64) float64 {
+	return (BodyRadius + math.Min(float64(n)*0.025, 5)) * scale
+}
+
+// HeadPath returns the segment the head of body moved along in the last
+// step.
+func HeadPath(body []Vec2) (from, to Vec2) {
+	if len(body) > 1 {
+		return body[1], body[0]
+	}
+	return body[0], body[0]
+}
+
+// BodyAnchors returns the points a body hangs from: its head, and while it
+// passes through a portal, the point past the jump at index gap. Every body
+// point is within the body's span of one of them.
+func BodyAnchors(body []Vec2, gap int) []Vec2 {
+	if gap == 0 || gap >= len(body) {
+		return body[:1]
+	}
+	return []Vec2{body[0], body[gap]}
+}
+
+// nearBody reports whether p is within r of an anchor of s's body.
+func nearBody(s *Snake, p Vec2, r float64) bool {
+	for _, a := range BodyAnchors(s.Body, s.PortalGap) {
+		if DistSq(p.X, p.Y, a.X, a.Y) <= r*r {
+			return true
+		}
+	}
+	return false
+}
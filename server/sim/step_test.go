@@ -0,0 +1,291 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+// world returns a state in a square world of size 1000 with the default
+// game rules and one step per tick.
+func world(snakes ...Snake) State {
+	arena, _ := ArenaShapeFor("square", 1000)
+	return State{
+		Rules: Rules{
+			WorldSize: 1000, Arena: arena, BoundaryMargin: 50, WarningBand: 150,
+			Frac: 1, BaseSpeed: 3, BoostSpeed: 6, TurnSpeed: 0.08,
+			GrowthRate: 1, MaxGap: 8, SizeScale: 1,
+		},
+		Snakes: snakes,
+	}
+}
+
+// snake returns a living snake of n points 3 apart with its head at
+// (x, y), facing angle.
+func snake(x, y, angle float64, n int) Snake {
+	body, _ := LineBody(Vec2{X: x, Y: y}, angle, n, 3, false)
+	return Snake{
+		Body: body, Angle: angle, TargetAngle: angle, Speed: 3,
+		TargetLen: n, BodyLen: float64(n), Alive: true,
+	}
+}
+
+func near(a, b Vec2) bool {
+	return math.Abs(a.X-b.X) < 1e-9 && math.Abs(a.Y-b.Y) < 1e-9
+}
+
+func hasEvent(st State, e Event) bool {
+	for _, got := range st.Events {
+		if got == e {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStepMovesHead(t *testing.T) {
+	st := Step(world(snake(500, 500, 0, 10)), nil)
+	s := st.Snakes[0]
+	if want := (Vec2{X: 503, Y: 500}); !near(s.Body[0], want) {
+		t.Fatalf("head at %v, want %v", s.Body[0], want)
+	}
+	if len(s.Body) != 10 {
+		t.Fatalf("body has %d points, want 10", len(s.Body))
+	}
+}
+
+func TestStepTurnLimit(t *testing.T) {
+	st := Step(world(snake(500, 500, 0, 10)), []Input{{Angle: math.Pi / 2}})
+	s := st.Snakes[0]
+	want := 0.08 * turnOvershoot
+	if math.Abs(s.Angle-want) > 1e-9 || math.Abs(s.Turn-want) > 1e-9 {
+		t.Fatalf("angle %g, turn %g; want %g", s.Angle, s.Turn, want)
+	}
+	if s.TargetAngle != math.Pi/2 {
+		t.Fatalf("target angle %g, want π/2", s.TargetAngle)
+	}
+}
+
+func TestStepGrowth(t *testing.T) {
+	s := snake(500, 500, 0, 10)
+	s.TargetLen, s.AddPoint = 13, true
+	st := world(s)
+	st.Rules.GrowthRate = 2
+	st = Step(st, nil)
+	got := st.Snakes[0]
+	if len(got.Body) != 12 || got.BodyLen != 12 {
+		t.Fatalf("body %d points, length %g; want 12", len(got.Body), got.BodyLen)
+	}
+	if got.AddPoint {
+		t.Fatal("AddPoint still set after the step")
+	}
+	if !near(got.Body[1], Vec2{X: 500, Y: 500}) {
+		t.Fatalf("old head at %v, want it kept as the second point", got.Body[1])
+	}
+}
+
+func TestStepBoost(t *testing.T) {
+	a, b := snake(500, 300, 0, 20), snake(500, 600, 0, 20)
+	a.CanBoost = true
+	st := Step(world(a, b), []Input{{Boost: true}, {Boost: true}})
+	if s := st.Snakes[0]; s.Speed != 6 || !s.Boosting {
+		t.Fatalf("boosting snake: speed %g, boosting %v", s.Speed, s.Boosting)
+	}
+	if s := st.Snakes[1]; s.Speed != 3 || s.Boosting {
+		t.Fatalf("snake that can't boost: speed %g, boosting %v", s.Speed, s.Boosting)
+	}
+	// Without an input the speed of the tick is kept.
+	st = Step(st, nil)
+	if s := st.Snakes[0]; s.Speed != 6 {
+		t.Fatalf("speed %g after a step without input, want 6", s.Speed)
+	}
+}
+
+func TestStepEats(t *testing.T) {
+	st := world(snake(500, 500, 0, 10))
+	st.Food = []Food{
+		{Pos: Vec2{X: 510, Y: 500}, Radius: 6, Value: 2},
+		{Pos: Vec2{X: 800, Y: 800}, Radius: 6, Value: 1},
+		{Pos: Vec2{X: 505, Y: 505}, Radius: 6, Value: 3},
+	}
+	st = Step(st, nil)
+	if got := st.Snakes[0].TargetLen; got != 15 {
+		t.Fatalf("TargetLen %d, want 15", got)
+	}
+	if len(st.Food) != 1 || st.Food[0].Pos != (Vec2{X: 800, Y: 800}) {
+		t.Fatalf("food left %v, want only the far pellet", st.Food)
+	}
+	if len(st.Events) != 2 || st.Events[0] != (Event{Kind: Ate, Snake: 0, Other: 2}) || st.Events[1] != (Event{Kind: Ate, Snake: 0, Other: 0}) {
+		t.Fatalf("events %v", st.Events)
+	}
+}
+
+func TestStepWall(t *testing.T) {
+	player := snake(950, 500, 0, 10)
+	bot := snake(500, 952, math.Pi/2, 10)
+	bot.Bot = true
+	st := Step(world(player, bot), nil)
+	if st.Snakes[0].Alive || !hasEvent(st, Event{Kind: HitWall, Snake: 0}) {
+		t.Fatalf("player alive %v at the wall, events %v", st.Snakes[0].Alive, st.Events)
+	}
+	b := st.Snakes[1]
+	if !b.Alive || b.Body[0] != bot.Body[0] {
+		t.Fatalf("bot alive %v at %v, want it alive and held", b.Alive, b.Body[0])
+	}
+	if want := math.Atan2(500-952, 0); math.Abs(b.TargetAngle-want) > 1e-9 {
+		t.Fatalf("bot target angle %g, want %g (the center)", b.TargetAngle, want)
+	}
+}
+
+func TestStepWallBrake(t *testing.T) {
+	st := world(snake(900, 500, 0, 10), snake(949.9, 300, 0, 10))
+	st.Rules.WallBrake = true
+	st = Step(st, []Input{{}, {}})
+	a := st.Snakes[0]
+	// 100 from the edge is 50 into the 150 band: a third of the way
+	// from wallBrakeMin to full speed.
+	want := 3 * (wallBrakeMin + (1-wallBrakeMin)*50/150)
+	if math.Abs(a.Speed-want) > 1e-9 {
+		t.Fatalf("speed in the band %g, want %g", a.Speed, want)
+	}
+	b := st.Snakes[1]
+	if !b.Alive || b.Body[0] != (Vec2{X: 949.9, Y: 300}) {
+		t.Fatalf("snake at the line: alive %v at %v, want held", b.Alive, b.Body[0])
+	}
+}
+
+func TestStepHeadIntoBody(t *testing.T) {
+	runner := snake(500, 480, math.Pi/2, 10) // heading down into the body of o
+	o := snake(560, 500, 0, 40)              // body along y = 500 from x = 560 back to 443
+	st := Step(world(runner, o), nil)
+	if st.Snakes[0].Alive || !st.Snakes[1].Alive {
+		t.Fatalf("alive: runner %v, body %v", st.Snakes[0].Alive, st.Snakes[1].Alive)
+	}
+	if !hasEvent(st, Event{Kind: HitSnake, Snake: 0, Other: 1}) {
+		t.Fatalf("events %v", st.Events)
+	}
+}
+
+func TestStepInvulnerable(t *testing.T) {
+	runner := snake(500, 480, math.Pi/2, 10)
+	runner.Invulnerable = true
+	st := Step(world(runner, snake(560, 500, 0, 40)), nil)
+	if !st.Snakes[0].Alive || len(st.Events) != 0 {
+		t.Fatalf("invulnerable runner alive %v, events %v", st.Snakes[0].Alive, st.Events)
+	}
+}
+
+func TestStepHeadOn(t *testing.T) {
+	// Two standing snakes whose heads meet at right angles, each head
+	// within reach of the other's body.
+	headOn := func(la, lb int, rule HeadOnRule) State {
+		a := snake(500, 500, 0, la)
+		b := snake(500, 500, -math.Pi/2, lb)
+		a.Speed, b.Speed = 0, 0
+		st := world(a, b)
+		st.Rules.HeadOn = rule
+		return Step(st, nil)
+	}
+
+	st := headOn(30, 20, nil)
+	if !st.Snakes[0].Alive || st.Snakes[1].Alive {
+		t.Fatalf("longer: alive %v %v, events %v", st.Snakes[0].Alive, st.Snakes[1].Alive, st.Events)
+	}
+	if !hasEvent(st, Event{Kind: HeadOn, Snake: 0, Other: 1}) || !hasEvent(st, Event{Kind: HitSnake, Snake: 1, Other: 0}) {
+		t.Fatalf("longer: events %v", st.Events)
+	}
+
+	st = headOn(20, 20, nil)
+	if st.Snakes[0].Alive || st.Snakes[1].Alive || !hasEvent(st, Event{Kind: Traded, Snake: 0, Other: 1}) {
+		t.Fatalf("equal: alive %v %v, events %v", st.Snakes[0].Alive, st.Snakes[1].Alive, st.Events)
+	}
+
+	both, _ := HeadOnRuleFor("both")
+	st = headOn(30, 20, both)
+	if st.Snakes[0].Alive || st.Snakes[1].Alive {
+		t.Fatalf("both: alive %v %v", st.Snakes[0].Alive, st.Snakes[1].Alive)
+	}
+}
+
+func TestHeadOnRules(t *testing.T) {
+	long, short := snake(0, 0, 0, 30), snake(0, 0, 0, 20)
+	short.Speed = 6
+	if LongerWins(&short, &long) != &long || LongerWins(&long, &short) != &long {
+		t.Error("LongerWins doesn't pick the longer snake")
+	}
+	if FasterWins(&long, &short) != &short || FasterWins(&short, &long) != &short {
+		t.Error("FasterWins doesn't pick the faster snake")
+	}
+	if _, err := HeadOnRuleFor("sideways"); err == nil {
+		t.Error("HeadOnRuleFor accepted an unknown rule")
+	}
+}
+
+func TestStepPortal(t *testing.T) {
+	s := snake(300, 300, 0, 10)
+	s.AddPoint = true
+	st := world(s)
+	st.Rules.Portals = []PortalPair{{{X: 310, Y: 300}, {X: 700, Y: 700}}}
+	st.Rules.PortalCooldown = 180
+	st = Step(st, nil)
+	got := st.Snakes[0]
+	d := PortalRadius + HeadRadiusFor(len(got.Body), 1)
+	if want := (Vec2{X: 700 + d, Y: 700}); !near(got.Body[0], want) {
+		t.Fatalf("head at %v, want %v", got.Body[0], want)
+	}
+	if got.PortalGap != 1 || got.PortalCooldown != 180 {
+		t.Fatalf("gap %d, cooldown %d", got.PortalGap, got.PortalCooldown)
+	}
+	if !hasEvent(st, Event{Kind: Jumped, Snake: 0}) {
+		t.Fatalf("events %v", st.Events)
+	}
+	// The gap follows the first point past the jump as points are added.
+	st.Snakes[0].AddPoint = true
+	st = Step(st, nil)
+	if got := st.Snakes[0].PortalGap; got != 2 {
+		t.Fatalf("gap %d after adding a point, want 2", got)
+	}
+}
+
+func TestStepAvoid(t *testing.T) {
+	// A wall of bodies straight ahead of the bot.
+	bot := snake(300, 500, 0, 10)
+	bot.Bot = true
+	snakes := []Snake{bot}
+	for y := 380.0; y <= 620; y += 40 {
+		snakes = append(snakes, snake(420, y, math.Pi/2, 15))
+	}
+	in := make([]Input, len(snakes))
+	in[0] = Input{Angle: 0, Avoid: true}
+	for i := 1; i < len(in); i++ {
+		in[i].Angle = math.Pi / 2
+	}
+	st := Step(world(snakes...), in)
+	if got := st.Snakes[0].TargetAngle; math.Abs(got) < 0.3 {
+		t.Fatalf("target angle %g, want the bot to turn away from the wall", got)
+	}
+
+	// Nothing in the way: the wanted heading stands.
+	st = Step(world(bot), []Input{{Angle: 0.2, Avoid: true}})
+	if got := st.Snakes[0].TargetAngle; got != 0.2 {
+		t.Fatalf("target angle %g on a clear path, want 0.2", got)
+	}
+}
+
+type pairs [][]int
+
+func (p pairs) Hits(*State) [][]int { return p }
+
+func TestStepBroadphase(t *testing.T) {
+	// The broad phase decides who is tested: a hit it doesn't report
+	// doesn't count.
+	st := world(snake(500, 480, math.Pi/2, 10), snake(560, 500, 0, 40))
+	st.Rules.Broadphase = pairs{nil, nil}
+	if st = Step(st, nil); !st.Snakes[0].Alive {
+		t.Fatal("snake died from a hit the broad phase didn't report")
+	}
+	st = world(snake(500, 480, math.Pi/2, 10), snake(560, 500, 0, 40))
+	st.Rules.Broadphase = pairs{{1}, nil}
+	if st = Step(st, nil); st.Snakes[0].Alive {
+		t.Fatal("snake survived a reported hit")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Size classes
+//
+// In an open world a new player spawns among snakes thousands of points
+// long and mostly ends up as their food. MinLength and MaxLength give a
+// room a length bracket, so rooms can be set up as weight classes, for
+// example the main room for snakes up to 300 and a custom room for longer
+// ones:
+//
+//	config             {"maxLength": 300}
+//	POST /admin/rooms  {"id": "heavy", "rules": {"minLength": 300}}
+//
+// Custom rooms don't inherit the bracket of the startup config. Both bounds
+// are fixed for the life of a room.
+//
+// Once a second, every player whose snake has grown past MaxLength is
+// moved to a room whose bracket fits its length. A snake that spawns below
+// MinLength is moved the same way, so fresh joins and respawns in a
+// heavyweight room go back to a lighter one; a snake that only shrinks
+// below MinLength stays, as it earned its place.
+//
+// Rooms are separate worlds with their own game loops, so the move goes
+// through the client: the snake leaves the world quietly (no death, no
+// food, an AI takes its place) and the player gets a "sizeClass" event
+// with the room to reconnect to and a ticket. A join to that room with the
+// ticket within sizeTicketTTL gets a snake of the same length and score.
+// The bundled client does this on its own. Without a room that fits, the
+// snake stays where it is. /stats counts the moves as "classMoves".
+// ---------------------------------------------------------------------------
+
+const sizeTicketTTL = 30 * time.Second
+
+type sizeClassEvent struct {
+	Type   string `json:"t"`    // "sizeClass"
+	Room   string `json:"room"` // room to reconnect to, with ?room=
+	Ticket string `json:"ticket"`
+	Length int    `json:"length"`
+}
+
+// sizeTicket carries a snake's length and score to the room it moves to.
+type sizeTicket struct {
+	room          string
+	length, score int
+	expires       time.Time
+}
+
+// fitsClass reports whether cfg's length bracket admits a snake of length
+// n.
+func fitsClass(cfg *GameConfig, n int) bool {
+	return (cfg.MinLength == 0 || n >= cfg.MinLength) && (cfg.MaxLength == 0 || n <= cfg.MaxLength)
+}
+
+// classFor returns the ID of a room other than from whose bracket admits a
+// snake of length n, preferring the main room and then the extra rooms by
+// ID.
+func (rs *Rooms) classFor(from string, n int) (string, bool) {
+	var best string
+	for _, r := range rs.all() {
+		if r.id == from || !fitsClass(&r.cfg, n) {
+			continue
+		}
+		if r.id == DefaultRoomID {
+			return r.id, true
+		}
+		if best == "" || r.id < best {
+			best = r.id
+		}
+	}
+	return best, best != ""
+}
+
+// issueTicket stores a ticket for a snake of the given length and score
+// moving to room, and returns its token.
+func (rs *Rooms) issueTicket(room string, length, score int) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	now := time.Now()
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for t, st := range rs.tickets {
+		if now.After(st.expires) {
+			delete(rs.tickets, t)
+		}
+	}
+	rs.tickets[token] = sizeTicket{room: room, length: length, score: score, expires: now.Add(sizeTicketTTL)}
+	return token
+}
+
+// redeemTicket returns and forgets the ticket token for room, if it is
+// valid.
+func (rs *Rooms) redeemTicket(token, room string) (sizeTicket, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	st, ok := rs.tickets[token]
+	if !ok || st.room != room || time.Now().After(st.expires) {
+		return sizeTicket{}, false
+	}
+	delete(rs.tickets, token)
+	return st, true
+}
+
+// applySizeTicket gives the new snake of p the length and score of the
+// ticket p joined with, if it is valid here.
+func (g *Game) applySizeTicket(p *Player) {
+	token := p.sizeTicket
+	p.sizeTicket = ""
+	if token == "" || g.rooms == nil {
+		return
+	}
+	st, ok := g.rooms.redeemTicket(token, g.roomID)
+	if !ok {
+		g.log.Debug("size class ticket rejected", "playerID", p.id)
+		return
+	}
+	s := p.snake
+	s.TargetLen = max(st.length, s.TargetLen)
+	s.Score = st.score
+}
+
+// updateSizeClasses moves the players whose snakes have outgrown the
+// room's bracket. Called once a second.
+func (g *Game) updateSizeClasses() {
+	if g.cfg.MaxLength == 0 {
+		return
+	}
+	for _, p := range g.players {
+		if s := p.snake; s != nil && s.Alive && s.TargetLen > g.cfg.MaxLength {
+			g.moveSizeClass(p)
+		}
+	}
+}
+
+// checkSpawnClass moves p if its snake has just spawned outside the room's
+// bracket.
+func (g *Game) checkSpawnClass(p *Player) {
+	if s := p.snake; s != nil && !fitsClass(&g.cfg, s.TargetLen) {
+		g.moveSizeClass(p)
+	}
+}
+
+// moveSizeClass takes the snake of p out of the world and sends p to a
+// room whose bracket fits it, if there is one.
+func (g *Game) moveSizeClass(p *Player) {
+	s := p.snake
+	if g.rooms == nil {
+		return
+	}
+	room, ok := g.rooms.classFor(g.roomID, s.TargetLen)
+	if !ok {
+		return
+	}
+	ticket := g.rooms.issueTicket(room, s.TargetLen, s.Score)
+	g.recordProfile(p)
+	g.replaceWithAI(p)
+	p.snake = nil
+	p.setCamera(nil)
+	g.sizeClassMoves++
+	g.log.Info("player moved to another size class", "playerID", p.id, "name", p.name, "length", s.TargetLen, "to", room)
+	g.sendEvent(p, sizeClassEvent{Type: "sizeClass", Room: room, Ticket: ticket, Length: s.TargetLen})
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Snapshot / restore of the full world state
+// ---------------------------------------------------------------------------
+
+const snapshotVersion = 1
+
+// splitMix64 is a tiny rand.Source64 whose whole state is one uint64, so the
+// game RNG can be saved and restored with the world.
+type splitMix64 struct {
+	state uint64
+}
+
+func (s *splitMix64) Seed(seed int64) { s.state = uint64(seed) }
+
+func (s *splitMix64) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix64) Int63() int64 { return int64(s.Uint64() >> 1) }
+
+type gameSnapshot struct {
+	Version   int       `json:"version"`
+	SavedAt   time.Time `json:"savedAt"`
+	Frame     int       `json:"frame"`
+	NetTick   int       `json:"netTick"`
+	RNG       uint64    `json:"rng"`
+	AIIDCount int64     `json:"aiIdCounter"`
+	Snakes    []*Snake  `json:"snakes"` // AI only; player snakes end with their connection
+	Foods     []*Food   `json:"foods"`
+
+	TotalJoins  int64 `json:"totalJoins"`
+	TotalLeaves int64 `json:"totalLeaves"`
+	TotalKills  int64 `json:"totalKills"`
+	PeakPlayers int   `json:"peakPlayers"`
+
+	AIKills        int64 `json:"aiKills,omitempty"`
+	PlayerKills    int64 `json:"playerKills,omitempty"`
+	BoundaryDeaths int64 `json:"boundaryDeaths,omitempty"`
+	PredatorKills  int64 `json:"predatorKills,omitempty"`
+	BestStreak     int   `json:"bestStreak,omitempty"`
+	Assists        int64 `json:"assists,omitempty"`
+
+	Profiles map[string]*Profile `json:"profiles,omitempty"` // by identity (see identity.go)
+}
+
+// captureSnapshot deep-copies the world state. Called from the game loop only.
+func (g *Game) captureSnapshot() *gameSnapshot {
+	snap := &gameSnapshot{
+		Version:     snapshotVersion,
+		SavedAt:     time.Now(),
+		Frame:       g.frame,
+		NetTick:     g.netTick,
+		RNG:         g.rngSrc.state,
+		AIIDCount:   atomic.LoadInt64(&aiIDCounter),
+		Foods:       make([]*Food, len(g.foods)),
+		TotalJoins:  g.totalJoins,
+		TotalLeaves: g.totalLeaves,
+		TotalKills:  g.totalKills,
+		PeakPlayers: g.peakPlayers,
+
+		AIKills:        g.aiKills,
+		PlayerKills:    g.playerKills,
+		BoundaryDeaths: g.boundaryDeaths,
+		PredatorKills:  g.predatorKills,
+		BestStreak:     g.bestStreak,
+		Assists:        g.totalAssists,
+	}
+	for _, s := range g.snakes {
+		if !s.IsAI {
+			continue
+		}
+		c := *s
+		c.Segments = append([]Vec2(nil), s.Segments...)
+		snap.Snakes = append(snap.Snakes, &c)
+	}
+	for i, f := range g.foods {
+		c := *f
+		snap.Foods[i] = &c
+	}
+	snap.Profiles = make(map[string]*Profile, len(g.profiles))
+	for id, prof := range g.profiles {
+		snap.Profiles[id] = prof.clone()
+	}
+	return snap
+}
+
+// SaveSnapshot writes the current world state as JSON. The game loop must
+// be running; the state is captured between ticks.
+func (g *Game) SaveSnapshot(w io.Writer) error {
+	reply := make(chan *gameSnapshot, 1)
+	snap, ok := ask(g, g.snapshotReqCh, reply, reply)
+	if !ok {
+		return errRoomStopped
+	}
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// LoadSnapshot replaces the world state with a saved snapshot. Must be
+// called before Run. Missing AI snakes (slots that belonged to players when
+// the snapshot was taken) are respawned to reach the configured AI count.
+func (g *Game) LoadSnapshot(r io.Reader) error {
+	var snap gameSnapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return err
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	g.snakes = g.snakes[:0]
+	for _, s := range snap.Snakes {
+		if len(s.Segments) == 0 || !s.IsAI {
+			continue
+		}
+		s.cell = -1
+		s.id = g.newEntityID()
+		s.bodyLen = float64(len(s.Segments))
+		g.snakes = append(g.snakes, s)
+	}
+	g.foods = g.foods[:0]
+	for _, f := range snap.Foods {
+		g.placeFood(f) // food IDs are not saved
+	}
+	g.trails = nil
+	g.frame = snap.Frame
+	g.netTick = snap.NetTick
+	g.rngSrc.state = snap.RNG
+	atomic.StoreInt64(&aiIDCounter, snap.AIIDCount)
+	g.totalJoins = snap.TotalJoins
+	g.totalLeaves = snap.TotalLeaves
+	g.totalKills = snap.TotalKills
+	g.peakPlayers = snap.PeakPlayers
+	g.aiKills = snap.AIKills
+	g.playerKills = snap.PlayerKills
+	g.boundaryDeaths = snap.BoundaryDeaths
+	g.predatorKills = snap.PredatorKills
+	g.bestStreak = snap.BestStreak
+	g.totalAssists = snap.Assists
+	g.bwLastSec = g.frame
+	if g.rotation != nil {
+		g.rotation.scheduleSwitch(g.frame)
+	}
+	if snap.Profiles != nil {
+		g.profiles = snap.Profiles
+	}
+
+	if missing := g.cfg.AICount - len(g.snakes); missing > 0 {
+		g.syncAICount(g.cfg.AICount - missing)
+	}
+	return nil
+}
+
+// EnableAutosave writes a snapshot to path every interval. Must be called
+// before Run.
+func (g *Game) EnableAutosave(path string, interval time.Duration) {
+	g.autosavePath = path
+	g.autosaveEvery = int(interval.Seconds() * TickRate)
+	if g.autosaveEvery < 1 {
+		g.autosaveEvery = 1
+	}
+}
+
+// LoadSnapshotFile restores the world from path. Must be called before Run.
+func (g *Game) LoadSnapshotFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return g.LoadSnapshot(f)
+}
+
+// writeSnapshotFile atomically replaces path with snap (temp file + rename).
+func writeSnapshotFile(path string, snap *gameSnapshot) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := json.NewEncoder(tmp).Encode(snap); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// autosave captures the world on the game loop and writes it in the
+// background so the tick isn't blocked on disk I/O.
+func (g *Game) autosave() {
+	if g.autosaveBusy.Load() {
+		return
+	}
+	g.autosaveBusy.Store(true)
+	snap := g.captureSnapshot()
+	path := g.autosavePath
+	go func() {
+		defer g.autosaveBusy.Store(false)
+		start := time.Now()
+		if err := writeSnapshotFile(path, snap); err != nil {
+			g.log.Error("autosave failed", "path", path, "err", err)
+			return
+		}
+		g.log.Debug("autosaved world", "path", path, "snakes", len(snap.Snakes),
+			"food", len(snap.Foods), "took", time.Since(start))
+	}()
+}
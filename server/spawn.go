@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Spawn placement and respawn protection
+//
+// The SpawnPolicy (see spawnpolicy.go) picks where new snakes appear. The
+// basic search samples SpawnSamples random positions and takes the first
+// one with no living snake body within SpawnClearance, or failing that the
+// one farthest from any body. New snakes are invulnerable for
+// SpawnProtection ticks; a player's protection ends early as soon as they
+// boost or steer away from the first direction they sent after spawning.
+// ---------------------------------------------------------------------------
+
+const (
+	SpawnSamples      = 16
+	spawnSteerEndsInv = 0.35 // rad of steering that ends spawn protection
+)
+
+// clearSpawnPos returns a spawn point away from other snakes.
+func (g *Game) clearSpawnPos() Vec2 {
+	limit := g.cfg.SpawnClearance
+	if limit <= 0 {
+		return g.randWorldPos()
+	}
+	var best Vec2
+	bestD := -1.0
+	for i := 0; i < SpawnSamples; i++ {
+		p := g.randWorldPos()
+		d := g.clearance(p, limit)
+		if d >= limit {
+			return p
+		}
+		if d > bestD {
+			best, bestD = p, d
+		}
+	}
+	return best
+}
+
+// clearance returns the distance from p to the nearest living snake body
+// point (every other point is enough at spawn scale), capped at limit.
+func (g *Game) clearance(p Vec2, limit float64) float64 {
+	minSq := limit * limit
+	for _, s := range g.snakes {
+		if !s.Alive {
+			continue
+		}
+		for k := 0; k < len(s.Segments); k += 2 {
+			seg := s.Segments[k]
+			if d := sim.DistSq(p.X, p.Y, seg.X, seg.Y); d < minSq {
+				minSq = d
+			}
+		}
+	}
+	return math.Sqrt(minSq)
+}
+
+// checkSpawnInput ends s's spawn protection once the player acts. Clients
+// send input every frame, so only boosting or a real change of direction
+// counts, not the first message after spawning.
+func checkSpawnInput(s *Snake, msg InputMsg) {
+	if s.InvTimer <= 0 {
+		return
+	}
+	if !s.spawnInput {
+		s.spawnInput, s.spawnAngle = true, msg.Angle
+		if !msg.Boost {
+			return
+		}
+	}
+	if msg.Boost || math.Abs(sim.AngleDiff(s.spawnAngle, msg.Angle)) > spawnSteerEndsInv {
+		s.InvTimer = 0
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Spawn policies
+//
+// The default policy places AI snakes with the basic clearance search and
+// matchmakes human players: it samples candidate points and scores each by
+// the body density around it (from the AI steering grid) and by how close
+// the biggest snakes are, then takes the lowest score. Beginners, players
+// whose name hasn't been seen on this server yet, get BeginnerSpawnFactor
+// times the distance. With RatingSpawn, players rated much higher than the
+// spawning one are avoided like the biggest snakes (see rating.go). Modes
+// can install their own rules with SetSpawnPolicy.
+// ---------------------------------------------------------------------------
+
+const (
+	SpawnDensityRadius    = 400.0  // body points counted around a candidate
+	SpawnBigSnakeDistance = 1500.0 // preferred distance from the biggest snakes
+	SpawnBigSnakes        = 3      // how many of the biggest snakes to avoid
+	BeginnerSpawnFactor   = 2.0    // distance multiplier for beginners
+	spawnBigSnakeCost     = 200.0  // score of a candidate right next to a big snake
+	maxSeenNames          = 50000  // beginner tracking forgets everyone past this
+)
+
+// SpawnPolicy decides where new snakes appear. Methods run on the game loop
+// goroutine.
+type SpawnPolicy interface {
+	Name() string
+	// SpawnPos returns the spawn point for a new snake of p, or of an AI
+	// snake if p is nil.
+	SpawnPos(g *Game, p *Player) Vec2
+}
+
+// SetSpawnPolicy replaces the spawn policy. Must be called before Run.
+func (g *Game) SetSpawnPolicy(sp SpawnPolicy) {
+	g.spawn = sp
+}
+
+func (g *Game) spawnPos(p *Player) Vec2 {
+	return g.spawn.SpawnPos(g, p)
+}
+
+type balancedSpawn struct{}
+
+func (balancedSpawn) Name() string { return "balanced" }
+
+func (balancedSpawn) SpawnPos(g *Game, p *Player) Vec2 {
+	if p == nil || g.cfg.SpawnClearance <= 0 {
+		return g.clearSpawnPos()
+	}
+	factor := 1.0
+	if p.beginner {
+		factor = BeginnerSpawnFactor
+	}
+	avoid := SpawnBigSnakeDistance * factor
+	big := g.biggestSnakes(SpawnBigSnakes)
+	if g.cfg.RatingSpawn {
+		big = append(big, g.strongerSnakes(p)...)
+	}
+	d := g.bodyDensity()
+
+	var best Vec2
+	bestScore := math.Inf(1)
+	for i := 0; i < SpawnSamples*2; i++ {
+		c := g.randWorldPos()
+		score := d.Within(c, SpawnDensityRadius*factor)
+		for _, b := range big {
+			h := b.Segments[0]
+			if bd := sim.Dist(h.X, h.Y, c.X, c.Y); bd < avoid {
+				score += (1 - bd/avoid) * spawnBigSnakeCost
+			}
+		}
+		if score < bestScore {
+			best, bestScore = c, score
+		}
+	}
+	return best
+}
+
+// biggestSnakes returns up to n living snakes that are at least three times
+// the base length, longest first.
+func (g *Game) biggestSnakes(n int) []*Snake {
+	var big []*Snake
+	for _, s := range g.snakes {
+		if s.Alive && len(s.Segments) >= 3*g.cfg.BaseSnakeLen {
+			big = append(big, s)
+		}
+	}
+	sort.Slice(big, func(i, j int) bool { return len(big[i].Segments) > len(big[j].Segments) })
+	return big[:min(len(big), n)]
+}
+
+// firstSession reports whether name hasn't joined this server before, and
+// remembers it.
+func (g *Game) firstSession(name string) bool {
+	key := strings.ToLower(name)
+	if g.seenNames[key] {
+		return false
+	}
+	if len(g.seenNames) >= maxSeenNames {
+		clear(g.seenNames)
+	}
+	g.seenNames[key] = true
+	return true
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// ---------------------------------------------------------------------------
+// Death report and killer camera
+//
+// When a player's snake dies the client gets a "death" event with the cause,
+// the killer and the final stats. Until the player respawns, their state
+// frames are centered on the killer (the camera target) instead of the
+// corpse. If the target dies too, the camera moves on to its killer and the
+// client is told with a "spectate" event.
+// ---------------------------------------------------------------------------
+
+type deathEvent struct {
+	Type         string `json:"t"`     // "death"
+	Cause        string `json:"cause"` // collision, trail, boundary or predator
+	KillerID     int    `json:"killerId,omitempty"`
+	Killer       string `json:"killer,omitempty"`
+	KillerAI     bool   `json:"killerAi,omitempty"` // killer is an AI snake
+	Score        int    `json:"score"`
+	Length       int    `json:"length"`
+	Kills        int    `json:"kills"`
+	BestStreak   int    `json:"bestStreak,omitempty"` // longest kill streak of this life (see streak.go)
+	Assists      int    `json:"assists,omitempty"`    // kill assists of this life (see assist.go)
+	AliveSec     int    `json:"aliveSec"`
+	Best         int    `json:"best,omitempty"`   // best score of the player's identity
+	XP           int    `json:"xp,omitempty"`     // XP earned (see xp.go)
+	Rating       int    `json:"rating,omitempty"` // skill rating after this death (see rating.go)
+	RatingChange int    `json:"ratingChange,omitempty"`
+	Camera       int    `json:"camera,omitempty"`    // snake ID the view follows until respawn
+	RespawnIn    int    `json:"respawnIn,omitempty"` // ms until a respawn is accepted (see respawn.go)
+
+	AssistedBy []string `json:"assistedBy,omitempty"` // snakes that boxed the victim in (see assist.go)
+
+	// Entity IDs of the killer and the camera, for protocol v6 clients
+	KillerEntity uint32 `json:"killerEntity,omitempty"`
+	CameraEntity uint32 `json:"cameraEntity,omitempty"`
+}
+
+type spectateEvent struct {
+	Type         string `json:"t"` // "spectate"
+	Camera       int    `json:"camera"`
+	CameraEntity uint32 `json:"cameraEntity"`
+}
+
+// reportDeath credits the killer killSnake recorded (none for boundary
+// deaths), sends victim's player the death report and moves cameras that
+// were following victim. Called after killSnake.
+func (g *Game) reportDeath(victim *Snake) {
+	killer := victim.killedBy
+	ratingChange := 0
+	if killer != nil {
+		ratingChange = g.rateKill(victim, killer)
+		killer.kills++
+		g.creditPack(victim, killer)
+		g.creditStreak(killer)
+		g.unlock(killer, achFirstKill)
+		g.advanceChallenges(killer, chKills, 1)
+		if killer.IsBoosting {
+			g.advanceChallenges(killer, chBoostKills, 1)
+		}
+	}
+	g.creditAssists(victim)
+	g.directorKill(victim, killer)
+	target := killer
+	if target != nil && !target.Alive {
+		target = nil
+	}
+
+	for _, p := range g.players {
+		if p.snake == victim {
+			ev := deathEvent{
+				Type: "death", Cause: victim.deathCause, Score: victim.Score, Length: len(victim.Segments),
+				Kills: victim.kills, BestStreak: victim.streak.best, Assists: victim.assists, AliveSec: (g.frame - victim.spawnFrame) / TickRate,
+				Best: g.recordProfile(p), AssistedBy: assistNames(victim),
+			}
+			if ev.Best > 0 {
+				ev.XP = g.xpFor(victim)
+			}
+			if prof := g.profiles[p.identity]; prof != nil {
+				ev.Rating, ev.RatingChange = prof.rating(), ratingChange
+			}
+			if killer != nil {
+				ev.KillerID, ev.KillerEntity, ev.Killer = killer.PlayerID, killer.id, killer.Name
+				ev.KillerAI = killer.IsAI
+			}
+			if target != nil {
+				ev.Camera, ev.CameraEntity = target.PlayerID, target.id
+			}
+			p.setCamera(target)
+			ev.RespawnIn = g.startRespawnCooldown(p, target)
+			g.sendEvent(p, ev)
+		} else if p.camera == victim && p.snake != nil && !p.snake.Alive {
+			p.setCamera(target)
+			if target != nil {
+				g.sendEvent(p, spectateEvent{Type: "spectate", Camera: target.PlayerID, CameraEntity: target.id})
+			}
+		}
+	}
+}
+
+func (p *Player) setCamera(s *Snake) {
+	p.camera = s
+	p.cameraID = 0
+	if s != nil {
+		p.cameraID = s.id
+	}
+}
+
+// cameraTarget returns the snake p's view is centered on: their own snake,
+// or while dead (or directing, without a snake) the snake they are watching,
+// if it is still the same one. respawnAI reuses Snake values, so the ID is
+// checked too.
+func (p *Player) cameraTarget() *Snake {
+	if (p.snake == nil || !p.snake.Alive) && p.camera != nil &&
+		p.camera.Alive && p.camera.id == p.cameraID {
+		return p.camera
+	}
+	return p.snake
+}
+
+// sendEvent queues a JSON event for one player, dropping it if the queue
+// is full.
+func (g *Game) sendEvent(p *Player, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		g.log.Error("failed to encode event", "err", err)
+		return
+	}
+	select {
+	case p.textCh <- data:
+	default:
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "snake-server/sim"
+
+// ---------------------------------------------------------------------------
+// Body-density grid
+//
+// Bot steering (see sim/steer.go) and spawn placement (see spawnpolicy.go
+// and fill.go) look at how crowded a place is through a grid of body
+// points per SteerCellSize cell, built at most once per frame.
+// ---------------------------------------------------------------------------
+
+const SteerCellSize = sim.SteerCellSize
+
+// densityGrid is the body-density grid of a frame.
+type densityGrid struct {
+	frame int
+	built bool
+	sim.Density
+}
+
+func (g *Game) bodyDensity() *densityGrid {
+	d := &g.density
+	if d.built && d.frame == g.frame {
+		return d
+	}
+	d.Reset(float64(g.cfg.WorldSize))
+	d.frame, d.built = g.frame, true
+	for _, s := range g.snakes {
+		if s.Alive {
+			d.Add(s.Segments, 1)
+		}
+	}
+	return d
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Persistent storage
+//
+// With -data-dir, data that should outlive a world goes to a Storage
+// backend: player profiles, the all-time leaderboard, bans and tournament
+// match history. The built-in backend is SQLite (see storage_sqlite.go);
+// other backends only need to implement Storage.
+//
+// Storage calls can block on I/O, so the game loop never makes them
+// directly: it queues writes with g.persist, and a single writer goroutine
+// applies them in order. HTTP handlers read from the Storage directly.
+// ---------------------------------------------------------------------------
+
+const (
+	storeQueueSize  = 1024
+	maxStoredScores = 100 // rows returned by /leaderboard and /matches at most
+)
+
+// Storage is a persistence backend. Implementations must be safe for
+// concurrent use.
+type Storage interface {
+	Name() string
+
+	LoadProfiles() (map[string]*Profile, error)
+	SaveProfile(id string, p Profile) error
+	DeleteProfiles(ids []string) error
+
+	AddScore(ScoreRecord) error
+	TopScores(limit int) ([]ScoreRecord, error)
+	// TopStreaks returns up to limit games with a kill streak, longest
+	// streak first.
+	TopStreaks(limit int) ([]ScoreRecord, error)
+	// TopRatings returns up to limit rated identities, best first.
+	TopRatings(limit int) ([]RatingRecord, error)
+
+	LoadBans() ([]Ban, error)
+	SaveBan(Ban) error
+	DeleteBan(prefix string) error
+
+	AddMatch(MatchRecord) error
+	// Matches returns up to limit matches with an ID below before (0 =
+	// the newest), newest first.
+	Matches(before int64, limit int) ([]MatchRecord, error)
+
+	Close() error
+}
+
+// ScoreRecord is one finished game on the all-time leaderboard.
+type ScoreRecord struct {
+	Name       string    `json:"name"`
+	Identity   string    `json:"identity,omitempty"`
+	Score      int       `json:"score"`
+	Kills      int       `json:"kills"`
+	BestStreak int       `json:"bestStreak"` // longest kill streak (see streak.go)
+	At         time.Time `json:"at"`
+}
+
+// RatingRecord is one identity on the rating leaderboard.
+type RatingRecord struct {
+	Name     string `json:"name"` // account name, else the name of the latest stored game
+	Identity string `json:"identity"`
+	Rating   int    `json:"rating"`
+	Games    int    `json:"games"`
+}
+
+// EnableStorage loads the stored profiles (they replace those from a
+// snapshot) and starts the writer. Must be called before Run.
+func (g *Game) EnableStorage(st Storage) error {
+	profiles, err := st.LoadProfiles()
+	if err != nil {
+		return err
+	}
+	for id, prof := range profiles {
+		g.profiles[id] = prof
+	}
+	g.store = st
+	g.storeCh = make(chan func(Storage) error, storeQueueSize)
+	g.writers.Add(1)
+	go func() {
+		defer g.writers.Done()
+		for fn := range g.storeCh {
+			if err := fn(st); err != nil {
+				g.log.Error("storage write failed", "backend", st.Name(), "err", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// persist queues a write. Called from the game loop only; writes are
+// dropped (and logged) if the backend falls too far behind.
+func (g *Game) persist(fn func(Storage) error) {
+	if g.store == nil {
+		return
+	}
+	select {
+	case g.storeCh <- fn:
+	default:
+		g.log.Warn("storage queue full, dropping write", "backend", g.store.Name())
+	}
+}
+
+func (g *Game) persistProfile(id string, prof *Profile) {
+	if !prof.earned() {
+		return // kept in memory only until it earns something (see identity.go)
+	}
+	cp := prof.clone()
+	g.persist(func(st Storage) error { return st.SaveProfile(id, *cp) })
+}
+
+// persistGame records p's finished game on the leaderboard.
+func (g *Game) persistGame(p *Player) {
+	if p.snake.Score <= 0 {
+		return
+	}
+	rec := ScoreRecord{Name: p.snake.Name, Identity: p.identity, Score: p.snake.Score, Kills: p.snake.kills,
+		BestStreak: p.snake.streak.best, At: time.Now()}
+	g.persist(func(st Storage) error { return st.AddScore(rec) })
+}
+
+// queryLimit reads ?limit=, defaulting to def and capped at maxStoredScores.
+func queryLimit(r *http.Request, def int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return min(n, maxStoredScores)
+}
+
+// HandleLeaderboard serves /leaderboard: the best stored games, with
+// ?by=streak the games with the longest kill streaks, or with ?by=rating
+// the best rated identities.
+func HandleLeaderboard(st Storage, w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Query().Get("by") {
+	case "streak":
+		scores, err := st.TopStreaks(queryLimit(r, 10))
+		if err != nil {
+			slog.Error("failed to read streak leaderboard", "err", err)
+			http.Error(w, "storage error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"scores": scores})
+		return
+	case "rating":
+		ratings, err := st.TopRatings(queryLimit(r, 10))
+		if err != nil {
+			slog.Error("failed to read rating leaderboard", "err", err)
+			http.Error(w, "storage error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"ratings": ratings})
+		return
+	}
+	scores, err := st.TopScores(queryLimit(r, 10))
+	if err != nil {
+		slog.Error("failed to read leaderboard", "err", err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"scores": scores})
+}
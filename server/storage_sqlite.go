@@ -0,0 +1,415 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go SQLite driver
+)
+
+// ---------------------------------------------------------------------------
+// SQLite storage backend
+//
+// One database file, <data-dir>/snake.db, in WAL mode. The schema version
+// is kept in PRAGMA user_version; migrations are applied in order at open,
+// each in its own transaction. Append new migrations, never edit old ones.
+// ---------------------------------------------------------------------------
+
+const sqliteFile = "snake.db"
+
+var sqliteMigrations = []string{
+	// 1: initial schema
+	`CREATE TABLE profiles (
+		id        TEXT PRIMARY KEY,
+		color     INTEGER NOT NULL,
+		best      INTEGER NOT NULL,
+		games     INTEGER NOT NULL,
+		kills     INTEGER NOT NULL,
+		last_seen INTEGER NOT NULL, -- unix seconds
+		name      TEXT NOT NULL DEFAULT '',
+		provider  TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE scores (
+		id       INTEGER PRIMARY KEY AUTOINCREMENT,
+		name     TEXT NOT NULL,
+		identity TEXT NOT NULL,
+		score    INTEGER NOT NULL,
+		kills    INTEGER NOT NULL,
+		at       INTEGER NOT NULL
+	);
+	CREATE INDEX scores_by_score ON scores (score DESC);
+	CREATE TABLE bans (
+		prefix TEXT PRIMARY KEY,
+		reason TEXT NOT NULL
+	);
+	CREATE TABLE matches (
+		id       INTEGER PRIMARY KEY AUTOINCREMENT,
+		round    INTEGER NOT NULL,
+		ended_at INTEGER NOT NULL,
+		winner   TEXT NOT NULL,
+		results  TEXT NOT NULL -- RoundResults as JSON
+	);`,
+
+	// 2: match start times and participants in their own table
+	`ALTER TABLE matches ADD COLUMN started_at INTEGER NOT NULL DEFAULT 0;
+	UPDATE matches SET started_at = ended_at;
+	CREATE TABLE match_players (
+		match_id INTEGER NOT NULL REFERENCES matches (id),
+		place    INTEGER NOT NULL, -- 1 = best score
+		name     TEXT NOT NULL,
+		identity TEXT NOT NULL,
+		is_ai    INTEGER NOT NULL,
+		score    INTEGER NOT NULL,
+		kills    INTEGER NOT NULL,
+		PRIMARY KEY (match_id, place)
+	);
+	CREATE INDEX match_players_by_identity ON match_players (identity);
+	INSERT INTO match_players (match_id, place, name, identity, is_ai, score, kills)
+		SELECT m.id, s.key + 1, json_extract(s.value, '$.name'), '',
+			coalesce(json_extract(s.value, '$.isAI'), 0), json_extract(s.value, '$.score'), 0
+		FROM matches m, json_each(m.results, '$.standings') s;
+	ALTER TABLE matches DROP COLUMN results;`,
+
+	// 3: achievements and food eaten
+	`ALTER TABLE profiles ADD COLUMN food INTEGER NOT NULL DEFAULT 0;
+	CREATE TABLE achievements (
+		identity    TEXT NOT NULL,
+		achievement TEXT NOT NULL,
+		unlocked_at INTEGER NOT NULL,
+		PRIMARY KEY (identity, achievement)
+	);`,
+
+	// 4: challenge progress
+	`ALTER TABLE profiles ADD COLUMN challenges TEXT NOT NULL DEFAULT '{}';`,
+
+	// 5: XP and skin
+	`ALTER TABLE profiles ADD COLUMN xp INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE profiles ADD COLUMN skin INTEGER NOT NULL DEFAULT 0;`,
+
+	// 6: skill rating
+	`ALTER TABLE profiles ADD COLUMN rating INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX profiles_by_rating ON profiles (rating DESC);
+	CREATE INDEX scores_by_identity ON scores (identity, id);`,
+
+	// 7: best kill streaks
+	`ALTER TABLE scores ADD COLUMN best_streak INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE match_players ADD COLUMN best_streak INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX scores_by_streak ON scores (best_streak DESC);`,
+
+	// 8: kill assists
+	`ALTER TABLE match_players ADD COLUMN assists INTEGER NOT NULL DEFAULT 0;`,
+}
+
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens (creating if needed) the database in dir and migrates it
+// to the current schema.
+func OpenSQLite(dir string) (Storage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	dsn := "file:" + filepath.Join(dir, sqliteFile) + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1) // one writer; reads are short
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database schema version %d is newer than this server (%d)", version, len(sqliteMigrations))
+	}
+	for v := version; v < len(sqliteMigrations); v++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[v]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", v+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", v+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) Name() string { return "sqlite" }
+
+func (s *sqliteStore) Close() error { return s.db.Close() }
+
+func (s *sqliteStore) LoadProfiles() (map[string]*Profile, error) {
+	rows, err := s.db.Query("SELECT id, color, best, games, kills, food, last_seen, name, provider, challenges, xp, skin, rating FROM profiles")
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]*Profile)
+	for rows.Next() {
+		var id, challenges string
+		var seen int64
+		p := &Profile{}
+		if err := rows.Scan(&id, &p.Color, &p.Best, &p.Games, &p.Kills, &p.Food, &seen, &p.Name, &p.Provider, &challenges, &p.XP, &p.Skin, &p.Rating); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		p.LastSeen = time.Unix(seen, 0)
+		json.Unmarshal([]byte(challenges), &p.Challenges)
+		profiles[id] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query("SELECT identity, achievement, unlocked_at FROM achievements")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, ach string
+		var at int64
+		if err := rows.Scan(&id, &ach, &at); err != nil {
+			return nil, err
+		}
+		if p := profiles[id]; p != nil {
+			if p.Achievements == nil {
+				p.Achievements = make(map[string]time.Time)
+			}
+			p.Achievements[ach] = time.Unix(at, 0)
+		}
+	}
+	return profiles, rows.Err()
+}
+
+func (s *sqliteStore) SaveProfile(id string, p Profile) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	challenges, err := json.Marshal(p.Challenges)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO profiles (id, color, best, games, kills, food, last_seen, name, provider, challenges, xp, skin, rating)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET color = excluded.color, best = excluded.best, games = excluded.games,
+			kills = excluded.kills, food = excluded.food, last_seen = excluded.last_seen, name = excluded.name,
+			provider = excluded.provider, challenges = excluded.challenges, xp = excluded.xp, skin = excluded.skin,
+			rating = excluded.rating`,
+		id, p.Color, p.Best, p.Games, p.Kills, p.Food, p.LastSeen.Unix(), p.Name, p.Provider, string(challenges), p.XP, p.Skin,
+		p.Rating)
+	if err != nil {
+		return err
+	}
+	// Achievements are never taken away.
+	for ach, at := range p.Achievements {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO achievements (identity, achievement, unlocked_at) VALUES (?, ?, ?)",
+			id, ach, at.Unix()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) DeleteProfiles(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	if _, err := s.db.Exec("DELETE FROM achievements WHERE identity IN ("+marks+")", args...); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("DELETE FROM profiles WHERE id IN ("+marks+")", args...)
+	return err
+}
+
+func (s *sqliteStore) AddScore(r ScoreRecord) error {
+	_, err := s.db.Exec("INSERT INTO scores (name, identity, score, kills, best_streak, at) VALUES (?, ?, ?, ?, ?, ?)",
+		r.Name, r.Identity, r.Score, r.Kills, r.BestStreak, r.At.Unix())
+	return err
+}
+
+func (s *sqliteStore) TopScores(limit int) ([]ScoreRecord, error) {
+	return s.queryScores("SELECT name, identity, score, kills, best_streak, at FROM scores ORDER BY score DESC, id LIMIT ?", limit)
+}
+
+func (s *sqliteStore) TopStreaks(limit int) ([]ScoreRecord, error) {
+	return s.queryScores(`SELECT name, identity, score, kills, best_streak, at FROM scores
+		WHERE best_streak > 0 ORDER BY best_streak DESC, score DESC, id LIMIT ?`, limit)
+}
+
+func (s *sqliteStore) queryScores(query string, limit int) ([]ScoreRecord, error) {
+	rows, err := s.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	scores := []ScoreRecord{}
+	for rows.Next() {
+		var r ScoreRecord
+		var at int64
+		if err := rows.Scan(&r.Name, &r.Identity, &r.Score, &r.Kills, &r.BestStreak, &at); err != nil {
+			return nil, err
+		}
+		r.At = time.Unix(at, 0)
+		scores = append(scores, r)
+	}
+	return scores, rows.Err()
+}
+
+func (s *sqliteStore) TopRatings(limit int) ([]RatingRecord, error) {
+	rows, err := s.db.Query(`SELECT p.id, p.rating, p.games, coalesce(nullif(p.name, ''),
+			(SELECT sc.name FROM scores sc WHERE sc.identity = p.id ORDER BY sc.id DESC LIMIT 1), '')
+		FROM profiles p WHERE p.rating > 0 ORDER BY p.rating DESC, p.id LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ratings := []RatingRecord{}
+	for rows.Next() {
+		var r RatingRecord
+		if err := rows.Scan(&r.Identity, &r.Rating, &r.Games, &r.Name); err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, r)
+	}
+	return ratings, rows.Err()
+}
+
+func (s *sqliteStore) LoadBans() ([]Ban, error) {
+	rows, err := s.db.Query("SELECT prefix, reason FROM bans ORDER BY prefix")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var bans []Ban
+	for rows.Next() {
+		var b Ban
+		if err := rows.Scan(&b.Prefix, &b.Reason); err != nil {
+			return nil, err
+		}
+		bans = append(bans, b)
+	}
+	return bans, rows.Err()
+}
+
+func (s *sqliteStore) SaveBan(b Ban) error {
+	_, err := s.db.Exec("INSERT INTO bans (prefix, reason) VALUES (?, ?) ON CONFLICT (prefix) DO UPDATE SET reason = excluded.reason",
+		b.Prefix, b.Reason)
+	return err
+}
+
+func (s *sqliteStore) DeleteBan(prefix string) error {
+	_, err := s.db.Exec("DELETE FROM bans WHERE prefix = ?", prefix)
+	return err
+}
+
+func (s *sqliteStore) AddMatch(m MatchRecord) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	winner := ""
+	if len(m.Winners) > 0 {
+		winner = m.Winners[0]
+	}
+	res, err := tx.Exec("INSERT INTO matches (round, started_at, ended_at, winner) VALUES (?, ?, ?, ?)",
+		m.Round, m.StartedAt.Unix(), m.EndedAt.Unix(), winner)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for i, p := range m.Participants {
+		if _, err := tx.Exec(`INSERT INTO match_players (match_id, place, name, identity, is_ai, score, kills, assists, best_streak)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, id, i+1, p.Name, p.Identity, p.IsAI, p.Score, p.Kills, p.Assists, p.BestStreak); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) Matches(before int64, limit int) ([]MatchRecord, error) {
+	if before <= 0 {
+		before = math.MaxInt64
+	}
+	rows, err := s.db.Query("SELECT id, round, started_at, ended_at FROM matches WHERE id < ? ORDER BY id DESC LIMIT ?",
+		before, limit)
+	if err != nil {
+		return nil, err
+	}
+	matches := []MatchRecord{}
+	for rows.Next() {
+		var m MatchRecord
+		var started, ended int64
+		if err := rows.Scan(&m.ID, &m.Round, &started, &ended); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		m.StartedAt, m.EndedAt = time.Unix(started, 0), time.Unix(ended, 0)
+		matches = append(matches, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range matches {
+		if err := s.loadParticipants(&matches[i]); err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+func (s *sqliteStore) loadParticipants(m *MatchRecord) error {
+	rows, err := s.db.Query(`SELECT name, identity, is_ai, score, kills, assists, best_streak FROM match_players
+		WHERE match_id = ? ORDER BY place`, m.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	m.Participants = []MatchParticipant{}
+	for rows.Next() {
+		var p MatchParticipant
+		if err := rows.Scan(&p.Name, &p.Identity, &p.IsAI, &p.Score, &p.Kills, &p.Assists, &p.BestStreak); err != nil {
+			return err
+		}
+		if len(m.Winners) < PodiumSize {
+			m.Winners = append(m.Winners, p.Name)
+		}
+		m.Participants = append(m.Participants, p)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,89 @@
+package main
+
+// ---------------------------------------------------------------------------
+// Kill streaks and multi-kills
+//
+// A streak is a run of kills by one snake with no more than streakTimeout
+// of game time between them; it ends with the snake's death or the timeout.
+// A multi-kill is two or more kills within multiKillWindow of each other.
+// With StreakBonus > 0 every credited kill grows the killer by
+//
+//	StreakBonus × streak multiplier × multi-kill count
+//
+// where the streak multiplier starts at 1 and rises by 0.5 per kill of the
+// streak up to maxStreakMult, so the fifth kill of a double kill late in a
+// streak is worth far more than a lone first kill. The bonus is on top of
+// the victim's dropped food.
+//
+// Multi-kills and streaks of streakAnnounceAt kills and every
+// streakAnnounceEvery after that are announced to everyone as "streak"
+// events, which the bundled client shows in its kill feed. The best streak
+// of a life goes to the death report, the leaderboard (see storage.go) and
+// the match history (see matches.go).
+// ---------------------------------------------------------------------------
+
+const (
+	DefaultStreakBonus = 10
+
+	streakTimeout       = 20 * TickRate // game ticks between kills that keep a streak going
+	multiKillWindow     = 3 * TickRate  // game ticks between kills of a multi-kill
+	maxStreakMult       = 4.0
+	maxMultiKill        = 5 // multi-kill count the bonus stops growing at
+	streakAnnounceAt    = 3
+	streakAnnounceEvery = 5
+)
+
+type streakEvent struct {
+	Type   string `json:"t"`    // "streak"
+	Kind   string `json:"kind"` // "multi" or "streak"
+	ID     int    `json:"id"`
+	Entity uint32 `json:"entity"` // entity ID, for protocol v6 clients
+	Name   string `json:"name"`
+	Count  int    `json:"count"` // kills of the multi-kill or the streak
+	Bonus  int    `json:"bonus,omitempty"`
+}
+
+// streakState is the kill streak of one snake life.
+type streakState struct {
+	streak   int // kills of the current streak
+	best     int // longest streak of this life
+	multi    int // kills of the current multi-kill
+	lastKill int // game clock of the last kill
+}
+
+// creditStreak counts a kill by killer towards its streak and pays the
+// bonus. Called at every credited kill.
+func (g *Game) creditStreak(killer *Snake) {
+	st := &killer.streak
+	since := g.clock - st.lastKill
+	if since > streakTimeout {
+		st.streak = 0
+	}
+	if since > multiKillWindow {
+		st.multi = 0
+	}
+	st.streak++
+	st.multi++
+	st.lastKill = g.clock
+	st.best = max(st.best, st.streak)
+	g.bestStreak = max(g.bestStreak, st.streak)
+
+	bonus := 0
+	if g.cfg.StreakBonus > 0 && killer.Alive {
+		mult := min(1+0.5*float64(st.streak-1), maxStreakMult)
+		bonus = int(float64(g.cfg.StreakBonus) * mult * float64(min(st.multi, maxMultiKill)))
+		g.growSnake(killer, bonus)
+	}
+
+	ev := streakEvent{Type: "streak", ID: killer.PlayerID, Entity: killer.id, Name: killer.Name, Bonus: bonus}
+	switch {
+	case st.multi >= 2:
+		ev.Kind, ev.Count = "multi", st.multi
+	case st.streak == streakAnnounceAt || st.streak > streakAnnounceAt && st.streak%streakAnnounceEvery == 0:
+		ev.Kind, ev.Count = "streak", st.streak
+	default:
+		return
+	}
+	g.log.Info("kill streak", append(snakeAttrs(killer), "kind", ev.Kind, "count", ev.Count, "bonus", bonus)...)
+	g.announce(ev)
+}
@@ -0,0 +1,46 @@
+package main
+
+// ---------------------------------------------------------------------------
+// Time scale
+//
+// TimeScale slows the whole simulation down or speeds it up without changing
+// the tick rate: clients still get 60 ticks a second, but each tick covers
+// TimeScale ticks of game time. Movement, turning and the boost meter are
+// scaled directly. Tick-counted timers (spawn protection, AI respawn and
+// states, boost cooldown, trail lifetime) and the round, bounty and decay
+// schedules follow the game clock, which advances by whole ticks: at 0.5×
+// every other tick, at 2× two per tick. A body point is added per game
+// tick, so snakes keep their shape at any scale.
+// ---------------------------------------------------------------------------
+
+const (
+	MinTimeScale = 0.25
+	MaxTimeScale = 2.0
+)
+
+// advanceClock moves the game clock by this tick's share of game time.
+// Called once per tick, before anything reads clockSteps.
+func (g *Game) advanceClock() {
+	g.clockAcc += g.cfg.TimeScale
+	g.clockSteps = int(g.clockAcc)
+	g.clockAcc -= float64(g.clockSteps)
+	g.clock += g.clockSteps
+}
+
+// every reports whether the game clock passed a multiple of n this tick,
+// the time-scaled form of g.frame%n == 0.
+func (g *Game) every(n int) bool {
+	return g.clockSteps > 0 && g.clock/n != (g.clock-g.clockSteps)/n
+}
+
+// countDown lowers a tick-counted timer by the game ticks of this tick,
+// stopping at zero.
+func (g *Game) countDown(t *int) {
+	*t = max(*t-g.clockSteps, 0)
+}
+
+// headSpeed returns the distance s's head moves per tick, as sent to
+// clients for extrapolation.
+func (g *Game) headSpeed(s *Snake) float64 {
+	return s.Speed * g.cfg.TimeScale
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Tournament mode: timed rounds (countdown → playing → results → reset)
+// ---------------------------------------------------------------------------
+
+type RoundPhase uint8
+
+const (
+	PhaseCountdown RoundPhase = iota
+	PhasePlaying
+	PhaseResults
+)
+
+func (p RoundPhase) String() string {
+	switch p {
+	case PhaseCountdown:
+		return "countdown"
+	case PhasePlaying:
+		return "playing"
+	case PhaseResults:
+		return "results"
+	}
+	return "unknown"
+}
+
+const PodiumSize = 3
+
+type roundState struct {
+	phase    RoundPhase
+	round    int // 0 = not started yet
+	phaseEnd int // game clock tick at which the current phase ends
+
+	startedAt time.Time // when the current round began playing
+}
+
+// RoundResults is sent to clients as a "results" text frame and posted to
+// the webhook at the end of each round.
+type RoundResults struct {
+	Type      string             `json:"t"`
+	Round     int                `json:"round"`
+	EndedAt   time.Time          `json:"endedAt"`
+	Podium    []LeaderboardEntry `json:"podium"`
+	Standings []LeaderboardEntry `json:"standings"`
+}
+
+var webhookClient = &http.Client{Timeout: 5 * time.Second}
+
+func (g *Game) roundsEnabled() bool {
+	return g.cfg.RoundDuration > 0
+}
+
+// roundFrozen reports whether the simulation is paused between rounds.
+func (g *Game) roundFrozen() bool {
+	return g.roundsEnabled() && g.round.phase != PhasePlaying
+}
+
+// roundRemaining returns the seconds left in the current phase.
+func (g *Game) roundRemaining() int {
+	left := g.round.phaseEnd - g.clock
+	if left < 0 {
+		return 0
+	}
+	return (left + TickRate - 1) / TickRate
+}
+
+// updateRound advances the round state machine. Called once per tick.
+func (g *Game) updateRound() {
+	if !g.roundsEnabled() {
+		g.round = roundState{}
+		return
+	}
+	if g.round.round == 0 {
+		g.startCountdown(1)
+		return
+	}
+	if g.clock < g.round.phaseEnd {
+		return
+	}
+	switch g.round.phase {
+	case PhaseCountdown:
+		g.round.phase = PhasePlaying
+		g.round.phaseEnd = g.clock + g.cfg.RoundDuration*TickRate
+		g.round.startedAt = time.Now()
+		g.log.Info("round started", "round", g.round.round, "durationSec", g.cfg.RoundDuration)
+		g.announceRoundStart()
+		g.startReplay()
+	case PhasePlaying:
+		g.endRound()
+	case PhaseResults:
+		g.rotateAfterRound()
+		g.resetWorld()
+		g.startCountdown(g.round.round + 1)
+	}
+}
+
+func (g *Game) startCountdown(round int) {
+	g.round = roundState{
+		phase:    PhaseCountdown,
+		round:    round,
+		phaseEnd: g.clock + g.cfg.RoundCountdown*TickRate,
+	}
+	g.drawRoundMutators()
+}
+
+// endRound freezes the scores, sends the results to every client and the
+// webhook, and enters the results phase.
+func (g *Game) endRound() {
+	standings := make([]LeaderboardEntry, 0, len(g.snakes))
+	for _, s := range g.snakes {
+		standings = append(standings, LeaderboardEntry{
+			Name: s.Name, Score: s.Score, IsAI: s.IsAI, IsAlive: s.Alive,
+		})
+	}
+	sort.SliceStable(standings, func(i, j int) bool { return standings[i].Score > standings[j].Score })
+	podium := standings
+	if len(podium) > PodiumSize {
+		podium = podium[:PodiumSize]
+	}
+
+	res := RoundResults{
+		Type:      "results",
+		Round:     g.round.round,
+		EndedAt:   time.Now(),
+		Podium:    podium,
+		Standings: standings,
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		g.log.Error("failed to encode round results", "err", err)
+	} else {
+		g.broadcastText(data)
+		if g.cfg.WebhookURL != "" {
+			go postWebhook(g.cfg.WebhookURL, data)
+		}
+	}
+	g.recordMatch(res)
+	g.finishReplay(res)
+	g.logRound(res)
+	g.announceRoundEnd(res)
+
+	attrs := []any{"round", res.Round, "snakes", len(standings)}
+	if len(podium) > 0 {
+		attrs = append(attrs, "winner", podium[0].Name, "score", podium[0].Score)
+	}
+	g.log.Info("round ended", attrs...)
+
+	g.round.phase = PhaseResults
+	g.round.phaseEnd = g.clock + g.cfg.RoundResultsTime*TickRate
+}
+
+// resetWorld starts a fresh round: new food, no trails, every snake
+// respawned at base length.
+func (g *Game) resetWorld() {
+	g.trails = nil
+	g.foods = g.foods[:0]
+	g.food.Reset(g)
+	for i := 0; i < g.cfg.FoodCount; i++ {
+		g.addFood(g.newFood())
+	}
+	for i, s := range g.snakes {
+		if s.IsAI {
+			g.respawnAI(s)
+			continue
+		}
+		p, ok := g.players[s.PlayerID]
+		if !ok {
+			continue
+		}
+		if s.Alive {
+			g.recordProfile(p)
+		}
+		pos := g.spawnPos(p)
+		p.snake = g.createSnake(p.name, pos.X, pos.Y, s.ColorIdx, false, p.id)
+		g.dressSnake(p)
+		g.snakes[i] = p.snake
+	}
+}
+
+func postWebhook(url string, body []byte) {
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("round results webhook failed", "url", url, "err", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Warn("round results webhook rejected", "url", url, "status", resp.StatusCode)
+	}
+}
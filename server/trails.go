@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+
+	"snake-server/sim"
+)
+
+// ---------------------------------------------------------------------------
+// Laser tail: boosting snakes leave a short-lived hazardous trail
+// ---------------------------------------------------------------------------
+
+const (
+	TrailRadius   = 8.0
+	TrailInterval = 2 // game ticks between trail points while boosting
+)
+
+type Trail struct {
+	X, Y     float64
+	Owner    uint32 // owner's entity ID: an AI's next life reuses its *Snake
+	ColorIdx int    // owner's color
+	TTL      int    // game ticks until the trail point disappears
+}
+
+// emitTrail drops a trail point at the tail of a boosting snake.
+func (g *Game) emitTrail(s *Snake) {
+	if !g.every(TrailInterval) {
+		return
+	}
+	tail := s.Segments[len(s.Segments)-1]
+	g.trails = append(g.trails, &Trail{X: tail.X, Y: tail.Y, Owner: s.id, ColorIdx: s.ColorIdx,
+		TTL: g.cfg.TrailLifetime})
+}
+
+// trailOwner returns the snake that dropped t, or nil if that life has left
+// the world.
+func (g *Game) trailOwner(t *Trail) *Snake {
+	for _, s := range g.snakes {
+		if s.id == t.Owner {
+			return s
+		}
+	}
+	return nil
+}
+
+// updateTrails ages all trail points and removes expired ones.
+func (g *Game) updateTrails() {
+	n := 0
+	for _, t := range g.trails {
+		g.countDown(&t.TTL)
+		if t.TTL > 0 {
+			g.trails[n] = t
+			n++
+		}
+	}
+	for i := n; i < len(g.trails); i++ {
+		g.trails[i] = nil
+	}
+	g.trails = g.trails[:n]
+}
+
+// checkTrailCollisions kills any snake whose head touches another snake's
+// trail. The trail owner is credited with the kill if it is still in the
+// world.
+func (g *Game) checkTrailCollisions() {
+	if len(g.trails) == 0 {
+		return
+	}
+	for _, s := range g.snakes {
+		if !s.Alive || s.InvTimer > 0 {
+			continue
+		}
+		threshold := g.headRadius(s) + TrailRadius - 2
+		thresholdSq := threshold * threshold
+
+		for _, t := range g.trails {
+			if t.Owner == s.id {
+				continue
+			}
+			if g.touchesPoint(s, Vec2{X: t.X, Y: t.Y}, thresholdSq) {
+				owner := g.trailOwner(t)
+				g.killSnake(s, owner, "trail")
+				if owner != nil {
+					g.rewardKill(s, owner)
+					g.claimBounty(s, owner)
+					g.hookKill(s, owner)
+				}
+				g.reportDeath(s)
+				break
+			}
+		}
+	}
+}
+
+// avoidTrails steers an AI away from nearby foreign trail points. Returns
+// true if an evasive turn was taken.
+func (g *Game) avoidTrails(s *Snake, head Vec2) bool {
+	ad := g.headRadius(s) + TrailRadius + 50
+	adSq := ad * ad
+	for _, t := range g.trails {
+		if t.Owner == s.id {
+			continue
+		}
+		if sim.DistSq(head.X, head.Y, t.X, t.Y) < adSq {
+			s.TargetAngle = math.Atan2(head.Y-t.Y, head.X-t.X)
+			s.IsBoosting = false
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Tick budget watchdog
+//
+// With TickBudget set, the game loop averages its tick time over windows of
+// two seconds. A window over budget raises the degradation level by one; each
+// level sheds more load on top of the previous ones:
+//
+//   1 ai         half of the configured AI snakes leave the world
+//   2 broadcast  everyone gets state every other net tick at most
+//   3 food       food sections are sent half as often
+//
+// After five windows in a row under 60% of the budget the level drops by
+// one again. Level changes are logged and passed to OnDegrade hooks. Admins
+// can pin a level through /admin/degrade, which stops the automatic
+// changes until the pin is removed.
+// ---------------------------------------------------------------------------
+
+const (
+	degradeNone = iota
+	degradeAI
+	degradeBroadcast
+	degradeFood
+	MaxDegrade = degradeFood
+
+	watchdogWindow  = 2 * TickRate // ticks averaged per decision
+	watchdogCalm    = 5            // calm windows before the level drops
+	watchdogRecover = 0.6          // fraction of the budget a calm window stays under
+)
+
+var degradeNames = [...]string{"none", "ai", "broadcast", "food"}
+
+type watchdog struct {
+	level  int
+	manual bool // level pinned through /admin/degrade
+	aiShed int  // AI snakes removed at level ai
+
+	sum     time.Duration // tick time in the current window
+	ticks   int
+	calm    int
+	lastAvg time.Duration // average of the last full window
+}
+
+// DegradeStatus is the watchdog state, as returned by /admin/degrade and
+// included in the stats.
+type DegradeStatus struct {
+	Level     int     `json:"level"`
+	Name      string  `json:"name"`
+	Manual    bool    `json:"manual"`
+	BudgetMs  float64 `json:"budgetMs"`  // 0 = watchdog off
+	AvgTickMs float64 `json:"avgTickMs"` // last full window
+}
+
+type degradeReq struct {
+	level int  // -1 = back to automatic
+	set   bool // false = read only
+	reply chan DegradeStatus
+}
+
+func (g *Game) degradeStatus() DegradeStatus {
+	return DegradeStatus{
+		Level: g.wd.level, Name: degradeNames[g.wd.level], Manual: g.wd.manual,
+		BudgetMs:  g.cfg.TickBudget,
+		AvgTickMs: float64(g.wd.lastAvg.Microseconds()) / 1000,
+	}
+}
+
+// updateWatchdog adds a finished tick to the window and adjusts the level
+// at the end of each window. Called once per tick.
+func (g *Game) updateWatchdog(elapsed time.Duration) {
+	w := &g.wd
+	if w.manual {
+		return
+	}
+	if g.cfg.TickBudget <= 0 {
+		w.sum, w.ticks, w.calm = 0, 0, 0
+		g.setDegrade(degradeNone, "watchdog off")
+		return
+	}
+	w.sum += elapsed
+	w.ticks++
+	if w.ticks < watchdogWindow {
+		return
+	}
+	w.lastAvg = w.sum / time.Duration(w.ticks)
+	w.sum, w.ticks = 0, 0
+	budget := time.Duration(g.cfg.TickBudget * float64(time.Millisecond))
+	switch {
+	case w.lastAvg > budget:
+		w.calm = 0
+		g.setDegrade(min(w.level+1, MaxDegrade), "ticks over budget")
+	case w.lastAvg < time.Duration(float64(budget)*watchdogRecover):
+		w.calm++
+		if w.calm >= watchdogCalm && w.level > degradeNone {
+			w.calm = 0
+			g.setDegrade(w.level-1, "ticks under budget")
+		}
+	default:
+		w.calm = 0
+	}
+}
+
+// setDegrade switches to level, shedding or restoring the AI snakes.
+func (g *Game) setDegrade(level int, reason string) {
+	w := &g.wd
+	prev := w.level
+	if level == prev {
+		return
+	}
+	w.level = level
+	switch {
+	case level >= degradeAI && prev < degradeAI:
+		w.aiShed = g.removeAI(g.cfg.AICount / 2)
+	case level < degradeAI && prev >= degradeAI:
+		g.addAI(w.aiShed)
+		w.aiShed = 0
+	}
+	attrs := []any{"level", degradeNames[level], "from", degradeNames[prev], "reason", reason,
+		"avgTickMs", g.degradeStatus().AvgTickMs, "budgetMs", g.cfg.TickBudget}
+	if level > prev {
+		g.log.Warn("load shedding raised", attrs...)
+	} else {
+		g.log.Info("load shedding lowered", attrs...)
+	}
+	g.hookDegrade(level)
+}
+
+// degradeSendEvery returns the smallest net-tick divisor for state frames.
+func (g *Game) degradeSendEvery() int {
+	if g.wd.level >= degradeBroadcast {
+		return 2
+	}
+	return 1
+}
+
+// degradeFoodFactor returns the factor by which food sections are thinned.
+func (g *Game) degradeFoodFactor() int {
+	if g.wd.level >= degradeFood {
+		return 2
+	}
+	return 1
+}
+
+func (g *Game) handleDegrade(r degradeReq) {
+	if r.set {
+		if r.level < 0 {
+			g.wd.manual = false
+			g.wd.sum, g.wd.ticks, g.wd.calm = 0, 0, 0
+			g.log.Info("load shedding back to automatic", "level", degradeNames[g.wd.level])
+		} else {
+			g.wd.manual = true
+			g.setDegrade(r.level, "set by admin")
+		}
+	}
+	r.reply <- g.degradeStatus()
+}
+
+// Degrade pins the degradation level, or returns to automatic changes with
+// level -1 (thread-safe).
+func (g *Game) Degrade(level int) DegradeStatus {
+	reply := make(chan DegradeStatus, 1)
+	st, _ := ask(g, g.degradeReqCh, degradeReq{level: level, set: true, reply: reply}, reply)
+	return st
+}
+
+// GetDegrade returns the watchdog state (thread-safe).
+func (g *Game) GetDegrade() DegradeStatus {
+	reply := make(chan DegradeStatus, 1)
+	st, _ := ask(g, g.degradeReqCh, degradeReq{reply: reply}, reply)
+	return st
+}
+
+// HandleDegrade serves /admin/degrade: GET shows the state, POST
+// {"level":N} pins a level and DELETE returns to automatic changes.
+func HandleDegrade(game *Game, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(game.GetDegrade())
+
+	case http.MethodPost:
+		var req struct {
+			Level *int `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Level == nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if *req.Level < 0 || *req.Level > MaxDegrade {
+			http.Error(w, fmt.Sprintf("level must be 0 to %d", MaxDegrade), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(game.Degrade(*req.Level))
+
+	case http.MethodDelete:
+		json.NewEncoder(w).Encode(game.Degrade(-1))
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,236 @@
+package wire
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ---------------------------------------------------------------------------
+// v1 state frames
+//
+// Header: type(1)=1, flags(1), snakeCount(uint16)
+//   flags: bit0=hasFood, bit1=hasSummary, bit2=hasTrails, bit3=hasRound,
+//          bit4=hasHeatmap, bit5=wideScores
+// Per snake:
+//   id(int16), flags(uint8: bit0=alive, bit1=boosting, bit2=player,
+//   bit3=hasMeta, bit4=golden),
+//   [if hasMeta: nameLen(uint8), name(UTF-8), colorIdx(uint8)],
+//   score(uint16), angle*10000(int16), boost(uint8), targetLen(uint16),
+//   invTimer(uint8), segCount(uint16), segCount × x(uint16), y(uint16)
+//   (score and targetLen are uint32 with wideScores)
+// If hasFood: count(uint16), count × x(uint16), y(uint16), colorIdx(uint8),
+//   radius*10(uint8), value*10(uint8)
+// If hasTrails: count(uint16), count × x(uint16), y(uint16),
+//   colorIdx(uint8), life*255(uint8)
+// If hasSummary: count(uint16), count × id(int16), headX(uint16),
+//   headY(uint16), score(uint16, uint32 with wideScores), colorIdx(uint8),
+//   nameLen(uint8), name
+// If hasRound: phase(uint8), round(uint16), remaining(uint16)
+// If hasHeatmap: size(uint8), size² cells(uint8)
+//
+// All multi-byte fields are big-endian. v2 and later keep per-connection
+// state (name table, known snakes, food IDs): V2Decoder reads v2 frames
+// (see state_v2.go). Later versions aren't decoded here, and protobuf
+// frames are read with package statepb.
+// ---------------------------------------------------------------------------
+
+// Snake flag bits.
+const (
+	SnakeAlive    = 1
+	SnakeBoosting = 2
+	SnakePlayer   = 4
+	SnakeHasMeta  = 8
+	SnakeGolden   = 16
+)
+
+// State is a decoded v1 state frame. Food, Trails and Summary are nil when
+// the frame has no such section; Round and Heatmap likewise.
+type State struct {
+	Snakes  []Snake
+	Food    []Food
+	Trails  []Trail
+	Summary []SummaryEntry
+	Round   *Round
+	Heatmap *Heatmap
+
+	WideScores bool // scores and target lengths were sent as uint32
+}
+
+type Point struct{ X, Y int }
+
+type Snake struct {
+	ID        int
+	Flags     byte
+	Name      string // only with SnakeHasMeta
+	ColorIdx  int    // only with SnakeHasMeta
+	Score     int
+	Angle     float64 // radians
+	Boost     int
+	TargetLen int
+	InvTimer  int
+	Segments  []Point // every segment-stride-th body point, head first
+	Speed     float64 // v2 only: units per tick
+	Turn      float64 // v2 only: radians per tick
+}
+
+func (s Snake) Alive() bool   { return s.Flags&SnakeAlive != 0 }
+func (s Snake) HasMeta() bool { return s.Flags&SnakeHasMeta != 0 }
+
+type Food struct {
+	ID       uint32 // v2 only
+	X, Y     int
+	ColorIdx int
+	Radius   float64
+	Value    float64
+}
+
+type Trail struct {
+	X, Y     int
+	ColorIdx int
+	Life     float64 // 1 when fresh, 0 when gone
+}
+
+type SummaryEntry struct {
+	ID       int
+	X, Y     int // head
+	Score    int
+	ColorIdx int
+	Name     string
+}
+
+type Round struct {
+	Phase     int // 0 countdown, 1 playing, 2 results
+	Round     int
+	Remaining int // seconds left in the phase
+}
+
+type Heatmap struct {
+	Size  int
+	Cells []byte // Size × Size, snake mass in the high nibble, food in the low
+}
+
+// DecodeState decodes a v1 state frame.
+func DecodeState(data []byte) (*State, error) {
+	r := reader{data: data}
+	if typ := r.u8(); r.err == nil && typ != TypeState {
+		return nil, fmt.Errorf("message type %d is not a v1 state frame", typ)
+	}
+	flags := r.u8()
+	score := r.u16int
+	if flags&32 != 0 {
+		score = r.u32int
+	}
+	st := &State{Snakes: make([]Snake, 0, r.count(13)), WideScores: flags&32 != 0}
+	for n := cap(st.Snakes); len(st.Snakes) < n && r.err == nil; {
+		s := Snake{ID: int(int16(r.u16())), Flags: r.u8()}
+		if s.HasMeta() {
+			s.Name = r.str()
+			s.ColorIdx = int(r.u8())
+		}
+		s.Score = score()
+		s.Angle = float64(int16(r.u16())) / 10000
+		s.Boost = int(r.u8())
+		s.TargetLen = score()
+		s.InvTimer = int(r.u8())
+		s.Segments = make([]Point, r.count(4))
+		for i := range s.Segments {
+			s.Segments[i] = Point{int(r.u16()), int(r.u16())}
+		}
+		st.Snakes = append(st.Snakes, s)
+	}
+	if flags&1 != 0 {
+		st.Food = make([]Food, r.count(7))
+		for i := range st.Food {
+			st.Food[i] = Food{X: int(r.u16()), Y: int(r.u16()), ColorIdx: int(r.u8()),
+				Radius: float64(r.u8()) / 10, Value: float64(r.u8()) / 10}
+		}
+	}
+	if flags&4 != 0 {
+		st.Trails = make([]Trail, r.count(6))
+		for i := range st.Trails {
+			st.Trails[i] = Trail{X: int(r.u16()), Y: int(r.u16()), ColorIdx: int(r.u8()),
+				Life: float64(r.u8()) / 255}
+		}
+	}
+	if flags&2 != 0 {
+		st.Summary = make([]SummaryEntry, r.count(10))
+		for i := range st.Summary {
+			st.Summary[i] = SummaryEntry{ID: int(int16(r.u16())), X: int(r.u16()), Y: int(r.u16()),
+				Score: score(), ColorIdx: int(r.u8()), Name: r.str()}
+		}
+	}
+	if flags&8 != 0 {
+		st.Round = &Round{Phase: int(r.u8()), Round: int(r.u16()), Remaining: int(r.u16())}
+	}
+	if flags&16 != 0 {
+		size := int(r.u8())
+		st.Heatmap = &Heatmap{Size: size, Cells: r.bytes(size * size)}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.off != len(data) {
+		return nil, fmt.Errorf("%d trailing bytes after the state frame", len(data)-r.off)
+	}
+	return st, nil
+}
+
+// reader reads big-endian fields and remembers the first overrun; reads
+// after it return zero.
+type reader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.data)-r.off {
+		r.err = fmt.Errorf("%w: need %d bytes at offset %d, have %d", errShort, n, r.off, len(r.data)-r.off)
+		return nil
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *reader) u8() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) u16int() int { return int(r.u16()) }
+
+func (r *reader) u32int() int {
+	if b := r.bytes(4); b != nil {
+		return int(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *reader) str() string {
+	return string(r.bytes(int(r.u8())))
+}
+
+// count reads a uint16 element count and checks that the rest of the
+// frame can hold that many elements of at least minSize bytes, so a bogus
+// count can't make the decoder allocate more than the frame is worth.
+func (r *reader) count(minSize int) int {
+	n := int(r.u16())
+	if r.err == nil && n*minSize > len(r.data)-r.off {
+		r.err = fmt.Errorf("%w: %d elements at offset %d don't fit in %d bytes", errShort, n, r.off, len(r.data)-r.off)
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,203 @@
+package wire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+// ---------------------------------------------------------------------------
+// v2 state frames
+//
+// Protocol v2 (type 5) sends the sections of v1 with varints, a name
+// string table and food synced by ID (see the server's protocol_v2.go for
+// the layout). Names and food carry over from frame to frame, so a
+// V2Decoder keeps the name table and food list of one connection. Only
+// protocol 2 itself is decoded: v3 and later add fields to the same frame
+// type.
+//
+// In a decoded v2 frame, Food is the whole list the client holds after the
+// frame, by ID, whether the frame carried a keyframe or a delta, and nil
+// if it carried neither. Radius and Value are 0 for pellets of the default
+// size. Summary heads are in 1/256 of the world size, and Heatmap is part
+// of the summary.
+// ---------------------------------------------------------------------------
+
+// V2Decoder decodes the v2 state frames of one connection. The zero value
+// is ready to use. A frame that fails to decode leaves it unchanged.
+type V2Decoder struct {
+	names []string
+	food  map[uint32]Food
+}
+
+// Decode decodes the next v2 state frame of the connection.
+func (d *V2Decoder) Decode(data []byte) (*State, error) {
+	r := reader{data: data}
+	if typ := r.u8(); r.err == nil && typ != TypeStateV2 {
+		return nil, fmt.Errorf("message type %d is not a v2 state frame", typ)
+	}
+	flags := r.u8()
+	names := d.names
+	if flags&32 != 0 {
+		names = nil
+	}
+	if flags&16 != 0 {
+		n := r.uvcount(1)
+		names = slices.Clip(names) // don't write into d.names before the frame decoded
+		for i := 0; i < n && r.err == nil; i++ {
+			names = append(names, string(r.bytes(r.uvcount(1))))
+		}
+	}
+	name := func() string {
+		i := r.uvarint()
+		if r.err == nil && i >= uint64(len(names)) {
+			r.err = fmt.Errorf("name index %d at offset %d, table has %d", i, r.off, len(names))
+		}
+		if r.err != nil {
+			return ""
+		}
+		return names[i]
+	}
+
+	st := &State{Snakes: make([]Snake, 0, r.uvcount(10))}
+	for n := cap(st.Snakes); len(st.Snakes) < n && r.err == nil; {
+		s := Snake{ID: int(r.varint()), Flags: r.u8()}
+		if s.HasMeta() {
+			s.Name = name()
+			s.ColorIdx = int(r.u8())
+		}
+		s.Score = int(r.uvarint())
+		s.Angle = float64(r.u8()) / 256 * 2 * math.Pi
+		s.Boost = int(r.u8())
+		s.TargetLen = int(r.uvarint())
+		s.InvTimer = int(r.u8())
+		s.Speed = float64(r.u8()) / 16
+		s.Turn = float64(int8(r.u8())) / 512
+		s.Segments = make([]Point, r.uvcount(2))
+		var p Point
+		for i := range s.Segments {
+			if i == 0 {
+				p = Point{int(r.u16()), int(r.u16())}
+			} else {
+				p.X += int(int8(r.u8()))
+				p.Y += int(int8(r.u8()))
+			}
+			s.Segments[i] = p
+		}
+		st.Snakes = append(st.Snakes, s)
+	}
+
+	food := d.food
+	switch {
+	case flags&1 != 0:
+		n := r.uvcount(6)
+		food = make(map[uint32]Food, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			id, f := r.foodV2()
+			food[id] = f
+		}
+	case flags&64 != 0:
+		food = maps.Clone(food)
+		for n := r.uvcount(1); n > 0 && r.err == nil; n-- {
+			delete(food, uint32(r.uvarint()))
+		}
+		if food == nil {
+			food = make(map[uint32]Food)
+		}
+		for n := r.uvcount(6); n > 0 && r.err == nil; n-- {
+			id, f := r.foodV2()
+			food[id] = f
+		}
+	}
+	if flags&(1|64) != 0 {
+		ids := make([]uint32, 0, len(food))
+		for id := range food {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		st.Food = make([]Food, len(ids))
+		for i, id := range ids {
+			st.Food[i] = food[id]
+		}
+	}
+
+	if flags&4 != 0 {
+		st.Trails = make([]Trail, r.uvcount(6))
+		for i := range st.Trails {
+			st.Trails[i] = Trail{X: int(r.u16()), Y: int(r.u16()), ColorIdx: int(r.u8()),
+				Life: float64(r.u8()) / 255}
+		}
+	}
+	if flags&2 != 0 {
+		st.Summary = make([]SummaryEntry, r.uvcount(6))
+		for i := range st.Summary {
+			st.Summary[i] = SummaryEntry{ID: int(r.varint()), X: int(r.u8()), Y: int(r.u8()),
+				Score: int(r.uvarint()), ColorIdx: int(r.u8()), Name: name()}
+		}
+		if size := int(r.u8()); size > 0 {
+			st.Heatmap = &Heatmap{Size: size, Cells: r.bytes(size * size)}
+		}
+	}
+	if flags&8 != 0 {
+		st.Round = &Round{Phase: int(r.u8()), Round: int(r.u16()), Remaining: int(r.u16())}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.off != len(data) {
+		return nil, fmt.Errorf("%d trailing bytes after the state frame", len(data)-r.off)
+	}
+	d.names, d.food = names, food
+	return st, nil
+}
+
+// foodV2 reads a v2 food entry.
+func (r *reader) foodV2() (uint32, Food) {
+	id := uint32(r.uvarint())
+	f := Food{ID: id, X: int(r.u16()), Y: int(r.u16())}
+	packed := r.u8()
+	f.ColorIdx = int(packed & 15)
+	if packed&128 != 0 {
+		f.Radius = float64(r.u8()) / 10
+		f.Value = float64(r.u8()) / 10
+	}
+	return id, f
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data[r.off:])
+	if n <= 0 {
+		r.err = fmt.Errorf("%w: bad varint at offset %d", errShort, r.off)
+		return 0
+	}
+	r.off += n
+	return v
+}
+
+func (r *reader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data[r.off:])
+	if n <= 0 {
+		r.err = fmt.Errorf("%w: bad varint at offset %d", errShort, r.off)
+		return 0
+	}
+	r.off += n
+	return v
+}
+
+// uvcount is count for a uvarint element count.
+func (r *reader) uvcount(minSize int) int {
+	n := r.uvarint()
+	if r.err == nil && n > uint64((len(r.data)-r.off)/minSize) {
+		r.err = fmt.Errorf("%w: %d elements at offset %d don't fit in %d bytes", errShort, n, r.off, len(r.data)-r.off)
+		return 0
+	}
+	return int(n)
+}
@@ -0,0 +1,139 @@
+// Package wire decodes the messages of the game's WebSocket protocol: the
+// JSON text messages and binary input and pong frames clients send, and
+// the v1 and v2 state frames the server sends back. The server's read pump
+// uses the client decoders, so every byte a client sends goes through this
+// package first. Bots and tools can use DecodeState and V2Decoder to read
+// state frames without a browser.
+//
+// The decoders never panic and never trust a length field: malformed
+// input gives an error. wire_fuzz_test.go fuzzes all of them.
+package wire
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Binary message types, the first byte of every binary frame.
+const (
+	TypeState    = 1 // server: v1 state frame (see state.go)
+	TypeInput    = 2 // client: steering input
+	TypePing     = 3 // server: ping with the player's RTT
+	TypePong     = 4 // client: echoed ping timestamp
+	TypeStateV2  = 5 // server: v2 to v13 state frame
+	TypeProtobuf = 6 // server: statepb.State frame
+	TypeSection  = 7 // server: v5+ section frame
+)
+
+// maxField is the largest number accepted in an integer field of a text
+// message, far above any valid color, skin or vote but safe to convert.
+const maxField = math.MaxInt32
+
+var errShort = errors.New("message too short")
+
+// ClientMessage is a JSON text message from a client. Fields a message
+// type doesn't use are left at their zero values, and Color, Skin and
+// Choice are -1 when left out.
+type ClientMessage struct {
+	Type       string // "t": join, director, respawn, customize, chat or vote
+	Name       string
+	HasName    bool // "name" was given; customize leaves it out to keep the name
+	Token      string
+	Identity   string
+	Proto      any    // "proto": a protocol version number or "protobuf"
+	WideScores bool   // "wideScores": v1 frames with uint32 scores
+	Locale     string // "locale": language hint for announcements, e.g. "de-AT"
+	Ticket     string // "ticket": size class ticket of a player moved here
+	Color      int
+	Skin       int
+	Text       string
+	Channel    string // "ch": "", "all" or "near"
+	Choice     int
+}
+
+// DecodeText decodes a client's JSON text message. Fields of the wrong
+// JSON type are treated as left out, as clients have always been allowed
+// to send extra or odd fields; color, skin and choice must be
+// non-negative integers if they are numbers at all.
+func DecodeText(data []byte) (ClientMessage, error) {
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return ClientMessage{}, err
+	}
+	msg := ClientMessage{Proto: m["proto"]}
+	msg.Type, _ = m["t"].(string)
+	msg.Name, msg.HasName = m["name"].(string)
+	msg.Token, _ = m["token"].(string)
+	msg.Identity, _ = m["identity"].(string)
+	msg.Text, _ = m["text"].(string)
+	msg.Channel, _ = m["ch"].(string)
+	msg.WideScores, _ = m["wideScores"].(bool)
+	msg.Locale, _ = m["locale"].(string)
+	msg.Ticket, _ = m["ticket"].(string)
+	var err error
+	if msg.Color, err = intField(m, "color"); err != nil {
+		return ClientMessage{}, err
+	}
+	if msg.Skin, err = intField(m, "skin"); err != nil {
+		return ClientMessage{}, err
+	}
+	if msg.Choice, err = intField(m, "choice"); err != nil {
+		return ClientMessage{}, err
+	}
+	return msg, nil
+}
+
+// intField returns the non-negative integer m[key], or -1 if it isn't a
+// number.
+func intField(m map[string]any, key string) (int, error) {
+	v, ok := m[key].(float64)
+	if !ok {
+		return -1, nil
+	}
+	if v < 0 || v > maxField || v != math.Trunc(v) {
+		return -1, fmt.Errorf("%s: %g is not a valid index", key, v)
+	}
+	return int(v), nil
+}
+
+// Input is a steering input: type(1) + angle_int16(2) + boost(1), with an
+// optional seq_uint16(2) for acknowledgements (see prediction.go).
+type Input struct {
+	Angle  float64 // radians
+	Boost  bool
+	Seq    uint16
+	HasSeq bool
+}
+
+// DecodeInput decodes a binary input message.
+func DecodeInput(data []byte) (Input, error) {
+	if len(data) != 4 && len(data) != 6 {
+		return Input{}, fmt.Errorf("input message has %d bytes, want 4 or 6", len(data))
+	}
+	if data[0] != TypeInput {
+		return Input{}, fmt.Errorf("message type %d is not an input", data[0])
+	}
+	in := Input{
+		Angle: float64(int16(binary.BigEndian.Uint16(data[1:3]))) / 10000.0,
+		Boost: data[3]&1 != 0,
+	}
+	if len(data) == 6 {
+		in.Seq, in.HasSeq = binary.BigEndian.Uint16(data[4:6]), true
+	}
+	return in, nil
+}
+
+// DecodePong decodes a pong, type(1) + serverTimeMs_uint32(4), and returns
+// the echoed timestamp.
+func DecodePong(data []byte) (uint32, error) {
+	if len(data) != 5 {
+		return 0, fmt.Errorf("pong message has %d bytes, want 5", len(data))
+	}
+	if data[0] != TypePong {
+		return 0, fmt.Errorf("message type %d is not a pong", data[0])
+	}
+	return binary.BigEndian.Uint32(data[1:5]), nil
+}
@@ -0,0 +1,132 @@
+package wire
+
+import (
+	"math"
+	"testing"
+)
+
+// The fuzz targets run their seeds with go test. The DecodeState seeds in
+// testdata/fuzz/FuzzDecodeState are v1 frames of a running server. To
+// fuzz:
+//
+//	go test ./wire -fuzz FuzzDecodeText
+//	go test ./wire -fuzz FuzzDecodeInput
+//	go test ./wire -fuzz FuzzDecodePong
+//	go test ./wire -fuzz '^FuzzDecodeState$'
+//	go test ./wire -fuzz FuzzDecodeStateV2
+
+func FuzzDecodeText(f *testing.F) {
+	for _, seed := range []string{
+		`{"t":"join","name":"Alice","color":3,"skin":0,"proto":13,"locale":"de-AT","identity":"x"}`,
+		`{"t":"join","name":"Bob","proto":"protobuf","wideScores":true,"ticket":"00ff"}`,
+		`{"t":"director","token":"secret"}`,
+		`{"t":"customize","color":7,"skin":2}`,
+		`{"t":"chat","text":"hi","ch":"near"}`,
+		`{"t":"vote","choice":1}`,
+		`{"t":"respawn"}`,
+		`{"t":"vote","choice":-1}`,
+		`{"color":1e300}`,
+		`[]`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := DecodeText(data)
+		if err != nil {
+			if msg != (ClientMessage{}) {
+				t.Fatalf("DecodeText returned %+v and an error", msg)
+			}
+			return
+		}
+		for _, v := range []int{msg.Color, msg.Skin, msg.Choice} {
+			if v < -1 || v > maxField {
+				t.Fatalf("DecodeText returned index %d", v)
+			}
+		}
+	})
+}
+
+func FuzzDecodeInput(f *testing.F) {
+	f.Add([]byte{TypeInput, 0x3d, 0x5c, 0})             // π/2
+	f.Add([]byte{TypeInput, 0x85, 0x44, 1})             // -π, boosting
+	f.Add([]byte{TypeInput, 0x00, 0x00, 1, 0x12, 0x34}) // with seq
+	f.Add([]byte{TypeInput, 0x00, 0x00})
+	f.Add([]byte{TypePong, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		in, err := DecodeInput(data)
+		if err != nil {
+			if in != (Input{}) {
+				t.Fatalf("DecodeInput returned %+v and an error", in)
+			}
+			return
+		}
+		if in.HasSeq != (len(data) == 6) {
+			t.Fatalf("HasSeq = %v for %d bytes", in.HasSeq, len(data))
+		}
+		if math.Abs(in.Angle) > 3.3 {
+			t.Fatalf("angle %g out of range", in.Angle)
+		}
+	})
+}
+
+func FuzzDecodePong(f *testing.F) {
+	f.Add([]byte{TypePong, 0x00, 0x01, 0xe2, 0x40})
+	f.Add([]byte{TypePong, 0xff, 0xff, 0xff, 0xff})
+	f.Add([]byte{TypePong})
+	f.Add([]byte{TypeInput, 0, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := DecodePong(data)
+		if ok := len(data) == 5 && data[0] == TypePong; ok != (err == nil) {
+			t.Fatalf("DecodePong(%x) error = %v", data, err)
+		}
+	})
+}
+
+func FuzzDecodeState(f *testing.F) {
+	f.Add([]byte{TypeState, 0, 0, 0})
+	f.Add([]byte{TypeState, 1, 0, 0, 0xff, 0xff})                                     // food count past the end
+	f.Add([]byte{TypeState, 8 | 16, 0, 0, 1, 0, 3, 0, 42, 2, 0x10, 0x01, 0x00, 0x21}) // round, 2×2 heatmap
+	f.Fuzz(func(t *testing.T, data []byte) {
+		st, err := DecodeState(data)
+		if err != nil {
+			if st != nil {
+				t.Fatal("DecodeState returned a state and an error")
+			}
+			return
+		}
+		if st.Heatmap != nil && len(st.Heatmap.Cells) != st.Heatmap.Size*st.Heatmap.Size {
+			t.Fatalf("heatmap of size %d has %d cells", st.Heatmap.Size, len(st.Heatmap.Cells))
+		}
+	})
+}
+
+func FuzzDecodeStateV2(f *testing.F) {
+	f.Add([]byte{TypeStateV2, 128, 0})
+	// A name, a snake using it and two body points
+	f.Add([]byte{TypeStateV2, 16 | 128, 1, 5, 'A', 'l', 'i', 'c', 'e',
+		1, 2, 1 | 8, 0, 3, 10, 64, 0, 20, 0, 48, 0, 2, 0x01, 0x00, 0x02, 0x00, 3, 0xfd})
+	f.Add([]byte{TypeStateV2, 1 | 128, 0, 1, 7, 0, 100, 0, 200, 2})                                          // food keyframe
+	f.Add([]byte{TypeStateV2, 64 | 128, 0, 1, 7, 1, 9, 0, 10, 0, 20, 0x83, 15, 30})                          // food delta, custom size
+	f.Add([]byte{TypeStateV2, 128, 1, 2, 1 | 8, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0})                               // name index past the table
+	f.Add([]byte{TypeStateV2, 2 | 16 | 128, 1, 1, 'B', 0, 1, 2, 10, 20, 5, 1, 0, 2, 0x10, 0x01, 0x00, 0x21}) // summary, 2×2 heatmap
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var d V2Decoder
+		st, err := d.Decode(data)
+		if err != nil {
+			if st != nil {
+				t.Fatal("Decode returned a state and an error")
+			}
+			if d.names != nil || d.food != nil {
+				t.Fatal("Decode changed the decoder on error")
+			}
+			return
+		}
+		if st.Heatmap != nil && len(st.Heatmap.Cells) != st.Heatmap.Size*st.Heatmap.Size {
+			t.Fatalf("heatmap of size %d has %d cells", st.Heatmap.Size, len(st.Heatmap.Cells))
+		}
+		// The same frame again, on top of the state it left
+		if _, err := d.Decode(data); err != nil {
+			t.Fatalf("frame decoded once but not twice: %v", err)
+		}
+	})
+}
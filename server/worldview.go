@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// World snapshot endpoint
+//
+// GET /world returns the whole world of a room (?room=<id>, the main room
+// by default) as JSON, so stream overlays and info screens can draw a live
+// map without speaking the WebSocket protocol:
+//
+//	{"tick":91234,"worldSize":10000,"arena":"square",
+//	 "snakes":[{"id":41,"name":"Viper","x":4210,"y":830,"score":212,"color":3,"player":true}],
+//	 "food":{"size":32,"cells":"0013f2..."}}
+//
+// Snakes are the living ones by score, highest first, with their heads
+// rounded to worldPrecision units. The food grid has worldGridSize cells a
+// side, row by row from the top left, one hex digit per cell: the food
+// mass on the square-root scale of the minimap heatmap (see heatmap.go),
+// from 0 for none to f for the densest cell.
+//
+// A room's game loop builds the snapshot at most once per
+// worldViewInterval; requests in between get the same bytes, so any number
+// of pollers costs the loop no more than one.
+// ---------------------------------------------------------------------------
+
+const (
+	worldViewInterval = 500 * time.Millisecond
+	worldGridSize     = 32
+	worldPrecision    = 10 // world units
+)
+
+type WorldView struct {
+	Tick      int          `json:"tick"`
+	WorldSize int          `json:"worldSize"`
+	Arena     string       `json:"arena"`
+	Snakes    []worldSnake `json:"snakes"`
+	Food      worldFood    `json:"food"`
+}
+
+type worldSnake struct {
+	ID     uint32 `json:"id"` // entity ID, as in protocol v6
+	Name   string `json:"name"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Score  int    `json:"score"`
+	Color  int    `json:"color"`
+	Player bool   `json:"player"`
+}
+
+type worldFood struct {
+	Size  int    `json:"size"`
+	Cells string `json:"cells"`
+}
+
+// worldViewCache holds the last /world response of a room. HTTP handlers
+// only.
+type worldViewCache struct {
+	mu   sync.Mutex
+	at   time.Time
+	body []byte
+}
+
+// buildWorldView snapshots the heads, scores and food of the world. Game
+// loop only.
+func (g *Game) buildWorldView() WorldView {
+	v := WorldView{Tick: g.frame, WorldSize: g.cfg.WorldSize, Arena: g.arena.Name(), Snakes: []worldSnake{}}
+	round := func(f float64) int { return int(math.Round(f/worldPrecision)) * worldPrecision }
+	for _, s := range g.snakes {
+		if !s.Alive {
+			continue
+		}
+		head := s.Segments[0]
+		v.Snakes = append(v.Snakes, worldSnake{
+			ID: s.id, Name: s.Name, X: round(head.X), Y: round(head.Y),
+			Score: s.Score, Color: s.ColorIdx, Player: !s.IsAI,
+		})
+	}
+	sort.SliceStable(v.Snakes, func(i, j int) bool { return v.Snakes[i].Score > v.Snakes[j].Score })
+
+	const hex = "0123456789abcdef"
+	cells := g.buildHeatmap(worldGridSize)
+	for i, c := range cells {
+		cells[i] = hex[c&15]
+	}
+	v.Food = worldFood{Size: worldGridSize, Cells: string(cells)}
+	return v
+}
+
+// WorldJSON returns the room's /world response, asking the game loop for a
+// new snapshot if the last one is worldViewInterval old, or nil if the
+// room has stopped (thread-safe).
+func (g *Game) WorldJSON() []byte {
+	c := &g.worldView
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.body == nil || time.Since(c.at) >= worldViewInterval {
+		reply := make(chan WorldView, 1)
+		v, ok := ask(g, g.worldReqCh, reply, reply)
+		if !ok {
+			return nil
+		}
+		c.body, _ = json.Marshal(v)
+		c.at = time.Now()
+	}
+	return c.body
+}
+
+func HandleWorld(rooms *Rooms, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	g, ok := rooms.Get(r.URL.Query().Get("room"))
+	var body []byte
+	if ok {
+		body = g.WorldJSON()
+	}
+	if body == nil {
+		http.Error(w, "room not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(body)
+}
@@ -0,0 +1,113 @@
+package main
+
+// ---------------------------------------------------------------------------
+// XP, levels and skins
+//
+// Every finished game (death, leaving, end of a round) earns the player's
+// identity XP: the final score plus XPPerKill per kill. The level follows
+// from the XP total and the curve in the config: reaching level 2 takes
+// XPLevelBase XP, and every further level XPLevelGrowth times as much as
+// the one before, up to MaxLevel. Only the total is stored, so a changed
+// curve applies to everyone right away.
+//
+// Levels unlock skins, which players pick with "skin" in a customize
+// message. Level and skin are part of the snake metadata (protocol v3 and
+// protobuf frames), so other players see them too.
+// ---------------------------------------------------------------------------
+
+const MaxLevelLimit = 255 // level is a uint8 on the wire
+
+type Skin struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Level int    `json:"level"` // level that unlocks it
+}
+
+var skins = []Skin{
+	{0, "Classic", 1},
+	{1, "Dotted", 5},
+	{2, "Gradient", 10},
+	{3, "Neon", 20},
+	{4, "Rainbow", 30},
+}
+
+// LevelInfo is where an XP total stands on the level curve.
+type LevelInfo struct {
+	XP      int `json:"xp"`
+	Level   int `json:"level"`
+	LevelXP int `json:"levelXp"` // XP earned within the current level
+	NextXP  int `json:"nextXp"`  // XP the current level takes, 0 at MaxLevel
+}
+
+type xpEvent struct {
+	Type   string `json:"t"` // "xp"
+	Gained int    `json:"gained"`
+	LevelInfo
+	Unlocked []Skin `json:"unlocked,omitempty"` // skins unlocked by this game
+}
+
+func (g *Game) levelFor(xp int) LevelInfo {
+	li := LevelInfo{XP: xp, Level: 1, LevelXP: xp}
+	need := float64(g.cfg.XPLevelBase)
+	for li.Level < g.cfg.MaxLevel && li.LevelXP >= int(need) {
+		li.LevelXP -= int(need)
+		li.Level++
+		need *= g.cfg.XPLevelGrowth
+	}
+	if li.Level < g.cfg.MaxLevel {
+		li.NextXP = int(need)
+	}
+	return li
+}
+
+// playerLevel is the level of p's identity (1 without a profile).
+func (g *Game) playerLevel(p *Player) int {
+	prof := g.profiles[p.identity]
+	if prof == nil {
+		return 1
+	}
+	return g.levelFor(prof.XP).Level
+}
+
+// xpFor is the XP a finished game of s earns.
+func (g *Game) xpFor(s *Snake) int {
+	return s.Score + s.kills*g.cfg.XPPerKill
+}
+
+// awardXP adds the XP for the game p's snake just finished to prof and
+// tells p. Called from recordProfile.
+func (g *Game) awardXP(p *Player, prof *Profile) {
+	gained := g.xpFor(p.snake)
+	if gained <= 0 {
+		return
+	}
+	before := g.levelFor(prof.XP).Level
+	prof.XP += gained
+	ev := xpEvent{Type: "xp", Gained: gained, LevelInfo: g.levelFor(prof.XP)}
+	for _, sk := range skins {
+		if sk.Level > before && sk.Level <= ev.Level {
+			ev.Unlocked = append(ev.Unlocked, sk)
+		}
+	}
+	if ev.Level > before {
+		g.log.Info("player leveled up", "playerID", p.id, "name", p.name, "level", ev.Level, "xp", prof.XP)
+	}
+	g.sendEvent(p, ev)
+}
+
+// dressSnake gives p's current snake the level and skin of p's identity.
+func (g *Game) dressSnake(p *Player) {
+	if p.snake == nil {
+		return
+	}
+	p.snake.level = g.playerLevel(p)
+	p.snake.skin = 0
+	if prof := g.profiles[p.identity]; prof != nil && g.skinUnlocked(p, prof.Skin) {
+		p.snake.skin = prof.Skin
+	}
+}
+
+// skinUnlocked reports whether p's level allows skin id.
+func (g *Game) skinUnlocked(p *Player, id int) bool {
+	return id >= 0 && id < len(skins) && g.playerLevel(p) >= skins[id].Level
+}
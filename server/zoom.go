@@ -0,0 +1,46 @@
+package main
+
+import "math"
+
+// ---------------------------------------------------------------------------
+// Camera zoom hints (protocol v9)
+//
+// A long snake fills the screen at a fixed zoom, so clients want to zoom out
+// as it grows, but each used to guess its own curve. The server now picks
+// the scale: 1 up to CameraZoomLen segments, then falling with the square
+// root of the length down to MinCameraScale. The scale follows the camera
+// target, so spectators get the zoom of the snake they watch. The food view
+// and the segment cull box grow by 1/scale, so a zoomed-out client actually
+// receives the wider area it shows. v9 frames send the scale after the input
+// acknowledgement, protobuf frames in camera_scale.
+// ---------------------------------------------------------------------------
+
+const (
+	// CameraZoomLen is the snake length at which clients start to zoom out.
+	CameraZoomLen = 100.0
+	// MinCameraScale is the widest zoom; at this scale the segment cull box
+	// grows to the snake view distance (ViewDist), past which nothing is sent.
+	MinCameraScale = SegmentViewDist / ViewDist
+)
+
+// cameraScale returns the recommended zoom for a snake of length n:
+// 1 is the default view, smaller values show more of the world.
+func cameraScale(n int) float64 {
+	if float64(n) <= CameraZoomLen {
+		return 1
+	}
+	return math.Max(MinCameraScale, math.Sqrt(CameraZoomLen/float64(n)))
+}
+
+// cameraScaleFor returns the recommended zoom for p's camera target.
+func (p *Player) cameraScaleFor() float64 {
+	if c := p.cameraTarget(); c != nil {
+		return cameraScale(len(c.Segments))
+	}
+	return 1
+}
+
+// appendCameraScale appends the v9 zoom hint: scale*100 (uint8).
+func appendCameraScale(b []byte, scale float64) []byte {
+	return append(b, byte(math.Round(scale*100)))
+}